@@ -1,9 +1,14 @@
 package api
 
 import (
+	"context"
+	"log"
+	"math"
+	"crypto/rand"
+	"encoding/hex"
 	"strings"
 	"time"
-	"sort"
+	"sync"
 	"encoding/json"
 	"net/http"
 	"sync/atomic"
@@ -14,22 +19,68 @@ import (
 	"github.com/potix/gobitflyer/api/public"
 	"github.com/potix/gobitflyer/api/private"
 	"github.com/potix/gobitflyer/api/realtime"
+	"github.com/potix/gobitflyer/orderbook"
 )
 
 const (
         BFCallableAPISpanSeconds int64 = 300
         BFCallableAPICount       int64 = 500
+        BFCallableOrderAPICount  int64 = 300
 )
 
 const (
-	apiEndpoint         string = "https://api.bitflyer.jp"
-	realtimeApiEndpoint string = "wss://ws.lightstream.bitflyer.com/json-rpc"
+	apiEndpoint string = "https://api.bitflyer.jp"
 )
 
 type APIClient struct {
 	endpoint                  string
 	httpClient                *client.HTTPClient
 	authenticator             Authenticator
+	readRateLimiter           *RateLimiter
+	orderRateLimiter          *RateLimiter
+	retryPolicy               *RetryPolicy
+	acceptedOrders            *acceptedOrders
+	options                   *clientOptions
+}
+
+func (c *APIClient) SetRetryPolicy(retryPolicy *RetryPolicy) {
+	c.retryPolicy = retryPolicy
+}
+
+func (c *APIClient) SetRateLimiters(readRateLimiter *RateLimiter, orderRateLimiter *RateLimiter) {
+	c.readRateLimiter = readRateLimiter
+	c.orderRateLimiter = orderRateLimiter
+}
+
+func (c *APIClient) RemainingReadAPICount() (int64) {
+	return c.readRateLimiter.Remaining()
+}
+
+func (c *APIClient) RemainingOrderAPICount() (int64) {
+	return c.orderRateLimiter.Remaining()
+}
+
+func (c *APIClient) doRequest(ctx context.Context, httpRequest *client.HTTPRequest, rateLimiter *RateLimiter, private bool) (*http.Response, []byte, error) {
+	// acquire before signing so that the timestamp is not stale after waiting
+	// every call counts for the read limit, an order call counts for the order limit as well
+	if rateLimiter != c.readRateLimiter {
+		err := rateLimiter.AcquireCtx(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	err := c.readRateLimiter.AcquireCtx(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if httpRequest.Headers == nil {
+		httpRequest.Headers = make(map[string]string)
+	}
+	c.options.setHeaders(httpRequest.Headers)
+	if private {
+		c.authenticator.SetAuthHeaders(httpRequest.Headers, time.Now(), httpRequest.Method, httpRequest.PathQuery, httpRequest.Body)
+	}
+	return c.httpClient.DoRequestCtx(ctx, httpRequest)
 }
 
 func (c *APIClient) containsStatus(candidates []int, statusCode int) (bool) {
@@ -42,18 +93,22 @@ func (c *APIClient) containsStatus(candidates []int, statusCode int) (bool) {
 }
 
 func (c *APIClient) PubGetMarkets() (*http.Response, public.GetMarketsResponse, error) {
+	return c.PubGetMarketsCtx(context.Background())
+}
+
+func (c *APIClient) PubGetMarketsCtx(ctx context.Context) (*http.Response, public.GetMarketsResponse, error) {
 	getMarketsRequest := public.NewGetMarketsRequest()
 	getMarketsResponse := make(public.GetMarketsResponse, 0)
 	httpRequest, err := getMarketsRequest.CreateHTTPRequest(c.endpoint)
 	if err != nil {
 		return nil, nil, errors.Wrapf(err, "can not create http request of get markets")
 	}
-	httpResponse, body, err := c.httpClient.DoRequest(httpRequest)
+	httpResponse, body, err := c.doRequestWithRetry(ctx, httpRequest, c.readRateLimiter, false)
 	if err != nil {
 		return nil, nil, errors.Wrapf(err, "can not request of get markets (request = %v)", httpRequest.ToString())
 	}
 	if !c.containsStatus([]int{200}, httpResponse.StatusCode) {
-		return nil, nil, errors.Errorf("unexpected status code of get markets (request = %v, status = %v, body = %v)", httpRequest.ToString(), httpResponse.Status, string(body))
+		return nil, nil, newAPIError("get markets", httpRequest, httpResponse, body)
 	}
 	err = json.Unmarshal(body, &getMarketsResponse)
 	if err != nil {
@@ -63,18 +118,22 @@ func (c *APIClient) PubGetMarkets() (*http.Response, public.GetMarketsResponse,
 }
 
 func (c *APIClient) PubGetBoard(productCode types.ProductCode) (*http.Response, *public.GetBoardResponse, error) {
+	return c.PubGetBoardCtx(context.Background(), productCode)
+}
+
+func (c *APIClient) PubGetBoardCtx(ctx context.Context, productCode types.ProductCode) (*http.Response, *public.GetBoardResponse, error) {
 	getBoardRequest := public.NewGetBoardRequest(productCode)
 	getBoardResponse := new(public.GetBoardResponse)
 	httpRequest, err := getBoardRequest.CreateHTTPRequest(c.endpoint)
 	if err != nil {
 		return nil, nil, errors.Wrapf(err, "can not create http request of get board")
 	}
-	httpResponse, body, err := c.httpClient.DoRequest(httpRequest)
+	httpResponse, body, err := c.doRequestWithRetry(ctx, httpRequest, c.readRateLimiter, false)
 	if err != nil {
 		return nil, nil, errors.Wrapf(err, "can not request of get board (request = %v)", httpRequest.ToString())
 	}
 	if !c.containsStatus([]int{200}, httpResponse.StatusCode) {
-		return nil, nil, errors.Errorf("unexpected status code of get board (request = %v, status = %v, body = %v)", httpRequest.ToString(), httpResponse.Status, string(body))
+		return nil, nil, newAPIError("get board", httpRequest, httpResponse, body)
 	}
 	err = json.Unmarshal(body, getBoardResponse)
 	if err != nil {
@@ -84,18 +143,22 @@ func (c *APIClient) PubGetBoard(productCode types.ProductCode) (*http.Response,
 }
 
 func (c *APIClient) PubGetTicker(productCode types.ProductCode) (*http.Response, *public.GetTickerResponse, error) {
+	return c.PubGetTickerCtx(context.Background(), productCode)
+}
+
+func (c *APIClient) PubGetTickerCtx(ctx context.Context, productCode types.ProductCode) (*http.Response, *public.GetTickerResponse, error) {
 	getTickerRequest := public.NewGetTickerRequest(productCode)
 	getTickerResponse := new(public.GetTickerResponse)
 	httpRequest, err := getTickerRequest.CreateHTTPRequest(c.endpoint)
 	if err != nil {
 		return nil, nil, errors.Wrapf(err, "can not create http request of get ticker")
 	}
-	httpResponse, body, err := c.httpClient.DoRequest(httpRequest)
+	httpResponse, body, err := c.doRequestWithRetry(ctx, httpRequest, c.readRateLimiter, false)
 	if err != nil {
 		return nil, nil, errors.Wrapf(err, "can not request of get ticker (request = %v)", httpRequest.ToString())
 	}
 	if !c.containsStatus([]int{200}, httpResponse.StatusCode) {
-		return nil, nil, errors.Errorf("unexpected status code of get ticker (request = %v, status = %v, body = %v)", httpRequest.ToString(), httpResponse.Status, string(body))
+		return nil, nil, newAPIError("get ticker", httpRequest, httpResponse, body)
 	}
 	err = json.Unmarshal(body, getTickerResponse)
 	if err != nil {
@@ -105,18 +168,22 @@ func (c *APIClient) PubGetTicker(productCode types.ProductCode) (*http.Response,
 }
 
 func (c *APIClient) PubGetExecutions(productCode types.ProductCode, count int64, before int64, after int64) (*http.Response, public.GetExecutionsResponse, error) {
+	return c.PubGetExecutionsCtx(context.Background(), productCode, count, before, after)
+}
+
+func (c *APIClient) PubGetExecutionsCtx(ctx context.Context, productCode types.ProductCode, count int64, before int64, after int64) (*http.Response, public.GetExecutionsResponse, error) {
 	getExecutionsRequest := public.NewGetExecutionsRequest(productCode, count, before, after)
 	getExecutionsResponse := make(public.GetExecutionsResponse, 0)
 	httpRequest, err := getExecutionsRequest.CreateHTTPRequest(c.endpoint)
 	if err != nil {
 		return nil, nil, errors.Wrapf(err, "can not create http request of get  executions")
 	}
-	httpResponse, body, err := c.httpClient.DoRequest(httpRequest)
+	httpResponse, body, err := c.doRequestWithRetry(ctx, httpRequest, c.readRateLimiter, false)
 	if err != nil {
 		return nil, nil, errors.Wrapf(err, "can not request of get executions (request = %v)", httpRequest.ToString())
 	}
 	if !c.containsStatus([]int{200}, httpResponse.StatusCode) {
-		return nil, nil, errors.Errorf("unexpected status code of get executions (request = %v, status = %v, body = %v)", httpRequest.ToString(), httpResponse.Status, string(body))
+		return nil, nil, newAPIError("get executions", httpRequest, httpResponse, body)
 	}
 	err = json.Unmarshal(body, &getExecutionsResponse)
 	if err != nil {
@@ -126,18 +193,22 @@ func (c *APIClient) PubGetExecutions(productCode types.ProductCode, count int64,
 }
 
 func (c *APIClient) PubGetBoardState(productCode types.ProductCode) (*http.Response, *public.GetBoardStateResponse, error) {
+	return c.PubGetBoardStateCtx(context.Background(), productCode)
+}
+
+func (c *APIClient) PubGetBoardStateCtx(ctx context.Context, productCode types.ProductCode) (*http.Response, *public.GetBoardStateResponse, error) {
 	getBoardStateRequest := public.NewGetBoardStateRequest(productCode)
 	getBoardStateResponse := new(public.GetBoardStateResponse)
 	httpRequest, err := getBoardStateRequest.CreateHTTPRequest(c.endpoint)
 	if err != nil {
 		return nil, nil, errors.Wrapf(err, "can not create http request of get board state")
 	}
-	httpResponse, body, err := c.httpClient.DoRequest(httpRequest)
+	httpResponse, body, err := c.doRequestWithRetry(ctx, httpRequest, c.readRateLimiter, false)
 	if err != nil {
 		return nil, nil, errors.Wrapf(err, "can not request of get board state (request = %v)", httpRequest.ToString())
 	}
 	if !c.containsStatus([]int{200}, httpResponse.StatusCode) {
-		return nil, nil, errors.Errorf("unexpected status code of get board state (request = %v, status = %v, body = %v)", httpRequest.ToString(), httpResponse.Status, string(body))
+		return nil, nil, newAPIError("get board state", httpRequest, httpResponse, body)
 	}
 	err = json.Unmarshal(body, getBoardStateResponse)
 	if err != nil {
@@ -147,18 +218,22 @@ func (c *APIClient) PubGetBoardState(productCode types.ProductCode) (*http.Respo
 }
 
 func (c *APIClient) PubGetHealth(productCode types.ProductCode) (*http.Response, *public.GetHealthResponse, error) {
+	return c.PubGetHealthCtx(context.Background(), productCode)
+}
+
+func (c *APIClient) PubGetHealthCtx(ctx context.Context, productCode types.ProductCode) (*http.Response, *public.GetHealthResponse, error) {
 	getHealthRequest := public.NewGetHealthRequest(productCode)
 	getHealthResponse := new(public.GetHealthResponse)
 	httpRequest, err := getHealthRequest.CreateHTTPRequest(c.endpoint)
 	if err != nil {
 		return nil, nil, errors.Wrapf(err, "can not create http request of get health")
 	}
-	httpResponse, body, err := c.httpClient.DoRequest(httpRequest)
+	httpResponse, body, err := c.doRequestWithRetry(ctx, httpRequest, c.readRateLimiter, false)
 	if err != nil {
 		return nil, nil, errors.Wrapf(err, "can not request of get health (request = %v)", httpRequest.ToString())
 	}
 	if !c.containsStatus([]int{200}, httpResponse.StatusCode) {
-		return nil, nil, errors.Errorf("unexpected status code of get health (request = %v, status = %v, body = %v)", httpRequest.ToString(), httpResponse.Status, string(body))
+		return nil, nil, newAPIError("get health", httpRequest, httpResponse, body)
 	}
 	err = json.Unmarshal(body, getHealthResponse)
 	if err != nil {
@@ -168,18 +243,22 @@ func (c *APIClient) PubGetHealth(productCode types.ProductCode) (*http.Response,
 }
 
 func (c *APIClient) PubGetChats(fromDate int64) (*http.Response, *public.GetChatsResponse, error) {
+	return c.PubGetChatsCtx(context.Background(), fromDate)
+}
+
+func (c *APIClient) PubGetChatsCtx(ctx context.Context, fromDate int64) (*http.Response, *public.GetChatsResponse, error) {
 	getChatsRequest := public.NewGetChatsRequest(fromDate)
 	getChatsResponse := new(public.GetChatsResponse)
 	httpRequest, err := getChatsRequest.CreateHTTPRequest(c.endpoint)
 	if err != nil {
 		return nil, nil, errors.Wrapf(err, "can not create http request of get chats")
 	}
-	httpResponse, body, err := c.httpClient.DoRequest(httpRequest)
+	httpResponse, body, err := c.doRequestWithRetry(ctx, httpRequest, c.readRateLimiter, false)
 	if err != nil {
 		return nil, nil, errors.Wrapf(err, "can not request of get chats (request = %v)", httpRequest.ToString())
 	}
 	if !c.containsStatus([]int{200}, httpResponse.StatusCode) {
-		return nil, nil, errors.Errorf("unexpected status code of get chats (request = %v, status = %v, body = %v)", httpRequest.ToString(), httpResponse.Status, string(body))
+		return nil, nil, newAPIError("get chats", httpRequest, httpResponse, body)
 	}
 	err = json.Unmarshal(body, getChatsResponse)
 	if err != nil {
@@ -189,19 +268,22 @@ func (c *APIClient) PubGetChats(fromDate int64) (*http.Response, *public.GetChat
 }
 
 func (c *APIClient) PriGetPermissions() (*http.Response, *private.GetPermissionsResponse, error) {
+	return c.PriGetPermissionsCtx(context.Background())
+}
+
+func (c *APIClient) PriGetPermissionsCtx(ctx context.Context) (*http.Response, *private.GetPermissionsResponse, error) {
 	getPermissionsRequest := private.NewGetPermissionsRequest()
 	getPermissionsResponse := new(private.GetPermissionsResponse)
 	httpRequest, err := getPermissionsRequest.CreateHTTPRequest(c.endpoint)
 	if err != nil {
 		return nil, nil, errors.Wrapf(err, "can not create http request of get permissions")
 	}
-	c.authenticator.SetAuthHeaders(httpRequest.Headers, time.Now(), httpRequest.Method, httpRequest.PathQuery, httpRequest.Body)
-	httpResponse, body, err := c.httpClient.DoRequest(httpRequest)
+	httpResponse, body, err := c.doRequestWithRetry(ctx, httpRequest, c.readRateLimiter, true)
 	if err != nil {
 		return nil, nil, errors.Wrapf(err, "can not request of get permissions (request = %v)", httpRequest.ToString())
 	}
 	if !c.containsStatus([]int{200}, httpResponse.StatusCode) {
-		return nil, nil, errors.Errorf("unexpected status code of get permissions (request = %v, status = %v, body = %v)", httpRequest.ToString(), httpResponse.Status, string(body))
+		return nil, nil, newAPIError("get permissions", httpRequest, httpResponse, body)
 	}
 	err = json.Unmarshal(body, getPermissionsResponse)
 	if err != nil {
@@ -211,19 +293,22 @@ func (c *APIClient) PriGetPermissions() (*http.Response, *private.GetPermissions
 }
 
 func (c *APIClient) PriGetBalance() (*http.Response, private.GetBalanceResponse, error) {
+	return c.PriGetBalanceCtx(context.Background())
+}
+
+func (c *APIClient) PriGetBalanceCtx(ctx context.Context) (*http.Response, private.GetBalanceResponse, error) {
 	getBalanceRequest := private.NewGetBalanceRequest()
 	getBalanceResponse := make(private.GetBalanceResponse, 0)
 	httpRequest, err := getBalanceRequest.CreateHTTPRequest(c.endpoint)
 	if err != nil {
 		return nil, nil, errors.Wrapf(err, "can not create http request of get balance")
 	}
-	c.authenticator.SetAuthHeaders(httpRequest.Headers, time.Now(), httpRequest.Method, httpRequest.PathQuery, httpRequest.Body)
-	httpResponse, body, err := c.httpClient.DoRequest(httpRequest)
+	httpResponse, body, err := c.doRequestWithRetry(ctx, httpRequest, c.readRateLimiter, true)
 	if err != nil {
 		return nil, nil, errors.Wrapf(err, "can not request of get balance (request = %v)", httpRequest.ToString())
 	}
 	if !c.containsStatus([]int{200}, httpResponse.StatusCode) {
-		return nil, nil, errors.Errorf("unexpected status code ofaget  balance (request = %v, status = %v, body = %v)", httpRequest.ToString(), httpResponse.Status, string(body))
+		return nil, nil, newAPIError("get balance", httpRequest, httpResponse, body)
 	}
 	err = json.Unmarshal(body, &getBalanceResponse)
 	if err != nil {
@@ -233,19 +318,22 @@ func (c *APIClient) PriGetBalance() (*http.Response, private.GetBalanceResponse,
 }
 
 func (c *APIClient) PriGetCollateral() (*http.Response, *private.GetCollateralResponse, error) {
+	return c.PriGetCollateralCtx(context.Background())
+}
+
+func (c *APIClient) PriGetCollateralCtx(ctx context.Context) (*http.Response, *private.GetCollateralResponse, error) {
 	getCollateralRequest := private.NewGetCollateralRequest()
 	getCollateralResponse := new(private.GetCollateralResponse)
 	httpRequest, err := getCollateralRequest.CreateHTTPRequest(c.endpoint)
 	if err != nil {
 		return nil, nil, errors.Wrapf(err, "can not create http request of get collateral")
 	}
-	c.authenticator.SetAuthHeaders(httpRequest.Headers, time.Now(), httpRequest.Method, httpRequest.PathQuery, httpRequest.Body)
-	httpResponse, body, err := c.httpClient.DoRequest(httpRequest)
+	httpResponse, body, err := c.doRequestWithRetry(ctx, httpRequest, c.readRateLimiter, true)
 	if err != nil {
 		return nil, nil, errors.Wrapf(err, "can not request of get collateral (request = %v)", httpRequest.ToString())
 	}
 	if !c.containsStatus([]int{200}, httpResponse.StatusCode) {
-		return nil, nil, errors.Errorf("unexpected status code of get collateral (request = %v, status = %v, body = %v)", httpRequest.ToString(), httpResponse.Status, string(body))
+		return nil, nil, newAPIError("get collateral", httpRequest, httpResponse, body)
 	}
 	err = json.Unmarshal(body, getCollateralResponse)
 	if err != nil {
@@ -255,19 +343,22 @@ func (c *APIClient) PriGetCollateral() (*http.Response, *private.GetCollateralRe
 }
 
 func (c *APIClient) PriGetCollateralAccounts() (*http.Response, private.GetCollateralAccountsResponse, error) {
+	return c.PriGetCollateralAccountsCtx(context.Background())
+}
+
+func (c *APIClient) PriGetCollateralAccountsCtx(ctx context.Context) (*http.Response, private.GetCollateralAccountsResponse, error) {
 	getCollateralAccountsRequest := private.NewGetCollateralAccountsRequest()
 	getCollateralAccountsResponse := make(private.GetCollateralAccountsResponse, 0)
 	httpRequest, err := getCollateralAccountsRequest.CreateHTTPRequest(c.endpoint)
 	if err != nil {
 		return nil, nil, errors.Wrapf(err, "can not create http request of get collateral accounts")
 	}
-	c.authenticator.SetAuthHeaders(httpRequest.Headers, time.Now(), httpRequest.Method, httpRequest.PathQuery, httpRequest.Body)
-	httpResponse, body, err := c.httpClient.DoRequest(httpRequest)
+	httpResponse, body, err := c.doRequestWithRetry(ctx, httpRequest, c.readRateLimiter, true)
 	if err != nil {
 		return nil, nil, errors.Wrapf(err, "can not request of get collateral accounts (request = %v)", httpRequest.ToString())
 	}
 	if !c.containsStatus([]int{200}, httpResponse.StatusCode) {
-		return nil, nil, errors.Errorf("unexpected status code of get collateral accounts (request = %v, status = %v, body = %v)", httpRequest.ToString(), httpResponse.Status, string(body))
+		return nil, nil, newAPIError("get collateral accounts", httpRequest, httpResponse, body)
 	}
 	err = json.Unmarshal(body, &getCollateralAccountsResponse)
 	if err != nil {
@@ -276,6 +367,206 @@ func (c *APIClient) PriGetCollateralAccounts() (*http.Response, private.GetColla
 	return httpResponse, getCollateralAccountsResponse, nil
 }
 
+func (c *APIClient) PriGetAddresses() (*http.Response, private.GetAddressesResponse, error) {
+	return c.PriGetAddressesCtx(context.Background())
+}
+
+func (c *APIClient) PriGetAddressesCtx(ctx context.Context) (*http.Response, private.GetAddressesResponse, error) {
+	getAddressesRequest := private.NewGetAddressesRequest()
+	getAddressesResponse := make(private.GetAddressesResponse, 0)
+	httpRequest, err := getAddressesRequest.CreateHTTPRequest(c.endpoint)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "can not create http request of get addresses")
+	}
+	httpResponse, body, err := c.doRequestWithRetry(ctx, httpRequest, c.readRateLimiter, true)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "can not request of get addresses (request = %v)", httpRequest.ToString())
+	}
+	if !c.containsStatus([]int{200}, httpResponse.StatusCode) {
+		return nil, nil, newAPIError("get addresses", httpRequest, httpResponse, body)
+	}
+	err = json.Unmarshal(body, &getAddressesResponse)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "unmarshal data of get addresses (request = %v, body = %v)", httpRequest.ToString(), string(body))
+	}
+	return httpResponse, getAddressesResponse, nil
+}
+
+func (c *APIClient) PriGetCoinIns(count int64, before int64, after int64) (*http.Response, private.GetCoinInsResponse, error) {
+	return c.PriGetCoinInsCtx(context.Background(), count, before, after)
+}
+
+func (c *APIClient) PriGetCoinInsCtx(ctx context.Context, count int64, before int64, after int64) (*http.Response, private.GetCoinInsResponse, error) {
+	getCoinInsRequest := private.NewGetCoinInsRequest(count, before, after)
+	getCoinInsResponse := make(private.GetCoinInsResponse, 0)
+	httpRequest, err := getCoinInsRequest.CreateHTTPRequest(c.endpoint)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "can not create http request of get coin ins")
+	}
+	httpResponse, body, err := c.doRequestWithRetry(ctx, httpRequest, c.readRateLimiter, true)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "can not request of get coin ins (request = %v)", httpRequest.ToString())
+	}
+	if !c.containsStatus([]int{200}, httpResponse.StatusCode) {
+		return nil, nil, newAPIError("get coin ins", httpRequest, httpResponse, body)
+	}
+	err = json.Unmarshal(body, &getCoinInsResponse)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "unmarshal data of get coin ins (request = %v, body = %v)", httpRequest.ToString(), string(body))
+	}
+	return httpResponse, getCoinInsResponse, nil
+}
+
+func (c *APIClient) PriGetCoinOuts(count int64, before int64, after int64) (*http.Response, private.GetCoinOutsResponse, error) {
+	return c.PriGetCoinOutsCtx(context.Background(), count, before, after)
+}
+
+func (c *APIClient) PriGetCoinOutsCtx(ctx context.Context, count int64, before int64, after int64) (*http.Response, private.GetCoinOutsResponse, error) {
+	getCoinOutsRequest := private.NewGetCoinOutsRequest(count, before, after)
+	getCoinOutsResponse := make(private.GetCoinOutsResponse, 0)
+	httpRequest, err := getCoinOutsRequest.CreateHTTPRequest(c.endpoint)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "can not create http request of get coin outs")
+	}
+	httpResponse, body, err := c.doRequestWithRetry(ctx, httpRequest, c.readRateLimiter, true)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "can not request of get coin outs (request = %v)", httpRequest.ToString())
+	}
+	if !c.containsStatus([]int{200}, httpResponse.StatusCode) {
+		return nil, nil, newAPIError("get coin outs", httpRequest, httpResponse, body)
+	}
+	err = json.Unmarshal(body, &getCoinOutsResponse)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "unmarshal data of get coin outs (request = %v, body = %v)", httpRequest.ToString(), string(body))
+	}
+	return httpResponse, getCoinOutsResponse, nil
+}
+
+func (c *APIClient) PriGetBankAccounts() (*http.Response, private.GetBankAccountsResponse, error) {
+	return c.PriGetBankAccountsCtx(context.Background())
+}
+
+func (c *APIClient) PriGetBankAccountsCtx(ctx context.Context) (*http.Response, private.GetBankAccountsResponse, error) {
+	getBankAccountsRequest := private.NewGetBankAccountsRequest()
+	getBankAccountsResponse := make(private.GetBankAccountsResponse, 0)
+	httpRequest, err := getBankAccountsRequest.CreateHTTPRequest(c.endpoint)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "can not create http request of get bank accounts")
+	}
+	httpResponse, body, err := c.doRequestWithRetry(ctx, httpRequest, c.readRateLimiter, true)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "can not request of get bank accounts (request = %v)", httpRequest.ToString())
+	}
+	if !c.containsStatus([]int{200}, httpResponse.StatusCode) {
+		return nil, nil, newAPIError("get bank accounts", httpRequest, httpResponse, body)
+	}
+	err = json.Unmarshal(body, &getBankAccountsResponse)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "unmarshal data of get bank accounts (request = %v, body = %v)", httpRequest.ToString(), string(body))
+	}
+	return httpResponse, getBankAccountsResponse, nil
+}
+
+func (c *APIClient) PriGetDeposits(count int64, before int64, after int64) (*http.Response, private.GetDepositsResponse, error) {
+	return c.PriGetDepositsCtx(context.Background(), count, before, after)
+}
+
+func (c *APIClient) PriGetDepositsCtx(ctx context.Context, count int64, before int64, after int64) (*http.Response, private.GetDepositsResponse, error) {
+	getDepositsRequest := private.NewGetDepositsRequest(count, before, after)
+	getDepositsResponse := make(private.GetDepositsResponse, 0)
+	httpRequest, err := getDepositsRequest.CreateHTTPRequest(c.endpoint)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "can not create http request of get deposits")
+	}
+	httpResponse, body, err := c.doRequestWithRetry(ctx, httpRequest, c.readRateLimiter, true)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "can not request of get deposits (request = %v)", httpRequest.ToString())
+	}
+	if !c.containsStatus([]int{200}, httpResponse.StatusCode) {
+		return nil, nil, newAPIError("get deposits", httpRequest, httpResponse, body)
+	}
+	err = json.Unmarshal(body, &getDepositsResponse)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "unmarshal data of get deposits (request = %v, body = %v)", httpRequest.ToString(), string(body))
+	}
+	return httpResponse, getDepositsResponse, nil
+}
+
+func (c *APIClient) PriWithdraw(currencyCode types.CurrencyCode, bankAccountId int64, amount float64, code string) (*http.Response, *private.WithdrawResponse, error) {
+	return c.PriWithdrawCtx(context.Background(), currencyCode, bankAccountId, amount, code)
+}
+
+func (c *APIClient) PriWithdrawCtx(ctx context.Context, currencyCode types.CurrencyCode, bankAccountId int64, amount float64, code string) (*http.Response, *private.WithdrawResponse, error) {
+	withdrawRequest := private.NewWithdrawRequest(currencyCode, bankAccountId, amount, code)
+	withdrawResponse := new(private.WithdrawResponse)
+	httpRequest, err := withdrawRequest.CreateHTTPRequest(c.endpoint)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "can not create http request of withdraw")
+	}
+	httpResponse, body, err := c.doRequest(ctx, httpRequest, c.readRateLimiter, true)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "can not request of withdraw (request = %v)", httpRequest.ToString())
+	}
+	if !c.containsStatus([]int{200}, httpResponse.StatusCode) {
+		return nil, nil, newAPIError("withdraw", httpRequest, httpResponse, body)
+	}
+	err = json.Unmarshal(body, withdrawResponse)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "unmarshal data of withdraw (request = %v, body = %v)", httpRequest.ToString(), string(body))
+	}
+	return httpResponse, withdrawResponse, nil
+}
+
+func (c *APIClient) PriGetWithdrawals(count int64, before int64, after int64) (*http.Response, private.GetWithdrawalsResponse, error) {
+	return c.PriGetWithdrawalsCtx(context.Background(), count, before, after)
+}
+
+func (c *APIClient) PriGetWithdrawalsCtx(ctx context.Context, count int64, before int64, after int64) (*http.Response, private.GetWithdrawalsResponse, error) {
+	getWithdrawalsRequest := private.NewGetWithdrawalsRequest(count, before, after)
+	getWithdrawalsResponse := make(private.GetWithdrawalsResponse, 0)
+	httpRequest, err := getWithdrawalsRequest.CreateHTTPRequest(c.endpoint)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "can not create http request of get withdrawals")
+	}
+	httpResponse, body, err := c.doRequestWithRetry(ctx, httpRequest, c.readRateLimiter, true)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "can not request of get withdrawals (request = %v)", httpRequest.ToString())
+	}
+	if !c.containsStatus([]int{200}, httpResponse.StatusCode) {
+		return nil, nil, newAPIError("get withdrawals", httpRequest, httpResponse, body)
+	}
+	err = json.Unmarshal(body, &getWithdrawalsResponse)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "unmarshal data of get withdrawals (request = %v, body = %v)", httpRequest.ToString(), string(body))
+	}
+	return httpResponse, getWithdrawalsResponse, nil
+}
+
+func (c *APIClient) PriGetWithdrawalsById(messageId string) (*http.Response, private.GetWithdrawalsResponse, error) {
+	return c.PriGetWithdrawalsByIdCtx(context.Background(), messageId)
+}
+
+func (c *APIClient) PriGetWithdrawalsByIdCtx(ctx context.Context, messageId string) (*http.Response, private.GetWithdrawalsResponse, error) {
+	getWithdrawalsRequest := private.NewGetWithdrawalsRequestById(messageId)
+	getWithdrawalsResponse := make(private.GetWithdrawalsResponse, 0)
+	httpRequest, err := getWithdrawalsRequest.CreateHTTPRequest(c.endpoint)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "can not create http request of get withdrawals")
+	}
+	httpResponse, body, err := c.doRequestWithRetry(ctx, httpRequest, c.readRateLimiter, true)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "can not request of get withdrawals (request = %v)", httpRequest.ToString())
+	}
+	if !c.containsStatus([]int{200}, httpResponse.StatusCode) {
+		return nil, nil, newAPIError("get withdrawals", httpRequest, httpResponse, body)
+	}
+	err = json.Unmarshal(body, &getWithdrawalsResponse)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "unmarshal data of get withdrawals (request = %v, body = %v)", httpRequest.ToString(), string(body))
+	}
+	return httpResponse, getWithdrawalsResponse, nil
+}
+
 func (c *APIClient) PriSendChildOrder(productCode types.ProductCode,
                                            childOrderType types.OrderType,
                                            side types.Side,
@@ -283,28 +574,49 @@ func (c *APIClient) PriSendChildOrder(productCode types.ProductCode,
                                            size float64,
                                            minuteToExpire int64,
                                            timeInForce types.TimeInForce) (*http.Response, *private.SendChildOrderResponse, error) {
+	return c.PriSendChildOrderCtx(context.Background(), productCode, childOrderType, side, price, size, minuteToExpire, timeInForce)
+}
+
+func (c *APIClient) PriSendChildOrderCtx(ctx context.Context, productCode types.ProductCode,
+                                           childOrderType types.OrderType,
+                                           side types.Side,
+                                           price float64,
+                                           size float64,
+                                           minuteToExpire int64,
+                                           timeInForce types.TimeInForce) (*http.Response, *private.SendChildOrderResponse, error) {
 	sendChildOrderRequest := private.NewSendChildOrderRequest(productCode, childOrderType, side, price, size, minuteToExpire, timeInForce)
+	err := sendChildOrderRequest.Validate()
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "invalid send child order request")
+	}
 	sendChildOrderResponse := new(private.SendChildOrderResponse)
 	httpRequest, err := sendChildOrderRequest.CreateHTTPRequest(c.endpoint)
 	if err != nil {
 		return nil, nil, errors.Wrapf(err, "can not create http request of send child order")
 	}
-	c.authenticator.SetAuthHeaders(httpRequest.Headers, time.Now(), httpRequest.Method, httpRequest.PathQuery, httpRequest.Body)
-	httpResponse, body, err := c.httpClient.DoRequest(httpRequest)
+	sentAt := time.Now()
+	httpResponse, body, err := c.doOrderRequestWithRetry(ctx, "send child order", httpRequest, func(ctx context.Context) (*http.Response, []byte, bool, error) {
+		return c.lookupChildOrder(ctx, sendChildOrderRequest, sentAt)
+	})
 	if err != nil {
 		return nil, nil, errors.Wrapf(err, "can not request of send child order (request = %v)", httpRequest.ToString())
 	}
 	if !c.containsStatus([]int{200}, httpResponse.StatusCode) {
-		return nil, nil, errors.Errorf("unexpected status code of send child order (request = %v, status = %v, body = %v)", httpRequest.ToString(), httpResponse.Status, string(body))
+		return nil, nil, newAPIError("send child order", httpRequest, httpResponse, body)
 	}
 	err = json.Unmarshal(body, sendChildOrderResponse)
 	if err != nil {
 		return nil, nil, errors.Wrapf(err, "unmarshal data of send child order (request = %v, body = %v)", httpRequest.ToString(), string(body))
 	}
+	c.acceptedOrders.add(sendChildOrderResponse.ChildOrderAcceptanceId)
 	return httpResponse, sendChildOrderResponse, nil
 }
 
 func (c *APIClient) PriCancelChildOrder(productCode types.ProductCode, idType types.IdType, orderId string) (*http.Response, error) {
+	return c.PriCancelChildOrderCtx(context.Background(), productCode, idType, orderId)
+}
+
+func (c *APIClient) PriCancelChildOrderCtx(ctx context.Context, productCode types.ProductCode, idType types.IdType, orderId string) (*http.Response, error) {
 	cancelChildOrderRequest, err := private.NewCancelChildOrderRequest(productCode, idType, orderId)
 	if err != nil {
 		return nil, errors.Wrapf(err, "can not create cancel child order request")
@@ -313,31 +625,33 @@ func (c *APIClient) PriCancelChildOrder(productCode types.ProductCode, idType ty
 	if err != nil {
 		return nil, errors.Wrapf(err, "can not create http request of cancel child order")
 	}
-	c.authenticator.SetAuthHeaders(httpRequest.Headers, time.Now(), httpRequest.Method, httpRequest.PathQuery, httpRequest.Body)
-	httpResponse, body, err := c.httpClient.DoRequest(httpRequest)
+	httpResponse, body, err := c.doRequestWithRetry(ctx, httpRequest, c.orderRateLimiter, true)
 	if err != nil {
 		return nil, errors.Wrapf(err, "can not request of cancel child order (request = %v)", httpRequest.ToString())
 	}
 	if !c.containsStatus([]int{200}, httpResponse.StatusCode) {
-		return nil, errors.Errorf("unexpected status code of cancel child order (request = %v, status = %v, body = %v)", httpRequest.ToString(), httpResponse.Status, string(body))
+		return nil, newAPIError("cancel child order", httpRequest, httpResponse, body)
 	}
 	return httpResponse, nil
 }
 
 func (c *APIClient) PriGetChildOrders(productCode types.ProductCode, count int64, before int64, after int64, orderState types.OrderState) (*http.Response, private.GetChildOrdersResponse, error) {
+	return c.PriGetChildOrdersCtx(context.Background(), productCode, count, before, after, orderState)
+}
+
+func (c *APIClient) PriGetChildOrdersCtx(ctx context.Context, productCode types.ProductCode, count int64, before int64, after int64, orderState types.OrderState) (*http.Response, private.GetChildOrdersResponse, error) {
 	getChildOrdersRequest := private.NewGetChildOrdersRequest(productCode, count, before, after, orderState)
 	getChildOrdersResponse := make(private.GetChildOrdersResponse, 0)
 	httpRequest, err := getChildOrdersRequest.CreateHTTPRequest(c.endpoint)
 	if err != nil {
 		return nil, nil, errors.Wrapf(err, "can not create http request of get child orders")
 	}
-	c.authenticator.SetAuthHeaders(httpRequest.Headers, time.Now(), httpRequest.Method, httpRequest.PathQuery, httpRequest.Body)
-	httpResponse, body, err := c.httpClient.DoRequest(httpRequest)
+	httpResponse, body, err := c.doRequestWithRetry(ctx, httpRequest, c.readRateLimiter, true)
 	if err != nil {
 		return nil, nil, errors.Wrapf(err, "can not request of get child orders (request = %v)", httpRequest.ToString())
 	}
 	if !c.containsStatus([]int{200}, httpResponse.StatusCode) {
-		return nil, nil, errors.Errorf("unexpected status code of get child orders (request = %v, status = %v, body = %v)", httpRequest.ToString(), httpResponse.Status, string(body))
+		return nil, nil, newAPIError("get child orders", httpRequest, httpResponse, body)
 	}
 	err = json.Unmarshal(body, &getChildOrdersResponse)
 	if err != nil {
@@ -347,6 +661,10 @@ func (c *APIClient) PriGetChildOrders(productCode types.ProductCode, count int64
 }
 
 func (c *APIClient) PriGetChildOrdersById(productCode types.ProductCode, idType types.IdType, orderId string) (*http.Response, private.GetChildOrdersResponse, error) {
+	return c.PriGetChildOrdersByIdCtx(context.Background(), productCode, idType, orderId)
+}
+
+func (c *APIClient) PriGetChildOrdersByIdCtx(ctx context.Context, productCode types.ProductCode, idType types.IdType, orderId string) (*http.Response, private.GetChildOrdersResponse, error) {
 	getChildOrdersRequest, err := private.NewGetChildOrdersRequestById(productCode, idType, orderId)
 	if err != nil {
 		return nil, nil, errors.Wrapf(err, "can not create get child orders by id request")
@@ -356,13 +674,12 @@ func (c *APIClient) PriGetChildOrdersById(productCode types.ProductCode, idType
 	if err != nil {
 		return nil, nil, errors.Wrapf(err, "can not create http request of get child orders by id")
 	}
-	c.authenticator.SetAuthHeaders(httpRequest.Headers, time.Now(), httpRequest.Method, httpRequest.PathQuery, httpRequest.Body)
-	httpResponse, body, err := c.httpClient.DoRequest(httpRequest)
+	httpResponse, body, err := c.doRequestWithRetry(ctx, httpRequest, c.readRateLimiter, true)
 	if err != nil {
 		return nil, nil, errors.Wrapf(err, "can not request of get child orders by id (request = %v)", httpRequest.ToString())
 	}
 	if !c.containsStatus([]int{200}, httpResponse.StatusCode) {
-		return nil, nil, errors.Errorf("unexpected status code of get child orders by id (request = %v, status = %v, body = %v)", httpRequest.ToString(), httpResponse.Status, string(body))
+		return nil, nil, newAPIError("get child orders by id", httpRequest, httpResponse, body)
 	}
 	err = json.Unmarshal(body, &getChildOrdersResponse)
 	if err != nil {
@@ -372,36 +689,42 @@ func (c *APIClient) PriGetChildOrdersById(productCode types.ProductCode, idType
 }
 
 func (c *APIClient) PriCancelAllChildOrders(productCode types.ProductCode) (*http.Response, error) {
+	return c.PriCancelAllChildOrdersCtx(context.Background(), productCode)
+}
+
+func (c *APIClient) PriCancelAllChildOrdersCtx(ctx context.Context, productCode types.ProductCode) (*http.Response, error) {
 	cancelAllChildOrdersRequest := private.NewCancelAllChildOrdersRequest(productCode)
 	httpRequest, err := cancelAllChildOrdersRequest.CreateHTTPRequest(c.endpoint)
 	if err != nil {
 		return nil, errors.Wrapf(err, "can not create http request of cancel all child order")
 	}
-	c.authenticator.SetAuthHeaders(httpRequest.Headers, time.Now(), httpRequest.Method, httpRequest.PathQuery, httpRequest.Body)
-	httpResponse, body, err := c.httpClient.DoRequest(httpRequest)
+	httpResponse, body, err := c.doRequestWithRetry(ctx, httpRequest, c.orderRateLimiter, true)
 	if err != nil {
 		return nil, errors.Wrapf(err, "can not request of cancel all child order (request = %v)", httpRequest.ToString())
 	}
 	if !c.containsStatus([]int{200}, httpResponse.StatusCode) {
-		return nil, errors.Errorf("unexpected status code of cancel all child order (request = %v, status = %v, body = %v)", httpRequest.ToString(), httpResponse.Status, string(body))
+		return nil, newAPIError("cancel all child order", httpRequest, httpResponse, body)
 	}
 	return httpResponse, nil
 }
 
 func (c *APIClient) PriGetExecutions(productCode types.ProductCode, count int64, before int64, after int64) (*http.Response, private.GetExecutionsResponse, error) {
+	return c.PriGetExecutionsCtx(context.Background(), productCode, count, before, after)
+}
+
+func (c *APIClient) PriGetExecutionsCtx(ctx context.Context, productCode types.ProductCode, count int64, before int64, after int64) (*http.Response, private.GetExecutionsResponse, error) {
 	getExecutionsRequest := private.NewGetExecutionsRequest(productCode, count, before, after)
 	getExecutionsResponse := make(private.GetExecutionsResponse, 0)
 	httpRequest, err := getExecutionsRequest.CreateHTTPRequest(c.endpoint)
 	if err != nil {
 		return nil, nil, errors.Wrapf(err, "can not create http request of get executions")
 	}
-	c.authenticator.SetAuthHeaders(httpRequest.Headers, time.Now(), httpRequest.Method, httpRequest.PathQuery, httpRequest.Body)
-	httpResponse, body, err := c.httpClient.DoRequest(httpRequest)
+	httpResponse, body, err := c.doRequestWithRetry(ctx, httpRequest, c.readRateLimiter, true)
 	if err != nil {
 		return nil, nil, errors.Wrapf(err, "can not request of get executions (request = %v)", httpRequest.ToString())
 	}
 	if !c.containsStatus([]int{200}, httpResponse.StatusCode) {
-		return nil, nil, errors.Errorf("unexpected status code of get executions (request = %v, status = %v, body = %v)", httpRequest.ToString(), httpResponse.Status, string(body))
+		return nil, nil, newAPIError("get executions", httpRequest, httpResponse, body)
 	}
 	err = json.Unmarshal(body, &getExecutionsResponse)
 	if err != nil {
@@ -411,6 +734,10 @@ func (c *APIClient) PriGetExecutions(productCode types.ProductCode, count int64,
 }
 
 func (c *APIClient) PriGetExecutionsById(productCode types.ProductCode, idType types.IdType, orderId string) (*http.Response, private.GetExecutionsResponse, error) {
+	return c.PriGetExecutionsByIdCtx(context.Background(), productCode, idType, orderId)
+}
+
+func (c *APIClient) PriGetExecutionsByIdCtx(ctx context.Context, productCode types.ProductCode, idType types.IdType, orderId string) (*http.Response, private.GetExecutionsResponse, error) {
 	getExecutionsRequest, err := private.NewGetExecutionsRequestById(productCode, idType, orderId)
 	if err != nil {
 		return nil, nil, errors.Wrapf(err, "can not create get executions by id request")
@@ -420,13 +747,12 @@ func (c *APIClient) PriGetExecutionsById(productCode types.ProductCode, idType t
 	if err != nil {
 		return nil, nil, errors.Wrapf(err, "can not create http request of get executions by id")
 	}
-	c.authenticator.SetAuthHeaders(httpRequest.Headers, time.Now(), httpRequest.Method, httpRequest.PathQuery, httpRequest.Body)
-	httpResponse, body, err := c.httpClient.DoRequest(httpRequest)
+	httpResponse, body, err := c.doRequestWithRetry(ctx, httpRequest, c.readRateLimiter, true)
 	if err != nil {
 		return nil, nil, errors.Wrapf(err, "can not request of get executions by id (request = %v)", httpRequest.ToString())
 	}
 	if !c.containsStatus([]int{200}, httpResponse.StatusCode) {
-		return nil, nil, errors.Errorf("unexpected status code of get executions by id (request = %v, status = %v, body = %v)", httpRequest.ToString(), httpResponse.Status, string(body))
+		return nil, nil, newAPIError("get executions by id", httpRequest, httpResponse, body)
 	}
 	err = json.Unmarshal(body, &getExecutionsResponse)
 	if err != nil {
@@ -436,19 +762,22 @@ func (c *APIClient) PriGetExecutionsById(productCode types.ProductCode, idType t
 }
 
 func (c *APIClient) PriGetBalanceHistory(currencyCode types.CurrencyCode, count int64, before int64, after int64) (*http.Response, private.GetBalanceHistoryResponse, error) {
+	return c.PriGetBalanceHistoryCtx(context.Background(), currencyCode, count, before, after)
+}
+
+func (c *APIClient) PriGetBalanceHistoryCtx(ctx context.Context, currencyCode types.CurrencyCode, count int64, before int64, after int64) (*http.Response, private.GetBalanceHistoryResponse, error) {
 	getBalanceHistoryRequest := private.NewGetBalanceHistoryRequest(currencyCode, count, before, after)
 	getBalanceHistoryResponse := make(private.GetBalanceHistoryResponse, 0)
 	httpRequest, err := getBalanceHistoryRequest.CreateHTTPRequest(c.endpoint)
 	if err != nil {
 		return nil, nil, errors.Wrapf(err, "can not create http request of get balance history")
 	}
-	c.authenticator.SetAuthHeaders(httpRequest.Headers, time.Now(), httpRequest.Method, httpRequest.PathQuery, httpRequest.Body)
-	httpResponse, body, err := c.httpClient.DoRequest(httpRequest)
+	httpResponse, body, err := c.doRequestWithRetry(ctx, httpRequest, c.readRateLimiter, true)
 	if err != nil {
 		return nil, nil, errors.Wrapf(err, "can not request of get balance history (request = %v)", httpRequest.ToString())
 	}
 	if !c.containsStatus([]int{200}, httpResponse.StatusCode) {
-		return nil, nil, errors.Errorf("unexpected status code of get balance history (request = %v, status = %v, body = %v)", httpRequest.ToString(), httpResponse.Status, string(body))
+		return nil, nil, newAPIError("get balance history", httpRequest, httpResponse, body)
 	}
 	err = json.Unmarshal(body, &getBalanceHistoryResponse)
 	if err != nil {
@@ -458,19 +787,22 @@ func (c *APIClient) PriGetBalanceHistory(currencyCode types.CurrencyCode, count
 }
 
 func (c *APIClient) PriGetPositions() (*http.Response, private.GetPositionsResponse, error) {
+	return c.PriGetPositionsCtx(context.Background())
+}
+
+func (c *APIClient) PriGetPositionsCtx(ctx context.Context) (*http.Response, private.GetPositionsResponse, error) {
 	getPositionsRequest := private.NewGetPositionsRequest()
 	getPositionsResponse := make(private.GetPositionsResponse, 0)
 	httpRequest, err := getPositionsRequest.CreateHTTPRequest(c.endpoint)
 	if err != nil {
 		return nil, nil, errors.Wrapf(err, "can not create http request of get positions")
 	}
-	c.authenticator.SetAuthHeaders(httpRequest.Headers, time.Now(), httpRequest.Method, httpRequest.PathQuery, httpRequest.Body)
-	httpResponse, body, err := c.httpClient.DoRequest(httpRequest)
+	httpResponse, body, err := c.doRequestWithRetry(ctx, httpRequest, c.readRateLimiter, true)
 	if err != nil {
 		return nil, nil, errors.Wrapf(err, "can not request of get positions (request = %v)", httpRequest.ToString())
 	}
 	if !c.containsStatus([]int{200}, httpResponse.StatusCode) {
-		return nil, nil, errors.Errorf("unexpected status code of get positions (request = %v, status = %v, body = %v)", httpRequest.ToString(), httpResponse.Status, string(body))
+		return nil, nil, newAPIError("get positions", httpRequest, httpResponse, body)
 	}
 	err = json.Unmarshal(body, &getPositionsResponse)
 	if err != nil {
@@ -480,19 +812,22 @@ func (c *APIClient) PriGetPositions() (*http.Response, private.GetPositionsRespo
 }
 
 func (c *APIClient) PriGetCollateralHistory(count int64, before int64, after int64) (*http.Response, private.GetCollateralHistoryResponse, error) {
+	return c.PriGetCollateralHistoryCtx(context.Background(), count, before, after)
+}
+
+func (c *APIClient) PriGetCollateralHistoryCtx(ctx context.Context, count int64, before int64, after int64) (*http.Response, private.GetCollateralHistoryResponse, error) {
 	getCollateralHistoryRequest := private.NewGetCollateralHistoryRequest(count, before, after)
 	getCollateralHistoryResponse := make(private.GetCollateralHistoryResponse, 0)
 	httpRequest, err := getCollateralHistoryRequest.CreateHTTPRequest(c.endpoint)
 	if err != nil {
 		return nil, nil, errors.Wrapf(err, "can not create http request of get collateral history")
 	}
-	c.authenticator.SetAuthHeaders(httpRequest.Headers, time.Now(), httpRequest.Method, httpRequest.PathQuery, httpRequest.Body)
-	httpResponse, body, err := c.httpClient.DoRequest(httpRequest)
+	httpResponse, body, err := c.doRequestWithRetry(ctx, httpRequest, c.readRateLimiter, true)
 	if err != nil {
 		return nil, nil, errors.Wrapf(err, "can not request of get collateral history (request = %v)", httpRequest.ToString())
 	}
 	if !c.containsStatus([]int{200}, httpResponse.StatusCode) {
-		return nil, nil, errors.Errorf("unexpected status code of get collateral history (request = %v, status = %v, body = %v)", httpRequest.ToString(), httpResponse.Status, string(body))
+		return nil, nil, newAPIError("get collateral history", httpRequest, httpResponse, body)
 	}
 	err = json.Unmarshal(body, &getCollateralHistoryResponse)
 	if err != nil {
@@ -502,19 +837,22 @@ func (c *APIClient) PriGetCollateralHistory(count int64, before int64, after int
 }
 
 func (c *APIClient) PriGetTradingCommission(productCode types.ProductCode) (*http.Response, *private.GetTradingCommissionResponse, error) {
+	return c.PriGetTradingCommissionCtx(context.Background(), productCode)
+}
+
+func (c *APIClient) PriGetTradingCommissionCtx(ctx context.Context, productCode types.ProductCode) (*http.Response, *private.GetTradingCommissionResponse, error) {
 	getTradingCommissionRequest := private.NewGetTradingCommissionRequest(productCode)
 	getTradingCommissionResponse := new(private.GetTradingCommissionResponse)
 	httpRequest, err := getTradingCommissionRequest.CreateHTTPRequest(c.endpoint)
 	if err != nil {
 		return nil, nil, errors.Wrapf(err, "can not create http request of get trading commission")
 	}
-	c.authenticator.SetAuthHeaders(httpRequest.Headers, time.Now(), httpRequest.Method, httpRequest.PathQuery, httpRequest.Body)
-	httpResponse, body, err := c.httpClient.DoRequest(httpRequest)
+	httpResponse, body, err := c.doRequestWithRetry(ctx, httpRequest, c.readRateLimiter, true)
 	if err != nil {
 		return nil, nil, errors.Wrapf(err, "can not request of get trading commission (request = %v)", httpRequest.ToString())
 	}
 	if !c.containsStatus([]int{200}, httpResponse.StatusCode) {
-		return nil, nil, errors.Errorf("unexpected status code of get trading commission (request = %v, status = %v, body = %v)", httpRequest.ToString(), httpResponse.Status, string(body))
+		return nil, nil, newAPIError("get trading commission", httpRequest, httpResponse, body)
 	}
 	err = json.Unmarshal(body, getTradingCommissionResponse)
 	if err != nil {
@@ -527,28 +865,46 @@ func (c *APIClient) PriSendParentOrder(orderMethod types.OrderMethod,
 				       minuteToRxpire int64,
 				       timeInForce types.TimeInForce,
 				       parameters ...*private.SendParentOrderParameter) (*http.Response, *private.SendParentOrderResponse, error) {
+	return c.PriSendParentOrderCtx(context.Background(), orderMethod, minuteToRxpire, timeInForce, parameters...)
+}
+
+func (c *APIClient) PriSendParentOrderCtx(ctx context.Context, orderMethod types.OrderMethod,
+				       minuteToRxpire int64,
+				       timeInForce types.TimeInForce,
+				       parameters ...*private.SendParentOrderParameter) (*http.Response, *private.SendParentOrderResponse, error) {
 	sendParentOrderRequest := private.NewSendParentOrderRequest(orderMethod, minuteToRxpire, timeInForce, parameters...)
+	err := sendParentOrderRequest.Validate()
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "invalid send parent order request")
+	}
 	sendParentOrderResponse := new(private.SendParentOrderResponse)
 	httpRequest, err := sendParentOrderRequest.CreateHTTPRequest(c.endpoint)
 	if err != nil {
 		return nil, nil, errors.Wrapf(err, "can not create http request of send parent order")
 	}
-	c.authenticator.SetAuthHeaders(httpRequest.Headers, time.Now(), httpRequest.Method, httpRequest.PathQuery, httpRequest.Body)
-	httpResponse, body, err := c.httpClient.DoRequest(httpRequest)
+	sentAt := time.Now()
+	httpResponse, body, err := c.doOrderRequestWithRetry(ctx, "send parent order", httpRequest, func(ctx context.Context) (*http.Response, []byte, bool, error) {
+		return c.lookupParentOrder(ctx, sendParentOrderRequest, sentAt)
+	})
 	if err != nil {
 		return nil, nil, errors.Wrapf(err, "can not request of send parent order (request = %v)", httpRequest.ToString())
 	}
 	if !c.containsStatus([]int{200}, httpResponse.StatusCode) {
-		return nil, nil, errors.Errorf("unexpected status code of send parent order (request = %v, status = %v, body = %v)", httpRequest.ToString(), httpResponse.Status, string(body))
+		return nil, nil, newAPIError("send parent order", httpRequest, httpResponse, body)
 	}
 	err = json.Unmarshal(body, sendParentOrderResponse)
 	if err != nil {
 		return nil, nil, errors.Wrapf(err, "unmarshal data of send parent order (request = %v, body = %v)", httpRequest.ToString(), string(body))
 	}
+	c.acceptedOrders.add(sendParentOrderResponse.ParentOrderAcceptanceId)
 	return httpResponse, sendParentOrderResponse, nil
 }
 
 func (c *APIClient) PriCancelParentOrder(productCode types.ProductCode, idType types.IdType, orderId string) (*http.Response, error) {
+	return c.PriCancelParentOrderCtx(context.Background(), productCode, idType, orderId)
+}
+
+func (c *APIClient) PriCancelParentOrderCtx(ctx context.Context, productCode types.ProductCode, idType types.IdType, orderId string) (*http.Response, error) {
 	cancelParentOrderRequest, err := private.NewCancelParentOrderRequest(productCode, idType, orderId)
 	if err != nil {
 		return nil, errors.Wrapf(err, "can not create cancel parent order request")
@@ -557,31 +913,33 @@ func (c *APIClient) PriCancelParentOrder(productCode types.ProductCode, idType t
 	if err != nil {
 		return nil, errors.Wrapf(err, "can not create http request of cancel parent order")
 	}
-	c.authenticator.SetAuthHeaders(httpRequest.Headers, time.Now(), httpRequest.Method, httpRequest.PathQuery, httpRequest.Body)
-	httpResponse, body, err := c.httpClient.DoRequest(httpRequest)
+	httpResponse, body, err := c.doRequestWithRetry(ctx, httpRequest, c.orderRateLimiter, true)
 	if err != nil {
 		return nil, errors.Wrapf(err, "can not request of cancel parent order (request = %v)", httpRequest.ToString())
 	}
 	if !c.containsStatus([]int{200}, httpResponse.StatusCode) {
-		return nil, errors.Errorf("unexpected status code of cancel parent order (request = %v, status = %v, body = %v)", httpRequest.ToString(), httpResponse.Status, string(body))
+		return nil, newAPIError("cancel parent order", httpRequest, httpResponse, body)
 	}
 	return httpResponse, nil
 }
 
 func (c *APIClient) PriGetParentOrders(productCode types.ProductCode, count int64, before int64, after int64, orderState types.OrderState) (*http.Response, private.GetParentOrdersResponse, error) {
+	return c.PriGetParentOrdersCtx(context.Background(), productCode, count, before, after, orderState)
+}
+
+func (c *APIClient) PriGetParentOrdersCtx(ctx context.Context, productCode types.ProductCode, count int64, before int64, after int64, orderState types.OrderState) (*http.Response, private.GetParentOrdersResponse, error) {
 	getParentOrdersRequest := private.NewGetParentOrdersRequest(productCode, count, before, after, orderState)
 	getParentOrdersResponse := make(private.GetParentOrdersResponse, 0)
 	httpRequest, err := getParentOrdersRequest.CreateHTTPRequest(c.endpoint)
 	if err != nil {
 		return nil, nil, errors.Wrapf(err, "can not create http request of get parent orders")
 	}
-	c.authenticator.SetAuthHeaders(httpRequest.Headers, time.Now(), httpRequest.Method, httpRequest.PathQuery, httpRequest.Body)
-	httpResponse, body, err := c.httpClient.DoRequest(httpRequest)
+	httpResponse, body, err := c.doRequestWithRetry(ctx, httpRequest, c.readRateLimiter, true)
 	if err != nil {
 		return nil, nil, errors.Wrapf(err, "can not request of get parent orders (request = %v)", httpRequest.ToString())
 	}
 	if !c.containsStatus([]int{200}, httpResponse.StatusCode) {
-		return nil, nil, errors.Errorf("unexpected status code of get parent orders (request = %v, status = %v, body = %v)", httpRequest.ToString(), httpResponse.Status, string(body))
+		return nil, nil, newAPIError("get parent orders", httpRequest, httpResponse, body)
 	}
 	err = json.Unmarshal(body, &getParentOrdersResponse)
 	if err != nil {
@@ -591,6 +949,10 @@ func (c *APIClient) PriGetParentOrders(productCode types.ProductCode, count int6
 }
 
 func (c *APIClient) PriGetParentOrder(IdType types.IdType, orderId string) (*http.Response, *private.GetParentOrderResponse, error) {
+	return c.PriGetParentOrderCtx(context.Background(), IdType, orderId)
+}
+
+func (c *APIClient) PriGetParentOrderCtx(ctx context.Context, IdType types.IdType, orderId string) (*http.Response, *private.GetParentOrderResponse, error) {
 	getParentOrderRequest, err := private.NewGetParentOrderRequest(IdType, orderId)
 	if err != nil {
 		return nil, nil, errors.Wrapf(err, "can not create get parent order request")
@@ -600,13 +962,12 @@ func (c *APIClient) PriGetParentOrder(IdType types.IdType, orderId string) (*htt
 	if err != nil {
 		return nil, nil, errors.Wrapf(err, "can not create http request of get parent order")
 	}
-	c.authenticator.SetAuthHeaders(httpRequest.Headers, time.Now(), httpRequest.Method, httpRequest.PathQuery, httpRequest.Body)
-	httpResponse, body, err := c.httpClient.DoRequest(httpRequest)
+	httpResponse, body, err := c.doRequestWithRetry(ctx, httpRequest, c.readRateLimiter, true)
 	if err != nil {
 		return nil, nil, errors.Wrapf(err, "can not request of get parent order (request = %v)", httpRequest.ToString())
 	}
 	if !c.containsStatus([]int{200}, httpResponse.StatusCode) {
-		return nil, nil, errors.Errorf("unexpected status code of get parent order (request = %v, status = %v, body = %v)", httpRequest.ToString(), httpResponse.Status, string(body))
+		return nil, nil, newAPIError("get parent order", httpRequest, httpResponse, body)
 	}
 	err = json.Unmarshal(body, getParentOrderResponse)
 	if err != nil {
@@ -615,11 +976,28 @@ func (c *APIClient) PriGetParentOrder(IdType types.IdType, orderId string) (*htt
 	return httpResponse, getParentOrderResponse, nil
 }
 
-func NewAPIClient(httpClient *client.HTTPClient, authenticator Authenticator) (*APIClient) {
+// NewAPIClient creates a rest api client. WithHTTPClient and WithAuthenticator override httpClient and authenticator,
+// and a default http client is used when neither is given.
+func NewAPIClient(httpClient *client.HTTPClient, authenticator Authenticator, options ...ClientOption) (*APIClient) {
+	clientOptions := newClientOptions(options)
+	if clientOptions.httpClient == nil {
+		clientOptions.httpClient = httpClient
+	}
+	if clientOptions.httpClient == nil {
+		clientOptions.httpClient = client.NewHTTPClient(0, 0, 0, nil)
+	}
+	if clientOptions.authenticator == nil {
+		clientOptions.authenticator = authenticator
+	}
 	return &APIClient{
-		endpoint:                  apiEndpoint,
-		httpClient:                httpClient,
-		authenticator:             authenticator,
+		endpoint:                  clientOptions.endpoint,
+		httpClient:                clientOptions.httpClient,
+		authenticator:             clientOptions.authenticator,
+		readRateLimiter:           NewRateLimiter(BFCallableAPISpanSeconds, BFCallableAPICount, RateLimitModeWait),
+		orderRateLimiter:          NewRateLimiter(BFCallableAPISpanSeconds, BFCallableOrderAPICount, RateLimitModeWait),
+		retryPolicy:               DefaultRetryPolicy(),
+		acceptedOrders:            newAcceptedOrders(),
+		options:                   clientOptions,
 	}
 }
 
@@ -627,210 +1005,617 @@ type RealAPIClient struct {
 	endpoint                  string
 	wsClient                  *client.WSClient
 	apiClient                 *APIClient
-	realtimeChannel           *realtime.RealtimeChannel
+	realtimeChannels          map[string]*realtime.RealtimeChannel
+	subscribed                map[string]bool
+	mutex                     *sync.Mutex
+	requestChan               chan *realtime.JsonRPC2Subscribe
+	// wakes the writer of the session to subscribe new channels
+	writeChan                 chan int
+	authenticator             Authenticator
+	authState                 int
+	authId                    int64
+	options                   *clientOptions
+	boardResyncCallback       realtime.BoardResyncCallback
+	boardMidPriceTolerance    float64
+	transport                 realtime.Transport
+	currentSession            realtime.Session
+	sessionConn               *websocket.Conn
+	writeFinishChan           chan int
+	writeDoneChan             chan int
+	// cancels the connection, which is owned by the client and not by a channel
+	connCancel                context.CancelFunc
+	// replays recorded messages instead of connecting
+	player                    *realtime.Player
+	// timers of rest api resyncs of stale merged boards, and boards fetched by them which are not applied yet
+	boardResyncTimers         map[*realtime.RealtimeChannel]*time.Timer
+	restBoards                map[*realtime.RealtimeChannel]*public.GetBoardResponse
 }
 
-func (c *RealAPIClient) RealBoardSnapshotCallback(conn *websocket.Conn, calbackData interface{}) (error) {
-	rc := (calbackData).(*realtime.RealtimeChannel)
-	select {
-	case d := <-rc.UnsubscribeChan:
-		conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
-		err := conn.WriteJSON(d)
-		if err != nil {
-			atomic.StoreUint32(&rc.Subscribed, 0)
-			return errors.Wrapf(err, "can not write unsubscribed")
-		}
+const (
+	// a stale merged board falls back to the rest api when no snapshot arrives within this time
+	boardResyncTimeout            time.Duration = 5 * time.Second
+	defaultBoardMidPriceTolerance float64       = 1e-9
+)
+
+const (
+	realtimeAuthStateNone    int = 0
+	realtimeAuthStateWaiting int = 1
+	realtimeAuthStateDone    int = 2
+	realtimeAuthStateFailed  int = 3
+)
+
+// SetBoardResyncCallback sets the callback called when a merged board is resynchronized.
+// The callback data of the board channel is passed.
+func (c *RealAPIClient) SetBoardResyncCallback(callback realtime.BoardResyncCallback) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.boardResyncCallback = callback
+}
+
+// SetBoardMidPriceTolerance sets the relative difference allowed between the mid price of a merged board
+// and mid_price of the diff. A negative tolerance disables the check.
+func (c *RealAPIClient) SetBoardMidPriceTolerance(tolerance float64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.boardMidPriceTolerance = tolerance
+}
+
+func (c *RealAPIClient) resetSubscribed() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.subscribed = make(map[string]bool)
+	c.authState = realtimeAuthStateNone
+	for _, rc := range c.realtimeChannels {
 		atomic.StoreUint32(&rc.Subscribed, 0)
-		return nil
+		if rc.Merge {
+			// wait for new snapshot
+			if rc.OrderBook != nil && !rc.Stale {
+				rc.Stale = true
+				rc.StaleReason = realtime.BoardResyncReasonReconnect
+				rc.StaleSince = time.Now()
+				c.scheduleBoardResync(rc)
+			}
+			rc.OrderBook = nil
+		}
+	}
+}
+
+// session returns the transport session of conn, a new session is started for a new connection.
+func (c *RealAPIClient) session(conn *websocket.Conn) (realtime.Session, error) {
+	if c.sessionConn == conn {
+		return c.currentSession, nil
+	}
+	c.closeSession()
+	session, err := c.transport.NewSession(conn)
+	if err != nil {
+		return nil, errors.Wrapf(err, "can not start realtime session")
+	}
+	c.currentSession = session
+	c.sessionConn = conn
+	c.writeFinishChan = make(chan int)
+	c.writeDoneChan = make(chan int)
+	go c.writeLoop(session, conn, c.writeFinishChan, c.writeDoneChan)
+	// subscribe all channels of the new connection
+	c.signalWrite()
+	return session, nil
+}
+
+func (c *RealAPIClient) closeSession() {
+	if c.currentSession == nil {
+		return
+	}
+	close(c.writeFinishChan)
+	<-c.writeDoneChan
+	c.currentSession.Close()
+	c.currentSession = nil
+	c.sessionConn = nil
+}
+
+func (c *RealAPIClient) signalWrite() {
+	select {
+	case c.writeChan <- 1:
 	default:
-		if atomic.LoadUint32(&rc.Subscribed) == 0 {
-			conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
-			err := conn.WriteJSON(&realtime.JsonRPC2Subscribe{
-					JsonRpc: "2.0",
-					Method:  "subscribe",
-					Params:  realtime.JsonRPC2SubscribeParams{
-						Channel: "lightning_board_snapshot_" + string(rc.ProductCode),
-					},
-				})
+	}
+}
+
+// writeLoop writes subscribe, unsubscribe and auth as soon as they are requested, while the callback is blocked
+// in reading a message. The connection is closed on an error of writing, and the callback reconnects.
+func (c *RealAPIClient) writeLoop(session realtime.Session, conn *websocket.Conn, finishChan chan int, doneChan chan int) {
+	defer close(doneChan)
+	for {
+		select {
+		case <-finishChan:
+			return
+		case d := <-c.requestChan:
+			err := session.WriteRequest(d.Method, d.Params.Channel)
 			if err != nil {
-				atomic.StoreUint32(&rc.Subscribed, 0)
-				return errors.Wrapf(err, "can not write subscribe")
+				log.Printf("can not write %v (channel = %v, reason = %v)", d.Method, d.Params.Channel, err)
+				conn.Close()
+				return
 			}
-			atomic.StoreUint32(&rc.Subscribed, 1)
-		} else {
-			notify := new(realtime.JsonRPC2BoardSnapshotNotify)
-			conn.SetReadDeadline(time.Now().Add(time.Minute))
-			err := conn.ReadJSON(notify);
+		case <-c.writeChan:
+			err := c.subscribeAll(session)
 			if err != nil {
-				atomic.StoreUint32(&rc.Subscribed, 0)
-				return errors.Wrapf(err, "can not read message")
+				log.Printf("can not subscribe (reason = %v)", err)
+				conn.Close()
+				return
 			}
-			rc.BoardSnapshotCallback(rc.ProductCode, notify.Params.Message, rc.CallbackData)
 		}
-		return nil
 	}
 }
 
-func (c *RealAPIClient) RealBoardSnapshotStart(productCode types.ProductCode, callback realtime.BoardSnapshotCallback, callbackData interface{}) (error){
-	if c.realtimeChannel != nil {
-		return errors.Errorf("already exists realtime api connection")
-	}
-	wsRequest := &client.WSRequest {
-		URL: c.endpoint,
-		Headers: make(map[string]string),
-	}
-	rc := &realtime.RealtimeChannel{
-		ProductCode:           productCode,
-		RealtimeType:          types.RealtimeTypeBoardSnapshot,
-                BoardSnapshotCallback: callback,
-                BoardCallback:         nil,
-                TickerCallback:        nil,
-                ExecutionsCallback:    nil,
-		CallbackData:          callbackData,
-		Subscribed:            0,
-		UnsubscribeChan:       make(chan *realtime.JsonRPC2Subscribe),
-		Merge:                 false,
-		GetBoardResponseFull:  nil,
-	}
-	err := c.wsClient.Start(wsRequest, c.RealBoardSnapshotCallback, rc)
+func (c *RealAPIClient) newNonce() (string, error) {
+	nonce := make([]byte, 16)
+	_, err := rand.Read(nonce)
 	if err != nil {
-		return errors.Wrapf(err, "can not connect realtime api")
+		return "", errors.Wrapf(err, "can not create nonce")
 	}
-	c.realtimeChannel = rc
-	return nil
+	return hex.EncodeToString(nonce), nil
+}
+
+func (c *RealAPIClient) writeAuth(session realtime.Session) (error) {
+	realtimeAuthenticator, ok := c.authenticator.(RealtimeAuthenticator)
+	if !ok {
+		return errors.Errorf("authenticator can not sign realtime auth")
+	}
+	nonce, err := c.newNonce()
+	if err != nil {
+		return err
+	}
+	c.mutex.Lock()
+	c.authId += 1
+	authId := c.authId
+	params := new(realtime.JsonRPC2AuthParams)
+	realtimeAuthenticator.SetRealtimeAuthParams(params, time.Now(), nonce)
+	c.authState = realtimeAuthStateWaiting
+	c.mutex.Unlock()
+	return session.WriteAuth(params, authId)
 }
 
-func (c *RealAPIClient) realBoardCallbackMerge(rc *realtime.RealtimeChannel, getBoardResponseDiff *public.GetBoardResponse) (*public.GetBoardResponse) {
-	rc.GetBoardResponseFull.MidPrice = getBoardResponseDiff.MidPrice
-	for _, diffAsk := range getBoardResponseDiff.Asks {
-		if diffAsk.Price == 0 {
+func (c *RealAPIClient) handleResponse(notify *realtime.JsonRPC2Notify) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if notify.Id != c.authId || c.authState != realtimeAuthStateWaiting {
+		return
+	}
+	if notify.Error == nil && string(notify.Result) == "true" {
+		c.authState = realtimeAuthStateDone
+		// subscribe private channels
+		c.signalWrite()
+		return
+	}
+	// do not retry with the same key, private channels stay unsubscribed
+	c.authState = realtimeAuthStateFailed
+	if notify.Error != nil {
+		log.Printf("can not authenticate realtime api (code = %v, message = %v)", notify.Error.Code, notify.Error.Message)
+	} else {
+		log.Printf("can not authenticate realtime api (result = %v)", string(notify.Result))
+	}
+}
+
+func (c *RealAPIClient) subscribeAll(session realtime.Session) (error) {
+	c.mutex.Lock()
+	channels := make([]string, 0)
+	needAuth := false
+	for channel, rc := range c.realtimeChannels {
+		if c.subscribed[channel] {
 			continue
 		}
-		if diffAsk.Size == 0 {
-			for i := 0; i < len(rc.GetBoardResponseFull.Asks); i+= 1 {
-				if rc.GetBoardResponseFull.Asks[i].Price == diffAsk.Price {
-					rc.GetBoardResponseFull.Asks = append(rc.GetBoardResponseFull.Asks[:i], rc.GetBoardResponseFull.Asks[i+1:]...)
-					break
-				}
-			}
-		} else  {
-			var i int
-			for i = 0; i < len(rc.GetBoardResponseFull.Asks); i+= 1 {
-				if rc.GetBoardResponseFull.Asks[i].Price == diffAsk.Price {
-					rc.GetBoardResponseFull.Asks[i].Size = diffAsk.Size
-					break
-				}
-			}
-			if i == len(rc.GetBoardResponseFull.Asks) {
-				rc.GetBoardResponseFull.Asks = append(rc.GetBoardResponseFull.Asks, diffAsk)
-			}
+		if rc.Private && c.authState != realtimeAuthStateDone {
+			needAuth = needAuth || c.authState == realtimeAuthStateNone
+			continue
 		}
+		channels = append(channels, channel)
 	}
-	for _, diffBid := range getBoardResponseDiff.Bids {
-		if diffBid.Price == 0 {
-			continue
+	c.mutex.Unlock()
+	if needAuth {
+		err := c.writeAuth(session)
+		if err != nil {
+			return errors.Wrapf(err, "can not write auth")
 		}
-		if diffBid.Size == 0 {
-			for i := 0; i < len(rc.GetBoardResponseFull.Bids); i+= 1 {
-				if rc.GetBoardResponseFull.Bids[i].Price == diffBid.Price {
-					rc.GetBoardResponseFull.Bids = append(rc.GetBoardResponseFull.Bids[:i], rc.GetBoardResponseFull.Bids[i+1:]...)
-					break
-				}
-			}
-		} else  {
-			var i int
-			for i = 0; i < len(rc.GetBoardResponseFull.Bids); i+= 1 {
-				if rc.GetBoardResponseFull.Bids[i].Price == diffBid.Price {
-					rc.GetBoardResponseFull.Bids[i].Size = diffBid.Size
-					break
-				}
-			}
-			if i == len(rc.GetBoardResponseFull.Bids) {
-				rc.GetBoardResponseFull.Bids = append(rc.GetBoardResponseFull.Bids, diffBid)
-			}
+	}
+	for _, channel := range channels {
+		err := session.WriteRequest("subscribe", channel)
+		if err != nil {
+			return errors.Wrapf(err, "can not write subscribe (channel = %v)", channel)
 		}
+		c.mutex.Lock()
+		rc, ok := c.realtimeChannels[channel]
+		if ok {
+			c.subscribed[channel] = true
+			atomic.StoreUint32(&rc.Subscribed, 1)
+		}
+		c.mutex.Unlock()
 	}
-	sort.Slice(rc.GetBoardResponseFull.Asks, func (i int, j int) bool {
-		return rc.GetBoardResponseFull.Asks[i].Price < rc.GetBoardResponseFull.Asks[j].Price
-	})
-	sort.Slice(rc.GetBoardResponseFull.Bids, func (i int, j int) bool {
-		return rc.GetBoardResponseFull.Bids[i].Price > rc.GetBoardResponseFull.Bids[j].Price
+	return nil
+}
+
+func (c *RealAPIClient) realBoardCallbackMerge(rc *realtime.RealtimeChannel, snapshot *orderbook.Snapshot) {
+	if rc.OrderBookCallback != nil {
+		rc.OrderBookCallback(rc.ProductCode, snapshot, rc.CallbackData)
+		return
+	}
+	rc.BoardCallback(rc.ProductCode, snapshot.GetBoardResponse(), rc.CallbackData)
+}
+
+func (c *RealAPIClient) checkBoard(snapshot *orderbook.Snapshot, midPrice float64) (realtime.BoardResyncReason, bool) {
+	bestBid, okBid := snapshot.BestBid()
+	bestAsk, okAsk := snapshot.BestAsk()
+	if !okBid || !okAsk {
+		return "", true
+	}
+	if bestBid.Price >= bestAsk.Price {
+		return realtime.BoardResyncReasonCrossed, false
+	}
+	c.mutex.Lock()
+	tolerance := c.boardMidPriceTolerance
+	c.mutex.Unlock()
+	if tolerance < 0 || midPrice == 0 {
+		return "", true
+	}
+	if math.Abs((bestBid.Price + bestAsk.Price) / 2 - midPrice) > midPrice * tolerance {
+		return realtime.BoardResyncReasonMidPrice, false
+	}
+	return "", true
+}
+
+// markBoardStale stops the callbacks of the merged board and requests a new snapshot by subscribing again.
+func (c *RealAPIClient) markBoardStale(rc *realtime.RealtimeChannel, reason realtime.BoardResyncReason, now time.Time) {
+	rc.Stale = true
+	rc.StaleReason = reason
+	rc.StaleSince = now
+	if c.player != nil {
+		// a replay can not subscribe, the merged board resumes with a recorded snapshot
+		return
+	}
+	c.mutex.Lock()
+	c.scheduleBoardResync(rc)
+	c.mutex.Unlock()
+	channel := realtime.ChannelName(types.RealtimeTypeBoardSnapshot, rc.ProductCode)
+	for _, method := range []string{"unsubscribe", "subscribe"} {
+		select {
+		case c.requestChan <- &realtime.JsonRPC2Subscribe{
+			JsonRpc: "2.0",
+			Method:  method,
+			Params:  realtime.JsonRPC2SubscribeParams{
+				Channel: channel,
+			},
+		}:
+		default:
+			log.Printf("can not queue %v (channel = %v)", method, channel)
+		}
+	}
+}
+
+func (c *RealAPIClient) boardResynced(rc *realtime.RealtimeChannel, source realtime.BoardResyncSource, now time.Time) {
+	if !rc.Stale {
+		return
+	}
+	boardResync := &realtime.BoardResync{
+		Reason:     rc.StaleReason,
+		Source:     source,
+		StaleSince: rc.StaleSince,
+		ResyncedAt: now,
+	}
+	rc.Stale = false
+	rc.StaleReason = ""
+	c.mutex.Lock()
+	callback := c.boardResyncCallback
+	c.mutex.Unlock()
+	if callback != nil {
+		callback(rc.ProductCode, boardResync, rc.CallbackData)
+	}
+}
+
+// scheduleBoardResync fetches the board of a stale merged board by the rest api after boardResyncTimeout, unless
+// a snapshot arrives before. The request runs on the timer, so that messages are read meanwhile. c.mutex must be held.
+func (c *RealAPIClient) scheduleBoardResync(rc *realtime.RealtimeChannel) {
+	if c.apiClient == nil || c.player != nil {
+		return
+	}
+	if _, ok := c.boardResyncTimers[rc]; ok {
+		return
+	}
+	c.boardResyncTimers[rc] = time.AfterFunc(boardResyncTimeout, func() {
+		c.resyncBoardByREST(rc)
 	})
-	return rc.GetBoardResponseFull.Clone()
 }
 
-func (c *RealAPIClient) realBoardCallback(conn *websocket.Conn, calbackData interface{}) (error) {
-	rc := (calbackData).(*realtime.RealtimeChannel)
-	select {
-	case d := <-rc.UnsubscribeChan:
-		conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
-		err := conn.WriteJSON(d)
-		if err != nil {
-			atomic.StoreUint32(&rc.Subscribed, 0)
-			return errors.Wrapf(err, "can not write unsubscribed")
+// cancelBoardResync stops the rest api resync of a merged board. c.mutex must be held.
+func (c *RealAPIClient) cancelBoardResync(rc *realtime.RealtimeChannel) {
+	if timer, ok := c.boardResyncTimers[rc]; ok {
+		timer.Stop()
+		delete(c.boardResyncTimers, rc)
+	}
+	delete(c.restBoards, rc)
+}
+
+// resyncBoardByREST runs on the timer of scheduleBoardResync. The board is applied by the next board message.
+func (c *RealAPIClient) resyncBoardByREST(rc *realtime.RealtimeChannel) {
+	ctx, cancel := context.WithTimeout(context.Background(), boardResyncTimeout)
+	defer cancel()
+	_, getBoardResponse, err := c.apiClient.PubGetBoardCtx(ctx, rc.ProductCode)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	timer, ok := c.boardResyncTimers[rc]
+	if !ok {
+		// resynced by a snapshot or unsubscribed meanwhile
+		return
+	}
+	if err != nil {
+		log.Printf("can not get board (product code = %v, reason = %v)", rc.ProductCode, err)
+		// try again after the timeout
+		timer.Reset(boardResyncTimeout)
+		return
+	}
+	delete(c.boardResyncTimers, rc)
+	c.restBoards[rc] = getBoardResponse
+}
+
+func (c *RealAPIClient) takeRESTBoard(rc *realtime.RealtimeChannel) (*public.GetBoardResponse, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	getBoardResponse, ok := c.restBoards[rc]
+	delete(c.restBoards, rc)
+	return getBoardResponse, ok
+}
+
+// realBoardMerge merges a board message received at now. A replay measures staleness with the recorded times and never
+// uses the rest api, whose board is not of the recorded time.
+func (c *RealAPIClient) realBoardMerge(rc *realtime.RealtimeChannel, channel string, getBoardResponse *public.GetBoardResponse, now time.Time) {
+	if strings.HasPrefix(channel, "lightning_board_snapshot_") {
+		if rc.OrderBook == nil {
+			rc.OrderBook = orderbook.NewBook()
 		}
-		atomic.StoreUint32(&rc.Subscribed, 0)
+		snapshot := rc.OrderBook.Reset(getBoardResponse)
+		c.mutex.Lock()
+		c.cancelBoardResync(rc)
+		c.mutex.Unlock()
+		c.boardResynced(rc, realtime.BoardResyncSourceSnapshot, now)
+		c.realBoardCallbackMerge(rc, snapshot)
+		return
+	}
+	if restBoard, ok := c.takeRESTBoard(rc); ok && rc.Stale {
+		if rc.OrderBook == nil {
+			rc.OrderBook = orderbook.NewBook()
+		}
+		rc.OrderBook.Reset(restBoard)
+		c.boardResynced(rc, realtime.BoardResyncSourceREST, now)
+	}
+	if rc.OrderBook == nil {
+		return
+	}
+	snapshot := rc.OrderBook.Apply(getBoardResponse)
+	if rc.Stale {
+		return
+	}
+	if reason, ok := c.checkBoard(snapshot, getBoardResponse.MidPrice); !ok {
+		log.Printf("merged board is stale (product code = %v, reason = %v)", rc.ProductCode, reason)
+		c.markBoardStale(rc, reason, now)
+		return
+	}
+	c.realBoardCallbackMerge(rc, snapshot)
+}
+
+func (c *RealAPIClient) dispatch(channel string, message json.RawMessage, now time.Time) (error) {
+	c.mutex.Lock()
+	rc, ok := c.realtimeChannels[channel]
+	c.mutex.Unlock()
+	if !ok {
+		// already unsubscribed
 		return nil
-	default:
-		if atomic.LoadUint32(&rc.Subscribed) == 0 {
-			if rc.Merge {
-				conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
-				err := conn.WriteJSON(&realtime.JsonRPC2Subscribe{
-						JsonRpc: "2.0",
-						Method: "subscribe",
-						Params: realtime.JsonRPC2SubscribeParams{
-							Channel: "lightning_board_snapshot_" + string(rc.ProductCode),
-						},
-					})
-				if err != nil {
-					atomic.StoreUint32(&rc.Subscribed, 0)
-					return errors.Wrapf(err, "can not write subscribe")
-				}
-			}
-			conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
-			err := conn.WriteJSON(&realtime.JsonRPC2Subscribe{
-					JsonRpc: "2.0",
-					Method: "subscribe",
-					Params: realtime.JsonRPC2SubscribeParams{
-						Channel: "lightning_board_" + string(rc.ProductCode),
-					},
-				})
-			if err != nil {
-				return errors.Wrapf(err, "can not write subscribe")
-			}
-			atomic.StoreUint32(&rc.Subscribed, 1)
+	}
+	switch rc.RealtimeType {
+	case types.RealtimeTypeBoardSnapshot:
+		getBoardResponse := new(public.GetBoardResponse)
+		err := json.Unmarshal(message, getBoardResponse)
+		if err != nil {
+			return errors.Wrapf(err, "can not unmarshal board snapshot (channel = %v)", channel)
+		}
+		rc.BoardSnapshotCallback(rc.ProductCode, getBoardResponse, rc.CallbackData)
+	case types.RealtimeTypeBoard:
+		getBoardResponse := new(public.GetBoardResponse)
+		err := json.Unmarshal(message, getBoardResponse)
+		if err != nil {
+			return errors.Wrapf(err, "can not unmarshal board (channel = %v)", channel)
+		}
+		if rc.Merge {
+			c.realBoardMerge(rc, channel, getBoardResponse, now)
 		} else {
-			notify := new(realtime.JsonRPC2BoardNotify)
-			conn.SetReadDeadline(time.Now().Add(time.Minute))
-			err := conn.ReadJSON(notify);
-			if err != nil {
-				atomic.StoreUint32(&rc.Subscribed, 0)
-				return errors.Wrapf(err, "can not read message")
-			}
-			if rc.Merge {
-				if strings.Contains(notify.Params.Channel, "lightning_board_snapshot") {
-					rc.GetBoardResponseFull = notify.Params.Message
-					rc.BoardCallback(rc.ProductCode, notify.Params.Message, rc.CallbackData)
-				} else if rc.GetBoardResponseFull != nil {
-					getBoardResponse := c.realBoardCallbackMerge(rc, notify.Params.Message)
-					rc.BoardCallback(rc.ProductCode, getBoardResponse, rc.CallbackData)
-				}
-			} else {
-				rc.BoardCallback(rc.ProductCode, notify.Params.Message, rc.CallbackData)
-			}
+			rc.BoardCallback(rc.ProductCode, getBoardResponse, rc.CallbackData)
+		}
+	case types.RealtimeTypeTicker:
+		getTickerResponse := new(public.GetTickerResponse)
+		err := json.Unmarshal(message, getTickerResponse)
+		if err != nil {
+			return errors.Wrapf(err, "can not unmarshal ticker (channel = %v)", channel)
+		}
+		rc.TickerCallback(rc.ProductCode, getTickerResponse, rc.CallbackData)
+	case types.RealtimeTypeExecutions:
+		getExecutionsResponse := make(public.GetExecutionsResponse, 0)
+		err := json.Unmarshal(message, &getExecutionsResponse)
+		if err != nil {
+			return errors.Wrapf(err, "can not unmarshal executions (channel = %v)", channel)
+		}
+		rc.ExecutionsCallback(rc.ProductCode, getExecutionsResponse, rc.CallbackData)
+	case types.RealtimeTypeChildOrderEvents:
+		childOrderEvents := make(realtime.ChildOrderEvents, 0)
+		err := json.Unmarshal(message, &childOrderEvents)
+		if err != nil {
+			return errors.Wrapf(err, "can not unmarshal child order events (channel = %v)", channel)
 		}
+		rc.ChildOrderEventsCallback(childOrderEvents, rc.CallbackData)
+	case types.RealtimeTypeParentOrderEvents:
+		parentOrderEvents := make(realtime.ParentOrderEvents, 0)
+		err := json.Unmarshal(message, &parentOrderEvents)
+		if err != nil {
+			return errors.Wrapf(err, "can not unmarshal parent order events (channel = %v)", channel)
+		}
+		rc.ParentOrderEventsCallback(parentOrderEvents, rc.CallbackData)
+	}
+	return nil
+}
+
+func (c *RealAPIClient) realCallback(conn *websocket.Conn, callbackData interface{}) (error) {
+	session, err := c.session(conn)
+	if err != nil {
+		c.resetSubscribed()
+		return err
+	}
+	notify, err := session.ReadMessage()
+	if err != nil {
+		c.closeSession()
+		c.resetSubscribed()
+		return err
+	}
+	if notify.Params == nil {
+		c.handleResponse(notify)
 		return nil
 	}
+	now := time.Now()
+	if c.options.recorder != nil {
+		err := c.options.recorder.Record(now, notify)
+		if err != nil {
+			log.Printf("can not record message (reason = %v)", err)
+		}
+	}
+	err = c.dispatch(notify.Params.Channel, notify.Params.Message, now)
+	if err != nil {
+		log.Printf("can not dispatch message (reason = %v)", err)
+	}
+	return nil
+}
+
+func (c *RealAPIClient) addRealtimeChannel(ctx context.Context, rc *realtime.RealtimeChannel, channels ...string) (error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if _, ok := c.authenticator.(RealtimeAuthenticator); rc.Private && !ok && c.player == nil {
+		return errors.Errorf("no realtime authenticator for private channel")
+	}
+	for _, channel := range channels {
+		if _, ok := c.realtimeChannels[channel]; ok {
+			return errors.Errorf("already subscribed channel (channel = %v)", channel)
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return errors.Wrapf(err, "can not start realtime channel")
+	}
+	rc.FinishChan = make(chan int)
+	for _, channel := range channels {
+		c.realtimeChannels[channel] = rc
+	}
+	if c.player == nil && !c.wsClient.IsStarted() {
+		// the connection is shared by channels, so the context of a channel does not control it
+		wsRequest := &client.WSRequest {
+			URL: c.endpoint,
+			Headers: make(map[string]string),
+		}
+		c.options.setHeaders(wsRequest.Headers)
+		c.subscribed = make(map[string]bool)
+		c.authState = realtimeAuthStateNone
+		connCtx, connCancel := context.WithCancel(context.Background())
+		err := c.wsClient.StartCtx(connCtx, wsRequest, c.realCallback, nil)
+		if err != nil {
+			connCancel()
+			for _, channel := range channels {
+				delete(c.realtimeChannels, channel)
+			}
+			return errors.Wrapf(err, "can not connect realtime api")
+		}
+		c.connCancel = connCancel
+	}
+	c.signalWrite()
+	c.watchChannel(ctx, rc)
+	return nil
+}
+
+// watchChannel unsubscribes only the channel when its context is done.
+func (c *RealAPIClient) watchChannel(ctx context.Context, rc *realtime.RealtimeChannel) {
+	if ctx.Done() == nil {
+		return
+	}
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.unsubscribeChannel(rc)
+		case <-rc.FinishChan:
+		}
+	}()
+}
+
+// unsubscribeChannel removes rc, and closes the connection when no channel remains.
+func (c *RealAPIClient) unsubscribeChannel(rc *realtime.RealtimeChannel) (bool) {
+	c.mutex.Lock()
+	found := false
+	for _, v := range c.realtimeChannels {
+		if v == rc {
+			found = true
+			break
+		}
+	}
+	if !found {
+		c.mutex.Unlock()
+		return false
+	}
+	c.removeRealtimeChannel(rc)
+	remain := len(c.realtimeChannels)
+	c.mutex.Unlock()
+	if remain == 0 {
+		c.stopConnection()
+	}
+	return true
 }
 
-func (c *RealAPIClient) RealBoardStart(productCode types.ProductCode, callback realtime.BoardCallback, callbackData interface{}, merge bool) (error){
-	if c.realtimeChannel != nil {
-		return errors.Errorf("already exists realtime api connection")
+func (c *RealAPIClient) stopConnection() {
+	c.mutex.Lock()
+	connCancel := c.connCancel
+	c.connCancel = nil
+	c.mutex.Unlock()
+	if c.player != nil {
+		return
+	}
+	if connCancel != nil {
+		connCancel()
+	}
+	if c.wsClient.IsStarted() {
+		c.wsClient.Stop()
 	}
-	wsRequest := &client.WSRequest {
-		URL: c.endpoint,
-		Headers: make(map[string]string),
+	if connCancel != nil {
+		// the callback is not called any more, stop the writer if the callback did not
+		c.closeSession()
 	}
+}
+
+func (c *RealAPIClient) RealBoardSnapshotStart(productCode types.ProductCode, callback realtime.BoardSnapshotCallback, callbackData interface{}) (error) {
+	return c.RealBoardSnapshotStartCtx(context.Background(), productCode, callback, callbackData)
+}
+
+func (c *RealAPIClient) RealBoardSnapshotStartCtx(ctx context.Context, productCode types.ProductCode, callback realtime.BoardSnapshotCallback, callbackData interface{}) (error) {
+	rc := &realtime.RealtimeChannel{
+		ProductCode:           productCode,
+		RealtimeType:          types.RealtimeTypeBoardSnapshot,
+                BoardSnapshotCallback: callback,
+                BoardCallback:         nil,
+                TickerCallback:        nil,
+                ExecutionsCallback:    nil,
+		CallbackData:          callbackData,
+		Subscribed:            0,
+		Merge:                 false,
+		OrderBook:             nil,
+	}
+	return c.addRealtimeChannel(ctx, rc, realtime.ChannelName(types.RealtimeTypeBoardSnapshot, productCode))
+}
+
+func (c *RealAPIClient) RealBoardStart(productCode types.ProductCode, callback realtime.BoardCallback, callbackData interface{}, merge bool) (error) {
+	return c.RealBoardStartCtx(context.Background(), productCode, callback, callbackData, merge)
+}
+
+// RealBoardStartCtx subscribes board diffs. With merge, diffs are merged into the latest snapshot and a crossed book
+// or a mid price disagreeing with the diff marks the board stale. A stale board is not passed to the callback until
+// a new snapshot arrives, or the rest api is used after a while when the client has WithHTTPClient.
+// The merged board passed to the callback is rebuilt from the whole book on every diff, which costs O(depth) per
+// message; use RealOrderBookStart, whose snapshots share the book, when only a part of the board is read.
+func (c *RealAPIClient) RealBoardStartCtx(ctx context.Context, productCode types.ProductCode, callback realtime.BoardCallback, callbackData interface{}, merge bool) (error) {
 	rc := &realtime.RealtimeChannel{
 		ProductCode:           productCode,
 		RealtimeType:          types.RealtimeTypeBoard,
@@ -840,67 +1625,43 @@ func (c *RealAPIClient) RealBoardStart(productCode types.ProductCode, callback r
                 ExecutionsCallback:    nil,
 		CallbackData:          callbackData,
 		Subscribed:            0,
-		UnsubscribeChan:       make(chan *realtime.JsonRPC2Subscribe),
 		Merge:                 merge,
-		GetBoardResponseFull:  nil,
+		OrderBook:             nil,
 	}
-	err := c.wsClient.Start(wsRequest, c.realBoardCallback, rc)
-	if err != nil {
-		return errors.Wrapf(err, "can not connect realtime api")
+	if merge {
+		return c.addRealtimeChannel(ctx, rc,
+			realtime.ChannelName(types.RealtimeTypeBoardSnapshot, productCode),
+			realtime.ChannelName(types.RealtimeTypeBoard, productCode))
 	}
-	c.realtimeChannel = rc
-	return nil
+	return c.addRealtimeChannel(ctx, rc, realtime.ChannelName(types.RealtimeTypeBoard, productCode))
 }
 
-func (c *RealAPIClient) realTickerCallback(conn *websocket.Conn, calbackData interface{}) (error) {
-	rc := (calbackData).(*realtime.RealtimeChannel)
-	select {
-	case d := <-rc.UnsubscribeChan:
-		conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
-		err := conn.WriteJSON(d)
-		if err != nil {
-			atomic.StoreUint32(&rc.Subscribed, 0)
-			return errors.Wrapf(err, "can not write unsubscribed")
-		}
-		atomic.StoreUint32(&rc.Subscribed, 0)
-		return nil
-	default:
-		if atomic.LoadUint32(&rc.Subscribed) == 0 {
-			conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
-			err := conn.WriteJSON(&realtime.JsonRPC2Subscribe{
-					JsonRpc: "2.0",
-					Method: "subscribe",
-					Params: realtime.JsonRPC2SubscribeParams{
-						Channel: "lightning_ticker_" + string(rc.ProductCode),
-					},
-				})
-			if err != nil {
-				atomic.StoreUint32(&rc.Subscribed, 0)
-				return errors.Wrapf(err, "can not write subscribe")
-			}
-			atomic.StoreUint32(&rc.Subscribed, 1)
-		} else {
-			notify := new(realtime.JsonRPC2TickerNotify)
-			conn.SetReadDeadline(time.Now().Add(time.Minute))
-			err := conn.ReadJSON(notify);
-			if err != nil {
-				atomic.StoreUint32(&rc.Subscribed, 0)
-				return errors.Wrapf(err, "can not read message")
-			}
-			rc.TickerCallback(rc.ProductCode, notify.Params.Message, rc.CallbackData)
-		}
-		return nil
-	}
+// RealOrderBookStart maintains the merged board of productCode and passes an immutable snapshot on every update.
+// It can be stopped by RealUnsubscribe with types.RealtimeTypeBoard.
+func (c *RealAPIClient) RealOrderBookStart(productCode types.ProductCode, callback realtime.OrderBookCallback, callbackData interface{}) (error) {
+	return c.RealOrderBookStartCtx(context.Background(), productCode, callback, callbackData)
 }
 
-func (c *RealAPIClient) RealTickerStart(productCode types.ProductCode, callback realtime.TickerCallback, callbackData interface{}) (error){
-	if c.realtimeChannel != nil {
-		return errors.Errorf("already exists realtime api connection")
-	}
-	wsRequest := &client.WSRequest {
-		URL: c.endpoint,
-		Headers: make(map[string]string),
+func (c *RealAPIClient) RealOrderBookStartCtx(ctx context.Context, productCode types.ProductCode, callback realtime.OrderBookCallback, callbackData interface{}) (error) {
+	rc := &realtime.RealtimeChannel{
+		ProductCode:           productCode,
+		RealtimeType:          types.RealtimeTypeBoard,
+		OrderBookCallback:     callback,
+		CallbackData:          callbackData,
+		Subscribed:            0,
+		Merge:                 true,
+		OrderBook:             nil,
 	}
+	return c.addRealtimeChannel(ctx, rc,
+		realtime.ChannelName(types.RealtimeTypeBoardSnapshot, productCode),
+		realtime.ChannelName(types.RealtimeTypeBoard, productCode))
+}
+
+func (c *RealAPIClient) RealTickerStart(productCode types.ProductCode, callback realtime.TickerCallback, callbackData interface{}) (error) {
+	return c.RealTickerStartCtx(context.Background(), productCode, callback, callbackData)
+}
+
+func (c *RealAPIClient) RealTickerStartCtx(ctx context.Context, productCode types.ProductCode, callback realtime.TickerCallback, callbackData interface{}) (error) {
 	rc := &realtime.RealtimeChannel{
 		ProductCode:           productCode,
 		RealtimeType:          types.RealtimeTypeTicker,
@@ -910,67 +1671,17 @@ func (c *RealAPIClient) RealTickerStart(productCode types.ProductCode, callback
                 ExecutionsCallback:    nil,
 		CallbackData:          callbackData,
 		Subscribed:            0,
-		UnsubscribeChan:       make(chan *realtime.JsonRPC2Subscribe),
 		Merge:                 false,
-		GetBoardResponseFull:  nil,
+		OrderBook:             nil,
 	}
-	err := c.wsClient.Start(wsRequest, c.realTickerCallback, rc)
-	if err != nil {
-		return errors.Wrapf(err, "can not connect realtime api")
-	}
-	c.realtimeChannel = rc
-	return nil
+	return c.addRealtimeChannel(ctx, rc, realtime.ChannelName(types.RealtimeTypeTicker, productCode))
 }
 
-func (c *RealAPIClient) realExecutionsCallback(conn *websocket.Conn, calbackData interface{}) (error) {
-	rc := (calbackData).(*realtime.RealtimeChannel)
-	select {
-	case d := <-rc.UnsubscribeChan:
-		conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
-		err := conn.WriteJSON(d)
-		if err != nil {
-			atomic.StoreUint32(&rc.Subscribed, 0)
-			return errors.Wrapf(err, "can not write unsubscribed")
-		}
-		atomic.StoreUint32(&rc.Subscribed, 0)
-		return nil
-	default:
-		if atomic.LoadUint32(&rc.Subscribed) == 0 {
-			conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
-			err := conn.WriteJSON(&realtime.JsonRPC2Subscribe{
-					JsonRpc: "2.0",
-					Method: "subscribe",
-					Params: realtime.JsonRPC2SubscribeParams{
-						Channel: "lightning_executions_" + string(rc.ProductCode),
-					},
-				})
-			if err != nil {
-				atomic.StoreUint32(&rc.Subscribed, 0)
-				return errors.Wrapf(err, "can not write subscribe")
-			}
-			atomic.StoreUint32(&rc.Subscribed, 1)
-		} else {
-			notify := new(realtime.JsonRPC2ExecutionsNotify)
-			conn.SetReadDeadline(time.Now().Add(time.Minute))
-			err := conn.ReadJSON(notify);
-			if err != nil {
-				atomic.StoreUint32(&rc.Subscribed, 0)
-				return errors.Wrapf(err, "can not read message")
-			}
-			rc.ExecutionsCallback(rc.ProductCode, notify.Params.Message, rc.CallbackData)
-		}
-		return nil
-	}
+func (c *RealAPIClient) RealExecutionsStart(productCode types.ProductCode, callback realtime.ExecutionsCallback, callbackData interface{}) (error) {
+	return c.RealExecutionsStartCtx(context.Background(), productCode, callback, callbackData)
 }
 
-func (c *RealAPIClient) RealExecutionsStart(productCode types.ProductCode, callback realtime.ExecutionsCallback, callbackData interface{}) (error){
-	if c.realtimeChannel != nil {
-		return errors.Errorf("already exists realtime api connection")
-	}
-	wsRequest := &client.WSRequest {
-		URL: c.endpoint,
-		Headers: make(map[string]string),
-	}
+func (c *RealAPIClient) RealExecutionsStartCtx(ctx context.Context, productCode types.ProductCode, callback realtime.ExecutionsCallback, callbackData interface{}) (error) {
 	rc := &realtime.RealtimeChannel{
 		ProductCode:           productCode,
 		RealtimeType:          types.RealtimeTypeExecutions,
@@ -980,78 +1691,171 @@ func (c *RealAPIClient) RealExecutionsStart(productCode types.ProductCode, callb
                 ExecutionsCallback:    callback,
 		CallbackData:          callbackData,
 		Subscribed:            0,
-		UnsubscribeChan:       make(chan *realtime.JsonRPC2Subscribe),
 		Merge:                 false,
-		GetBoardResponseFull:  nil,
+		OrderBook:             nil,
 	}
-	err := c.wsClient.Start(wsRequest, c.realExecutionsCallback, rc)
-	if err != nil {
-		return errors.Wrapf(err, "can not connect realtime api")
+	return c.addRealtimeChannel(ctx, rc, realtime.ChannelName(types.RealtimeTypeExecutions, productCode))
+}
+
+func (c *RealAPIClient) RealChildOrderEventsStart(callback realtime.ChildOrderEventsCallback, callbackData interface{}) (error) {
+	return c.RealChildOrderEventsStartCtx(context.Background(), callback, callbackData)
+}
+
+func (c *RealAPIClient) RealChildOrderEventsStartCtx(ctx context.Context, callback realtime.ChildOrderEventsCallback, callbackData interface{}) (error) {
+	rc := &realtime.RealtimeChannel{
+		RealtimeType:             types.RealtimeTypeChildOrderEvents,
+		ChildOrderEventsCallback: callback,
+		Private:                  true,
+		CallbackData:             callbackData,
+		Subscribed:               0,
+	}
+	return c.addRealtimeChannel(ctx, rc, realtime.ChannelName(types.RealtimeTypeChildOrderEvents, ""))
+}
+
+func (c *RealAPIClient) RealParentOrderEventsStart(callback realtime.ParentOrderEventsCallback, callbackData interface{}) (error) {
+	return c.RealParentOrderEventsStartCtx(context.Background(), callback, callbackData)
+}
+
+func (c *RealAPIClient) RealParentOrderEventsStartCtx(ctx context.Context, callback realtime.ParentOrderEventsCallback, callbackData interface{}) (error) {
+	rc := &realtime.RealtimeChannel{
+		RealtimeType:              types.RealtimeTypeParentOrderEvents,
+		ParentOrderEventsCallback: callback,
+		Private:                   true,
+		CallbackData:              callbackData,
+		Subscribed:                0,
+	}
+	return c.addRealtimeChannel(ctx, rc, realtime.ChannelName(types.RealtimeTypeParentOrderEvents, ""))
+}
+
+func (c *RealAPIClient) removeRealtimeChannel(rc *realtime.RealtimeChannel) {
+	removed := false
+	for channel, v := range c.realtimeChannels {
+		if v != rc {
+			continue
+		}
+		delete(c.realtimeChannels, channel)
+		removed = true
+		if !c.subscribed[channel] {
+			continue
+		}
+		delete(c.subscribed, channel)
+		select {
+		case c.requestChan <- &realtime.JsonRPC2Subscribe{
+			JsonRpc: "2.0",
+			Method:  "unsubscribe",
+			Params:  realtime.JsonRPC2SubscribeParams{
+				Channel: channel,
+			},
+		}:
+		default:
+			log.Printf("can not queue unsubscribe (channel = %v)", channel)
+		}
+	}
+	if removed {
+		close(rc.FinishChan)
+	}
+	c.cancelBoardResync(rc)
+	atomic.StoreUint32(&rc.Subscribed, 0)
+}
+
+// RealUnsubscribe stops one channel started by Real*Start. The connection is closed when no channel remains.
+func (c *RealAPIClient) RealUnsubscribe(realtimeType types.RealtimeType, productCode types.ProductCode) (error) {
+	c.mutex.Lock()
+	rc, ok := c.realtimeChannels[realtime.ChannelName(realtimeType, productCode)]
+	c.mutex.Unlock()
+	if !ok || rc.RealtimeType != realtimeType || !c.unsubscribeChannel(rc) {
+		return errors.Errorf("not found realtime channel (type = %v, product code = %v)", realtimeType, productCode)
 	}
-	c.realtimeChannel = rc
 	return nil
 }
 
 func (c *RealAPIClient) RealStop() (error) {
-	if c.realtimeChannel == nil {
+	c.mutex.Lock()
+	if len(c.realtimeChannels) == 0 {
+		c.mutex.Unlock()
 		return errors.Errorf("not found realtime api connection")
 	}
-	if atomic.LoadUint32(&c.realtimeChannel.Subscribed) == 1 {
-		switch  c.realtimeChannel.RealtimeType {
-		case types.RealtimeTypeBoardSnapshot:
-			c.realtimeChannel.UnsubscribeChan <- &realtime.JsonRPC2Subscribe{
-									JsonRpc: "2.0",
-									Method:  "unsubscribe",
-									Params:  realtime.JsonRPC2SubscribeParams{
-										Channel: "lightning_board_snapshot_" + string(c.realtimeChannel.ProductCode),
-									},
-								}
-		case types.RealtimeTypeBoard:
-			c.realtimeChannel.UnsubscribeChan <- &realtime.JsonRPC2Subscribe{
-									JsonRpc: "2.0",
-									Method:  "unsubscribe",
-									Params:  realtime.JsonRPC2SubscribeParams{
-										Channel: "lightning_board_" + string(c.realtimeChannel.ProductCode),
-									},
-								}
-			if c.realtimeChannel.Merge {
-				c.realtimeChannel.UnsubscribeChan <- &realtime.JsonRPC2Subscribe{
-										JsonRpc: "2.0",
-										Method:  "unsubscribe",
-										Params:  realtime.JsonRPC2SubscribeParams{
-											Channel: "lightning_board_snapshot_" + string(c.realtimeChannel.ProductCode),
-										},
-									}
-			}
-		case types.RealtimeTypeTicker:
-			c.realtimeChannel.UnsubscribeChan <- &realtime.JsonRPC2Subscribe{
-									JsonRpc: "2.0",
-									Method:  "unsubscribe",
-									Params:  realtime.JsonRPC2SubscribeParams{
-										Channel: "lightning_ticker_" + string(c.realtimeChannel.ProductCode),
-									},
-								}
-		case types.RealtimeTypeExecutions:
-			c.realtimeChannel.UnsubscribeChan <- &realtime.JsonRPC2Subscribe{
-									JsonRpc: "2.0",
-									Method:  "unsubscribe",
-									Params:  realtime.JsonRPC2SubscribeParams{
-										Channel: "lightning_executions_" + string(c.realtimeChannel.ProductCode),
-									},
-								}
+	for _, rc := range c.realtimeChannels {
+		c.removeRealtimeChannel(rc)
+	}
+	c.mutex.Unlock()
+	c.stopConnection()
+	// drop unsubscribe requests which were not written before stop
+	for {
+		select {
+		case <-c.requestChan:
+			continue
+		default:
 		}
+		break
 	}
-	c.wsClient.Stop()
-	close(c.realtimeChannel.UnsubscribeChan)
-	c.realtimeChannel = nil
 	return nil
 }
 
-func NewRealAPIClient(wsClient *client.WSClient) (*RealAPIClient) {
+// NewRealAPIClient creates a realtime api client. A rest api client sharing the options is also created
+// when WithHTTPClient is given.
+func NewRealAPIClient(wsClient *client.WSClient, options ...ClientOption) (*RealAPIClient) {
+	clientOptions := newClientOptions(options)
+	var apiClient *APIClient
+	if clientOptions.httpClient != nil {
+		apiClient = NewAPIClient(nil, nil, options...)
+	}
+	if clientOptions.realtimeTransport == nil {
+		clientOptions.realtimeTransport = realtime.NewJsonRPC2Transport()
+	}
+	if clientOptions.realtimeEndpoint == "" {
+		clientOptions.realtimeEndpoint = clientOptions.realtimeTransport.Endpoint()
+	}
 	return &RealAPIClient{
-		endpoint:                  realtimeApiEndpoint,
+		endpoint:                  clientOptions.realtimeEndpoint,
 		wsClient:                  wsClient,
-		realtimeChannel:           nil,
+		apiClient:                 apiClient,
+		realtimeChannels:          make(map[string]*realtime.RealtimeChannel),
+		subscribed:                make(map[string]bool),
+		mutex:                     new(sync.Mutex),
+		requestChan:               make(chan *realtime.JsonRPC2Subscribe, 64),
+		writeChan:                 make(chan int, 1),
+		authenticator:             clientOptions.authenticator,
+		options:                   clientOptions,
+		boardMidPriceTolerance:    defaultBoardMidPriceTolerance,
+		transport:                 clientOptions.realtimeTransport,
+		boardResyncTimers:         make(map[*realtime.RealtimeChannel]*time.Timer),
+		restBoards:                make(map[*realtime.RealtimeChannel]*public.GetBoardResponse),
 	}
 }
 
+// Replay dispatches the recorded messages of a client created by NewReplayRealAPIClient to the callbacks
+// of Real*Start, in the recorded order and on the calling goroutine. Messages of channels which are not started are skipped.
+func (c *RealAPIClient) Replay() (error) {
+	return c.ReplayCtx(context.Background())
+}
+
+func (c *RealAPIClient) ReplayCtx(ctx context.Context) (error) {
+	if c.player == nil {
+		return errors.Errorf("not replay client")
+	}
+	return c.player.Play(ctx, func(record *realtime.Record) (error) {
+		if record.Notify == nil || record.Notify.Params == nil {
+			return nil
+		}
+		err := c.dispatch(record.Notify.Params.Channel, record.Notify.Params.Message, record.ReceivedAt)
+		if err != nil {
+			log.Printf("can not dispatch message (reason = %v)", err)
+		}
+		return nil
+	})
+}
+
+// NewReplayRealAPIClient creates a realtime api client which does not connect, but replays the messages of player
+// with Replay. Private channels can be started without an authenticator.
+func NewReplayRealAPIClient(player *realtime.Player, options ...ClientOption) (*RealAPIClient) {
+	c := NewRealAPIClient(nil, options...)
+	c.player = player
+	return c
+}
+
+// NewRealAPIClientWithAuthenticator creates a client which can also subscribe private channels. The authenticator
+// must be a RealtimeAuthenticator for them, as the ones of NewAuthenticator and NewAuthenticatorFromKey are.
+func NewRealAPIClientWithAuthenticator(wsClient *client.WSClient, authenticator Authenticator, options ...ClientOption) (*RealAPIClient) {
+	return NewRealAPIClient(wsClient, append([]ClientOption{WithAuthenticator(authenticator)}, options...)...)
+}
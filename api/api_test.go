@@ -1,28 +1,55 @@
 package api_test
 
 import (
+	"context"
 	"log"
 	"fmt"
 	"time"
+	"strings"
 	"testing"
+	"net/url"
+	"net/http"
+	"sync/atomic"
+	"encoding/json"
+	"net/http/httptest"
+	"net/http/httputil"
+	"github.com/pkg/errors"
 	"github.com/potix/gobitflyer/client"
 	"github.com/potix/gobitflyer/api/types"
 	"github.com/potix/gobitflyer/api/public"
 	"github.com/potix/gobitflyer/api/private"
+	"github.com/potix/gobitflyer/api/realtime"
 	"github.com/potix/gobitflyer/api"
+	"github.com/potix/gobitflyer/bitflyertest"
+	"github.com/potix/gobitflyer/orderbook"
 )
 
-func createApiClient(t *testing.T) (*api.APIClient) {
-	httpClient := client.NewHTTPClient(30, 0, 180, nil)
-	authenticator, err := api.NewAuthenticator("apikey")
+func TestClientOptions(t *testing.T) {
+	headersChan := make(chan http.Header, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headersChan <- r.Header
+		w.Write([]byte("[]"))
+	}))
+	defer server.Close()
+	apiClient := api.NewAPIClient(nil, nil,
+		api.WithEndpoint(server.URL),
+		api.WithUserAgent("gobitflyer-test"),
+		api.WithHeaders(map[string]string{"X-Test": "1"}))
+	_, _, err := apiClient.PubGetMarkets()
 	if err != nil {
-		t.Errorf("can not create authenticator: %v", err)
+		t.Fatalf("error: %v", err)
+	}
+	headers := <-headersChan
+	if headers.Get("User-Agent") != "gobitflyer-test" {
+		t.Errorf("unexpected user agent: %v", headers.Get("User-Agent"))
+	}
+	if headers.Get("X-Test") != "1" {
+		t.Errorf("unexpected header: %v", headers.Get("X-Test"))
 	}
-	return api.NewAPIClient(httpClient, authenticator)
 }
 
 func TestPubMarkets(t *testing.T) {
-	apiClient := createApiClient(t)
+	_, apiClient, _ := bitflyertest.NewClients(t)
 	httpResponse, getMarketsResponse, err :=  apiClient.PubGetMarkets()
 	if err != nil {
 		t.Errorf("error: %v", err)
@@ -37,7 +64,7 @@ func TestPubMarkets(t *testing.T) {
 }
 
 func TestPubBoard(t *testing.T) {
-	apiClient := createApiClient(t)
+	_, apiClient, _ := bitflyertest.NewClients(t)
 	httpResponse, getBoardResponse, err :=  apiClient.PubGetBoard("BTC_JPY")
 	if err != nil {
 		t.Errorf("error: %v", err)
@@ -52,7 +79,7 @@ func TestPubBoard(t *testing.T) {
 }
 
 func TestPubTicker(t *testing.T) {
-	apiClient := createApiClient(t)
+	_, apiClient, _ := bitflyertest.NewClients(t)
 	httpResponse, getTickerResponse, err :=  apiClient.PubGetTicker("BTC_JPY")
 	if err != nil {
 		t.Errorf("error: %v", err)
@@ -67,7 +94,7 @@ func TestPubTicker(t *testing.T) {
 }
 
 func TestPubExecutions(t *testing.T) {
-	apiClient := createApiClient(t)
+	_, apiClient, _ := bitflyertest.NewClients(t)
 	httpResponse, getExecutionsResponse, err :=  apiClient.PubGetExecutions("BTC_JPY", 10, 0, 0)
 	if err != nil {
 		t.Errorf("error: %v", err)
@@ -82,7 +109,7 @@ func TestPubExecutions(t *testing.T) {
 }
 
 func TestPubBoardState(t *testing.T) {
-	apiClient := createApiClient(t)
+	_, apiClient, _ := bitflyertest.NewClients(t)
 	httpResponse, getBoardStateResponse, err :=  apiClient.PubGetBoardState("BTC_JPY")
 	if err != nil {
 		t.Errorf("error: %v", err)
@@ -97,7 +124,7 @@ func TestPubBoardState(t *testing.T) {
 }
 
 func TestPubHealth(t *testing.T) {
-	apiClient := createApiClient(t)
+	_, apiClient, _ := bitflyertest.NewClients(t)
 	httpResponse, getHealthResponse, err :=  apiClient.PubGetHealth("BTC_JPY")
 	if err != nil {
 		t.Errorf("error: %v", err)
@@ -112,7 +139,7 @@ func TestPubHealth(t *testing.T) {
 }
 
 func TestPubGetChats(t *testing.T) {
-	apiClient := createApiClient(t)
+	_, apiClient, _ := bitflyertest.NewClients(t)
 	httpResponse, getChatsResponse, err :=  apiClient.PubGetChats(1)
 	if err != nil {
 		t.Errorf("error: %v", err)
@@ -127,7 +154,7 @@ func TestPubGetChats(t *testing.T) {
 }
 
 func TestPriPermissions(t *testing.T) {
-	apiClient := createApiClient(t)
+	_, apiClient, _ := bitflyertest.NewClients(t)
 	httpResponse, getPermissionsResponse, err := apiClient.PriGetPermissions()
 	if err != nil {
 		t.Errorf("error: %v", err)
@@ -142,7 +169,7 @@ func TestPriPermissions(t *testing.T) {
 }
 
 func TestPriBalance(t *testing.T) {
-	apiClient := createApiClient(t)
+	_, apiClient, _ := bitflyertest.NewClients(t)
 	httpResponse, getBalanceResponse, err := apiClient.PriGetBalance()
 	if err != nil {
 		t.Errorf("error: %v", err)
@@ -157,7 +184,7 @@ func TestPriBalance(t *testing.T) {
 }
 
 func TestPriCollateral(t *testing.T) {
-	apiClient := createApiClient(t)
+	_, apiClient, _ := bitflyertest.NewClients(t)
 	httpResponse, getCollateralResponse, err := apiClient.PriGetCollateral()
 	if err != nil {
 		t.Errorf("error: %v", err)
@@ -172,7 +199,7 @@ func TestPriCollateral(t *testing.T) {
 }
 
 func TestPriCollateralAccounts(t *testing.T) {
-	apiClient := createApiClient(t)
+	_, apiClient, _ := bitflyertest.NewClients(t)
 	httpResponse, getCollateralAccountsResponse, err := apiClient.PriGetCollateralAccounts()
 	if err != nil {
 		t.Errorf("error: %v", err)
@@ -187,7 +214,7 @@ func TestPriCollateralAccounts(t *testing.T) {
 }
 
 func TestPriSendChildOrder(t *testing.T) {
-	apiClient := createApiClient(t)
+	_, apiClient, _ := bitflyertest.NewClients(t)
 	httpResponse, sendChildOrderResponse, err := apiClient.PriSendChildOrder("BTC_JPY", types.OrderTypeLimit, types.SideBuy, 550000, 0.11, 1, types.TimeInForceIOC)
 	if err != nil {
 		t.Errorf("error: %v", err)
@@ -202,7 +229,7 @@ func TestPriSendChildOrder(t *testing.T) {
 }
 
 func TestPriCancelChildOrderWithOrderAcceptanceId(t *testing.T) {
-	apiClient := createApiClient(t)
+	_, apiClient, _ := bitflyertest.NewClients(t)
 	httpResponse, sendChildOrderResponse, err := apiClient.PriSendChildOrder("BTC_JPY", types.OrderTypeLimit, types.SideBuy, 550000, 0.11, 1, types.TimeInForceFOK)
 	if err != nil {
 		t.Errorf("error: %v", err)
@@ -225,7 +252,7 @@ func TestPriCancelChildOrderWithOrderAcceptanceId(t *testing.T) {
 }
 
 func TestPriCancelAllChildOrders(t *testing.T) {
-	apiClient := createApiClient(t)
+	_, apiClient, _ := bitflyertest.NewClients(t)
 	httpResponse, sendChildOrderResponse, err := apiClient.PriSendChildOrder("BTC_JPY", types.OrderTypeLimit, types.SideBuy, 550000, 0.12, 1, types.TimeInForceFOK)
 	if err != nil {
 		t.Errorf("error: %v", err)
@@ -247,8 +274,90 @@ func TestPriCancelAllChildOrders(t *testing.T) {
 	t.Log(fmt.Sprintf("%#v", httpResponse))
 }
 
+// createFailingProxy forwards requests to the server, but answers 500 to the first request of the path after forwarding it,
+// as if the response was lost after the order was accepted.
+func createFailingProxy(t *testing.T, server *bitflyertest.Server, path string) (*httptest.Server) {
+	target, err := url.Parse(server.URL())
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	reverseProxy := httputil.NewSingleHostReverseProxy(target)
+	failed := int32(0)
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == path && atomic.CompareAndSwapInt32(&failed, 0, 1) {
+			reverseProxy.ServeHTTP(httptest.NewRecorder(), r)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		reverseProxy.ServeHTTP(w, r)
+	}))
+	t.Cleanup(proxy.Close)
+	return proxy
+}
+
+func TestPriSendChildOrderRetry(t *testing.T) {
+	server, directApiClient, _ := bitflyertest.NewClients(t)
+	proxy := createFailingProxy(t, server, "/v1/me/sendchildorder")
+	apiClient := api.NewAPIClient(client.NewHTTPClient(30, 0, 180, nil),
+		api.NewAuthenticatorFromKey(server.APIKey(), server.APISecret()), api.WithEndpoint(proxy.URL))
+	apiClient.SetRetryPolicy(api.NewRetryPolicy(3, 10 * time.Millisecond, 10 * time.Millisecond, http.StatusInternalServerError))
+	// an earlier order with the same parameters must not be mistaken for the retried one
+	_, earlier, err := directApiClient.PriSendChildOrder("BTC_JPY", types.OrderTypeLimit, types.SideBuy, 550000, 0.12, 1, types.TimeInForceGTC)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	_, first, err := apiClient.PriSendChildOrder("BTC_JPY", types.OrderTypeLimit, types.SideBuy, 550000, 0.13, 1, types.TimeInForceGTC)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	_, second, err := apiClient.PriSendChildOrder("BTC_JPY", types.OrderTypeLimit, types.SideBuy, 550000, 0.13, 1, types.TimeInForceGTC)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if first.ChildOrderAcceptanceId == second.ChildOrderAcceptanceId {
+		t.Errorf("same acceptance id: %v", first.ChildOrderAcceptanceId)
+	}
+	_, getChildOrdersResponse, err := apiClient.PriGetChildOrders("BTC_JPY", 10, 0, 0, types.OrderStateActive)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if len(getChildOrdersResponse) != 3 {
+		t.Fatalf("duplicate orders: %v", len(getChildOrdersResponse))
+	}
+	for _, order := range getChildOrdersResponse {
+		id := order.ChildOrderAcceptanceId
+		if id != earlier.ChildOrderAcceptanceId && id != first.ChildOrderAcceptanceId && id != second.ChildOrderAcceptanceId {
+			t.Errorf("unexpected order: %v", id)
+		}
+	}
+}
+
+// an order with the same parameters from elsewhere makes lookup ambiguous, so no retry is made
+func TestPriSendChildOrderRetryAmbiguous(t *testing.T) {
+	server, directApiClient, _ := bitflyertest.NewClients(t)
+	proxy := createFailingProxy(t, server, "/v1/me/sendchildorder")
+	apiClient := api.NewAPIClient(client.NewHTTPClient(30, 0, 180, nil),
+		api.NewAuthenticatorFromKey(server.APIKey(), server.APISecret()), api.WithEndpoint(proxy.URL))
+	apiClient.SetRetryPolicy(api.NewRetryPolicy(3, 10 * time.Millisecond, 10 * time.Millisecond, http.StatusInternalServerError))
+	_, _, err := directApiClient.PriSendChildOrder("BTC_JPY", types.OrderTypeLimit, types.SideBuy, 550000, 0.12, 1, types.TimeInForceGTC)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	_, _, err = apiClient.PriSendChildOrder("BTC_JPY", types.OrderTypeLimit, types.SideBuy, 550000, 0.12, 1, types.TimeInForceGTC)
+	if err == nil {
+		t.Fatalf("no error")
+	}
+	_, getChildOrdersResponse, err := apiClient.PriGetChildOrders("BTC_JPY", 10, 0, 0, types.OrderStateActive)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if len(getChildOrdersResponse) != 2 {
+		t.Errorf("unexpected orders: %v", len(getChildOrdersResponse))
+	}
+}
+
 func TestPriGetChildOrders(t *testing.T) {
-	apiClient := createApiClient(t)
+	_, apiClient, _ := bitflyertest.NewClients(t)
 	httpResponse, sendChildOrderResponse, err := apiClient.PriSendChildOrder("BTC_JPY", types.OrderTypeLimit, types.SideBuy, 550000, 0.12, 1, types.TimeInForceGTC)
 	if err != nil {
 		t.Errorf("error: %v", err)
@@ -261,8 +370,6 @@ func TestPriGetChildOrders(t *testing.T) {
 	}
 	t.Log(fmt.Sprintf("%#v\n%#v", httpResponse, sendChildOrderResponse))
 
-	time.Sleep(2 * time.Second)
-
 	httpResponse, getChildOrdersResponse, err := apiClient.PriGetChildOrders("BTC_JPY", 10, 0, 0, types.OrderStateActive)
 	if err != nil {
 		t.Errorf("error: %v", err)
@@ -305,8 +412,6 @@ func TestPriGetChildOrders(t *testing.T) {
 	}
 	t.Log(fmt.Sprintf("%#v", httpResponse))
 
-	time.Sleep(2 * time.Second)
-
 	httpResponse, getChildOrdersResponse, err = apiClient.PriGetChildOrders("BTC_JPY", 10, 0, 0, types.OrderStateActive)
 	if err != nil {
 		t.Errorf("error: %v", err)
@@ -325,7 +430,7 @@ func TestPriGetChildOrders(t *testing.T) {
 
 
 func TestPriGetExecutions(t *testing.T) {
-	apiClient := createApiClient(t)
+	_, apiClient, _ := bitflyertest.NewClients(t)
 	httpResponse, getExecutionsResponse, err := apiClient.PriGetExecutions("BTC_JPY", 10, 0, 0)
 	if err != nil {
 		t.Errorf("error: %v", err)
@@ -354,7 +459,7 @@ func TestPriGetExecutions(t *testing.T) {
 }
 
 func TestPriGetBalanceHistory(t *testing.T) {
-	apiClient := createApiClient(t)
+	_, apiClient, _ := bitflyertest.NewClients(t)
 	httpResponse, getBalanceHistoryResponse, err := apiClient.PriGetBalanceHistory("JPY", 10, 0, 0)
 	if err != nil {
 		t.Errorf("error: %v", err)
@@ -370,7 +475,7 @@ func TestPriGetBalanceHistory(t *testing.T) {
 }
 
 func TestPriGetPositions(t *testing.T) {
-	apiClient := createApiClient(t)
+	_, apiClient, _ := bitflyertest.NewClients(t)
 	httpResponse, getPositionsResponse, err := apiClient.PriGetPositions()
 	if err != nil {
 		t.Errorf("error: %v", err)
@@ -386,7 +491,7 @@ func TestPriGetPositions(t *testing.T) {
 }
 
 func TestPriGetCollateralHistory(t *testing.T) {
-	apiClient := createApiClient(t)
+	_, apiClient, _ := bitflyertest.NewClients(t)
 	httpResponse, getCollateralHistoryResponse, err := apiClient.PriGetCollateralHistory(10, 0, 0)
 	if err != nil {
 		t.Errorf("error: %v", err)
@@ -402,7 +507,7 @@ func TestPriGetCollateralHistory(t *testing.T) {
 }
 
 func TestPriGetTradingCommission(t *testing.T) {
-	apiClient := createApiClient(t)
+	_, apiClient, _ := bitflyertest.NewClients(t)
 	httpResponse, getTradingCommissionResponse, err := apiClient.PriGetTradingCommission("BTC_JPY")
 	if err != nil {
 		t.Errorf("error: %v", err)
@@ -419,8 +524,279 @@ func TestPriGetTradingCommission(t *testing.T) {
 
 
 
+func TestPriGetAddresses(t *testing.T) {
+	_, apiClient, _ := bitflyertest.NewClients(t)
+	httpResponse, getAddressesResponse, err := apiClient.PriGetAddresses()
+	if err != nil {
+		t.Errorf("error: %v", err)
+	}
+	if httpResponse == nil {
+		t.Errorf("no http response")
+	}
+	if getAddressesResponse == nil {
+		t.Errorf("no response")
+	}
+	t.Log(fmt.Sprintf("%#v", httpResponse))
+	t.Log(fmt.Sprintf("%#v", getAddressesResponse))
+}
+
+func TestPriGetCoinIns(t *testing.T) {
+	_, apiClient, _ := bitflyertest.NewClients(t)
+	httpResponse, getCoinInsResponse, err := apiClient.PriGetCoinIns(10, 0, 0)
+	if err != nil {
+		t.Errorf("error: %v", err)
+	}
+	if httpResponse == nil {
+		t.Errorf("no http response")
+	}
+	if getCoinInsResponse == nil {
+		t.Errorf("no response")
+	}
+	t.Log(fmt.Sprintf("%#v", httpResponse))
+	t.Log(fmt.Sprintf("%#v", getCoinInsResponse))
+}
+
+func TestPriGetCoinOuts(t *testing.T) {
+	_, apiClient, _ := bitflyertest.NewClients(t)
+	httpResponse, getCoinOutsResponse, err := apiClient.PriGetCoinOuts(10, 0, 0)
+	if err != nil {
+		t.Errorf("error: %v", err)
+	}
+	if httpResponse == nil {
+		t.Errorf("no http response")
+	}
+	if getCoinOutsResponse == nil {
+		t.Errorf("no response")
+	}
+	t.Log(fmt.Sprintf("%#v", httpResponse))
+	t.Log(fmt.Sprintf("%#v", getCoinOutsResponse))
+}
+
+func TestPriGetBankAccounts(t *testing.T) {
+	_, apiClient, _ := bitflyertest.NewClients(t)
+	httpResponse, getBankAccountsResponse, err := apiClient.PriGetBankAccounts()
+	if err != nil {
+		t.Errorf("error: %v", err)
+	}
+	if httpResponse == nil {
+		t.Errorf("no http response")
+	}
+	if getBankAccountsResponse == nil {
+		t.Errorf("no response")
+	}
+	t.Log(fmt.Sprintf("%#v", httpResponse))
+	t.Log(fmt.Sprintf("%#v", getBankAccountsResponse))
+}
+
+func TestPriGetDeposits(t *testing.T) {
+	_, apiClient, _ := bitflyertest.NewClients(t)
+	httpResponse, getDepositsResponse, err := apiClient.PriGetDeposits(10, 0, 0)
+	if err != nil {
+		t.Errorf("error: %v", err)
+	}
+	if httpResponse == nil {
+		t.Errorf("no http response")
+	}
+	if getDepositsResponse == nil {
+		t.Errorf("no response")
+	}
+	t.Log(fmt.Sprintf("%#v", httpResponse))
+	t.Log(fmt.Sprintf("%#v", getDepositsResponse))
+}
+
+func TestPriGetWithdrawals(t *testing.T) {
+	_, apiClient, _ := bitflyertest.NewClients(t)
+	httpResponse, getWithdrawalsResponse, err := apiClient.PriGetWithdrawals(10, 0, 0)
+	if err != nil {
+		t.Errorf("error: %v", err)
+	}
+	if httpResponse == nil {
+		t.Errorf("no http response")
+	}
+	if getWithdrawalsResponse == nil {
+		t.Errorf("no response")
+	}
+	t.Log(fmt.Sprintf("%#v", httpResponse))
+	t.Log(fmt.Sprintf("%#v", getWithdrawalsResponse))
+}
+
+func TestSendChildOrderRequestValidate(t *testing.T) {
+	invalidRequests := []*private.SendChildOrderRequest{
+		private.NewSendChildOrderRequest("BTC_JPY", types.OrderTypeLimit, types.SideBuy, 0, 0.1, 0, types.TimeInForceGTC),
+		private.NewSendChildOrderRequest("BTC_JPY", types.OrderTypeMarket, types.SideBuy, 0, 0, 0, types.TimeInForceGTC),
+		private.NewSendChildOrderRequest("BTC_JPY", types.OrderTypeMarket, types.SideBuy, 0, 0.0001, 0, types.TimeInForceGTC),
+		private.NewSendChildOrderRequest("BTC_JPY", types.OrderTypeMarket, types.SideNone, 0, 0.1, 0, types.TimeInForceGTC),
+		private.NewSendChildOrderRequest("BTC_JPY", types.OrderTypeMarket, types.SideBuy, 0, 0.1, -1, types.TimeInForceGTC),
+		private.NewSendChildOrderRequest("BTC_JPY", types.OrderTypeMarket, types.SideBuy, 0, 0.1, types.MaxMinuteToExpire + 1, types.TimeInForceGTC),
+		private.NewSendChildOrderRequest("BTC_JPY", "STOP", types.SideBuy, 0, 0.1, 0, types.TimeInForceGTC),
+	}
+	for i, sendChildOrderRequest := range invalidRequests {
+		if err := sendChildOrderRequest.Validate(); err == nil {
+			t.Errorf("no error (index = %v)", i)
+		}
+	}
+	if err := private.NewSendChildOrderRequest("BTC_JPY", types.OrderTypeMarket, types.SideBuy, 0, 0.001, 0, types.TimeInForceNone).Validate(); err != nil {
+		t.Errorf("error: %v", err)
+	}
+	_, apiClient, _ := bitflyertest.NewClients(t)
+	_, _, err := apiClient.PriSendChildOrder("BTC_JPY", types.OrderTypeLimit, types.SideBuy, 0, 0.1, 0, types.TimeInForceGTC)
+	if err == nil {
+		t.Fatalf("no error")
+	}
+	if _, ok := api.AsAPIError(err); ok {
+		t.Errorf("invalid request was sent: %v", err)
+	}
+}
+
+func TestSendParentOrderRequestValidate(t *testing.T) {
+	limit := &private.SendParentOrderParameter{ProductCode: "BTC_JPY", ConditionType: types.ConditionTypeLimit, Side: types.SideBuy, Price: 550000, Size: 0.1}
+	stop := &private.SendParentOrderParameter{ProductCode: "BTC_JPY", ConditionType: types.ConditionTypeStop, Side: types.SideSell, TriggerPrice: 500000, Size: 0.1}
+	noTrigger := &private.SendParentOrderParameter{ProductCode: "BTC_JPY", ConditionType: types.ConditionTypeStopLimit, Side: types.SideSell, Price: 500000, Size: 0.1}
+	noOffset := &private.SendParentOrderParameter{ProductCode: "BTC_JPY", ConditionType: types.ConditionTypeTrail, Side: types.SideSell, Size: 0.1}
+	invalidRequests := []*private.SendParentOrderRequest{
+		private.NewSendParentOrderRequest(types.OrderMethodSimple, 0, types.TimeInForceGTC, limit, stop),
+		private.NewSendParentOrderRequest(types.OrderMethodIFD, 0, types.TimeInForceGTC, limit),
+		private.NewSendParentOrderRequest(types.OrderMethodOCO, 0, types.TimeInForceGTC, limit, stop, stop),
+		private.NewSendParentOrderRequest(types.OrderMethodIFDOCO, 0, types.TimeInForceGTC, limit, stop),
+		private.NewSendParentOrderRequest(types.OrderMethodSimple, 0, types.TimeInForceGTC, noTrigger),
+		private.NewSendParentOrderRequest(types.OrderMethodSimple, 0, types.TimeInForceGTC, noOffset),
+		private.NewSendParentOrderRequest(types.OrderMethodIFD, 0, "DAY", limit, stop),
+		private.NewSendParentOrderRequest("FOO", 0, types.TimeInForceGTC, limit),
+	}
+	for i, sendParentOrderRequest := range invalidRequests {
+		if err := sendParentOrderRequest.Validate(); err == nil {
+			t.Errorf("no error (index = %v)", i)
+		}
+	}
+	if err := private.NewSendParentOrderRequest(types.OrderMethodIFDOCO, 0, types.TimeInForceGTC, limit, stop, limit).Validate(); err != nil {
+		t.Errorf("error: %v", err)
+	}
+}
+
+func TestParentOrderBuilder(t *testing.T) {
+	entry := private.NewLimitParameter("BTC_JPY", types.SideBuy, 550000, 0.1)
+	takeProfit := private.NewLimitParameter("BTC_JPY", types.SideSell, 560000, 0.1)
+	stopLoss := private.NewStopParameter("BTC_JPY", types.SideSell, 540000, 0.1)
+	sendParentOrderRequest, err := private.NewIFDOCO().If(entry).Then(takeProfit, stopLoss).MinuteToExpire(60).Build()
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if sendParentOrderRequest.OrderMethod != types.OrderMethodIFDOCO || len(sendParentOrderRequest.Parameters) != 3 ||
+	    sendParentOrderRequest.Parameters[0] != entry || sendParentOrderRequest.MinuteToExpire != 60 {
+		t.Errorf("unexpected request: %#v", sendParentOrderRequest)
+	}
+	invalidBuilders := []*private.ParentOrderBuilder{
+		private.NewIFDOCO().If(entry).Then(takeProfit),
+		private.NewIFDOCO().If(entry).Then(stopLoss, takeProfit).Then(takeProfit),
+		private.NewIFDOCO().If(entry).Then(private.NewLimitParameter("BTC_JPY", types.SideSell, 540000, 0.1), stopLoss),
+		private.NewIFDOCO().If(entry).Then(takeProfit, private.NewStopParameter("BTC_JPY", types.SideSell, 555000, 0.1)),
+		private.NewIFDOCO().If(entry).Then(takeProfit, private.NewStopParameter("BTC_JPY", types.SideBuy, 540000, 0.1)),
+		private.NewOCO().Either(private.NewLimitParameter("BTC_JPY", types.SideSell, 530000, 0.1), stopLoss),
+		private.NewOCO().If(entry),
+		private.NewIFD().If(entry).Then(private.NewTrailParameter("BTC_JPY", types.SideSell, 0, 0.1)),
+		private.NewIFD().If(entry).Then(private.NewLimitParameter("BTC_JPY", types.SideSell, 540000, 0.1)),
+		private.NewIFD().If(entry).Then(private.NewStopParameter("BTC_JPY", types.SideSell, 555000, 0.1)),
+		private.NewIFD().If(entry).Then(private.NewStopParameter("BTC_JPY", types.SideBuy, 545000, 0.1)),
+		private.NewSimple(private.NewMarketParameter("BTC_JPY", types.SideBuy, 0.0001)),
+	}
+	for i, builder := range invalidBuilders {
+		if _, err := builder.Build(); err == nil {
+			t.Errorf("no error (index = %v)", i)
+		}
+	}
+	validBuilders := []*private.ParentOrderBuilder{
+		private.NewSimple(private.NewStopLimitParameter("BTC_JPY", types.SideSell, 540000, 539000, 0.1)),
+		private.NewIFD().If(entry).Then(private.NewTrailParameter("BTC_JPY", types.SideSell, 5000, 0.1)),
+		private.NewIFD().If(entry).Then(takeProfit),
+		private.NewIFD().If(entry).Then(private.NewStopLimitParameter("BTC_JPY", types.SideBuy, 555000, 556000, 0.1)),
+		private.NewOCO().Either(takeProfit, stopLoss).TimeInForce(types.TimeInForceFOK),
+		private.NewIFDOCO().If(private.NewMarketParameter("BTC_JPY", types.SideBuy, 0.1)).Then(takeProfit, stopLoss),
+	}
+	for i, builder := range validBuilders {
+		if _, err := builder.Build(); err != nil {
+			t.Errorf("error: %v (index = %v)", err, i)
+		}
+	}
+}
+
+func TestPubGetExecutionsIterator(t *testing.T) {
+	server, apiClient, _ := bitflyertest.NewClients(t)
+	for i := 0; i < 25; i += 1 {
+		if err := server.Execute("BTC_JPY", types.SideBuy, 0, 0.01); err != nil {
+			t.Fatalf("error: %v", err)
+		}
+	}
+	_, getExecutionsResponse, err := apiClient.PubGetExecutions("BTC_JPY", 500, 0, 0)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if len(getExecutionsResponse) < 25 {
+		t.Fatalf("too few executions: %v", len(getExecutionsResponse))
+	}
+	backward := make([]int64, 0)
+	iterator := apiClient.PubGetExecutionsIterator("BTC_JPY", &api.PageOptions{Count: 7})
+	for {
+		execution, err := iterator.Next()
+		if err == api.ErrIteratorDone {
+			break
+		}
+		if err != nil {
+			t.Fatalf("error: %v", err)
+		}
+		backward = append(backward, execution.Id)
+	}
+	if len(backward) != len(getExecutionsResponse) {
+		t.Fatalf("unexpected count: %v != %v", len(backward), len(getExecutionsResponse))
+	}
+	for i, execution := range getExecutionsResponse {
+		if backward[i] != execution.Id {
+			t.Fatalf("unexpected id: %v != %v (index = %v)", backward[i], execution.Id, i)
+		}
+	}
+	afterId := backward[len(backward) - 3]
+	beforeId := backward[2]
+	executionChan, errChan := apiClient.PubGetExecutionsIterator("BTC_JPY", &api.PageOptions{
+		Count:     4,
+		Direction: api.PageDirectionForward,
+		AfterId:   afterId,
+		BeforeId:  beforeId,
+	}).Stream(context.Background())
+	forward := make([]int64, 0)
+	for execution := range executionChan {
+		forward = append(forward, execution.Id)
+	}
+	if err := <-errChan; err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if len(forward) != len(backward) - 6 {
+		t.Fatalf("unexpected count: %v != %v", len(forward), len(backward) - 6)
+	}
+	for i, id := range forward {
+		if id != backward[len(backward) - 4 - i] {
+			t.Fatalf("unexpected id: %v != %v (index = %v)", id, backward[len(backward) - 4 - i], i)
+		}
+	}
+	iterator = apiClient.PubGetExecutionsIterator("BTC_JPY", &api.PageOptions{Count: 3, Direction: api.PageDirectionForward})
+	for i := len(backward) - 1; i >= 0; i -= 1 {
+		execution, err := iterator.Next()
+		if err != nil {
+			t.Fatalf("error: %v", err)
+		}
+		if execution.Id != backward[i] {
+			t.Fatalf("unexpected id: %v != %v (index = %v)", execution.Id, backward[i], i)
+		}
+	}
+	if _, err := iterator.Next(); err != api.ErrIteratorDone {
+		t.Errorf("unexpected error: %v", err)
+	}
+	iterator = apiClient.PubGetExecutionsIterator("BTC_JPY", &api.PageOptions{Count: 7, Since: time.Now().Add(time.Hour)})
+	if _, err := iterator.Next(); err != api.ErrIteratorDone {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
 func TestPriSendParentOrder(t *testing.T) {
-	apiClient := createApiClient(t)
+	_, apiClient, _ := bitflyertest.NewClients(t)
 	sendParentOrderParameter1 := &private.SendParentOrderParameter {
 		ProductCode: "BTC_JPY",
 		ConditionType: types.ConditionTypeLimit,
@@ -448,8 +824,51 @@ func TestPriSendParentOrder(t *testing.T) {
 	t.Log(fmt.Sprintf("%#v\n%#v", httpResponse, sendParentOrderResponse))
 }
 
+func TestPriSendParentOrderRetry(t *testing.T) {
+	server, directApiClient, _ := bitflyertest.NewClients(t)
+	proxy := createFailingProxy(t, server, "/v1/me/sendparentorder")
+	apiClient := api.NewAPIClient(client.NewHTTPClient(30, 0, 180, nil),
+		api.NewAuthenticatorFromKey(server.APIKey(), server.APISecret()), api.WithEndpoint(proxy.URL))
+	apiClient.SetRetryPolicy(api.NewRetryPolicy(3, 10 * time.Millisecond, 10 * time.Millisecond, http.StatusInternalServerError))
+	sendParentOrderParameter1 := &private.SendParentOrderParameter {
+		ProductCode: "BTC_JPY",
+		ConditionType: types.ConditionTypeLimit,
+		Side: types.SideBuy,
+		Size: 0.11,
+		Price: 550000,
+	}
+	sendParentOrderParameter2 := &private.SendParentOrderParameter {
+		ProductCode: "BTC_JPY",
+		ConditionType: types.ConditionTypeLimit,
+		Side: types.SideSell,
+		Size: 0.11,
+		Price: 1900000,
+	}
+	// only the second leg differs, so the earlier order must not be taken for the retried one
+	otherParameter2 := *sendParentOrderParameter2
+	otherParameter2.Price = 1800000
+	_, earlier, err := directApiClient.PriSendParentOrder(types.OrderMethodIFD, 1, types.TimeInForceGTC, sendParentOrderParameter1, &otherParameter2)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	_, sendParentOrderResponse, err := apiClient.PriSendParentOrder(types.OrderMethodIFD, 1, types.TimeInForceGTC, sendParentOrderParameter1, sendParentOrderParameter2)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if sendParentOrderResponse.ParentOrderAcceptanceId == earlier.ParentOrderAcceptanceId {
+		t.Errorf("earlier order is returned: %v", earlier.ParentOrderAcceptanceId)
+	}
+	_, getParentOrdersResponse, err := apiClient.PriGetParentOrders("BTC_JPY", 10, 0, 0, types.OrderStateNone)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if len(getParentOrdersResponse) != 2 {
+		t.Errorf("duplicate orders: %v", len(getParentOrdersResponse))
+	}
+}
+
 func TestPriCancelParentOrder(t *testing.T) {
-	apiClient := createApiClient(t)
+	_, apiClient, _ := bitflyertest.NewClients(t)
 	sendParentOrderParameter1 := &private.SendParentOrderParameter {
 		ProductCode: "BTC_JPY",
 		ConditionType: types.ConditionTypeLimit,
@@ -488,7 +907,7 @@ func TestPriCancelParentOrder(t *testing.T) {
 }
 
 func TestPriGetParentOrders(t *testing.T) {
-	apiClient := createApiClient(t)
+	_, apiClient, _ := bitflyertest.NewClients(t)
 	sendParentOrderParameter1 := &private.SendParentOrderParameter {
 		ProductCode: "BTC_JPY",
 		ConditionType: types.ConditionTypeLimit,
@@ -515,8 +934,6 @@ func TestPriGetParentOrders(t *testing.T) {
 	}
 	t.Log(fmt.Sprintf("%#v\n%#v", httpResponse, sendParentOrderResponse))
 
-	time.Sleep(2 * time.Second)
-
 	httpResponse, getParentOrdersResponse, err := apiClient.PriGetParentOrders("BTC_JPY", 10, 0, 0, types.OrderStateActive)
 	if err != nil {
 		t.Errorf("error: %v", err)
@@ -550,8 +967,6 @@ func TestPriGetParentOrders(t *testing.T) {
 		t.Errorf("no order")
 	}
 
-	time.Sleep(2 * time.Second)
-
 	httpResponse, err = apiClient.PriCancelParentOrder("BTC_JPY", types.IdTypeParentOrderId, parentOrderId)
 	if err != nil {
 		t.Errorf("error: %v", err)
@@ -564,7 +979,7 @@ func TestPriGetParentOrders(t *testing.T) {
 
 
 func TestPriGetParentOrder(t *testing.T) {
-	apiClient := createApiClient(t)
+	_, apiClient, _ := bitflyertest.NewClients(t)
 	sendParentOrderParameter1 := &private.SendParentOrderParameter {
 		ProductCode: "BTC_JPY",
 		ConditionType: types.ConditionTypeLimit,
@@ -591,8 +1006,6 @@ func TestPriGetParentOrder(t *testing.T) {
 	}
 	t.Log(fmt.Sprintf("%#v\n%#v", httpResponse, sendParentOrderResponse))
 
-	time.Sleep(2 * time.Second)
-
 	httpResponse, getParentOrderResponse, err := apiClient.PriGetParentOrder(types.IdTypeParentOrderAcceptanceId, sendParentOrderResponse.ParentOrderAcceptanceId)
 	if err != nil {
 		t.Errorf("error: %v", err)
@@ -621,8 +1034,6 @@ func TestPriGetParentOrder(t *testing.T) {
 	t.Log(fmt.Sprintf("%#v", httpResponse))
 	t.Log(fmt.Sprintf("%#v", getParentOrderResponse))
 
-	time.Sleep(2 *time.Second)
-
 	httpResponse, err = apiClient.PriCancelParentOrder("BTC_JPY", types.IdTypeParentOrderId, parentOrderId)
 	if err != nil {
 		t.Errorf("error: %v", err)
@@ -633,14 +1044,33 @@ func TestPriGetParentOrder(t *testing.T) {
 	t.Log(fmt.Sprintf("%#v", httpResponse))
 }
 
-func createRealApiClient(t *testing.T) (*api.RealAPIClient) {
-        wsClient := client.NewWSClient(0, 0, 60, 1, nil)
-	return api.NewRealAPIClient(wsClient)
+type testCallbackData struct {
+	t        *testing.T
+	m        string
+	received chan int
 }
 
-type testCallbackData struct {
-	t *testing.T
-	m string
+func newTestCallbackData(t *testing.T) (*testCallbackData) {
+	return &testCallbackData{
+		t:        t,
+		m:        "test",
+		received: make(chan int, 1),
+	}
+}
+
+func (tcbd *testCallbackData) notify() {
+	select {
+	case tcbd.received <- 1:
+	default:
+	}
+}
+
+func (tcbd *testCallbackData) wait() {
+	select {
+	case <-tcbd.received:
+	case <-time.After(10 * time.Second):
+		tcbd.t.Fatalf("no message")
+	}
 }
 
 func tickerCallback(productCode types.ProductCode, getTickerResponse *public.GetTickerResponse, callbackData interface{}) {
@@ -649,20 +1079,18 @@ func tickerCallback(productCode types.ProductCode, getTickerResponse *public.Get
 		 tcbd.t.Errorf("mismatch message")
 	}
 	log.Printf("%#v", getTickerResponse)
+	tcbd.notify()
 }
 
 func TestRealSubscribeTicker(t *testing.T) {
-	realApiClient := createRealApiClient(t)
-	tcbd := &testCallbackData{
-		t: t,
-		m: "test",
-	}
+	_, _, realApiClient := bitflyertest.NewClients(t)
+	tcbd := newTestCallbackData(t)
 	err := realApiClient.RealTickerStart("BTC_JPY", tickerCallback, tcbd)
 	if err != nil {
 		t.Errorf("error: %v", err)
 	}
 
-	time.Sleep(20 * time.Second)
+	tcbd.wait()
 
 	err = realApiClient.RealStop()
 	if err != nil {
@@ -677,20 +1105,18 @@ func boardSnapshotCallback(productCode types.ProductCode, getBoardResponse *publ
 		 tcbd.t.Errorf("mismatch message")
 	}
 	log.Printf("%#v", getBoardResponse)
+	tcbd.notify()
 }
 
 func TestRealSubscribeBoardSnapshot(t *testing.T) {
-	realApiClient := createRealApiClient(t)
-	tcbd := &testCallbackData{
-		t: t,
-		m: "test",
-	}
+	_, _, realApiClient := bitflyertest.NewClients(t)
+	tcbd := newTestCallbackData(t)
 	err := realApiClient.RealBoardSnapshotStart("BTC_JPY", boardSnapshotCallback, tcbd)
 	if err != nil {
 		t.Errorf("error: %v", err)
 	}
 
-	time.Sleep(20 * time.Second)
+	tcbd.wait()
 
 	err = realApiClient.RealStop()
 	if err != nil {
@@ -704,20 +1130,18 @@ func boardCallback(productCode types.ProductCode, getBoardResponse *public.GetBo
 		 tcbd.t.Errorf("mismatch message")
 	}
 	log.Printf("%#v", getBoardResponse)
+	tcbd.notify()
 }
 
 func TestRealSubscribeBoard(t *testing.T) {
-	realApiClient := createRealApiClient(t)
-	tcbd := &testCallbackData{
-		t: t,
-		m: "test",
-	}
+	_, _, realApiClient := bitflyertest.NewClients(t)
+	tcbd := newTestCallbackData(t)
 	err := realApiClient.RealBoardStart("BTC_JPY", boardCallback, tcbd, true)
 	if err != nil {
 		t.Errorf("error: %v", err)
 	}
 
-	time.Sleep(20 * time.Second)
+	tcbd.wait()
 
 	err = realApiClient.RealStop()
 	if err != nil {
@@ -725,29 +1149,933 @@ func TestRealSubscribeBoard(t *testing.T) {
 	}
 }
 
+func TestRealOrderBook(t *testing.T) {
+	server, apiClient, realApiClient := bitflyertest.NewClients(t)
+	snapshotChan := make(chan *orderbook.Snapshot, 64)
+	err := realApiClient.RealOrderBookStart("BTC_JPY", func(productCode types.ProductCode, snapshot *orderbook.Snapshot, callbackData interface{}) {
+		select {
+		case snapshotChan <- snapshot:
+		default:
+		}
+	}, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	defer realApiClient.RealStop()
+	select {
+	case <-snapshotChan:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("no order book")
+	}
+	err = server.Execute("BTC_JPY", types.SideBuy, 0, 1.2)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	_, getBoardResponse, err := apiClient.PubGetBoard("BTC_JPY")
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case snapshot := <-snapshotChan:
+			ask, _ := snapshot.BestAsk()
+			if ask.Price == getBoardResponse.Asks[0].Price.Float64() && ask.Size == getBoardResponse.Asks[0].Size.Float64() &&
+			   snapshot.Len(orderbook.Asks) == len(getBoardResponse.Asks) {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("order book does not follow the board")
+		}
+	}
+}
+
+func TestRealBoardResync(t *testing.T) {
+	server, apiClient, realApiClient := bitflyertest.NewClients(t)
+	server.SetPublishInterval(time.Hour)
+	snapshotChan := make(chan *orderbook.Snapshot, 64)
+	resyncChan := make(chan *realtime.BoardResync, 1)
+	realApiClient.SetBoardResyncCallback(func(productCode types.ProductCode, boardResync *realtime.BoardResync, callbackData interface{}) {
+		resyncChan <- boardResync
+	})
+	err := realApiClient.RealOrderBookStart("BTC_JPY", func(productCode types.ProductCode, snapshot *orderbook.Snapshot, callbackData interface{}) {
+		select {
+		case snapshotChan <- snapshot:
+		default:
+		}
+	}, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	defer realApiClient.RealStop()
+	select {
+	case <-snapshotChan:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("no order book")
+	}
+	err = server.WaitSubscribed(true, 10 * time.Second, realtime.ChannelName(types.RealtimeTypeBoard, "BTC_JPY"))
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	err = server.DropBoardDiffs("BTC_JPY", 1)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	err = server.Execute("BTC_JPY", types.SideBuy, 0, 1.2)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	err = server.Execute("BTC_JPY", types.SideSell, 0, 0.1)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	select {
+	case boardResync := <-resyncChan:
+		if boardResync.Reason != realtime.BoardResyncReasonMidPrice || boardResync.Source != realtime.BoardResyncSourceSnapshot {
+			t.Errorf("unexpected resync: %#v", boardResync)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("no resync")
+	}
+	_, getBoardResponse, err := apiClient.PubGetBoard("BTC_JPY")
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case snapshot := <-snapshotChan:
+			ask, _ := snapshot.BestAsk()
+			if ask.Price == getBoardResponse.Asks[0].Price.Float64() && snapshot.Len(orderbook.Asks) == len(getBoardResponse.Asks) {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("order book is not resynchronized")
+		}
+	}
+}
+
+func TestRealBoardRESTResync(t *testing.T) {
+	server, _, _ := bitflyertest.NewClients(t)
+	server.SetPublishInterval(time.Hour)
+	wsClient := client.NewWSClient(0, 0, 60, 1, nil)
+	authenticator := api.NewAuthenticatorFromKey(server.APIKey(), server.APISecret())
+	realApiClient := api.NewRealAPIClient(wsClient, api.WithRealtimeEndpoint(server.RealtimeURL()), api.WithAuthenticator(authenticator),
+		api.WithHTTPClient(client.NewHTTPClient(30, 0, 180, nil)), api.WithEndpoint(server.URL()))
+	snapshotChan := make(chan *orderbook.Snapshot, 64)
+	resyncChan := make(chan *realtime.BoardResync, 1)
+	realApiClient.SetBoardResyncCallback(func(productCode types.ProductCode, boardResync *realtime.BoardResync, callbackData interface{}) {
+		resyncChan <- boardResync
+	})
+	err := realApiClient.RealOrderBookStart("BTC_JPY", func(productCode types.ProductCode, snapshot *orderbook.Snapshot, callbackData interface{}) {
+		select {
+		case snapshotChan <- snapshot:
+		default:
+		}
+	}, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	defer realApiClient.RealStop()
+	select {
+	case <-snapshotChan:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("no order book")
+	}
+	// the snapshot of the subscription again is lost, so that only the rest api can repair the board
+	err = server.DropBoardSnapshots("BTC_JPY", 1)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	err = server.DropBoardDiffs("BTC_JPY", 1)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	err = server.Execute("BTC_JPY", types.SideBuy, 0, 1.2)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	err = server.Execute("BTC_JPY", types.SideSell, 0, 0.1)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	// the board fetched by the rest api is applied with the next diff
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+	timeout := time.After(20 * time.Second)
+	side := types.SideBuy
+	for {
+		select {
+		case boardResync := <-resyncChan:
+			if boardResync.Reason != realtime.BoardResyncReasonMidPrice || boardResync.Source != realtime.BoardResyncSourceREST {
+				t.Errorf("unexpected resync: %#v", boardResync)
+			}
+			return
+		case <-ticker.C:
+			err = server.Execute("BTC_JPY", side, 0, 0.01)
+			if err != nil {
+				t.Fatalf("error: %v", err)
+			}
+			if side == types.SideBuy {
+				side = types.SideSell
+			} else {
+				side = types.SideBuy
+			}
+		case <-timeout:
+			t.Fatalf("no resync")
+		}
+	}
+}
+
 func executionsCallback(productCode types.ProductCode, getExecutionsResponse public.GetExecutionsResponse, callbackData interface{}) {
 	tcbd := (callbackData).(*testCallbackData)
 	if tcbd.m != "test" {
 		 tcbd.t.Errorf("mismatch message")
 	}
 	log.Printf("%#v", getExecutionsResponse)
+	tcbd.notify()
 }
 
 func TestRealSubscribeExecutions(t *testing.T) {
-	realApiClient := createRealApiClient(t)
-	tcbd := &testCallbackData{
-		t: t,
-		m: "test",
-	}
+	server, _, realApiClient := bitflyertest.NewClients(t)
+	tcbd := newTestCallbackData(t)
 	err := realApiClient.RealExecutionsStart("BTC_JPY", executionsCallback, tcbd)
 	if err != nil {
 		t.Errorf("error: %v", err)
 	}
+	err = server.WaitSubscribed(true, 10 * time.Second, realtime.ChannelName(types.RealtimeTypeExecutions, "BTC_JPY"))
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	err = server.Execute("BTC_JPY", types.SideBuy, 0, 0.01)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	tcbd.wait()
+
+	err = realApiClient.RealStop()
+	if err != nil {
+		t.Errorf("error: %v", err)
+	}
+}
 
-	time.Sleep(20 * time.Second)
+func TestRealSubscribeQuietConnection(t *testing.T) {
+	server, _, realApiClient := bitflyertest.NewClients(t)
+	// executions are sent only on change, so nothing is read while the channels are changed
+	callback := func(productCode types.ProductCode, getExecutionsResponse public.GetExecutionsResponse, callbackData interface{}) {}
+	err := realApiClient.RealExecutionsStart("BTC_JPY", callback, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	err = server.WaitSubscribed(true, 5 * time.Second, realtime.ChannelName(types.RealtimeTypeExecutions, "BTC_JPY"))
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	err = realApiClient.RealExecutionsStart("FX_BTC_JPY", callback, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	err = server.WaitSubscribed(true, 5 * time.Second, realtime.ChannelName(types.RealtimeTypeExecutions, "FX_BTC_JPY"))
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	err = realApiClient.RealUnsubscribe(types.RealtimeTypeExecutions, "BTC_JPY")
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	err = server.WaitSubscribed(false, 5 * time.Second, realtime.ChannelName(types.RealtimeTypeExecutions, "BTC_JPY"))
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	err = realApiClient.RealStop()
+	if err != nil {
+		t.Errorf("error: %v", err)
+	}
+}
+
+func TestRealTickerStream(t *testing.T) {
+	_, _, realApiClient := bitflyertest.NewClients(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	tickerChan, errChan, err := realApiClient.RealTickerStreamCtx(ctx, "BTC_JPY", nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	select {
+	case getTickerResponse := <-tickerChan:
+		if getTickerResponse.ProductCode != "BTC_JPY" {
+			t.Errorf("unexpected ticker: %#v", getTickerResponse)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("no ticker")
+	}
+	cancel()
+	select {
+	case err := <-errChan:
+		if err != context.Canceled {
+			t.Errorf("unexpected error: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("stream does not end")
+	}
+	for range tickerChan {
+	}
+}
+
+func TestRealTickerStreamConnectionClosed(t *testing.T) {
+	server, _, _ := bitflyertest.NewClients(t)
+	// no retry, so that the client gives up when the server is closed
+	realApiClient := api.NewRealAPIClient(client.NewWSClient(0, 0, 0, 0, nil), api.WithRealtimeEndpoint(server.RealtimeURL()))
+	tickerChan, errChan, err := realApiClient.RealTickerStream("BTC_JPY", nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	select {
+	case <-tickerChan:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("no ticker")
+	}
+	server.Close()
+	select {
+	case err := <-errChan:
+		if err == nil {
+			t.Errorf("no error")
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("stream does not end")
+	}
+	for range tickerChan {
+	}
+	// the channel is unregistered
+	if err := realApiClient.RealUnsubscribe(types.RealtimeTypeTicker, "BTC_JPY"); err == nil {
+		t.Errorf("channel of the ended stream is still subscribed")
+	}
+}
 
+func TestRealExecutionsStreamCoalesce(t *testing.T) {
+	server, _, realApiClient := bitflyertest.NewClients(t)
+	executionsChan, errChan, err := realApiClient.RealExecutionsStream("BTC_JPY", &api.StreamOptions{
+		BufferSize:     1,
+		OverflowPolicy: api.OverflowPolicyCoalesce,
+	})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	// messages of the connection are dispatched in order, so an execution of another product marks that
+	// the executions before it are in the stream
+	markChan := make(chan int, 1)
+	err = realApiClient.RealExecutionsStart("FX_BTC_JPY", func(productCode types.ProductCode, getExecutionsResponse public.GetExecutionsResponse, callbackData interface{}) {
+		markChan <- 1
+	}, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	err = server.WaitSubscribed(true, 10 * time.Second,
+		realtime.ChannelName(types.RealtimeTypeExecutions, "BTC_JPY"), realtime.ChannelName(types.RealtimeTypeExecutions, "FX_BTC_JPY"))
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	for _, size := range []float64{0.01, 0.02, 0.03} {
+		err = server.Execute("BTC_JPY", types.SideBuy, 0, size)
+		if err != nil {
+			t.Fatalf("error: %v", err)
+		}
+	}
+	err = server.Execute("FX_BTC_JPY", types.SideBuy, 0, 0.01)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	select {
+	case <-markChan:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("no executions")
+	}
+	// the stream may have taken a message before the next one arrived, the other queued messages are replaced by the last
+	sizes := make([]float64, 0)
+	for len(sizes) == 0 || sizes[len(sizes) - 1] != 0.03 {
+		select {
+		case getExecutionsResponse := <-executionsChan:
+			if len(getExecutionsResponse) != 1 {
+				t.Fatalf("unexpected executions: %v", len(getExecutionsResponse))
+			}
+			sizes = append(sizes, getExecutionsResponse[0].Size.Float64())
+		case <-time.After(10 * time.Second):
+			t.Fatalf("no executions: %v", sizes)
+		}
+	}
+	if len(sizes) > 2 {
+		t.Errorf("executions are not coalesced: %v", sizes)
+	}
+	select {
+	case getExecutionsResponse := <-executionsChan:
+		t.Errorf("unexpected executions: %v", getExecutionsResponse)
+	default:
+	}
 	err = realApiClient.RealStop()
 	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if err := <-errChan; err == nil {
+		t.Errorf("no error")
+	}
+	if _, ok := <-executionsChan; ok {
+		t.Errorf("executions channel is not closed")
+	}
+}
+
+func TestRealChannelContext(t *testing.T) {
+	server, _, realApiClient := bitflyertest.NewClients(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	_, errChan, err := realApiClient.RealExecutionsStreamCtx(ctx, "FX_BTC_JPY", nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	executionsChan := make(chan public.GetExecutionsResponse, 16)
+	err = realApiClient.RealExecutionsStart("BTC_JPY", func(productCode types.ProductCode, getExecutionsResponse public.GetExecutionsResponse, callbackData interface{}) {
+		executionsChan <- getExecutionsResponse
+	}, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	defer realApiClient.RealStop()
+	fxChannel := realtime.ChannelName(types.RealtimeTypeExecutions, "FX_BTC_JPY")
+	channel := realtime.ChannelName(types.RealtimeTypeExecutions, "BTC_JPY")
+	if err := server.WaitSubscribed(true, 10 * time.Second, fxChannel, channel); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	// the context of the first channel stops only the channel
+	cancel()
+	if err := <-errChan; err != context.Canceled {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := realApiClient.RealUnsubscribe(types.RealtimeTypeExecutions, "FX_BTC_JPY"); err == nil {
+		t.Errorf("channel is not unsubscribed")
+	}
+	if err := server.Execute("BTC_JPY", types.SideBuy, 0, 0.01); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	select {
+	case getExecutionsResponse := <-executionsChan:
+		if len(getExecutionsResponse) != 1 || getExecutionsResponse[0].Size.Float64() != 0.01 {
+			t.Errorf("unexpected executions: %v", getExecutionsResponse)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("no executions")
+	}
+	// a channel with a done context is not started
+	if err := realApiClient.RealTickerStartCtx(ctx, "BTC_JPY", tickerCallback, nil); err == nil {
+		t.Errorf("channel with a done context is started")
+	}
+}
+
+func TestRateLimiterFail(t *testing.T) {
+	rateLimiter := api.NewRateLimiter(1, 3, api.RateLimitModeFail)
+	for i := 0; i < 3; i += 1 {
+		err := rateLimiter.Acquire()
+		if err != nil {
+			t.Errorf("error: %v", err)
+		}
+	}
+	if rateLimiter.Remaining() != 0 {
+		t.Errorf("unexpected remaining: %v", rateLimiter.Remaining())
+	}
+	err := rateLimiter.Acquire()
+	rateLimitError, ok := err.(*api.RateLimitError)
+	if !ok {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rateLimitError.RetryAfter <= 0 || rateLimitError.RetryAfter > time.Second {
+		t.Fatalf("unexpected retry after: %v", rateLimitError.RetryAfter)
+	}
+	// the first call leaves the window after retry after
+	<-time.After(rateLimitError.RetryAfter)
+	if rateLimiter.Remaining() < 1 {
+		t.Errorf("unexpected remaining: %v", rateLimiter.Remaining())
+	}
+	if err := rateLimiter.Acquire(); err != nil {
+		t.Errorf("error: %v", err)
+	}
+}
+
+func TestRateLimiterWait(t *testing.T) {
+	rateLimiter := api.NewRateLimiter(1, 2, api.RateLimitModeWait)
+	start := time.Now()
+	for i := 0; i < 3; i += 1 {
+		err := rateLimiter.Acquire()
+		if err != nil {
+			t.Errorf("error: %v", err)
+		}
+	}
+	if time.Since(start) < time.Second {
+		t.Errorf("acquire did not wait")
+	}
+}
+
+func TestRateLimiterContext(t *testing.T) {
+	rateLimiter := api.NewRateLimiter(60, 1, api.RateLimitModeWait)
+	err := rateLimiter.Acquire()
+	if err != nil {
+		t.Errorf("error: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100 * time.Millisecond)
+	defer cancel()
+	err = rateLimiter.AcquireCtx(ctx)
+	if err != context.DeadlineExceeded {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestRateLimiterOrderCounts(t *testing.T) {
+	_, apiClient, _ := bitflyertest.NewClients(t)
+	apiClient.SetRateLimiters(api.NewRateLimiter(60, 2, api.RateLimitModeFail), api.NewRateLimiter(60, 10, api.RateLimitModeFail))
+	_, sendChildOrderResponse, err := apiClient.PriSendChildOrder("BTC_JPY", types.OrderTypeLimit, types.SideBuy, 1000000, 0.1, 0, types.TimeInForceGTC)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	_, err = apiClient.PriCancelChildOrder("BTC_JPY", types.IdTypeChildOrderAcceptanceId, sendChildOrderResponse.ChildOrderAcceptanceId)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	// order calls count for both limits
+	if apiClient.RemainingReadAPICount() != 0 || apiClient.RemainingOrderAPICount() != 8 {
+		t.Errorf("unexpected remaining: %v %v", apiClient.RemainingReadAPICount(), apiClient.RemainingOrderAPICount())
+	}
+	_, err = apiClient.PriCancelAllChildOrders("BTC_JPY")
+	if !api.IsRateLimited(err) {
+		t.Errorf("order call is not limited by the read limit: %v", err)
+	}
+}
+
+func TestAPIErrorPredicates(t *testing.T) {
+	var err error = &api.APIError{
+		Name:           "send child order",
+		HTTPStatusCode: 400,
+		HTTPStatus:     "400 Bad Request",
+		Status:         api.BFErrorStatusInsufficientFunds,
+		ErrorMessage:   "Insufficient funds",
+	}
+	if !api.IsInsufficientFunds(err) || api.IsRateLimited(err) || api.IsServerBusy(err) || api.IsOrderNotFound(err) {
+		t.Errorf("unexpected predicate result: %v", err)
+	}
+	err = errors.Wrapf(&api.APIError{HTTPStatusCode: 429, HTTPStatus: "429 Too Many Requests"}, "wrapped")
+	if !api.IsRateLimited(err) {
+		t.Errorf("unexpected predicate result: %v", err)
+	}
+	if _, ok := api.AsAPIError(err); !ok {
+		t.Errorf("can not get api error: %v", err)
+	}
+	err = &api.APIError{HTTPStatusCode: 400, Status: api.BFErrorStatusOrderNotAccepted}
+	if !api.IsServerBusy(err) {
+		t.Errorf("unexpected predicate result: %v", err)
+	}
+	// messages are not classified
+	err = &api.APIError{HTTPStatusCode: 400, Status: -100, ErrorMessage: "Order not found. Insufficient funds. Server is busy."}
+	if api.IsOrderNotFound(err) || api.IsInsufficientFunds(err) || api.IsServerBusy(err) || api.IsRateLimited(err) {
+		t.Errorf("unexpected predicate result: %v", err)
+	}
+	err = &api.APIError{HTTPStatusCode: 400, Status: api.BFErrorStatusOrderNotFound}
+	if !api.IsOrderNotFound(err) {
+		t.Errorf("unexpected predicate result: %v", err)
+	}
+}
+
+func TestPriInvalidSignature(t *testing.T) {
+	server, _, _ := bitflyertest.NewClients(t)
+	httpClient := client.NewHTTPClient(30, 0, 180, nil)
+	apiClient := api.NewAPIClient(httpClient, api.NewAuthenticatorFromKey(server.APIKey(), "invalid"), api.WithEndpoint(server.URL()))
+	_, _, err := apiClient.PriGetBalance()
+	if err == nil {
+		t.Fatalf("no error")
+	}
+	apiError, ok := api.AsAPIError(err)
+	if !ok {
+		t.Fatalf("not api error: %v", err)
+	}
+	if apiError.HTTPStatusCode != 401 {
+		t.Errorf("unexpected status code: %v", apiError.HTTPStatusCode)
+	}
+}
+
+func TestAPIErrorBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"status":-200,"error_message":"Insufficient funds","name":"x","method":"x","url":"x","path":"x","body":"eA=="}`))
+	}))
+	defer server.Close()
+	apiClient := api.NewAPIClient(nil, nil, api.WithEndpoint(server.URL))
+	_, _, err := apiClient.PubGetMarkets()
+	apiError, ok := api.AsAPIError(err)
+	if !ok {
+		t.Fatalf("not api error: %v", err)
+	}
+	if apiError.Status != api.BFErrorStatusInsufficientFunds || apiError.ErrorMessage != "Insufficient funds" {
+		t.Errorf("unexpected error body: %v %v", apiError.Status, apiError.ErrorMessage)
+	}
+	if apiError.Name == "x" || apiError.Method != "GET" || apiError.URL == "x" || apiError.Path == "x" || string(apiError.Body) == "x" {
+		t.Errorf("request is overwritten by the body: %v %v %v %v", apiError.Name, apiError.Method, apiError.URL, apiError.Path)
+	}
+}
+
+func TestPriChildOrderExecution(t *testing.T) {
+	server, apiClient, _ := bitflyertest.NewClients(t)
+	_, sendChildOrderResponse, err := apiClient.PriSendChildOrder("BTC_JPY", types.OrderTypeLimit, types.SideBuy, 1000000, 0.1, 0, types.TimeInForceGTC)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	err = server.Execute("BTC_JPY", types.SideSell, 1000000, 0.1)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	_, getChildOrdersResponse, err := apiClient.PriGetChildOrdersById("BTC_JPY", types.IdTypeChildOrderAcceptanceId, sendChildOrderResponse.ChildOrderAcceptanceId)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if len(getChildOrdersResponse) != 1 {
+		t.Fatalf("unexpected number of orders: %v", len(getChildOrdersResponse))
+	}
+	order := getChildOrdersResponse[0]
+	if order.ChildOrderState != types.OrderStateCompleted || order.ExecutedSize.Float64() != 0.1 || order.AveragePrice.Float64() != 1000000 {
+		t.Errorf("unexpected order: %#v", order)
+	}
+	_, getExecutionsResponse, err := apiClient.PriGetExecutionsById("BTC_JPY", types.IdTypeChildOrderAcceptanceId, sendChildOrderResponse.ChildOrderAcceptanceId)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if len(getExecutionsResponse) != 1 {
+		t.Errorf("unexpected number of executions: %v", len(getExecutionsResponse))
+	}
+	_, getBalanceResponse, err := apiClient.PriGetBalance()
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	for _, asset := range getBalanceResponse {
+		switch asset.CurrencyCode {
+		case "JPY":
+			if asset.Amount.Float64() != 10000000 - 100000 || !asset.Available.Equal(asset.Amount) {
+				t.Errorf("unexpected JPY balance: %#v", asset)
+			}
+		case "BTC":
+			if !asset.Amount.Equal(types.MustParseDecimal("10.1")) {
+				t.Errorf("unexpected BTC balance: %#v", asset)
+			}
+		}
+	}
+	_, getBalanceHistoryResponse, err := apiClient.PriGetBalanceHistory("BTC", 1, 0, 0)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if len(getBalanceHistoryResponse) != 1 || !getBalanceHistoryResponse[0].Balance.Equal(types.MustParseDecimal("10.1")) ||
+	   !getBalanceHistoryResponse[0].Amount.Equal(types.MustParseDecimal("0.1")) {
+		t.Errorf("unexpected balance history: %#v", getBalanceHistoryResponse)
+	}
+}
+
+func TestPriWithdraw(t *testing.T) {
+	server, apiClient, _ := bitflyertest.NewClients(t)
+	_, getBankAccountsResponse, err := apiClient.PriGetBankAccounts()
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if len(getBankAccountsResponse) == 0 {
+		t.Fatalf("no bank account")
+	}
+	_, _, err = apiClient.PriWithdraw("JPY", getBankAccountsResponse[0].Id, 10000, "invalid")
+	if err == nil {
+		t.Errorf("no error with invalid authentication code")
+	}
+	_, withdrawResponse, err := apiClient.PriWithdraw("JPY", getBankAccountsResponse[0].Id, 10000, server.AuthenticationCode())
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	_, getWithdrawalsResponse, err := apiClient.PriGetWithdrawalsById(withdrawResponse.MessageId)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if len(getWithdrawalsResponse) != 1 || getWithdrawalsResponse[0].Amount != 10000 {
+		t.Errorf("unexpected withdrawals: %#v", getWithdrawalsResponse)
+	}
+}
+
+type headersOnlyAuthenticator struct {
+}
+
+func (a *headersOnlyAuthenticator) SetAuthHeaders(headers map[string]string, now time.Time, method string, path string, body []byte) {
+}
+
+func TestRealPrivateChannelAuthenticator(t *testing.T) {
+	server, _, _ := bitflyertest.NewClients(t)
+	wsClient := client.NewWSClient(0, 0, 60, 1, nil)
+	realApiClient := api.NewRealAPIClientWithAuthenticator(wsClient, &headersOnlyAuthenticator{}, api.WithRealtimeEndpoint(server.RealtimeURL()))
+	err := realApiClient.RealChildOrderEventsStart(func(childOrderEvents realtime.ChildOrderEvents, callbackData interface{}) {}, nil)
+	if err == nil {
+		realApiClient.RealStop()
+		t.Errorf("private channel is started without a realtime authenticator")
+	}
+}
+
+func TestRealChildOrderEvents(t *testing.T) {
+	server, apiClient, realApiClient := bitflyertest.NewClients(t)
+	eventChan := make(chan *realtime.ChildOrderEvent, 16)
+	err := realApiClient.RealChildOrderEventsStart(func(childOrderEvents realtime.ChildOrderEvents, callbackData interface{}) {
+		for _, childOrderEvent := range childOrderEvents {
+			eventChan <- childOrderEvent
+		}
+	}, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	defer realApiClient.RealStop()
+	if err := server.WaitSubscribed(true, 10 * time.Second, realtime.ChannelName(types.RealtimeTypeChildOrderEvents, "")); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	_, sendChildOrderResponse, err := apiClient.PriSendChildOrder("BTC_JPY", types.OrderTypeLimit, types.SideBuy, 550000, 0.1, 0, types.TimeInForceGTC)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	select {
+	case childOrderEvent := <-eventChan:
+		if childOrderEvent.EventType != types.EventTypeOrder || childOrderEvent.ChildOrderAcceptanceId != sendChildOrderResponse.ChildOrderAcceptanceId {
+			t.Errorf("unexpected event: %#v", childOrderEvent)
+		}
+	case <-time.After(10 * time.Second):
+		t.Errorf("no child order event")
+	}
+}
+
+func TestRealSocketIO(t *testing.T) {
+	server, apiClient, _ := bitflyertest.NewClients(t)
+	wsClient := client.NewWSClient(0, 0, 60, 1, nil)
+	realApiClient := api.NewRealAPIClient(wsClient,
+		api.WithRealtimeTransport(realtime.NewSocketIOTransport()),
+		api.WithRealtimeEndpoint(server.SocketIOURL()),
+		api.WithAuthenticator(api.NewAuthenticatorFromKey(server.APIKey(), server.APISecret())))
+	tickerChan, _, err := realApiClient.RealTickerStream("BTC_JPY", nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	eventsChan, _, err := realApiClient.RealChildOrderEventsStream(nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	defer realApiClient.RealStop()
+	select {
+	case getTickerResponse := <-tickerChan:
+		if getTickerResponse.ProductCode != "BTC_JPY" {
+			t.Errorf("unexpected ticker: %#v", getTickerResponse)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("no ticker")
+	}
+	_, sendChildOrderResponse, err := apiClient.PriSendChildOrder("BTC_JPY", types.OrderTypeLimit, types.SideBuy, 550000, 0.1, 0, types.TimeInForceGTC)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	select {
+	case childOrderEvents := <-eventsChan:
+		if childOrderEvents[0].ChildOrderAcceptanceId != sendChildOrderResponse.ChildOrderAcceptanceId {
+			t.Errorf("unexpected event: %#v", childOrderEvents[0])
+		}
+	case <-time.After(10 * time.Second):
+		t.Errorf("no child order event")
+	}
+}
+
+// spacedRecorder records messages 100ms apart, so that a replay at the original speed takes a known time.
+type spacedRecorder struct {
+	recorder realtime.Recorder
+	start    time.Time
+	count    int
+}
+
+func (r *spacedRecorder) Record(receivedAt time.Time, notify *realtime.JsonRPC2Notify) (error) {
+	receivedAt = r.start.Add(time.Duration(r.count) * 100 * time.Millisecond)
+	r.count += 1
+	return r.recorder.Record(receivedAt, notify)
+}
+
+func TestRealRecordReplay(t *testing.T) {
+	server, _, _ := bitflyertest.NewClients(t)
+	path := t.TempDir() + "/realtime.jsonl"
+	recorder, err := realtime.NewFileRecorder(path)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	wsClient := client.NewWSClient(0, 0, 60, 1, nil)
+	realApiClient := api.NewRealAPIClient(wsClient, api.WithRealtimeEndpoint(server.RealtimeURL()),
+		api.WithRecorder(&spacedRecorder{recorder: recorder, start: time.Now()}))
+	recordedChan := make(chan int64, 100)
+	err = realApiClient.RealExecutionsStart("BTC_JPY", func(productCode types.ProductCode, getExecutionsResponse public.GetExecutionsResponse, callbackData interface{}) {
+		for _, execution := range getExecutionsResponse {
+			recordedChan <- execution.Id
+		}
+	}, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	err = server.WaitSubscribed(true, 10 * time.Second, realtime.ChannelName(types.RealtimeTypeExecutions, "BTC_JPY"))
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	recorded := make([]int64, 0)
+	for i := 0; i < 3; i += 1 {
+		if err := server.Execute("BTC_JPY", types.SideBuy, 0, 0.01); err != nil {
+			t.Fatalf("error: %v", err)
+		}
+		select {
+		case id := <-recordedChan:
+			recorded = append(recorded, id)
+		case <-time.After(10 * time.Second):
+			t.Fatalf("no executions")
+		}
+	}
+	realApiClient.RealStop()
+	recorder.Close()
+
+	for _, speed := range []float64{realtime.ReplaySpeedMax, realtime.ReplaySpeedOriginal} {
+		player, err := realtime.OpenPlayer(path, speed)
+		if err != nil {
+			t.Fatalf("error: %v", err)
+		}
+		replayApiClient := api.NewReplayRealAPIClient(player)
+		replayed := make([]int64, 0)
+		err = replayApiClient.RealExecutionsStart("BTC_JPY", func(productCode types.ProductCode, getExecutionsResponse public.GetExecutionsResponse, callbackData interface{}) {
+			for _, execution := range getExecutionsResponse {
+				replayed = append(replayed, execution.Id)
+			}
+		}, nil)
+		if err != nil {
+			t.Fatalf("error: %v", err)
+		}
+		start := time.Now()
+		err = replayApiClient.Replay()
+		if err != nil {
+			t.Fatalf("error: %v", err)
+		}
+		elapsed := time.Since(start)
+		player.Close()
+		if fmt.Sprint(replayed) != fmt.Sprint(recorded) {
+			t.Errorf("unexpected replay (speed = %v): %v != %v", speed, replayed, recorded)
+		}
+		if speed == realtime.ReplaySpeedOriginal && elapsed < 150 * time.Millisecond {
+			t.Errorf("replay is too fast (elapsed = %v)", elapsed)
+		}
+		if speed == realtime.ReplaySpeedMax && elapsed > 100 * time.Millisecond {
+			t.Errorf("replay is too slow (elapsed = %v)", elapsed)
+		}
+	}
+}
+
+func TestRealReplayBoardResync(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	records := []struct {
+		after   time.Duration
+		channel string
+		message string
+	}{
+		{ 0, "lightning_board_snapshot_BTC_JPY", `{"mid_price":100.5,"bids":[{"price":100,"size":1}],"asks":[{"price":101,"size":1}]}` },
+		// crossed
+		{ time.Second, "lightning_board_BTC_JPY", `{"mid_price":100.5,"bids":[{"price":102,"size":1}],"asks":[]}` },
+		{ time.Minute, "lightning_board_BTC_JPY", `{"mid_price":100.5,"bids":[{"price":102,"size":0}],"asks":[]}` },
+		{ 2 * time.Minute, "lightning_board_snapshot_BTC_JPY", `{"mid_price":100.5,"bids":[{"price":100,"size":2}],"asks":[{"price":101,"size":2}]}` },
+	}
+	lines := ""
+	for _, record := range records {
+		line, err := json.Marshal(&realtime.Record{
+			ReceivedAt: start.Add(record.after),
+			Notify:     &realtime.JsonRPC2Notify{
+				JsonRpc: "2.0",
+				Method:  "channelMessage",
+				Params:  &realtime.JsonRPC2NotifyParams{Channel: record.channel, Message: json.RawMessage(record.message)},
+			},
+		})
+		if err != nil {
+			t.Fatalf("error: %v", err)
+		}
+		lines += string(line) + "\n"
+	}
+	player := realtime.NewPlayer(strings.NewReader(lines), realtime.ReplaySpeedMax)
+	replayApiClient := api.NewReplayRealAPIClient(player, api.WithHTTPClient(client.NewHTTPClient(30, 0, 180, nil)), api.WithEndpoint(server.URL))
+	resyncs := make([]*realtime.BoardResync, 0)
+	replayApiClient.SetBoardResyncCallback(func(productCode types.ProductCode, boardResync *realtime.BoardResync, callbackData interface{}) {
+		resyncs = append(resyncs, boardResync)
+	})
+	err := replayApiClient.RealOrderBookStart("BTC_JPY", func(productCode types.ProductCode, snapshot *orderbook.Snapshot, callbackData interface{}) {}, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if err := replayApiClient.Replay(); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if atomic.LoadInt32(&requests) != 0 {
+		t.Errorf("rest api is used by replay")
+	}
+	if len(resyncs) != 1 || resyncs[0].Source != realtime.BoardResyncSourceSnapshot ||
+	   !resyncs[0].StaleSince.Equal(start.Add(time.Second)) || !resyncs[0].ResyncedAt.Equal(start.Add(2 * time.Minute)) {
+		t.Errorf("unexpected resyncs: %#v", resyncs)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	_, apiClient, realApiClient := bitflyertest.NewClients(t)
+	calls := make([]string, 0)
+	logging := func(next api.Handler) (api.Handler) {
+		return func(ctx context.Context, call *api.Call) (*http.Response, interface{}, error) {
+			calls = append(calls, call.Method)
+			return next(ctx, call)
+		}
+	}
+	dryRun := func(next api.Handler) (api.Handler) {
+		return func(ctx context.Context, call *api.Call) (*http.Response, interface{}, error) {
+			if call.Method == "PriSendChildOrder" {
+				return nil, &private.SendChildOrderResponse{ChildOrderAcceptanceId: "DRYRUN"}, nil
+			}
+			return next(ctx, call)
+		}
+	}
+	chained := api.ChainAPI(apiClient, logging, dryRun)
+	_, getBoardResponse, err := chained.PubGetBoard("FX_BTC_JPY")
+	if err != nil || getBoardResponse == nil {
+		t.Fatalf("unexpected board: %v, %v", getBoardResponse, err)
+	}
+	_, sendChildOrderResponse, err := chained.PriSendChildOrder("FX_BTC_JPY", types.OrderTypeLimit, types.SideBuy, 100, 0.01, 0, types.TimeInForceGTC)
+	if err != nil || sendChildOrderResponse.ChildOrderAcceptanceId != "DRYRUN" {
+		t.Fatalf("unexpected child order: %v, %v", sendChildOrderResponse, err)
+	}
+	_, childOrders, err := chained.PriGetChildOrdersCtx(context.Background(), "FX_BTC_JPY", 10, 0, 0, types.OrderStateNone)
+	if err != nil || len(childOrders) != 0 {
+		t.Errorf("unexpected child orders: %v, %v", childOrders, err)
+	}
+
+	wrongType := func(next api.Handler) (api.Handler) {
+		return func(ctx context.Context, call *api.Call) (*http.Response, interface{}, error) {
+			return nil, &private.SendChildOrderResponse{}, nil
+		}
+	}
+	if _, getTickerResponse, err := api.ChainPublicAPI(apiClient, wrongType).PubGetTicker("FX_BTC_JPY"); err == nil {
+		t.Errorf("result of a wrong type is not an error: %v", getTickerResponse)
+	}
+
+	realtimeAPI := api.ChainRealtimeAPI(realApiClient, logging)
+	err = realtimeAPI.RealTickerStart("FX_BTC_JPY", func(productCode types.ProductCode, getTickerResponse *public.GetTickerResponse, callbackData interface{}) {}, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	executionsChan, errChan, err := realtimeAPI.RealExecutionsStream("FX_BTC_JPY", nil)
+	if err != nil || executionsChan == nil || errChan == nil {
+		t.Fatalf("unexpected stream: %v, %v, %v", executionsChan, errChan, err)
+	}
+	if err := realtimeAPI.RealStop(); err != nil {
 		t.Errorf("error: %v", err)
 	}
+	if fmt.Sprint(calls) != "[PubGetBoard PriSendChildOrder PriGetChildOrders RealTickerStart RealExecutionsStream RealStop]" {
+		t.Errorf("unexpected calls: %v", calls)
+	}
 }
+
+// orders which were not sent through the middleware get their fills at the sync which tracks them
\ No newline at end of file
@@ -0,0 +1,106 @@
+package api
+
+import (
+	stderrors "errors"
+	"fmt"
+	"net/http"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"github.com/potix/gobitflyer/client"
+)
+
+// The predicates below key on these status codes and the http status code, never on error messages,
+// which bitFlyer localizes and rewords.
+const (
+	BFErrorStatusInsufficientFunds  int64 = -200
+	BFErrorStatusInsufficientMargin int64 = -205
+	BFErrorStatusOrderNotAccepted   int64 = -208
+	BFErrorStatusOrderNotFound      int64 = -300
+)
+
+// APIError is returned by APIClient when bitFlyer responds with an unexpected status code.
+// Status and ErrorMessage are taken from the error body ({"status":-200,"error_message":"..."}) if present.
+type APIError struct {
+	Name           string
+	Method         string
+	URL            string
+	Path           string
+	HTTPStatusCode int
+	HTTPStatus     string
+	Status         int64
+	ErrorMessage   string
+	Body           []byte
+}
+
+func (e *APIError) Error() (string) {
+	return fmt.Sprintf("unexpected status code of %v (request = %v %v, status = %v, body = %v)", e.Name, e.Method, e.URL, e.HTTPStatus, string(e.Body))
+}
+
+func newAPIError(name string, httpRequest *client.HTTPRequest, httpResponse *http.Response, body []byte) (*APIError) {
+	apiError := &APIError{
+		Name:           name,
+		Method:         httpRequest.Method,
+		URL:            httpRequest.URL,
+		Path:           httpRequest.PathQuery,
+		HTTPStatusCode: httpResponse.StatusCode,
+		HTTPStatus:     httpResponse.Status,
+		Body:           body,
+	}
+	errorBody := struct {
+		Status       int64  `json:"status"`
+		ErrorMessage string `json:"error_message"`
+	}{}
+	// the body is not always json (e.g. maintenance page), ignore the error
+	json.Unmarshal(body, &errorBody)
+	apiError.Status = errorBody.Status
+	apiError.ErrorMessage = errorBody.ErrorMessage
+	return apiError
+}
+
+func AsAPIError(err error) (*APIError, bool) {
+	var apiError *APIError
+	if stderrors.As(errors.Cause(err), &apiError) {
+		return apiError, true
+	}
+	return nil, false
+}
+
+func IsRateLimited(err error) (bool) {
+	var rateLimitError *RateLimitError
+	if stderrors.As(errors.Cause(err), &rateLimitError) {
+		return true
+	}
+	apiError, ok := AsAPIError(err)
+	if !ok {
+		return false
+	}
+	return apiError.HTTPStatusCode == http.StatusTooManyRequests
+}
+
+func IsInsufficientFunds(err error) (bool) {
+	apiError, ok := AsAPIError(err)
+	if !ok {
+		return false
+	}
+	return apiError.Status == BFErrorStatusInsufficientFunds || apiError.Status == BFErrorStatusInsufficientMargin
+}
+
+func IsOrderNotFound(err error) (bool) {
+	apiError, ok := AsAPIError(err)
+	if !ok {
+		return false
+	}
+	return apiError.HTTPStatusCode == http.StatusNotFound || apiError.Status == BFErrorStatusOrderNotFound
+}
+
+func IsServerBusy(err error) (bool) {
+	apiError, ok := AsAPIError(err)
+	if !ok {
+		return false
+	}
+	switch apiError.HTTPStatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return apiError.Status == BFErrorStatusOrderNotAccepted
+}
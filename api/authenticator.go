@@ -10,12 +10,20 @@ import (
 	"encoding/hex"
         "io/ioutil"
         "github.com/pkg/errors"
+        "github.com/potix/gobitflyer/api/realtime"
 )
 
 type Authenticator interface {
 	SetAuthHeaders(headers map[string]string, now time.Time, method string, path string, body []byte)
 }
 
+// RealtimeAuthenticator is an Authenticator which also signs the auth request of private realtime channels.
+// RealAPIClient detects it by a type assertion.
+type RealtimeAuthenticator interface {
+	Authenticator
+	SetRealtimeAuthParams(params *realtime.JsonRPC2AuthParams, now time.Time, nonce string)
+}
+
 type authenticator struct {
 	apiKeyFile string
 	apiKey     string
@@ -37,6 +45,17 @@ func (a *authenticator) SetAuthHeaders(headers map[string]string, now time.Time,
 	headers["ACCESS-SIGN"] = sign
 }
 
+func (a *authenticator) SetRealtimeAuthParams(params *realtime.JsonRPC2AuthParams, now time.Time, nonce string) {
+	timestamp := now.UnixNano() / int64(time.Millisecond)
+	mac := hmac.New(sha256.New, []byte(a.apiSecret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte(nonce))
+	params.ApiKey = a.apiKey
+	params.Timestamp = timestamp
+	params.Nonce = nonce
+	params.Signature = hex.EncodeToString(mac.Sum(nil))
+}
+
 func (a *authenticator) LoadAPIKey() (error) {
         fileInfo, err := os.Stat(a.apiKeyFile)
         if err != nil {
@@ -68,3 +87,10 @@ func NewAuthenticator(apiKeyFile string) (Authenticator, error){
 	}
 	return a, nil
 }
+
+func NewAuthenticatorFromKey(apiKey string, apiSecret string) (Authenticator) {
+	return &authenticator {
+		apiKey:    apiKey,
+		apiSecret: apiSecret,
+	}
+}
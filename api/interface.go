@@ -0,0 +1,129 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"github.com/potix/gobitflyer/api/types"
+	"github.com/potix/gobitflyer/api/public"
+	"github.com/potix/gobitflyer/api/private"
+	"github.com/potix/gobitflyer/api/realtime"
+	"github.com/potix/gobitflyer/orderbook"
+)
+
+// PublicAPI is implemented by APIClient. Iterators are built on APIClient and are not a part of it.
+type PublicAPI interface {
+	PubGetMarkets() (*http.Response, public.GetMarketsResponse, error)
+	PubGetMarketsCtx(ctx context.Context) (*http.Response, public.GetMarketsResponse, error)
+	PubGetBoard(productCode types.ProductCode) (*http.Response, *public.GetBoardResponse, error)
+	PubGetBoardCtx(ctx context.Context, productCode types.ProductCode) (*http.Response, *public.GetBoardResponse, error)
+	PubGetTicker(productCode types.ProductCode) (*http.Response, *public.GetTickerResponse, error)
+	PubGetTickerCtx(ctx context.Context, productCode types.ProductCode) (*http.Response, *public.GetTickerResponse, error)
+	PubGetExecutions(productCode types.ProductCode, count int64, before int64, after int64) (*http.Response, public.GetExecutionsResponse, error)
+	PubGetExecutionsCtx(ctx context.Context, productCode types.ProductCode, count int64, before int64, after int64) (*http.Response, public.GetExecutionsResponse, error)
+	PubGetBoardState(productCode types.ProductCode) (*http.Response, *public.GetBoardStateResponse, error)
+	PubGetBoardStateCtx(ctx context.Context, productCode types.ProductCode) (*http.Response, *public.GetBoardStateResponse, error)
+	PubGetHealth(productCode types.ProductCode) (*http.Response, *public.GetHealthResponse, error)
+	PubGetHealthCtx(ctx context.Context, productCode types.ProductCode) (*http.Response, *public.GetHealthResponse, error)
+	PubGetChats(fromDate int64) (*http.Response, *public.GetChatsResponse, error)
+	PubGetChatsCtx(ctx context.Context, fromDate int64) (*http.Response, *public.GetChatsResponse, error)
+}
+
+// PrivateAPI is implemented by APIClient and paper.Client.
+type PrivateAPI interface {
+	PriGetPermissions() (*http.Response, *private.GetPermissionsResponse, error)
+	PriGetPermissionsCtx(ctx context.Context) (*http.Response, *private.GetPermissionsResponse, error)
+	PriGetBalance() (*http.Response, private.GetBalanceResponse, error)
+	PriGetBalanceCtx(ctx context.Context) (*http.Response, private.GetBalanceResponse, error)
+	PriGetCollateral() (*http.Response, *private.GetCollateralResponse, error)
+	PriGetCollateralCtx(ctx context.Context) (*http.Response, *private.GetCollateralResponse, error)
+	PriGetCollateralAccounts() (*http.Response, private.GetCollateralAccountsResponse, error)
+	PriGetCollateralAccountsCtx(ctx context.Context) (*http.Response, private.GetCollateralAccountsResponse, error)
+	PriGetAddresses() (*http.Response, private.GetAddressesResponse, error)
+	PriGetAddressesCtx(ctx context.Context) (*http.Response, private.GetAddressesResponse, error)
+	PriGetCoinIns(count int64, before int64, after int64) (*http.Response, private.GetCoinInsResponse, error)
+	PriGetCoinInsCtx(ctx context.Context, count int64, before int64, after int64) (*http.Response, private.GetCoinInsResponse, error)
+	PriGetCoinOuts(count int64, before int64, after int64) (*http.Response, private.GetCoinOutsResponse, error)
+	PriGetCoinOutsCtx(ctx context.Context, count int64, before int64, after int64) (*http.Response, private.GetCoinOutsResponse, error)
+	PriGetBankAccounts() (*http.Response, private.GetBankAccountsResponse, error)
+	PriGetBankAccountsCtx(ctx context.Context) (*http.Response, private.GetBankAccountsResponse, error)
+	PriGetDeposits(count int64, before int64, after int64) (*http.Response, private.GetDepositsResponse, error)
+	PriGetDepositsCtx(ctx context.Context, count int64, before int64, after int64) (*http.Response, private.GetDepositsResponse, error)
+	PriWithdraw(currencyCode types.CurrencyCode, bankAccountId int64, amount float64, code string) (*http.Response, *private.WithdrawResponse, error)
+	PriWithdrawCtx(ctx context.Context, currencyCode types.CurrencyCode, bankAccountId int64, amount float64, code string) (*http.Response, *private.WithdrawResponse, error)
+	PriGetWithdrawals(count int64, before int64, after int64) (*http.Response, private.GetWithdrawalsResponse, error)
+	PriGetWithdrawalsCtx(ctx context.Context, count int64, before int64, after int64) (*http.Response, private.GetWithdrawalsResponse, error)
+	PriGetWithdrawalsById(messageId string) (*http.Response, private.GetWithdrawalsResponse, error)
+	PriGetWithdrawalsByIdCtx(ctx context.Context, messageId string) (*http.Response, private.GetWithdrawalsResponse, error)
+	PriSendChildOrder(productCode types.ProductCode, childOrderType types.OrderType, side types.Side, price float64, size float64, minuteToExpire int64, timeInForce types.TimeInForce) (*http.Response, *private.SendChildOrderResponse, error)
+	PriSendChildOrderCtx(ctx context.Context, productCode types.ProductCode, childOrderType types.OrderType, side types.Side, price float64, size float64, minuteToExpire int64, timeInForce types.TimeInForce) (*http.Response, *private.SendChildOrderResponse, error)
+	PriCancelChildOrder(productCode types.ProductCode, idType types.IdType, orderId string) (*http.Response, error)
+	PriCancelChildOrderCtx(ctx context.Context, productCode types.ProductCode, idType types.IdType, orderId string) (*http.Response, error)
+	PriGetChildOrders(productCode types.ProductCode, count int64, before int64, after int64, orderState types.OrderState) (*http.Response, private.GetChildOrdersResponse, error)
+	PriGetChildOrdersCtx(ctx context.Context, productCode types.ProductCode, count int64, before int64, after int64, orderState types.OrderState) (*http.Response, private.GetChildOrdersResponse, error)
+	PriGetChildOrdersById(productCode types.ProductCode, idType types.IdType, orderId string) (*http.Response, private.GetChildOrdersResponse, error)
+	PriGetChildOrdersByIdCtx(ctx context.Context, productCode types.ProductCode, idType types.IdType, orderId string) (*http.Response, private.GetChildOrdersResponse, error)
+	PriCancelAllChildOrders(productCode types.ProductCode) (*http.Response, error)
+	PriCancelAllChildOrdersCtx(ctx context.Context, productCode types.ProductCode) (*http.Response, error)
+	PriGetExecutions(productCode types.ProductCode, count int64, before int64, after int64) (*http.Response, private.GetExecutionsResponse, error)
+	PriGetExecutionsCtx(ctx context.Context, productCode types.ProductCode, count int64, before int64, after int64) (*http.Response, private.GetExecutionsResponse, error)
+	PriGetExecutionsById(productCode types.ProductCode, idType types.IdType, orderId string) (*http.Response, private.GetExecutionsResponse, error)
+	PriGetExecutionsByIdCtx(ctx context.Context, productCode types.ProductCode, idType types.IdType, orderId string) (*http.Response, private.GetExecutionsResponse, error)
+	PriGetBalanceHistory(currencyCode types.CurrencyCode, count int64, before int64, after int64) (*http.Response, private.GetBalanceHistoryResponse, error)
+	PriGetBalanceHistoryCtx(ctx context.Context, currencyCode types.CurrencyCode, count int64, before int64, after int64) (*http.Response, private.GetBalanceHistoryResponse, error)
+	PriGetPositions() (*http.Response, private.GetPositionsResponse, error)
+	PriGetPositionsCtx(ctx context.Context) (*http.Response, private.GetPositionsResponse, error)
+	PriGetCollateralHistory(count int64, before int64, after int64) (*http.Response, private.GetCollateralHistoryResponse, error)
+	PriGetCollateralHistoryCtx(ctx context.Context, count int64, before int64, after int64) (*http.Response, private.GetCollateralHistoryResponse, error)
+	PriGetTradingCommission(productCode types.ProductCode) (*http.Response, *private.GetTradingCommissionResponse, error)
+	PriGetTradingCommissionCtx(ctx context.Context, productCode types.ProductCode) (*http.Response, *private.GetTradingCommissionResponse, error)
+	PriSendParentOrder(orderMethod types.OrderMethod, minuteToExpire int64, timeInForce types.TimeInForce, parameters ...*private.SendParentOrderParameter) (*http.Response, *private.SendParentOrderResponse, error)
+	PriSendParentOrderCtx(ctx context.Context, orderMethod types.OrderMethod, minuteToExpire int64, timeInForce types.TimeInForce, parameters ...*private.SendParentOrderParameter) (*http.Response, *private.SendParentOrderResponse, error)
+	PriCancelParentOrder(productCode types.ProductCode, idType types.IdType, orderId string) (*http.Response, error)
+	PriCancelParentOrderCtx(ctx context.Context, productCode types.ProductCode, idType types.IdType, orderId string) (*http.Response, error)
+	PriGetParentOrders(productCode types.ProductCode, count int64, before int64, after int64, orderState types.OrderState) (*http.Response, private.GetParentOrdersResponse, error)
+	PriGetParentOrdersCtx(ctx context.Context, productCode types.ProductCode, count int64, before int64, after int64, orderState types.OrderState) (*http.Response, private.GetParentOrdersResponse, error)
+	PriGetParentOrder(idType types.IdType, orderId string) (*http.Response, *private.GetParentOrderResponse, error)
+	PriGetParentOrderCtx(ctx context.Context, idType types.IdType, orderId string) (*http.Response, *private.GetParentOrderResponse, error)
+}
+
+// RealtimeAPI is implemented by RealAPIClient.
+type RealtimeAPI interface {
+	RealBoardSnapshotStart(productCode types.ProductCode, callback realtime.BoardSnapshotCallback, callbackData interface{}) (error)
+	RealBoardSnapshotStartCtx(ctx context.Context, productCode types.ProductCode, callback realtime.BoardSnapshotCallback, callbackData interface{}) (error)
+	RealBoardStart(productCode types.ProductCode, callback realtime.BoardCallback, callbackData interface{}, merge bool) (error)
+	RealBoardStartCtx(ctx context.Context, productCode types.ProductCode, callback realtime.BoardCallback, callbackData interface{}, merge bool) (error)
+	RealOrderBookStart(productCode types.ProductCode, callback realtime.OrderBookCallback, callbackData interface{}) (error)
+	RealOrderBookStartCtx(ctx context.Context, productCode types.ProductCode, callback realtime.OrderBookCallback, callbackData interface{}) (error)
+	RealTickerStart(productCode types.ProductCode, callback realtime.TickerCallback, callbackData interface{}) (error)
+	RealTickerStartCtx(ctx context.Context, productCode types.ProductCode, callback realtime.TickerCallback, callbackData interface{}) (error)
+	RealExecutionsStart(productCode types.ProductCode, callback realtime.ExecutionsCallback, callbackData interface{}) (error)
+	RealExecutionsStartCtx(ctx context.Context, productCode types.ProductCode, callback realtime.ExecutionsCallback, callbackData interface{}) (error)
+	RealChildOrderEventsStart(callback realtime.ChildOrderEventsCallback, callbackData interface{}) (error)
+	RealChildOrderEventsStartCtx(ctx context.Context, callback realtime.ChildOrderEventsCallback, callbackData interface{}) (error)
+	RealParentOrderEventsStart(callback realtime.ParentOrderEventsCallback, callbackData interface{}) (error)
+	RealParentOrderEventsStartCtx(ctx context.Context, callback realtime.ParentOrderEventsCallback, callbackData interface{}) (error)
+	RealTickerStream(productCode types.ProductCode, streamOptions *StreamOptions) (<-chan *public.GetTickerResponse, <-chan error, error)
+	RealTickerStreamCtx(ctx context.Context, productCode types.ProductCode, streamOptions *StreamOptions) (<-chan *public.GetTickerResponse, <-chan error, error)
+	RealExecutionsStream(productCode types.ProductCode, streamOptions *StreamOptions) (<-chan public.GetExecutionsResponse, <-chan error, error)
+	RealExecutionsStreamCtx(ctx context.Context, productCode types.ProductCode, streamOptions *StreamOptions) (<-chan public.GetExecutionsResponse, <-chan error, error)
+	RealBoardSnapshotStream(productCode types.ProductCode, streamOptions *StreamOptions) (<-chan *public.GetBoardResponse, <-chan error, error)
+	RealBoardSnapshotStreamCtx(ctx context.Context, productCode types.ProductCode, streamOptions *StreamOptions) (<-chan *public.GetBoardResponse, <-chan error, error)
+	RealBoardStream(productCode types.ProductCode, merge bool, streamOptions *StreamOptions) (<-chan *public.GetBoardResponse, <-chan error, error)
+	RealBoardStreamCtx(ctx context.Context, productCode types.ProductCode, merge bool, streamOptions *StreamOptions) (<-chan *public.GetBoardResponse, <-chan error, error)
+	RealOrderBookStream(productCode types.ProductCode, streamOptions *StreamOptions) (<-chan *orderbook.Snapshot, <-chan error, error)
+	RealOrderBookStreamCtx(ctx context.Context, productCode types.ProductCode, streamOptions *StreamOptions) (<-chan *orderbook.Snapshot, <-chan error, error)
+	RealChildOrderEventsStream(streamOptions *StreamOptions) (<-chan realtime.ChildOrderEvents, <-chan error, error)
+	RealChildOrderEventsStreamCtx(ctx context.Context, streamOptions *StreamOptions) (<-chan realtime.ChildOrderEvents, <-chan error, error)
+	RealParentOrderEventsStream(streamOptions *StreamOptions) (<-chan realtime.ParentOrderEvents, <-chan error, error)
+	RealParentOrderEventsStreamCtx(ctx context.Context, streamOptions *StreamOptions) (<-chan realtime.ParentOrderEvents, <-chan error, error)
+	RealUnsubscribe(realtimeType types.RealtimeType, productCode types.ProductCode) (error)
+	RealStop() (error)
+}
+// API is the rest api of APIClient.
+type API interface {
+	PublicAPI
+	PrivateAPI
+}
+
+var _ API = (*APIClient)(nil)
+var _ RealtimeAPI = (*RealAPIClient)(nil)
@@ -0,0 +1,531 @@
+package api
+
+import (
+	"context"
+	"time"
+	"github.com/pkg/errors"
+	"github.com/potix/gobitflyer/api/types"
+	"github.com/potix/gobitflyer/api/public"
+	"github.com/potix/gobitflyer/api/private"
+)
+
+// ErrIteratorDone is returned by Next when there are no more items.
+var ErrIteratorDone = errors.New("no more items in iterator")
+
+type PageDirection int
+
+const (
+	// from the newest item to the oldest item
+	PageDirectionBackward PageDirection = 0
+	// from the oldest item to the newest item
+	PageDirectionForward  PageDirection = 1
+)
+
+const (
+	defaultPageCount int64 = 100
+	// bitFlyer returns at most 500 items per page
+	maxPageCount     int64 = 500
+)
+
+// PageOptions selects the items of an iterator. Zero values mean no bound.
+//
+// bitFlyer returns the newest items of a range on every page, so a forward iterator fetches ranges of ids
+// narrow enough to fit in a page, and walks backward to Since first when Since is given. Items newer than
+// the start of a forward iterator are not returned.
+type PageOptions struct {
+	// items per page, the default is 100 and the maximum is 500
+	Count     int64
+	Direction PageDirection
+	// only items with id > AfterId
+	AfterId   int64
+	// only items with id < BeforeId
+	BeforeId  int64
+	// only items at or after Since
+	Since     time.Time
+	// only items before Until
+	Until     time.Time
+	// read requests left for other callers, the iterator waits while the remaining count of
+	// the read rate limiter is not more than Reserve
+	Reserve   int64
+}
+
+type pageItem struct {
+	id    int64
+	date  time.Time
+	value interface{}
+}
+
+// fetchPage returns the items of a page newest first.
+type fetchPage func(ctx context.Context, count int64, before int64, after int64) ([]*pageItem, error)
+
+type pageIterator struct {
+	apiClient *APIClient
+	fetch     fetchPage
+	options   PageOptions
+	// items to return in order
+	items     []*pageItem
+	// before of the next page (backward) or after of the next page (forward)
+	cursor    int64
+	// before of the last page of a forward iterator, 0 until the first page
+	upper     int64
+	// width of ids of the next page of a forward iterator
+	window    int64
+	done      bool
+}
+
+func newPageIterator(apiClient *APIClient, pageOptions *PageOptions, fetch fetchPage) (*pageIterator) {
+	i := &pageIterator{
+		apiClient: apiClient,
+		fetch:     fetch,
+	}
+	if pageOptions != nil {
+		i.options = *pageOptions
+	}
+	if i.options.Count <= 0 {
+		i.options.Count = defaultPageCount
+	} else if i.options.Count > maxPageCount {
+		i.options.Count = maxPageCount
+	}
+	i.window = i.options.Count
+	if i.options.Direction == PageDirectionForward {
+		i.cursor = i.options.AfterId
+	} else {
+		i.cursor = i.options.BeforeId
+	}
+	return i
+}
+
+func (i *pageIterator) fetchCtx(ctx context.Context, before int64, after int64) ([]*pageItem, error) {
+	err := i.apiClient.readRateLimiter.waitRemainingCtx(ctx, i.options.Reserve)
+	if err != nil {
+		return nil, err
+	}
+	return i.fetch(ctx, i.options.Count, before, after)
+}
+
+// startForward fixes the range of a forward iterator. The upper bound is the newest item at the start, and the cursor
+// is moved to the newest item before Since.
+func (i *pageIterator) startForward(ctx context.Context) (error) {
+	page, err := i.fetchCtx(ctx, i.options.BeforeId, i.cursor)
+	if err != nil {
+		return err
+	}
+	if len(page) == 0 {
+		i.done = true
+		return nil
+	}
+	i.upper = page[0].id + 1
+	if i.options.Since.IsZero() {
+		return nil
+	}
+	// walk backward to Since, only the boundary is kept
+	for {
+		for _, item := range page {
+			if item.date.Before(i.options.Since) {
+				i.cursor = item.id
+				return nil
+			}
+		}
+		if int64(len(page)) < i.options.Count {
+			return nil
+		}
+		page, err = i.fetchCtx(ctx, page[len(page) - 1].id, i.cursor)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (i *pageIterator) fillBackward(ctx context.Context) (error) {
+	page, err := i.fetchCtx(ctx, i.cursor, i.options.AfterId)
+	if err != nil {
+		return err
+	}
+	if int64(len(page)) < i.options.Count {
+		i.done = true
+	}
+	if len(page) > 0 {
+		i.cursor = page[len(page) - 1].id
+	}
+	for _, item := range page {
+		if !i.options.Since.IsZero() && item.date.Before(i.options.Since) {
+			i.done = true
+			break
+		}
+		if !i.options.Until.IsZero() && !item.date.Before(i.options.Until) {
+			continue
+		}
+		i.items = append(i.items, item)
+	}
+	return nil
+}
+
+// fillForward fetches the items of ids between the cursor and the cursor + window. The window is narrowed while the
+// page is not the whole range and widened while pages are sparse, so that a page is held at a time.
+func (i *pageIterator) fillForward(ctx context.Context) (error) {
+	if i.upper == 0 {
+		if err := i.startForward(ctx); err != nil {
+			return err
+		}
+		if i.done {
+			return nil
+		}
+	}
+	for {
+		if i.cursor >= i.upper - 1 {
+			i.done = true
+			return nil
+		}
+		before := i.upper
+		if i.window < i.upper - i.cursor - 1 {
+			before = i.cursor + i.window + 1
+		}
+		page, err := i.fetchCtx(ctx, before, i.cursor)
+		if err != nil {
+			return err
+		}
+		if int64(len(page)) >= i.options.Count && page[len(page) - 1].id != i.cursor + 1 {
+			// older items of the range are not in the page
+			i.window = page[len(page) - 1].id - i.cursor - 1
+			continue
+		}
+		i.cursor = before - 1
+		if int64(len(page)) < i.options.Count / 2 && i.window < i.upper {
+			i.window *= 2
+		}
+		for j := len(page) - 1; j >= 0; j -= 1 {
+			item := page[j]
+			if !i.options.Since.IsZero() && item.date.Before(i.options.Since) {
+				continue
+			}
+			if !i.options.Until.IsZero() && !item.date.Before(i.options.Until) {
+				i.done = true
+				break
+			}
+			i.items = append(i.items, item)
+		}
+		return nil
+	}
+}
+
+func (i *pageIterator) next(ctx context.Context) (interface{}, error) {
+	for len(i.items) == 0 {
+		if i.done {
+			return nil, ErrIteratorDone
+		}
+		var err error
+		if i.options.Direction == PageDirectionForward {
+			err = i.fillForward(ctx)
+		} else {
+			err = i.fillBackward(ctx)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	item := i.items[0]
+	i.items[0] = nil
+	i.items = i.items[1:]
+	return item.value, nil
+}
+
+// stream sends items by send until the end, then the error channel receives nil or the error.
+func (i *pageIterator) stream(ctx context.Context, send func(value interface{}) (bool), finish func()) (<-chan error) {
+	errChan := make(chan error, 1)
+	go func() {
+		defer close(errChan)
+		defer finish()
+		for {
+			value, err := i.next(ctx)
+			if err == ErrIteratorDone {
+				errChan <- nil
+				return
+			}
+			if err != nil {
+				errChan <- err
+				return
+			}
+			if !send(value) {
+				errChan <- ctx.Err()
+				return
+			}
+		}
+	}()
+	return errChan
+}
+
+type PubExecutionsIterator struct {
+	iterator *pageIterator
+}
+
+func (i *PubExecutionsIterator) Next() (*public.GetExecutionsExecution, error) {
+	return i.NextCtx(context.Background())
+}
+
+func (i *PubExecutionsIterator) NextCtx(ctx context.Context) (*public.GetExecutionsExecution, error) {
+	value, err := i.iterator.next(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return value.(*public.GetExecutionsExecution), nil
+}
+
+// Stream sends the rest of items to the channel, which is closed at the end.
+func (i *PubExecutionsIterator) Stream(ctx context.Context) (<-chan *public.GetExecutionsExecution, <-chan error) {
+	executionChan := make(chan *public.GetExecutionsExecution)
+	errChan := i.iterator.stream(ctx, func(value interface{}) (bool) {
+		select {
+		case executionChan <- value.(*public.GetExecutionsExecution):
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}, func() { close(executionChan) })
+	return executionChan, errChan
+}
+
+func (c *APIClient) PubGetExecutionsIterator(productCode types.ProductCode, pageOptions *PageOptions) (*PubExecutionsIterator) {
+	return &PubExecutionsIterator{
+		iterator: newPageIterator(c, pageOptions, func(ctx context.Context, count int64, before int64, after int64) ([]*pageItem, error) {
+			_, getExecutionsResponse, err := c.PubGetExecutionsCtx(ctx, productCode, count, before, after)
+			if err != nil {
+				return nil, errors.Wrapf(err, "can not get executions")
+			}
+			items := make([]*pageItem, 0, len(getExecutionsResponse))
+			for _, execution := range getExecutionsResponse {
+				items = append(items, &pageItem{id: execution.Id, date: execution.ExecDate.Time, value: execution})
+			}
+			return items, nil
+		}),
+	}
+}
+
+type PriExecutionsIterator struct {
+	iterator *pageIterator
+}
+
+func (i *PriExecutionsIterator) Next() (*private.GetExecutionsExecution, error) {
+	return i.NextCtx(context.Background())
+}
+
+func (i *PriExecutionsIterator) NextCtx(ctx context.Context) (*private.GetExecutionsExecution, error) {
+	value, err := i.iterator.next(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return value.(*private.GetExecutionsExecution), nil
+}
+
+// Stream sends the rest of items to the channel, which is closed at the end.
+func (i *PriExecutionsIterator) Stream(ctx context.Context) (<-chan *private.GetExecutionsExecution, <-chan error) {
+	executionChan := make(chan *private.GetExecutionsExecution)
+	errChan := i.iterator.stream(ctx, func(value interface{}) (bool) {
+		select {
+		case executionChan <- value.(*private.GetExecutionsExecution):
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}, func() { close(executionChan) })
+	return executionChan, errChan
+}
+
+func (c *APIClient) PriGetExecutionsIterator(productCode types.ProductCode, pageOptions *PageOptions) (*PriExecutionsIterator) {
+	return &PriExecutionsIterator{
+		iterator: newPageIterator(c, pageOptions, func(ctx context.Context, count int64, before int64, after int64) ([]*pageItem, error) {
+			_, getExecutionsResponse, err := c.PriGetExecutionsCtx(ctx, productCode, count, before, after)
+			if err != nil {
+				return nil, errors.Wrapf(err, "can not get executions")
+			}
+			items := make([]*pageItem, 0, len(getExecutionsResponse))
+			for _, execution := range getExecutionsResponse {
+				items = append(items, &pageItem{id: execution.Id, date: execution.ExecDate.Time, value: execution})
+			}
+			return items, nil
+		}),
+	}
+}
+
+type PriChildOrdersIterator struct {
+	iterator *pageIterator
+}
+
+func (i *PriChildOrdersIterator) Next() (*private.GetChildOrdersOrder, error) {
+	return i.NextCtx(context.Background())
+}
+
+func (i *PriChildOrdersIterator) NextCtx(ctx context.Context) (*private.GetChildOrdersOrder, error) {
+	value, err := i.iterator.next(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return value.(*private.GetChildOrdersOrder), nil
+}
+
+// Stream sends the rest of items to the channel, which is closed at the end.
+func (i *PriChildOrdersIterator) Stream(ctx context.Context) (<-chan *private.GetChildOrdersOrder, <-chan error) {
+	orderChan := make(chan *private.GetChildOrdersOrder)
+	errChan := i.iterator.stream(ctx, func(value interface{}) (bool) {
+		select {
+		case orderChan <- value.(*private.GetChildOrdersOrder):
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}, func() { close(orderChan) })
+	return orderChan, errChan
+}
+
+func (c *APIClient) PriGetChildOrdersIterator(productCode types.ProductCode, orderState types.OrderState, pageOptions *PageOptions) (*PriChildOrdersIterator) {
+	return &PriChildOrdersIterator{
+		iterator: newPageIterator(c, pageOptions, func(ctx context.Context, count int64, before int64, after int64) ([]*pageItem, error) {
+			_, getChildOrdersResponse, err := c.PriGetChildOrdersCtx(ctx, productCode, count, before, after, orderState)
+			if err != nil {
+				return nil, errors.Wrapf(err, "can not get child orders")
+			}
+			items := make([]*pageItem, 0, len(getChildOrdersResponse))
+			for _, order := range getChildOrdersResponse {
+				items = append(items, &pageItem{id: order.Id, date: order.ChildOrderDate.Time, value: order})
+			}
+			return items, nil
+		}),
+	}
+}
+
+type PriParentOrdersIterator struct {
+	iterator *pageIterator
+}
+
+func (i *PriParentOrdersIterator) Next() (*private.GetParentOrdersOrder, error) {
+	return i.NextCtx(context.Background())
+}
+
+func (i *PriParentOrdersIterator) NextCtx(ctx context.Context) (*private.GetParentOrdersOrder, error) {
+	value, err := i.iterator.next(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return value.(*private.GetParentOrdersOrder), nil
+}
+
+// Stream sends the rest of items to the channel, which is closed at the end.
+func (i *PriParentOrdersIterator) Stream(ctx context.Context) (<-chan *private.GetParentOrdersOrder, <-chan error) {
+	orderChan := make(chan *private.GetParentOrdersOrder)
+	errChan := i.iterator.stream(ctx, func(value interface{}) (bool) {
+		select {
+		case orderChan <- value.(*private.GetParentOrdersOrder):
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}, func() { close(orderChan) })
+	return orderChan, errChan
+}
+
+func (c *APIClient) PriGetParentOrdersIterator(productCode types.ProductCode, orderState types.OrderState, pageOptions *PageOptions) (*PriParentOrdersIterator) {
+	return &PriParentOrdersIterator{
+		iterator: newPageIterator(c, pageOptions, func(ctx context.Context, count int64, before int64, after int64) ([]*pageItem, error) {
+			_, getParentOrdersResponse, err := c.PriGetParentOrdersCtx(ctx, productCode, count, before, after, orderState)
+			if err != nil {
+				return nil, errors.Wrapf(err, "can not get parent orders")
+			}
+			items := make([]*pageItem, 0, len(getParentOrdersResponse))
+			for _, order := range getParentOrdersResponse {
+				items = append(items, &pageItem{id: order.Id, date: order.ParentOrderDate.Time, value: order})
+			}
+			return items, nil
+		}),
+	}
+}
+
+type PriBalanceHistoryIterator struct {
+	iterator *pageIterator
+}
+
+func (i *PriBalanceHistoryIterator) Next() (*private.GetBalanceHistoryEvent, error) {
+	return i.NextCtx(context.Background())
+}
+
+func (i *PriBalanceHistoryIterator) NextCtx(ctx context.Context) (*private.GetBalanceHistoryEvent, error) {
+	value, err := i.iterator.next(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return value.(*private.GetBalanceHistoryEvent), nil
+}
+
+// Stream sends the rest of items to the channel, which is closed at the end.
+func (i *PriBalanceHistoryIterator) Stream(ctx context.Context) (<-chan *private.GetBalanceHistoryEvent, <-chan error) {
+	eventChan := make(chan *private.GetBalanceHistoryEvent)
+	errChan := i.iterator.stream(ctx, func(value interface{}) (bool) {
+		select {
+		case eventChan <- value.(*private.GetBalanceHistoryEvent):
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}, func() { close(eventChan) })
+	return eventChan, errChan
+}
+
+func (c *APIClient) PriGetBalanceHistoryIterator(currencyCode types.CurrencyCode, pageOptions *PageOptions) (*PriBalanceHistoryIterator) {
+	return &PriBalanceHistoryIterator{
+		iterator: newPageIterator(c, pageOptions, func(ctx context.Context, count int64, before int64, after int64) ([]*pageItem, error) {
+			_, getBalanceHistoryResponse, err := c.PriGetBalanceHistoryCtx(ctx, currencyCode, count, before, after)
+			if err != nil {
+				return nil, errors.Wrapf(err, "can not get balance history")
+			}
+			items := make([]*pageItem, 0, len(getBalanceHistoryResponse))
+			for _, event := range getBalanceHistoryResponse {
+				items = append(items, &pageItem{id: event.Id, date: event.TradeDate.Time, value: event})
+			}
+			return items, nil
+		}),
+	}
+}
+
+type PriCollateralHistoryIterator struct {
+	iterator *pageIterator
+}
+
+func (i *PriCollateralHistoryIterator) Next() (*private.GetCollateralHistoryEvent, error) {
+	return i.NextCtx(context.Background())
+}
+
+func (i *PriCollateralHistoryIterator) NextCtx(ctx context.Context) (*private.GetCollateralHistoryEvent, error) {
+	value, err := i.iterator.next(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return value.(*private.GetCollateralHistoryEvent), nil
+}
+
+// Stream sends the rest of items to the channel, which is closed at the end.
+func (i *PriCollateralHistoryIterator) Stream(ctx context.Context) (<-chan *private.GetCollateralHistoryEvent, <-chan error) {
+	eventChan := make(chan *private.GetCollateralHistoryEvent)
+	errChan := i.iterator.stream(ctx, func(value interface{}) (bool) {
+		select {
+		case eventChan <- value.(*private.GetCollateralHistoryEvent):
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}, func() { close(eventChan) })
+	return eventChan, errChan
+}
+
+func (c *APIClient) PriGetCollateralHistoryIterator(pageOptions *PageOptions) (*PriCollateralHistoryIterator) {
+	return &PriCollateralHistoryIterator{
+		iterator: newPageIterator(c, pageOptions, func(ctx context.Context, count int64, before int64, after int64) ([]*pageItem, error) {
+			_, getCollateralHistoryResponse, err := c.PriGetCollateralHistoryCtx(ctx, count, before, after)
+			if err != nil {
+				return nil, errors.Wrapf(err, "can not get collateral history")
+			}
+			items := make([]*pageItem, 0, len(getCollateralHistoryResponse))
+			for _, event := range getCollateralHistoryResponse {
+				items = append(items, &pageItem{id: event.Id, date: event.Date.Time, value: event})
+			}
+			return items, nil
+		}),
+	}
+}
@@ -0,0 +1,814 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"github.com/pkg/errors"
+	"github.com/potix/gobitflyer/api/types"
+	"github.com/potix/gobitflyer/api/public"
+	"github.com/potix/gobitflyer/api/private"
+	"github.com/potix/gobitflyer/api/realtime"
+	"github.com/potix/gobitflyer/orderbook"
+)
+
+// Call is a method call which passes through middlewares.
+type Call struct {
+	// name of the method without Ctx, e.g. PriSendChildOrder
+	Method string
+	// arguments without ctx, a variadic argument is a slice
+	Args   []interface{}
+	invoke func(ctx context.Context) (*http.Response, interface{}, error)
+}
+
+// Handler returns the http response (nil for realtime methods), the result (nil for methods without it) and the
+// error of a call. A nil result is returned as the zero value, and a result of a wrong type is an error.
+type Handler func(ctx context.Context, call *Call) (*http.Response, interface{}, error)
+
+// Middleware decorates calls, e.g. for metrics, logging, retry or dry run. It calls next to continue the call,
+// possibly more than once, or returns its own result without next.
+type Middleware func(next Handler) (Handler)
+
+func resultTypeError(method string, result interface{}) (error) {
+	return errors.Errorf("unexpected result type of middleware (method = %v, type = %T)", method, result)
+}
+
+func chainMiddlewares(middlewares []Middleware) (Handler) {
+	handler := Handler(func(ctx context.Context, call *Call) (*http.Response, interface{}, error) {
+		return call.invoke(ctx)
+	})
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
+
+type publicAPI struct {
+	next    PublicAPI
+	handler Handler
+}
+
+func (a *publicAPI) PubGetMarkets() (*http.Response, public.GetMarketsResponse, error) {
+	return a.PubGetMarketsCtx(context.Background())
+}
+
+func (a *publicAPI) PubGetMarketsCtx(ctx context.Context) (*http.Response, public.GetMarketsResponse, error) {
+	response, result, err := a.handler(ctx, &Call{Method: "PubGetMarkets", invoke: func(ctx context.Context) (*http.Response, interface{}, error) {
+		return a.next.PubGetMarketsCtx(ctx)
+	}})
+	value, ok := result.(public.GetMarketsResponse)
+	if !ok && result != nil && err == nil {
+		err = resultTypeError("PubGetMarkets", result)
+	}
+	return response, value, err
+}
+
+func (a *publicAPI) PubGetBoard(productCode types.ProductCode) (*http.Response, *public.GetBoardResponse, error) {
+	return a.PubGetBoardCtx(context.Background(), productCode)
+}
+
+func (a *publicAPI) PubGetBoardCtx(ctx context.Context, productCode types.ProductCode) (*http.Response, *public.GetBoardResponse, error) {
+	response, result, err := a.handler(ctx, &Call{Method: "PubGetBoard", Args: []interface{}{productCode}, invoke: func(ctx context.Context) (*http.Response, interface{}, error) {
+		return a.next.PubGetBoardCtx(ctx, productCode)
+	}})
+	value, ok := result.(*public.GetBoardResponse)
+	if !ok && result != nil && err == nil {
+		err = resultTypeError("PubGetBoard", result)
+	}
+	return response, value, err
+}
+
+func (a *publicAPI) PubGetTicker(productCode types.ProductCode) (*http.Response, *public.GetTickerResponse, error) {
+	return a.PubGetTickerCtx(context.Background(), productCode)
+}
+
+func (a *publicAPI) PubGetTickerCtx(ctx context.Context, productCode types.ProductCode) (*http.Response, *public.GetTickerResponse, error) {
+	response, result, err := a.handler(ctx, &Call{Method: "PubGetTicker", Args: []interface{}{productCode}, invoke: func(ctx context.Context) (*http.Response, interface{}, error) {
+		return a.next.PubGetTickerCtx(ctx, productCode)
+	}})
+	value, ok := result.(*public.GetTickerResponse)
+	if !ok && result != nil && err == nil {
+		err = resultTypeError("PubGetTicker", result)
+	}
+	return response, value, err
+}
+
+func (a *publicAPI) PubGetExecutions(productCode types.ProductCode, count int64, before int64, after int64) (*http.Response, public.GetExecutionsResponse, error) {
+	return a.PubGetExecutionsCtx(context.Background(), productCode, count, before, after)
+}
+
+func (a *publicAPI) PubGetExecutionsCtx(ctx context.Context, productCode types.ProductCode, count int64, before int64, after int64) (*http.Response, public.GetExecutionsResponse, error) {
+	response, result, err := a.handler(ctx, &Call{Method: "PubGetExecutions", Args: []interface{}{productCode, count, before, after}, invoke: func(ctx context.Context) (*http.Response, interface{}, error) {
+		return a.next.PubGetExecutionsCtx(ctx, productCode, count, before, after)
+	}})
+	value, ok := result.(public.GetExecutionsResponse)
+	if !ok && result != nil && err == nil {
+		err = resultTypeError("PubGetExecutions", result)
+	}
+	return response, value, err
+}
+
+func (a *publicAPI) PubGetBoardState(productCode types.ProductCode) (*http.Response, *public.GetBoardStateResponse, error) {
+	return a.PubGetBoardStateCtx(context.Background(), productCode)
+}
+
+func (a *publicAPI) PubGetBoardStateCtx(ctx context.Context, productCode types.ProductCode) (*http.Response, *public.GetBoardStateResponse, error) {
+	response, result, err := a.handler(ctx, &Call{Method: "PubGetBoardState", Args: []interface{}{productCode}, invoke: func(ctx context.Context) (*http.Response, interface{}, error) {
+		return a.next.PubGetBoardStateCtx(ctx, productCode)
+	}})
+	value, ok := result.(*public.GetBoardStateResponse)
+	if !ok && result != nil && err == nil {
+		err = resultTypeError("PubGetBoardState", result)
+	}
+	return response, value, err
+}
+
+func (a *publicAPI) PubGetHealth(productCode types.ProductCode) (*http.Response, *public.GetHealthResponse, error) {
+	return a.PubGetHealthCtx(context.Background(), productCode)
+}
+
+func (a *publicAPI) PubGetHealthCtx(ctx context.Context, productCode types.ProductCode) (*http.Response, *public.GetHealthResponse, error) {
+	response, result, err := a.handler(ctx, &Call{Method: "PubGetHealth", Args: []interface{}{productCode}, invoke: func(ctx context.Context) (*http.Response, interface{}, error) {
+		return a.next.PubGetHealthCtx(ctx, productCode)
+	}})
+	value, ok := result.(*public.GetHealthResponse)
+	if !ok && result != nil && err == nil {
+		err = resultTypeError("PubGetHealth", result)
+	}
+	return response, value, err
+}
+
+func (a *publicAPI) PubGetChats(fromDate int64) (*http.Response, *public.GetChatsResponse, error) {
+	return a.PubGetChatsCtx(context.Background(), fromDate)
+}
+
+func (a *publicAPI) PubGetChatsCtx(ctx context.Context, fromDate int64) (*http.Response, *public.GetChatsResponse, error) {
+	response, result, err := a.handler(ctx, &Call{Method: "PubGetChats", Args: []interface{}{fromDate}, invoke: func(ctx context.Context) (*http.Response, interface{}, error) {
+		return a.next.PubGetChatsCtx(ctx, fromDate)
+	}})
+	value, ok := result.(*public.GetChatsResponse)
+	if !ok && result != nil && err == nil {
+		err = resultTypeError("PubGetChats", result)
+	}
+	return response, value, err
+}
+
+// ChainPublicAPI returns a PublicAPI whose calls pass through middlewares before next. The first middleware is the outermost.
+func ChainPublicAPI(next PublicAPI, middlewares ...Middleware) (PublicAPI) {
+	return &publicAPI{
+		next:    next,
+		handler: chainMiddlewares(middlewares),
+	}
+}
+
+type privateAPI struct {
+	next    PrivateAPI
+	handler Handler
+}
+
+func (a *privateAPI) PriGetPermissions() (*http.Response, *private.GetPermissionsResponse, error) {
+	return a.PriGetPermissionsCtx(context.Background())
+}
+
+func (a *privateAPI) PriGetPermissionsCtx(ctx context.Context) (*http.Response, *private.GetPermissionsResponse, error) {
+	response, result, err := a.handler(ctx, &Call{Method: "PriGetPermissions", invoke: func(ctx context.Context) (*http.Response, interface{}, error) {
+		return a.next.PriGetPermissionsCtx(ctx)
+	}})
+	value, ok := result.(*private.GetPermissionsResponse)
+	if !ok && result != nil && err == nil {
+		err = resultTypeError("PriGetPermissions", result)
+	}
+	return response, value, err
+}
+
+func (a *privateAPI) PriGetBalance() (*http.Response, private.GetBalanceResponse, error) {
+	return a.PriGetBalanceCtx(context.Background())
+}
+
+func (a *privateAPI) PriGetBalanceCtx(ctx context.Context) (*http.Response, private.GetBalanceResponse, error) {
+	response, result, err := a.handler(ctx, &Call{Method: "PriGetBalance", invoke: func(ctx context.Context) (*http.Response, interface{}, error) {
+		return a.next.PriGetBalanceCtx(ctx)
+	}})
+	value, ok := result.(private.GetBalanceResponse)
+	if !ok && result != nil && err == nil {
+		err = resultTypeError("PriGetBalance", result)
+	}
+	return response, value, err
+}
+
+func (a *privateAPI) PriGetCollateral() (*http.Response, *private.GetCollateralResponse, error) {
+	return a.PriGetCollateralCtx(context.Background())
+}
+
+func (a *privateAPI) PriGetCollateralCtx(ctx context.Context) (*http.Response, *private.GetCollateralResponse, error) {
+	response, result, err := a.handler(ctx, &Call{Method: "PriGetCollateral", invoke: func(ctx context.Context) (*http.Response, interface{}, error) {
+		return a.next.PriGetCollateralCtx(ctx)
+	}})
+	value, ok := result.(*private.GetCollateralResponse)
+	if !ok && result != nil && err == nil {
+		err = resultTypeError("PriGetCollateral", result)
+	}
+	return response, value, err
+}
+
+func (a *privateAPI) PriGetCollateralAccounts() (*http.Response, private.GetCollateralAccountsResponse, error) {
+	return a.PriGetCollateralAccountsCtx(context.Background())
+}
+
+func (a *privateAPI) PriGetCollateralAccountsCtx(ctx context.Context) (*http.Response, private.GetCollateralAccountsResponse, error) {
+	response, result, err := a.handler(ctx, &Call{Method: "PriGetCollateralAccounts", invoke: func(ctx context.Context) (*http.Response, interface{}, error) {
+		return a.next.PriGetCollateralAccountsCtx(ctx)
+	}})
+	value, ok := result.(private.GetCollateralAccountsResponse)
+	if !ok && result != nil && err == nil {
+		err = resultTypeError("PriGetCollateralAccounts", result)
+	}
+	return response, value, err
+}
+
+func (a *privateAPI) PriGetAddresses() (*http.Response, private.GetAddressesResponse, error) {
+	return a.PriGetAddressesCtx(context.Background())
+}
+
+func (a *privateAPI) PriGetAddressesCtx(ctx context.Context) (*http.Response, private.GetAddressesResponse, error) {
+	response, result, err := a.handler(ctx, &Call{Method: "PriGetAddresses", invoke: func(ctx context.Context) (*http.Response, interface{}, error) {
+		return a.next.PriGetAddressesCtx(ctx)
+	}})
+	value, ok := result.(private.GetAddressesResponse)
+	if !ok && result != nil && err == nil {
+		err = resultTypeError("PriGetAddresses", result)
+	}
+	return response, value, err
+}
+
+func (a *privateAPI) PriGetCoinIns(count int64, before int64, after int64) (*http.Response, private.GetCoinInsResponse, error) {
+	return a.PriGetCoinInsCtx(context.Background(), count, before, after)
+}
+
+func (a *privateAPI) PriGetCoinInsCtx(ctx context.Context, count int64, before int64, after int64) (*http.Response, private.GetCoinInsResponse, error) {
+	response, result, err := a.handler(ctx, &Call{Method: "PriGetCoinIns", Args: []interface{}{count, before, after}, invoke: func(ctx context.Context) (*http.Response, interface{}, error) {
+		return a.next.PriGetCoinInsCtx(ctx, count, before, after)
+	}})
+	value, ok := result.(private.GetCoinInsResponse)
+	if !ok && result != nil && err == nil {
+		err = resultTypeError("PriGetCoinIns", result)
+	}
+	return response, value, err
+}
+
+func (a *privateAPI) PriGetCoinOuts(count int64, before int64, after int64) (*http.Response, private.GetCoinOutsResponse, error) {
+	return a.PriGetCoinOutsCtx(context.Background(), count, before, after)
+}
+
+func (a *privateAPI) PriGetCoinOutsCtx(ctx context.Context, count int64, before int64, after int64) (*http.Response, private.GetCoinOutsResponse, error) {
+	response, result, err := a.handler(ctx, &Call{Method: "PriGetCoinOuts", Args: []interface{}{count, before, after}, invoke: func(ctx context.Context) (*http.Response, interface{}, error) {
+		return a.next.PriGetCoinOutsCtx(ctx, count, before, after)
+	}})
+	value, ok := result.(private.GetCoinOutsResponse)
+	if !ok && result != nil && err == nil {
+		err = resultTypeError("PriGetCoinOuts", result)
+	}
+	return response, value, err
+}
+
+func (a *privateAPI) PriGetBankAccounts() (*http.Response, private.GetBankAccountsResponse, error) {
+	return a.PriGetBankAccountsCtx(context.Background())
+}
+
+func (a *privateAPI) PriGetBankAccountsCtx(ctx context.Context) (*http.Response, private.GetBankAccountsResponse, error) {
+	response, result, err := a.handler(ctx, &Call{Method: "PriGetBankAccounts", invoke: func(ctx context.Context) (*http.Response, interface{}, error) {
+		return a.next.PriGetBankAccountsCtx(ctx)
+	}})
+	value, ok := result.(private.GetBankAccountsResponse)
+	if !ok && result != nil && err == nil {
+		err = resultTypeError("PriGetBankAccounts", result)
+	}
+	return response, value, err
+}
+
+func (a *privateAPI) PriGetDeposits(count int64, before int64, after int64) (*http.Response, private.GetDepositsResponse, error) {
+	return a.PriGetDepositsCtx(context.Background(), count, before, after)
+}
+
+func (a *privateAPI) PriGetDepositsCtx(ctx context.Context, count int64, before int64, after int64) (*http.Response, private.GetDepositsResponse, error) {
+	response, result, err := a.handler(ctx, &Call{Method: "PriGetDeposits", Args: []interface{}{count, before, after}, invoke: func(ctx context.Context) (*http.Response, interface{}, error) {
+		return a.next.PriGetDepositsCtx(ctx, count, before, after)
+	}})
+	value, ok := result.(private.GetDepositsResponse)
+	if !ok && result != nil && err == nil {
+		err = resultTypeError("PriGetDeposits", result)
+	}
+	return response, value, err
+}
+
+func (a *privateAPI) PriWithdraw(currencyCode types.CurrencyCode, bankAccountId int64, amount float64, code string) (*http.Response, *private.WithdrawResponse, error) {
+	return a.PriWithdrawCtx(context.Background(), currencyCode, bankAccountId, amount, code)
+}
+
+func (a *privateAPI) PriWithdrawCtx(ctx context.Context, currencyCode types.CurrencyCode, bankAccountId int64, amount float64, code string) (*http.Response, *private.WithdrawResponse, error) {
+	response, result, err := a.handler(ctx, &Call{Method: "PriWithdraw", Args: []interface{}{currencyCode, bankAccountId, amount, code}, invoke: func(ctx context.Context) (*http.Response, interface{}, error) {
+		return a.next.PriWithdrawCtx(ctx, currencyCode, bankAccountId, amount, code)
+	}})
+	value, ok := result.(*private.WithdrawResponse)
+	if !ok && result != nil && err == nil {
+		err = resultTypeError("PriWithdraw", result)
+	}
+	return response, value, err
+}
+
+func (a *privateAPI) PriGetWithdrawals(count int64, before int64, after int64) (*http.Response, private.GetWithdrawalsResponse, error) {
+	return a.PriGetWithdrawalsCtx(context.Background(), count, before, after)
+}
+
+func (a *privateAPI) PriGetWithdrawalsCtx(ctx context.Context, count int64, before int64, after int64) (*http.Response, private.GetWithdrawalsResponse, error) {
+	response, result, err := a.handler(ctx, &Call{Method: "PriGetWithdrawals", Args: []interface{}{count, before, after}, invoke: func(ctx context.Context) (*http.Response, interface{}, error) {
+		return a.next.PriGetWithdrawalsCtx(ctx, count, before, after)
+	}})
+	value, ok := result.(private.GetWithdrawalsResponse)
+	if !ok && result != nil && err == nil {
+		err = resultTypeError("PriGetWithdrawals", result)
+	}
+	return response, value, err
+}
+
+func (a *privateAPI) PriGetWithdrawalsById(messageId string) (*http.Response, private.GetWithdrawalsResponse, error) {
+	return a.PriGetWithdrawalsByIdCtx(context.Background(), messageId)
+}
+
+func (a *privateAPI) PriGetWithdrawalsByIdCtx(ctx context.Context, messageId string) (*http.Response, private.GetWithdrawalsResponse, error) {
+	response, result, err := a.handler(ctx, &Call{Method: "PriGetWithdrawalsById", Args: []interface{}{messageId}, invoke: func(ctx context.Context) (*http.Response, interface{}, error) {
+		return a.next.PriGetWithdrawalsByIdCtx(ctx, messageId)
+	}})
+	value, ok := result.(private.GetWithdrawalsResponse)
+	if !ok && result != nil && err == nil {
+		err = resultTypeError("PriGetWithdrawalsById", result)
+	}
+	return response, value, err
+}
+
+func (a *privateAPI) PriSendChildOrder(productCode types.ProductCode, childOrderType types.OrderType, side types.Side, price float64, size float64, minuteToExpire int64, timeInForce types.TimeInForce) (*http.Response, *private.SendChildOrderResponse, error) {
+	return a.PriSendChildOrderCtx(context.Background(), productCode, childOrderType, side, price, size, minuteToExpire, timeInForce)
+}
+
+func (a *privateAPI) PriSendChildOrderCtx(ctx context.Context, productCode types.ProductCode, childOrderType types.OrderType, side types.Side, price float64, size float64, minuteToExpire int64, timeInForce types.TimeInForce) (*http.Response, *private.SendChildOrderResponse, error) {
+	response, result, err := a.handler(ctx, &Call{Method: "PriSendChildOrder", Args: []interface{}{productCode, childOrderType, side, price, size, minuteToExpire, timeInForce}, invoke: func(ctx context.Context) (*http.Response, interface{}, error) {
+		return a.next.PriSendChildOrderCtx(ctx, productCode, childOrderType, side, price, size, minuteToExpire, timeInForce)
+	}})
+	value, ok := result.(*private.SendChildOrderResponse)
+	if !ok && result != nil && err == nil {
+		err = resultTypeError("PriSendChildOrder", result)
+	}
+	return response, value, err
+}
+
+func (a *privateAPI) PriCancelChildOrder(productCode types.ProductCode, idType types.IdType, orderId string) (*http.Response, error) {
+	return a.PriCancelChildOrderCtx(context.Background(), productCode, idType, orderId)
+}
+
+func (a *privateAPI) PriCancelChildOrderCtx(ctx context.Context, productCode types.ProductCode, idType types.IdType, orderId string) (*http.Response, error) {
+	response, _, err := a.handler(ctx, &Call{Method: "PriCancelChildOrder", Args: []interface{}{productCode, idType, orderId}, invoke: func(ctx context.Context) (*http.Response, interface{}, error) {
+		response, err := a.next.PriCancelChildOrderCtx(ctx, productCode, idType, orderId)
+		return response, nil, err
+	}})
+	return response, err
+}
+
+func (a *privateAPI) PriGetChildOrders(productCode types.ProductCode, count int64, before int64, after int64, orderState types.OrderState) (*http.Response, private.GetChildOrdersResponse, error) {
+	return a.PriGetChildOrdersCtx(context.Background(), productCode, count, before, after, orderState)
+}
+
+func (a *privateAPI) PriGetChildOrdersCtx(ctx context.Context, productCode types.ProductCode, count int64, before int64, after int64, orderState types.OrderState) (*http.Response, private.GetChildOrdersResponse, error) {
+	response, result, err := a.handler(ctx, &Call{Method: "PriGetChildOrders", Args: []interface{}{productCode, count, before, after, orderState}, invoke: func(ctx context.Context) (*http.Response, interface{}, error) {
+		return a.next.PriGetChildOrdersCtx(ctx, productCode, count, before, after, orderState)
+	}})
+	value, ok := result.(private.GetChildOrdersResponse)
+	if !ok && result != nil && err == nil {
+		err = resultTypeError("PriGetChildOrders", result)
+	}
+	return response, value, err
+}
+
+func (a *privateAPI) PriGetChildOrdersById(productCode types.ProductCode, idType types.IdType, orderId string) (*http.Response, private.GetChildOrdersResponse, error) {
+	return a.PriGetChildOrdersByIdCtx(context.Background(), productCode, idType, orderId)
+}
+
+func (a *privateAPI) PriGetChildOrdersByIdCtx(ctx context.Context, productCode types.ProductCode, idType types.IdType, orderId string) (*http.Response, private.GetChildOrdersResponse, error) {
+	response, result, err := a.handler(ctx, &Call{Method: "PriGetChildOrdersById", Args: []interface{}{productCode, idType, orderId}, invoke: func(ctx context.Context) (*http.Response, interface{}, error) {
+		return a.next.PriGetChildOrdersByIdCtx(ctx, productCode, idType, orderId)
+	}})
+	value, ok := result.(private.GetChildOrdersResponse)
+	if !ok && result != nil && err == nil {
+		err = resultTypeError("PriGetChildOrdersById", result)
+	}
+	return response, value, err
+}
+
+func (a *privateAPI) PriCancelAllChildOrders(productCode types.ProductCode) (*http.Response, error) {
+	return a.PriCancelAllChildOrdersCtx(context.Background(), productCode)
+}
+
+func (a *privateAPI) PriCancelAllChildOrdersCtx(ctx context.Context, productCode types.ProductCode) (*http.Response, error) {
+	response, _, err := a.handler(ctx, &Call{Method: "PriCancelAllChildOrders", Args: []interface{}{productCode}, invoke: func(ctx context.Context) (*http.Response, interface{}, error) {
+		response, err := a.next.PriCancelAllChildOrdersCtx(ctx, productCode)
+		return response, nil, err
+	}})
+	return response, err
+}
+
+func (a *privateAPI) PriGetExecutions(productCode types.ProductCode, count int64, before int64, after int64) (*http.Response, private.GetExecutionsResponse, error) {
+	return a.PriGetExecutionsCtx(context.Background(), productCode, count, before, after)
+}
+
+func (a *privateAPI) PriGetExecutionsCtx(ctx context.Context, productCode types.ProductCode, count int64, before int64, after int64) (*http.Response, private.GetExecutionsResponse, error) {
+	response, result, err := a.handler(ctx, &Call{Method: "PriGetExecutions", Args: []interface{}{productCode, count, before, after}, invoke: func(ctx context.Context) (*http.Response, interface{}, error) {
+		return a.next.PriGetExecutionsCtx(ctx, productCode, count, before, after)
+	}})
+	value, ok := result.(private.GetExecutionsResponse)
+	if !ok && result != nil && err == nil {
+		err = resultTypeError("PriGetExecutions", result)
+	}
+	return response, value, err
+}
+
+func (a *privateAPI) PriGetExecutionsById(productCode types.ProductCode, idType types.IdType, orderId string) (*http.Response, private.GetExecutionsResponse, error) {
+	return a.PriGetExecutionsByIdCtx(context.Background(), productCode, idType, orderId)
+}
+
+func (a *privateAPI) PriGetExecutionsByIdCtx(ctx context.Context, productCode types.ProductCode, idType types.IdType, orderId string) (*http.Response, private.GetExecutionsResponse, error) {
+	response, result, err := a.handler(ctx, &Call{Method: "PriGetExecutionsById", Args: []interface{}{productCode, idType, orderId}, invoke: func(ctx context.Context) (*http.Response, interface{}, error) {
+		return a.next.PriGetExecutionsByIdCtx(ctx, productCode, idType, orderId)
+	}})
+	value, ok := result.(private.GetExecutionsResponse)
+	if !ok && result != nil && err == nil {
+		err = resultTypeError("PriGetExecutionsById", result)
+	}
+	return response, value, err
+}
+
+func (a *privateAPI) PriGetBalanceHistory(currencyCode types.CurrencyCode, count int64, before int64, after int64) (*http.Response, private.GetBalanceHistoryResponse, error) {
+	return a.PriGetBalanceHistoryCtx(context.Background(), currencyCode, count, before, after)
+}
+
+func (a *privateAPI) PriGetBalanceHistoryCtx(ctx context.Context, currencyCode types.CurrencyCode, count int64, before int64, after int64) (*http.Response, private.GetBalanceHistoryResponse, error) {
+	response, result, err := a.handler(ctx, &Call{Method: "PriGetBalanceHistory", Args: []interface{}{currencyCode, count, before, after}, invoke: func(ctx context.Context) (*http.Response, interface{}, error) {
+		return a.next.PriGetBalanceHistoryCtx(ctx, currencyCode, count, before, after)
+	}})
+	value, ok := result.(private.GetBalanceHistoryResponse)
+	if !ok && result != nil && err == nil {
+		err = resultTypeError("PriGetBalanceHistory", result)
+	}
+	return response, value, err
+}
+
+func (a *privateAPI) PriGetPositions() (*http.Response, private.GetPositionsResponse, error) {
+	return a.PriGetPositionsCtx(context.Background())
+}
+
+func (a *privateAPI) PriGetPositionsCtx(ctx context.Context) (*http.Response, private.GetPositionsResponse, error) {
+	response, result, err := a.handler(ctx, &Call{Method: "PriGetPositions", invoke: func(ctx context.Context) (*http.Response, interface{}, error) {
+		return a.next.PriGetPositionsCtx(ctx)
+	}})
+	value, ok := result.(private.GetPositionsResponse)
+	if !ok && result != nil && err == nil {
+		err = resultTypeError("PriGetPositions", result)
+	}
+	return response, value, err
+}
+
+func (a *privateAPI) PriGetCollateralHistory(count int64, before int64, after int64) (*http.Response, private.GetCollateralHistoryResponse, error) {
+	return a.PriGetCollateralHistoryCtx(context.Background(), count, before, after)
+}
+
+func (a *privateAPI) PriGetCollateralHistoryCtx(ctx context.Context, count int64, before int64, after int64) (*http.Response, private.GetCollateralHistoryResponse, error) {
+	response, result, err := a.handler(ctx, &Call{Method: "PriGetCollateralHistory", Args: []interface{}{count, before, after}, invoke: func(ctx context.Context) (*http.Response, interface{}, error) {
+		return a.next.PriGetCollateralHistoryCtx(ctx, count, before, after)
+	}})
+	value, ok := result.(private.GetCollateralHistoryResponse)
+	if !ok && result != nil && err == nil {
+		err = resultTypeError("PriGetCollateralHistory", result)
+	}
+	return response, value, err
+}
+
+func (a *privateAPI) PriGetTradingCommission(productCode types.ProductCode) (*http.Response, *private.GetTradingCommissionResponse, error) {
+	return a.PriGetTradingCommissionCtx(context.Background(), productCode)
+}
+
+func (a *privateAPI) PriGetTradingCommissionCtx(ctx context.Context, productCode types.ProductCode) (*http.Response, *private.GetTradingCommissionResponse, error) {
+	response, result, err := a.handler(ctx, &Call{Method: "PriGetTradingCommission", Args: []interface{}{productCode}, invoke: func(ctx context.Context) (*http.Response, interface{}, error) {
+		return a.next.PriGetTradingCommissionCtx(ctx, productCode)
+	}})
+	value, ok := result.(*private.GetTradingCommissionResponse)
+	if !ok && result != nil && err == nil {
+		err = resultTypeError("PriGetTradingCommission", result)
+	}
+	return response, value, err
+}
+
+func (a *privateAPI) PriSendParentOrder(orderMethod types.OrderMethod, minuteToExpire int64, timeInForce types.TimeInForce, parameters ...*private.SendParentOrderParameter) (*http.Response, *private.SendParentOrderResponse, error) {
+	return a.PriSendParentOrderCtx(context.Background(), orderMethod, minuteToExpire, timeInForce, parameters...)
+}
+
+func (a *privateAPI) PriSendParentOrderCtx(ctx context.Context, orderMethod types.OrderMethod, minuteToExpire int64, timeInForce types.TimeInForce, parameters ...*private.SendParentOrderParameter) (*http.Response, *private.SendParentOrderResponse, error) {
+	response, result, err := a.handler(ctx, &Call{Method: "PriSendParentOrder", Args: []interface{}{orderMethod, minuteToExpire, timeInForce, parameters}, invoke: func(ctx context.Context) (*http.Response, interface{}, error) {
+		return a.next.PriSendParentOrderCtx(ctx, orderMethod, minuteToExpire, timeInForce, parameters...)
+	}})
+	value, ok := result.(*private.SendParentOrderResponse)
+	if !ok && result != nil && err == nil {
+		err = resultTypeError("PriSendParentOrder", result)
+	}
+	return response, value, err
+}
+
+func (a *privateAPI) PriCancelParentOrder(productCode types.ProductCode, idType types.IdType, orderId string) (*http.Response, error) {
+	return a.PriCancelParentOrderCtx(context.Background(), productCode, idType, orderId)
+}
+
+func (a *privateAPI) PriCancelParentOrderCtx(ctx context.Context, productCode types.ProductCode, idType types.IdType, orderId string) (*http.Response, error) {
+	response, _, err := a.handler(ctx, &Call{Method: "PriCancelParentOrder", Args: []interface{}{productCode, idType, orderId}, invoke: func(ctx context.Context) (*http.Response, interface{}, error) {
+		response, err := a.next.PriCancelParentOrderCtx(ctx, productCode, idType, orderId)
+		return response, nil, err
+	}})
+	return response, err
+}
+
+func (a *privateAPI) PriGetParentOrders(productCode types.ProductCode, count int64, before int64, after int64, orderState types.OrderState) (*http.Response, private.GetParentOrdersResponse, error) {
+	return a.PriGetParentOrdersCtx(context.Background(), productCode, count, before, after, orderState)
+}
+
+func (a *privateAPI) PriGetParentOrdersCtx(ctx context.Context, productCode types.ProductCode, count int64, before int64, after int64, orderState types.OrderState) (*http.Response, private.GetParentOrdersResponse, error) {
+	response, result, err := a.handler(ctx, &Call{Method: "PriGetParentOrders", Args: []interface{}{productCode, count, before, after, orderState}, invoke: func(ctx context.Context) (*http.Response, interface{}, error) {
+		return a.next.PriGetParentOrdersCtx(ctx, productCode, count, before, after, orderState)
+	}})
+	value, ok := result.(private.GetParentOrdersResponse)
+	if !ok && result != nil && err == nil {
+		err = resultTypeError("PriGetParentOrders", result)
+	}
+	return response, value, err
+}
+
+func (a *privateAPI) PriGetParentOrder(idType types.IdType, orderId string) (*http.Response, *private.GetParentOrderResponse, error) {
+	return a.PriGetParentOrderCtx(context.Background(), idType, orderId)
+}
+
+func (a *privateAPI) PriGetParentOrderCtx(ctx context.Context, idType types.IdType, orderId string) (*http.Response, *private.GetParentOrderResponse, error) {
+	response, result, err := a.handler(ctx, &Call{Method: "PriGetParentOrder", Args: []interface{}{idType, orderId}, invoke: func(ctx context.Context) (*http.Response, interface{}, error) {
+		return a.next.PriGetParentOrderCtx(ctx, idType, orderId)
+	}})
+	value, ok := result.(*private.GetParentOrderResponse)
+	if !ok && result != nil && err == nil {
+		err = resultTypeError("PriGetParentOrder", result)
+	}
+	return response, value, err
+}
+
+// ChainPrivateAPI returns a PrivateAPI whose calls pass through middlewares before next. The first middleware is the outermost.
+func ChainPrivateAPI(next PrivateAPI, middlewares ...Middleware) (PrivateAPI) {
+	return &privateAPI{
+		next:    next,
+		handler: chainMiddlewares(middlewares),
+	}
+}
+
+type chainedAPI struct {
+	PublicAPI
+	PrivateAPI
+}
+
+// ChainAPI is ChainPublicAPI and ChainPrivateAPI of next.
+func ChainAPI(next API, middlewares ...Middleware) (API) {
+	return &chainedAPI{
+		PublicAPI:  ChainPublicAPI(next, middlewares...),
+		PrivateAPI: ChainPrivateAPI(next, middlewares...),
+	}
+}
+
+type realtimeAPI struct {
+	next    RealtimeAPI
+	handler Handler
+}
+
+func (a *realtimeAPI) RealBoardSnapshotStart(productCode types.ProductCode, callback realtime.BoardSnapshotCallback, callbackData interface{}) (error) {
+	return a.RealBoardSnapshotStartCtx(context.Background(), productCode, callback, callbackData)
+}
+
+func (a *realtimeAPI) RealBoardSnapshotStartCtx(ctx context.Context, productCode types.ProductCode, callback realtime.BoardSnapshotCallback, callbackData interface{}) (error) {
+	_, _, err := a.handler(ctx, &Call{Method: "RealBoardSnapshotStart", Args: []interface{}{productCode, callback, callbackData}, invoke: func(ctx context.Context) (*http.Response, interface{}, error) {
+		return nil, nil, a.next.RealBoardSnapshotStartCtx(ctx, productCode, callback, callbackData)
+	}})
+	return err
+}
+
+func (a *realtimeAPI) RealBoardStart(productCode types.ProductCode, callback realtime.BoardCallback, callbackData interface{}, merge bool) (error) {
+	return a.RealBoardStartCtx(context.Background(), productCode, callback, callbackData, merge)
+}
+
+func (a *realtimeAPI) RealBoardStartCtx(ctx context.Context, productCode types.ProductCode, callback realtime.BoardCallback, callbackData interface{}, merge bool) (error) {
+	_, _, err := a.handler(ctx, &Call{Method: "RealBoardStart", Args: []interface{}{productCode, callback, callbackData, merge}, invoke: func(ctx context.Context) (*http.Response, interface{}, error) {
+		return nil, nil, a.next.RealBoardStartCtx(ctx, productCode, callback, callbackData, merge)
+	}})
+	return err
+}
+
+func (a *realtimeAPI) RealOrderBookStart(productCode types.ProductCode, callback realtime.OrderBookCallback, callbackData interface{}) (error) {
+	return a.RealOrderBookStartCtx(context.Background(), productCode, callback, callbackData)
+}
+
+func (a *realtimeAPI) RealOrderBookStartCtx(ctx context.Context, productCode types.ProductCode, callback realtime.OrderBookCallback, callbackData interface{}) (error) {
+	_, _, err := a.handler(ctx, &Call{Method: "RealOrderBookStart", Args: []interface{}{productCode, callback, callbackData}, invoke: func(ctx context.Context) (*http.Response, interface{}, error) {
+		return nil, nil, a.next.RealOrderBookStartCtx(ctx, productCode, callback, callbackData)
+	}})
+	return err
+}
+
+func (a *realtimeAPI) RealTickerStart(productCode types.ProductCode, callback realtime.TickerCallback, callbackData interface{}) (error) {
+	return a.RealTickerStartCtx(context.Background(), productCode, callback, callbackData)
+}
+
+func (a *realtimeAPI) RealTickerStartCtx(ctx context.Context, productCode types.ProductCode, callback realtime.TickerCallback, callbackData interface{}) (error) {
+	_, _, err := a.handler(ctx, &Call{Method: "RealTickerStart", Args: []interface{}{productCode, callback, callbackData}, invoke: func(ctx context.Context) (*http.Response, interface{}, error) {
+		return nil, nil, a.next.RealTickerStartCtx(ctx, productCode, callback, callbackData)
+	}})
+	return err
+}
+
+func (a *realtimeAPI) RealExecutionsStart(productCode types.ProductCode, callback realtime.ExecutionsCallback, callbackData interface{}) (error) {
+	return a.RealExecutionsStartCtx(context.Background(), productCode, callback, callbackData)
+}
+
+func (a *realtimeAPI) RealExecutionsStartCtx(ctx context.Context, productCode types.ProductCode, callback realtime.ExecutionsCallback, callbackData interface{}) (error) {
+	_, _, err := a.handler(ctx, &Call{Method: "RealExecutionsStart", Args: []interface{}{productCode, callback, callbackData}, invoke: func(ctx context.Context) (*http.Response, interface{}, error) {
+		return nil, nil, a.next.RealExecutionsStartCtx(ctx, productCode, callback, callbackData)
+	}})
+	return err
+}
+
+func (a *realtimeAPI) RealChildOrderEventsStart(callback realtime.ChildOrderEventsCallback, callbackData interface{}) (error) {
+	return a.RealChildOrderEventsStartCtx(context.Background(), callback, callbackData)
+}
+
+func (a *realtimeAPI) RealChildOrderEventsStartCtx(ctx context.Context, callback realtime.ChildOrderEventsCallback, callbackData interface{}) (error) {
+	_, _, err := a.handler(ctx, &Call{Method: "RealChildOrderEventsStart", Args: []interface{}{callback, callbackData}, invoke: func(ctx context.Context) (*http.Response, interface{}, error) {
+		return nil, nil, a.next.RealChildOrderEventsStartCtx(ctx, callback, callbackData)
+	}})
+	return err
+}
+
+func (a *realtimeAPI) RealParentOrderEventsStart(callback realtime.ParentOrderEventsCallback, callbackData interface{}) (error) {
+	return a.RealParentOrderEventsStartCtx(context.Background(), callback, callbackData)
+}
+
+func (a *realtimeAPI) RealParentOrderEventsStartCtx(ctx context.Context, callback realtime.ParentOrderEventsCallback, callbackData interface{}) (error) {
+	_, _, err := a.handler(ctx, &Call{Method: "RealParentOrderEventsStart", Args: []interface{}{callback, callbackData}, invoke: func(ctx context.Context) (*http.Response, interface{}, error) {
+		return nil, nil, a.next.RealParentOrderEventsStartCtx(ctx, callback, callbackData)
+	}})
+	return err
+}
+
+func (a *realtimeAPI) RealUnsubscribe(realtimeType types.RealtimeType, productCode types.ProductCode) (error) {
+	_, _, err := a.handler(context.Background(), &Call{Method: "RealUnsubscribe", Args: []interface{}{realtimeType, productCode}, invoke: func(ctx context.Context) (*http.Response, interface{}, error) {
+		return nil, nil, a.next.RealUnsubscribe(realtimeType, productCode)
+	}})
+	return err
+}
+
+func (a *realtimeAPI) RealStop() (error) {
+	_, _, err := a.handler(context.Background(), &Call{Method: "RealStop", invoke: func(ctx context.Context) (*http.Response, interface{}, error) {
+		return nil, nil, a.next.RealStop()
+	}})
+	return err
+}
+
+func (a *realtimeAPI) RealTickerStream(productCode types.ProductCode, streamOptions *StreamOptions) (<-chan *public.GetTickerResponse, <-chan error, error) {
+	return a.RealTickerStreamCtx(context.Background(), productCode, streamOptions)
+}
+
+func (a *realtimeAPI) RealTickerStreamCtx(ctx context.Context, productCode types.ProductCode, streamOptions *StreamOptions) (<-chan *public.GetTickerResponse, <-chan error, error) {
+	var errChan <-chan error
+	_, result, err := a.handler(ctx, &Call{Method: "RealTickerStream", Args: []interface{}{productCode, streamOptions}, invoke: func(ctx context.Context) (*http.Response, interface{}, error) {
+		valueChan, e, err := a.next.RealTickerStreamCtx(ctx, productCode, streamOptions)
+		errChan = e
+		return nil, valueChan, err
+	}})
+	value, ok := result.(<-chan *public.GetTickerResponse)
+	if !ok && result != nil && err == nil {
+		err = resultTypeError("RealTickerStream", result)
+	}
+	return value, errChan, err
+}
+
+func (a *realtimeAPI) RealExecutionsStream(productCode types.ProductCode, streamOptions *StreamOptions) (<-chan public.GetExecutionsResponse, <-chan error, error) {
+	return a.RealExecutionsStreamCtx(context.Background(), productCode, streamOptions)
+}
+
+func (a *realtimeAPI) RealExecutionsStreamCtx(ctx context.Context, productCode types.ProductCode, streamOptions *StreamOptions) (<-chan public.GetExecutionsResponse, <-chan error, error) {
+	var errChan <-chan error
+	_, result, err := a.handler(ctx, &Call{Method: "RealExecutionsStream", Args: []interface{}{productCode, streamOptions}, invoke: func(ctx context.Context) (*http.Response, interface{}, error) {
+		valueChan, e, err := a.next.RealExecutionsStreamCtx(ctx, productCode, streamOptions)
+		errChan = e
+		return nil, valueChan, err
+	}})
+	value, ok := result.(<-chan public.GetExecutionsResponse)
+	if !ok && result != nil && err == nil {
+		err = resultTypeError("RealExecutionsStream", result)
+	}
+	return value, errChan, err
+}
+
+func (a *realtimeAPI) RealBoardSnapshotStream(productCode types.ProductCode, streamOptions *StreamOptions) (<-chan *public.GetBoardResponse, <-chan error, error) {
+	return a.RealBoardSnapshotStreamCtx(context.Background(), productCode, streamOptions)
+}
+
+func (a *realtimeAPI) RealBoardSnapshotStreamCtx(ctx context.Context, productCode types.ProductCode, streamOptions *StreamOptions) (<-chan *public.GetBoardResponse, <-chan error, error) {
+	var errChan <-chan error
+	_, result, err := a.handler(ctx, &Call{Method: "RealBoardSnapshotStream", Args: []interface{}{productCode, streamOptions}, invoke: func(ctx context.Context) (*http.Response, interface{}, error) {
+		valueChan, e, err := a.next.RealBoardSnapshotStreamCtx(ctx, productCode, streamOptions)
+		errChan = e
+		return nil, valueChan, err
+	}})
+	value, ok := result.(<-chan *public.GetBoardResponse)
+	if !ok && result != nil && err == nil {
+		err = resultTypeError("RealBoardSnapshotStream", result)
+	}
+	return value, errChan, err
+}
+
+func (a *realtimeAPI) RealBoardStream(productCode types.ProductCode, merge bool, streamOptions *StreamOptions) (<-chan *public.GetBoardResponse, <-chan error, error) {
+	return a.RealBoardStreamCtx(context.Background(), productCode, merge, streamOptions)
+}
+
+func (a *realtimeAPI) RealBoardStreamCtx(ctx context.Context, productCode types.ProductCode, merge bool, streamOptions *StreamOptions) (<-chan *public.GetBoardResponse, <-chan error, error) {
+	var errChan <-chan error
+	_, result, err := a.handler(ctx, &Call{Method: "RealBoardStream", Args: []interface{}{productCode, merge, streamOptions}, invoke: func(ctx context.Context) (*http.Response, interface{}, error) {
+		valueChan, e, err := a.next.RealBoardStreamCtx(ctx, productCode, merge, streamOptions)
+		errChan = e
+		return nil, valueChan, err
+	}})
+	value, ok := result.(<-chan *public.GetBoardResponse)
+	if !ok && result != nil && err == nil {
+		err = resultTypeError("RealBoardStream", result)
+	}
+	return value, errChan, err
+}
+
+func (a *realtimeAPI) RealOrderBookStream(productCode types.ProductCode, streamOptions *StreamOptions) (<-chan *orderbook.Snapshot, <-chan error, error) {
+	return a.RealOrderBookStreamCtx(context.Background(), productCode, streamOptions)
+}
+
+func (a *realtimeAPI) RealOrderBookStreamCtx(ctx context.Context, productCode types.ProductCode, streamOptions *StreamOptions) (<-chan *orderbook.Snapshot, <-chan error, error) {
+	var errChan <-chan error
+	_, result, err := a.handler(ctx, &Call{Method: "RealOrderBookStream", Args: []interface{}{productCode, streamOptions}, invoke: func(ctx context.Context) (*http.Response, interface{}, error) {
+		valueChan, e, err := a.next.RealOrderBookStreamCtx(ctx, productCode, streamOptions)
+		errChan = e
+		return nil, valueChan, err
+	}})
+	value, ok := result.(<-chan *orderbook.Snapshot)
+	if !ok && result != nil && err == nil {
+		err = resultTypeError("RealOrderBookStream", result)
+	}
+	return value, errChan, err
+}
+
+func (a *realtimeAPI) RealChildOrderEventsStream(streamOptions *StreamOptions) (<-chan realtime.ChildOrderEvents, <-chan error, error) {
+	return a.RealChildOrderEventsStreamCtx(context.Background(), streamOptions)
+}
+
+func (a *realtimeAPI) RealChildOrderEventsStreamCtx(ctx context.Context, streamOptions *StreamOptions) (<-chan realtime.ChildOrderEvents, <-chan error, error) {
+	var errChan <-chan error
+	_, result, err := a.handler(ctx, &Call{Method: "RealChildOrderEventsStream", Args: []interface{}{streamOptions}, invoke: func(ctx context.Context) (*http.Response, interface{}, error) {
+		valueChan, e, err := a.next.RealChildOrderEventsStreamCtx(ctx, streamOptions)
+		errChan = e
+		return nil, valueChan, err
+	}})
+	value, ok := result.(<-chan realtime.ChildOrderEvents)
+	if !ok && result != nil && err == nil {
+		err = resultTypeError("RealChildOrderEventsStream", result)
+	}
+	return value, errChan, err
+}
+
+func (a *realtimeAPI) RealParentOrderEventsStream(streamOptions *StreamOptions) (<-chan realtime.ParentOrderEvents, <-chan error, error) {
+	return a.RealParentOrderEventsStreamCtx(context.Background(), streamOptions)
+}
+
+func (a *realtimeAPI) RealParentOrderEventsStreamCtx(ctx context.Context, streamOptions *StreamOptions) (<-chan realtime.ParentOrderEvents, <-chan error, error) {
+	var errChan <-chan error
+	_, result, err := a.handler(ctx, &Call{Method: "RealParentOrderEventsStream", Args: []interface{}{streamOptions}, invoke: func(ctx context.Context) (*http.Response, interface{}, error) {
+		valueChan, e, err := a.next.RealParentOrderEventsStreamCtx(ctx, streamOptions)
+		errChan = e
+		return nil, valueChan, err
+	}})
+	value, ok := result.(<-chan realtime.ParentOrderEvents)
+	if !ok && result != nil && err == nil {
+		err = resultTypeError("RealParentOrderEventsStream", result)
+	}
+	return value, errChan, err
+}
+
+// ChainRealtimeAPI returns a RealtimeAPI whose calls pass through middlewares before next. Callbacks are not decorated.
+// The result of a stream call is the value channel, the error channel is the one of next.
+func ChainRealtimeAPI(next RealtimeAPI, middlewares ...Middleware) (RealtimeAPI) {
+	return &realtimeAPI{
+		next:    next,
+		handler: chainMiddlewares(middlewares),
+	}
+}
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"github.com/potix/gobitflyer/client"
+	"github.com/potix/gobitflyer/api/realtime"
+)
+
+type clientOptions struct {
+	endpoint          string
+	realtimeEndpoint  string
+	realtimeTransport realtime.Transport
+	userAgent         string
+	headers           map[string]string
+	authenticator     Authenticator
+	httpClient        *client.HTTPClient
+	recorder          realtime.Recorder
+}
+
+// ClientOption configures APIClient and RealAPIClient. Options which do not concern a client are ignored by it.
+type ClientOption func(options *clientOptions)
+
+// WithEndpoint sets the rest api endpoint (e.g. a proxy, a stub or a staging environment).
+func WithEndpoint(endpoint string) (ClientOption) {
+	return func(options *clientOptions) {
+		options.endpoint = endpoint
+	}
+}
+
+// WithRealtimeEndpoint sets the realtime api endpoint. The default is the endpoint of the transport.
+func WithRealtimeEndpoint(endpoint string) (ClientOption) {
+	return func(options *clientOptions) {
+		options.realtimeEndpoint = endpoint
+	}
+}
+
+// WithRealtimeTransport selects the transport of the realtime api, e.g. realtime.NewSocketIOTransport().
+// The default is realtime.NewJsonRPC2Transport().
+func WithRealtimeTransport(transport realtime.Transport) (ClientOption) {
+	return func(options *clientOptions) {
+		options.realtimeTransport = transport
+	}
+}
+
+func WithUserAgent(userAgent string) (ClientOption) {
+	return func(options *clientOptions) {
+		options.userAgent = userAgent
+	}
+}
+
+// WithHeaders adds headers to every request. It can be given more than once.
+func WithHeaders(headers map[string]string) (ClientOption) {
+	return func(options *clientOptions) {
+		for name, value := range headers {
+			options.headers[name] = value
+		}
+	}
+}
+
+func WithAuthenticator(authenticator Authenticator) (ClientOption) {
+	return func(options *clientOptions) {
+		options.authenticator = authenticator
+	}
+}
+
+// WithHTTPClient sets the http client. RealAPIClient uses it for rest api calls.
+func WithHTTPClient(httpClient *client.HTTPClient) (ClientOption) {
+	return func(options *clientOptions) {
+		options.httpClient = httpClient
+	}
+}
+
+// WithRecorder passes every channel message of RealAPIClient to recorder, e.g. realtime.NewFileRecorder(path).
+func WithRecorder(recorder realtime.Recorder) (ClientOption) {
+	return func(options *clientOptions) {
+		options.recorder = recorder
+	}
+}
+
+func newClientOptions(options []ClientOption) (*clientOptions) {
+	o := &clientOptions{
+		endpoint:         apiEndpoint,
+		headers:          make(map[string]string),
+	}
+	for _, option := range options {
+		option(o)
+	}
+	return o
+}
+
+func (o *clientOptions) setHeaders(headers map[string]string) {
+	for name, value := range o.headers {
+		headers[name] = value
+	}
+	if o.userAgent != "" {
+		headers["User-Agent"] = o.userAgent
+	}
+}
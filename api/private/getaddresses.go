@@ -0,0 +1,38 @@
+package private
+
+import (
+	"github.com/potix/gobitflyer/api/types"
+	"github.com/potix/gobitflyer/client"
+)
+
+const (
+        getAddressesPath string = "/v1/me/getaddresses"
+)
+
+type GetAddressesResponse []*GetAddressesAddress
+
+type GetAddressesAddress struct {
+	Type         string             `json:"type"`
+	CurrencyCode types.CurrencyCode `json:"currency_code"`
+	Address      string             `json:"address"`
+}
+
+type GetAddressesRequest struct {
+	Path string `json:"-"`
+}
+
+func (r *GetAddressesRequest) CreateHTTPRequest(endpoint string) (*client.HTTPRequest, error) {
+        return &client.HTTPRequest {
+		PathQuery: r.Path,
+                URL: endpoint + r.Path,
+                Method: "GET",
+                Headers: make(map[string]string),
+                Body: nil,
+        }, nil
+}
+
+func NewGetAddressesRequest() (*GetAddressesRequest) {
+        return &GetAddressesRequest{
+                Path: getAddressesPath,
+        }
+}
@@ -13,8 +13,8 @@ type GetBalanceResponse []*GetBalanceAsset
 
 type GetBalanceAsset struct {
 	CurrencyCode types.CurrencyCode `json:"currency_code"`
-	Amount       float64            `json:"amount"`
-	Available    float64            `json:"available"`
+	Amount       types.Decimal      `json:"amount"`
+	Available    types.Decimal      `json:"available"`
 }
 
 type GetBalanceRequest struct {
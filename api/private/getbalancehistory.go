@@ -15,15 +15,15 @@ type GetBalanceHistoryResponse  []*GetBalanceHistoryEvent
 
 type GetBalanceHistoryEvent struct {
 	Id           int64              `json:"id"`
-	TradeDate    string             `json:"trade_date"`
+	TradeDate    types.Time         `json:"trade_date"`
 	ProductCode  types.ProductCode  `json:"product_code"`
 	CurrencyCode types.CurrencyCode `json:"currency_code"`
 	TradeType    types.TradeType    `json:"trade_type"`
-	Price        float64            `json:"price"`
-	Amount       float64            `json:"amount"`
-	Quantity     float64            `json:"quantity"`
-	Commission   float64            `json:"commission"`
-	Balance      float64            `json:"balance"`
+	Price        types.Decimal      `json:"price"`
+	Amount       types.Decimal      `json:"amount"`
+	Quantity     types.Decimal      `json:"quantity"`
+	Commission   types.Decimal      `json:"commission"`
+	Balance      types.Decimal      `json:"balance"`
 	OrderId      string             `json:"order_id"`
 }
 
@@ -0,0 +1,41 @@
+package private
+
+import (
+	"github.com/potix/gobitflyer/client"
+)
+
+const (
+        getBankAccountsPath string = "/v1/me/getbankaccounts"
+)
+
+type GetBankAccountsResponse []*GetBankAccountsAccount
+
+type GetBankAccountsAccount struct {
+	Id            int64  `json:"id"`
+	IsVerified    bool   `json:"is_verified"`
+	BankName      string `json:"bank_name"`
+	BranchName    string `json:"branch_name"`
+	AccountType   string `json:"account_type"`
+	AccountNumber string `json:"account_number"`
+	AccountName   string `json:"account_name"`
+}
+
+type GetBankAccountsRequest struct {
+	Path string `json:"-"`
+}
+
+func (r *GetBankAccountsRequest) CreateHTTPRequest(endpoint string) (*client.HTTPRequest, error) {
+        return &client.HTTPRequest {
+		PathQuery: r.Path,
+                URL: endpoint + r.Path,
+                Method: "GET",
+                Headers: make(map[string]string),
+                Body: nil,
+        }, nil
+}
+
+func NewGetBankAccountsRequest() (*GetBankAccountsRequest) {
+        return &GetBankAccountsRequest{
+                Path: getBankAccountsPath,
+        }
+}
@@ -17,19 +17,19 @@ type GetChildOrdersOrder struct {
 	Id                     int64             `json:"id"`
 	ChildOrderId           string            `json:"child_order_id"`
 	ProductCode            types.ProductCode `json:"product_code"`
-	Side                   string            `json:"side"`
-	ChildOrderType         string            `json:"child_order_type"`
-	Price                  float64           `json:"price"`
-	AveragePrice           float64           `json:"average_price"`
-	Size                   float64           `json:"size"`
-	ChildOrderState        string            `json:"child_order_state"`
-	ExpireDate             string            `json:"expire_date"`
-	ChildOrderDate         string            `json:"child_order_date"`
+	Side                   types.Side        `json:"side"`
+	ChildOrderType         types.OrderType   `json:"child_order_type"`
+	Price                  types.Decimal     `json:"price"`
+	AveragePrice           types.Decimal     `json:"average_price"`
+	Size                   types.Decimal     `json:"size"`
+	ChildOrderState        types.OrderState  `json:"child_order_state"`
+	ExpireDate             types.Time        `json:"expire_date"`
+	ChildOrderDate         types.Time        `json:"child_order_date"`
 	ChildOrderAcceptanceId string            `json:"child_order_acceptance_id"`
-	OutstandingSize        float64           `json:"outstanding_size"`
-	CancelSize             float64           `json:"cancel_size"`
-	ExecutedSize           float64           `json:"executed_size"`
-	TotalCommission        float64           `json:"total_commission"`
+	OutstandingSize        types.Decimal     `json:"outstanding_size"`
+	CancelSize             types.Decimal     `json:"cancel_size"`
+	ExecutedSize           types.Decimal     `json:"executed_size"`
+	TotalCommission        types.Decimal     `json:"total_commission"`
 }
 
 type GetChildOrdersRequest struct {
@@ -0,0 +1,57 @@
+package private
+
+import (
+	"github.com/google/go-querystring/query"
+	"github.com/pkg/errors"
+	"github.com/potix/gobitflyer/api/types"
+	"github.com/potix/gobitflyer/client"
+)
+
+const (
+	getCoinInsPath string = "/v1/me/getcoinins"
+)
+
+type GetCoinInsResponse []*GetCoinInsCoinIn
+
+type GetCoinInsCoinIn struct {
+	Id           int64              `json:"id"`
+	OrderId      string             `json:"order_id"`
+	CurrencyCode types.CurrencyCode `json:"currency_code"`
+	Amount       float64            `json:"amount"`
+	Address      string             `json:"address"`
+	TxHash       string             `json:"tx_hash"`
+	Status       string             `json:"status"`
+	EventDate    types.Time         `json:"event_date"`
+}
+
+type GetCoinInsRequest struct {
+	Path             string `url:"-"`
+	types.Pagination
+}
+
+func (r *GetCoinInsRequest) CreateHTTPRequest(endpoint string) (*client.HTTPRequest, error) {
+	v, err := query.Values(r)
+	if err != nil {
+		return nil, errors.Wrapf(err, "can not create query of get coin ins")
+	}
+	query := v.Encode()
+	pathQuery := r.Path + "?" + query
+        return &client.HTTPRequest {
+		PathQuery: pathQuery,
+                URL: endpoint + pathQuery,
+                Method: "GET",
+                Headers: make(map[string]string),
+                Body: nil,
+        }, nil
+}
+
+func NewGetCoinInsRequest(count int64, before int64, after int64) (*GetCoinInsRequest) {
+	return &GetCoinInsRequest{
+		Path:        getCoinInsPath,
+		Pagination:  types.Pagination {
+			Count:  count,
+			Before: before,
+			After:  after,
+		},
+	}
+}
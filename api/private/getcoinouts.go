@@ -0,0 +1,59 @@
+package private
+
+import (
+	"github.com/google/go-querystring/query"
+	"github.com/pkg/errors"
+	"github.com/potix/gobitflyer/api/types"
+	"github.com/potix/gobitflyer/client"
+)
+
+const (
+	getCoinOutsPath string = "/v1/me/getcoinouts"
+)
+
+type GetCoinOutsResponse []*GetCoinOutsCoinOut
+
+type GetCoinOutsCoinOut struct {
+	Id            int64              `json:"id"`
+	OrderId       string             `json:"order_id"`
+	CurrencyCode  types.CurrencyCode `json:"currency_code"`
+	Amount        float64            `json:"amount"`
+	Address       string             `json:"address"`
+	TxHash        string             `json:"tx_hash"`
+	Fee           float64            `json:"fee"`
+	AdditionalFee float64            `json:"additional_fee"`
+	Status        string             `json:"status"`
+	EventDate     types.Time         `json:"event_date"`
+}
+
+type GetCoinOutsRequest struct {
+	Path             string `url:"-"`
+	types.Pagination
+}
+
+func (r *GetCoinOutsRequest) CreateHTTPRequest(endpoint string) (*client.HTTPRequest, error) {
+	v, err := query.Values(r)
+	if err != nil {
+		return nil, errors.Wrapf(err, "can not create query of get coin outs")
+	}
+	query := v.Encode()
+	pathQuery := r.Path + "?" + query
+        return &client.HTTPRequest {
+		PathQuery: pathQuery,
+                URL: endpoint + pathQuery,
+                Method: "GET",
+                Headers: make(map[string]string),
+                Body: nil,
+        }, nil
+}
+
+func NewGetCoinOutsRequest(count int64, before int64, after int64) (*GetCoinOutsRequest) {
+	return &GetCoinOutsRequest{
+		Path:        getCoinOutsPath,
+		Pagination:  types.Pagination {
+			Count:  count,
+			Before: before,
+			After:  after,
+		},
+	}
+}
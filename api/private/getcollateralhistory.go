@@ -16,10 +16,10 @@ type GetCollateralHistoryResponse  []*GetCollateralHistoryEvent
 type GetCollateralHistoryEvent struct {
 	Id           int64              `json:"id"`
 	CurrencyCode types.CurrencyCode `json:"currency_code"`
-	Change       float64            `json:"change"`
-	Amount       float64            `json:"amount"`
+	Change       types.Decimal      `json:"change"`
+	Amount       types.Decimal      `json:"amount"`
 	ReasonCode   string             `json:"reason_code"`
-	Date         string             `json:"date"`
+	Date         types.Time         `json:"date"`
 }
 
 type GetCollateralHistoryRequest struct {
@@ -0,0 +1,55 @@
+package private
+
+import (
+	"github.com/google/go-querystring/query"
+	"github.com/pkg/errors"
+	"github.com/potix/gobitflyer/api/types"
+	"github.com/potix/gobitflyer/client"
+)
+
+const (
+	getDepositsPath string = "/v1/me/getdeposits"
+)
+
+type GetDepositsResponse []*GetDepositsDeposit
+
+type GetDepositsDeposit struct {
+	Id           int64              `json:"id"`
+	OrderId      string             `json:"order_id"`
+	CurrencyCode types.CurrencyCode `json:"currency_code"`
+	Amount       float64            `json:"amount"`
+	Status       string             `json:"status"`
+	EventDate    types.Time         `json:"event_date"`
+}
+
+type GetDepositsRequest struct {
+	Path             string `url:"-"`
+	types.Pagination
+}
+
+func (r *GetDepositsRequest) CreateHTTPRequest(endpoint string) (*client.HTTPRequest, error) {
+	v, err := query.Values(r)
+	if err != nil {
+		return nil, errors.Wrapf(err, "can not create query of get deposits")
+	}
+	query := v.Encode()
+	pathQuery := r.Path + "?" + query
+        return &client.HTTPRequest {
+		PathQuery: pathQuery,
+                URL: endpoint + pathQuery,
+                Method: "GET",
+                Headers: make(map[string]string),
+                Body: nil,
+        }, nil
+}
+
+func NewGetDepositsRequest(count int64, before int64, after int64) (*GetDepositsRequest) {
+	return &GetDepositsRequest{
+		Path:        getDepositsPath,
+		Pagination:  types.Pagination {
+			Count:  count,
+			Before: before,
+			After:  after,
+		},
+	}
+}
@@ -14,14 +14,14 @@ const (
 type GetExecutionsResponse  []*GetExecutionsExecution
 
 type GetExecutionsExecution struct {
-	Id                     int64      `json:"id"`
-	ChildOrderId           string     `json:"child_order_id"`
-	Side                   types.Side `json:"side"`
-	Price                  float64    `json:"price"`
-	Size                   float64    `json:"size"`
-	Commission             float64    `json:"commission"`
-	ExecDate               string     `json:"expire_date"`
-	ChildOrderAcceptanceId string     `json:"child_order_acceptance_id"`
+	Id                     int64         `json:"id"`
+	ChildOrderId           string        `json:"child_order_id"`
+	Side                   types.Side    `json:"side"`
+	Price                  types.Decimal `json:"price"`
+	Size                   types.Decimal `json:"size"`
+	Commission             types.Decimal `json:"commission"`
+	ExecDate               types.Time    `json:"exec_date"`
+	ChildOrderAcceptanceId string        `json:"child_order_acceptance_id"`
 }
 
 type GetExecutionsRequest struct {
@@ -24,10 +24,10 @@ type GetParentOrderParameter struct {
         ProductCode    types.ProductCode   `json:"product_code"`
         ConditionType  types.ConditionType `json:"condition_type"`
         Side           types.Side          `json:"side"`
-        Price          float64             `json:"price"`
-        Size           float64             `json:"size"`
-        TriggerPrice   float64             `json:"trigger_price"`
-        Offset         float64             `json:"offset"`
+        Price          types.Decimal       `json:"price"`
+        Size           types.Decimal       `json:"size"`
+        TriggerPrice   types.Decimal       `json:"trigger_price"`
+        Offset         types.Decimal       `json:"offset"`
 }
 
 type GetParentOrderRequest struct {
@@ -14,22 +14,22 @@ const (
 type GetParentOrdersResponse  []*GetParentOrdersOrder
 
 type GetParentOrdersOrder struct {
-	Id                      int64             `json:"id"`
-	ParentOrderId           string            `json:"parent_order_id"`
-	ProductCode             types.ProductCode `json:"product_code"`
-	Side                    string            `json:"side"`
-	ParentOrderType         string            `json:"parent_order_type"`
-	Price                   float64           `json:"price"`
-	AveragePrice            float64           `json:"average_price"`
-	Size                    float64           `json:"size"`
-	ParentOrderState        string            `json:"parent_order_state"`
-	ExpireDate              string            `json:"expire_date"`
-	ParentOrderDate         string            `json:"parent_order_date"`
-	ParentOrderAcceptanceId string            `json:"parent_order_acceptance_id"`
-	OutstandingSize         float64           `json:"outstanding_size"`
-	CancelSize              float64           `json:"cancel_size"`
-	ExecutedSize            float64           `json:"executed_size"`
-	TotalCommission         float64           `json:"total_commission"`
+	Id                      int64                 `json:"id"`
+	ParentOrderId           string                `json:"parent_order_id"`
+	ProductCode             types.ProductCode     `json:"product_code"`
+	Side                    types.Side            `json:"side"`
+	ParentOrderType         types.ParentOrderType `json:"parent_order_type"`
+	Price                   types.Decimal         `json:"price"`
+	AveragePrice            types.Decimal         `json:"average_price"`
+	Size                    types.Decimal         `json:"size"`
+	ParentOrderState        types.OrderState      `json:"parent_order_state"`
+	ExpireDate              types.Time            `json:"expire_date"`
+	ParentOrderDate         types.Time            `json:"parent_order_date"`
+	ParentOrderAcceptanceId string                `json:"parent_order_acceptance_id"`
+	OutstandingSize         types.Decimal         `json:"outstanding_size"`
+	CancelSize              types.Decimal         `json:"cancel_size"`
+	ExecutedSize            types.Decimal         `json:"executed_size"`
+	TotalCommission         types.Decimal         `json:"total_commission"`
 }
 
 type GetParentOrdersRequest struct {
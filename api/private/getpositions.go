@@ -21,7 +21,7 @@ type GetPositionsPosition struct {
 	Commission          float64           `json:"commission"`
 	SwapPointAccumulate float64           `json:"swap_point_accumulate"`
         RequireCollateral   float64           `json:"require_collateral"`
-        OpenDate            string            `json:"open_date"`
+        OpenDate            types.Time        `json:"open_date"`
         Leverage            float64           `json:"leverage"`
         Pnl                 float64           `json:"pnl"`
         Sfd                 float64           `json:"sfd"`
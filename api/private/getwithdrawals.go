@@ -0,0 +1,63 @@
+package private
+
+import (
+	"github.com/google/go-querystring/query"
+	"github.com/pkg/errors"
+	"github.com/potix/gobitflyer/api/types"
+	"github.com/potix/gobitflyer/client"
+)
+
+const (
+	getWithdrawalsPath string = "/v1/me/getwithdrawals"
+)
+
+type GetWithdrawalsResponse []*GetWithdrawalsWithdrawal
+
+type GetWithdrawalsWithdrawal struct {
+	Id           int64              `json:"id"`
+	OrderId      string             `json:"order_id"`
+	CurrencyCode types.CurrencyCode `json:"currency_code"`
+	Amount       float64            `json:"amount"`
+	Status       string             `json:"status"`
+	EventDate    types.Time         `json:"event_date"`
+}
+
+type GetWithdrawalsRequest struct {
+	Path             string `url:"-"`
+	types.Pagination
+	MessageId        string `url:"message_id,omitempty"`
+}
+
+func (r *GetWithdrawalsRequest) CreateHTTPRequest(endpoint string) (*client.HTTPRequest, error) {
+	v, err := query.Values(r)
+	if err != nil {
+		return nil, errors.Wrapf(err, "can not create query of get withdrawals")
+	}
+	query := v.Encode()
+	pathQuery := r.Path + "?" + query
+        return &client.HTTPRequest {
+		PathQuery: pathQuery,
+                URL: endpoint + pathQuery,
+                Method: "GET",
+                Headers: make(map[string]string),
+                Body: nil,
+        }, nil
+}
+
+func NewGetWithdrawalsRequest(count int64, before int64, after int64) (*GetWithdrawalsRequest) {
+	return &GetWithdrawalsRequest{
+		Path:        getWithdrawalsPath,
+		Pagination:  types.Pagination {
+			Count:  count,
+			Before: before,
+			After:  after,
+		},
+	}
+}
+
+func NewGetWithdrawalsRequestById(messageId string) (*GetWithdrawalsRequest) {
+	return &GetWithdrawalsRequest{
+		Path:      getWithdrawalsPath,
+		MessageId: messageId,
+	}
+}
@@ -0,0 +1,260 @@
+package private
+
+import (
+	"github.com/pkg/errors"
+	"github.com/potix/gobitflyer/api/types"
+)
+
+func NewLimitParameter(productCode types.ProductCode, side types.Side, price float64, size float64) (*SendParentOrderParameter) {
+	return &SendParentOrderParameter{
+		ProductCode:   productCode,
+		ConditionType: types.ConditionTypeLimit,
+		Side:          side,
+		Price:         price,
+		Size:          size,
+	}
+}
+
+func NewMarketParameter(productCode types.ProductCode, side types.Side, size float64) (*SendParentOrderParameter) {
+	return &SendParentOrderParameter{
+		ProductCode:   productCode,
+		ConditionType: types.ConditionTypeMarket,
+		Side:          side,
+		Size:          size,
+	}
+}
+
+func NewStopParameter(productCode types.ProductCode, side types.Side, triggerPrice float64, size float64) (*SendParentOrderParameter) {
+	return &SendParentOrderParameter{
+		ProductCode:   productCode,
+		ConditionType: types.ConditionTypeStop,
+		Side:          side,
+		TriggerPrice:  triggerPrice,
+		Size:          size,
+	}
+}
+
+func NewStopLimitParameter(productCode types.ProductCode, side types.Side, triggerPrice float64, price float64, size float64) (*SendParentOrderParameter) {
+	return &SendParentOrderParameter{
+		ProductCode:   productCode,
+		ConditionType: types.ConditionTypeStopLimit,
+		Side:          side,
+		Price:         price,
+		TriggerPrice:  triggerPrice,
+		Size:          size,
+	}
+}
+
+func NewTrailParameter(productCode types.ProductCode, side types.Side, offset float64, size float64) (*SendParentOrderParameter) {
+	return &SendParentOrderParameter{
+		ProductCode:   productCode,
+		ConditionType: types.ConditionTypeTrail,
+		Side:          side,
+		Offset:        offset,
+		Size:          size,
+	}
+}
+
+// ParentOrderBuilder builds SendParentOrderRequest with the parameters in the order bitFlyer expects, e.g.
+// NewIFDOCO().If(limitBuy).Then(takeProfit, stopLoss).Build(). The first error is returned by Build.
+type ParentOrderBuilder struct {
+	orderMethod    types.OrderMethod
+	minuteToExpire int64
+	timeInForce    types.TimeInForce
+	ifParameter    *SendParentOrderParameter
+	parameters     []*SendParentOrderParameter
+	err            error
+}
+
+func (b *ParentOrderBuilder) setError(err error) (*ParentOrderBuilder) {
+	if b.err == nil {
+		b.err = err
+	}
+	return b
+}
+
+// If sets the first order of IFD and IFDOCO.
+func (b *ParentOrderBuilder) If(parameter *SendParentOrderParameter) (*ParentOrderBuilder) {
+	if b.orderMethod != types.OrderMethodIFD && b.orderMethod != types.OrderMethodIFDOCO {
+		return b.setError(errors.Errorf("%v order has no if order", b.orderMethod))
+	}
+	if b.ifParameter != nil {
+		return b.setError(errors.Errorf("if order is already set"))
+	}
+	b.ifParameter = parameter
+	return b
+}
+
+// Then sets the order placed after the if order is executed, one order for IFD and two orders (OCO) for IFDOCO.
+func (b *ParentOrderBuilder) Then(parameters ...*SendParentOrderParameter) (*ParentOrderBuilder) {
+	if b.orderMethod != types.OrderMethodIFD && b.orderMethod != types.OrderMethodIFDOCO {
+		return b.setError(errors.Errorf("%v order has no then order", b.orderMethod))
+	}
+	return b.setParameters(parameters)
+}
+
+// Either sets the two orders of OCO.
+func (b *ParentOrderBuilder) Either(first *SendParentOrderParameter, second *SendParentOrderParameter) (*ParentOrderBuilder) {
+	if b.orderMethod != types.OrderMethodOCO {
+		return b.setError(errors.Errorf("%v order has no either orders", b.orderMethod))
+	}
+	return b.setParameters([]*SendParentOrderParameter{first, second})
+}
+
+func (b *ParentOrderBuilder) setParameters(parameters []*SendParentOrderParameter) (*ParentOrderBuilder) {
+	if b.parameters != nil {
+		return b.setError(errors.Errorf("orders are already set"))
+	}
+	b.parameters = parameters
+	return b
+}
+
+func (b *ParentOrderBuilder) MinuteToExpire(minuteToExpire int64) (*ParentOrderBuilder) {
+	b.minuteToExpire = minuteToExpire
+	return b
+}
+
+func (b *ParentOrderBuilder) TimeInForce(timeInForce types.TimeInForce) (*ParentOrderBuilder) {
+	b.timeInForce = timeInForce
+	return b
+}
+
+// referencePrice returns the price which decides when the order is executed, 0 for MARKET and TRAIL.
+func referencePrice(parameter *SendParentOrderParameter) (float64) {
+	switch parameter.ConditionType {
+	case types.ConditionTypeLimit:
+		return parameter.Price
+	case types.ConditionTypeStop, types.ConditionTypeStopLimit:
+		return parameter.TriggerPrice
+	default:
+		return 0
+	}
+}
+
+func isStop(parameter *SendParentOrderParameter) (bool) {
+	return parameter.ConditionType == types.ConditionTypeStop || parameter.ConditionType == types.ConditionTypeStopLimit
+}
+
+// checkOCO checks that neither of OCO orders is executed at once. A take profit (LIMIT) and a stop loss (STOP)
+// of the same side must be on the opposite sides of the market.
+func checkOCO(first *SendParentOrderParameter, second *SendParentOrderParameter) (error) {
+	if first.Side != second.Side {
+		return nil
+	}
+	limit, stop := first, second
+	if isStop(limit) {
+		limit, stop = second, first
+	}
+	if limit.ConditionType != types.ConditionTypeLimit || !isStop(stop) {
+		return nil
+	}
+	if limit.Side == types.SideSell && limit.Price <= stop.TriggerPrice {
+		return errors.Errorf("price of SELL LIMIT must be higher than trigger price of SELL %v (price = %v, trigger price = %v)", stop.ConditionType, limit.Price, stop.TriggerPrice)
+	}
+	if limit.Side == types.SideBuy && limit.Price >= stop.TriggerPrice {
+		return errors.Errorf("price of BUY LIMIT must be lower than trigger price of BUY %v (price = %v, trigger price = %v)", stop.ConditionType, limit.Price, stop.TriggerPrice)
+	}
+	return nil
+}
+
+// checkExit checks that a then order of IFD and IFDOCO closes the position of the if order with a profit (LIMIT) or a loss (STOP).
+func checkExit(entry *SendParentOrderParameter, exit *SendParentOrderParameter) (error) {
+	if entry.Side == exit.Side {
+		return errors.Errorf("then orders must be the opposite side of if order (side = %v)", exit.Side)
+	}
+	entryPrice := referencePrice(entry)
+	exitPrice := referencePrice(exit)
+	if entryPrice == 0 || exitPrice == 0 {
+		return nil
+	}
+	// a higher exit is a profit for BUY entry
+	higher := exitPrice > entryPrice
+	if entry.Side == types.SideSell {
+		higher = exitPrice < entryPrice
+	}
+	if exit.ConditionType == types.ConditionTypeLimit && !higher {
+		return errors.Errorf("LIMIT of then orders must be a profit of if order (price = %v, if price = %v)", exitPrice, entryPrice)
+	}
+	if isStop(exit) && higher {
+		return errors.Errorf("%v of then orders must be a loss of if order (trigger price = %v, if price = %v)", exit.ConditionType, exitPrice, entryPrice)
+	}
+	return nil
+}
+
+// checkIFD checks the then order of IFD. An opposite side order closes the position of the if order, and a STOP of
+// the same side must not be triggered at the price of the if order.
+func checkIFD(entry *SendParentOrderParameter, then *SendParentOrderParameter) (error) {
+	if entry.Side != then.Side {
+		return checkExit(entry, then)
+	}
+	entryPrice := referencePrice(entry)
+	thenPrice := referencePrice(then)
+	if !isStop(then) || entryPrice == 0 || thenPrice == 0 {
+		return nil
+	}
+	if then.Side == types.SideBuy && thenPrice <= entryPrice {
+		return errors.Errorf("trigger price of BUY %v must be higher than price of if order (trigger price = %v, if price = %v)", then.ConditionType, thenPrice, entryPrice)
+	}
+	if then.Side == types.SideSell && thenPrice >= entryPrice {
+		return errors.Errorf("trigger price of SELL %v must be lower than price of if order (trigger price = %v, if price = %v)", then.ConditionType, thenPrice, entryPrice)
+	}
+	return nil
+}
+
+func (b *ParentOrderBuilder) Build() (*SendParentOrderRequest, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	parameters := b.parameters
+	if b.ifParameter != nil {
+		parameters = append([]*SendParentOrderParameter{b.ifParameter}, b.parameters...)
+	}
+	sendParentOrderRequest := NewSendParentOrderRequest(b.orderMethod, b.minuteToExpire, b.timeInForce, parameters...)
+	err := sendParentOrderRequest.Validate()
+	if err != nil {
+		return nil, err
+	}
+	switch b.orderMethod {
+	case types.OrderMethodIFD:
+		err = checkIFD(parameters[0], parameters[1])
+	case types.OrderMethodOCO:
+		err = checkOCO(parameters[0], parameters[1])
+	case types.OrderMethodIFDOCO:
+		err = checkOCO(parameters[1], parameters[2])
+		for _, exit := range parameters[1:] {
+			if err != nil {
+				break
+			}
+			err = checkExit(parameters[0], exit)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return sendParentOrderRequest, nil
+}
+
+func newParentOrderBuilder(orderMethod types.OrderMethod) (*ParentOrderBuilder) {
+	return &ParentOrderBuilder{
+		orderMethod: orderMethod,
+		timeInForce: types.TimeInForceGTC,
+	}
+}
+
+func NewSimple(parameter *SendParentOrderParameter) (*ParentOrderBuilder) {
+	b := newParentOrderBuilder(types.OrderMethodSimple)
+	b.parameters = []*SendParentOrderParameter{parameter}
+	return b
+}
+
+func NewIFD() (*ParentOrderBuilder) {
+	return newParentOrderBuilder(types.OrderMethodIFD)
+}
+
+func NewOCO() (*ParentOrderBuilder) {
+	return newParentOrderBuilder(types.OrderMethodOCO)
+}
+
+func NewIFDOCO() (*ParentOrderBuilder) {
+	return newParentOrderBuilder(types.OrderMethodIFDOCO)
+}
@@ -20,12 +20,72 @@ type SendChildOrderRequest struct {
 	ProductCode    types.ProductCode `json:"product_code"`
 	ChildOrderType types.OrderType   `json:"child_order_type"`
 	Side           types.Side        `json:"side"`
-	Price          float64           `json:"price,omitempty"`
-	Size           float64           `json:"size"`
+	Price          types.Decimal     `json:"price"`
+	Size           types.Decimal     `json:"size"`
 	MinuteToExpire int64             `json:"minute_to_expire,omitempty"`
 	TimeInForce    types.TimeInForce `json:"time_in_force,omitempty"`
 }
 
+// validateOrderSize checks size against the minimum order size of the product.
+func validateOrderSize(productCode types.ProductCode, size float64) (error) {
+	if size <= 0 {
+		return errors.Errorf("size must be positive (size = %v)", size)
+	}
+	minimumOrderSize, ok := types.MinimumOrderSize(productCode)
+	if ok && size < minimumOrderSize {
+		return errors.Errorf("size is less than minimum order size (size = %v, minimum order size = %v)", size, minimumOrderSize)
+	}
+	return nil
+}
+
+// validateExpiration checks minute to expire and time in force, which are common to child orders and parent orders.
+func validateExpiration(minuteToExpire int64, timeInForce types.TimeInForce) (error) {
+	if minuteToExpire < 0 || minuteToExpire > types.MaxMinuteToExpire {
+		return errors.Errorf("invalid minute to expire (minute to expire = %v)", minuteToExpire)
+	}
+	if !timeInForce.IsValid() {
+		return errors.Errorf("invalid time in force (time in force = %v)", timeInForce)
+	}
+	return nil
+}
+
+// Validate checks the request before it is sent.
+func (b *SendChildOrderRequest) Validate() (error) {
+	if b.ProductCode == "" {
+		return errors.Errorf("no product code")
+	}
+	if !b.ChildOrderType.IsValid() {
+		return errors.Errorf("invalid child order type (child order type = %v)", b.ChildOrderType)
+	}
+	if !b.Side.IsValid() {
+		return errors.Errorf("invalid side (side = %v)", b.Side)
+	}
+	if b.ChildOrderType == types.OrderTypeLimit && b.Price.Sign() <= 0 {
+		return errors.Errorf("limit order needs price (price = %v)", b.Price)
+	}
+	err := validateOrderSize(b.ProductCode, b.Size.Float64())
+	if err != nil {
+		return err
+	}
+	return validateExpiration(b.MinuteToExpire, b.TimeInForce)
+}
+
+// MarshalJSON omits the price of a market order, which is 0.
+func (b SendChildOrderRequest) MarshalJSON() ([]byte, error) {
+	type request SendChildOrderRequest
+	var price *types.Decimal
+	if !b.Price.IsZero() {
+		price = &b.Price
+	}
+	return json.Marshal(&struct {
+		*request
+		Price *types.Decimal `json:"price,omitempty"`
+	}{
+		request: (*request)(&b),
+		Price:   price,
+	})
+}
+
 func (b *SendChildOrderRequest) CreateHTTPRequest(endpoint string) (*client.HTTPRequest, error) {
 	body, err := json.Marshal(b)
 	if err != nil {
@@ -54,8 +114,8 @@ func NewSendChildOrderRequest(productCode types.ProductCode,
 		ProductCode:    productCode,
 		ChildOrderType: childOrderType,
 		Side:           side,
-		Price:          price,
-		Size:           size,
+		Price:          types.NewDecimalFromFloat(price),
+		Size:           types.NewDecimalFromFloat(size),
 		MinuteToExpire: minuteToExpire,
 		TimeInForce:    timeInForce,
         }
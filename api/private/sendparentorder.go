@@ -33,6 +33,50 @@ type SendParentOrderParameter struct {
 	Offset         float64             `json:"offset,omitempty"`
 }
 
+// Validate checks the parameter.
+func (p *SendParentOrderParameter) Validate() (error) {
+	if p.ProductCode == "" {
+		return errors.Errorf("no product code")
+	}
+	if !p.ConditionType.IsValid() {
+		return errors.Errorf("invalid condition type (condition type = %v)", p.ConditionType)
+	}
+	if !p.Side.IsValid() {
+		return errors.Errorf("invalid side (side = %v)", p.Side)
+	}
+	if (p.ConditionType == types.ConditionTypeLimit || p.ConditionType == types.ConditionTypeStopLimit) && p.Price <= 0 {
+		return errors.Errorf("%v order needs price (price = %v)", p.ConditionType, p.Price)
+	}
+	if (p.ConditionType == types.ConditionTypeStop || p.ConditionType == types.ConditionTypeStopLimit) && p.TriggerPrice <= 0 {
+		return errors.Errorf("%v order needs trigger price (trigger price = %v)", p.ConditionType, p.TriggerPrice)
+	}
+	if p.ConditionType == types.ConditionTypeTrail && p.Offset <= 0 {
+		return errors.Errorf("TRAIL order needs offset (offset = %v)", p.Offset)
+	}
+	return validateOrderSize(p.ProductCode, p.Size)
+}
+
+// Validate checks the request before it is sent.
+func (r *SendParentOrderRequest) Validate() (error) {
+	parameterCount := r.OrderMethod.ParameterCount()
+	if parameterCount == 0 {
+		return errors.Errorf("invalid order method (order method = %v)", r.OrderMethod)
+	}
+	if len(r.Parameters) != parameterCount {
+		return errors.Errorf("%v order needs %v parameters (parameters = %v)", r.OrderMethod, parameterCount, len(r.Parameters))
+	}
+	for i, parameter := range r.Parameters {
+		if parameter == nil {
+			return errors.Errorf("no parameter (index = %v)", i)
+		}
+		err := parameter.Validate()
+		if err != nil {
+			return errors.Wrapf(err, "invalid parameter (index = %v)", i)
+		}
+	}
+	return validateExpiration(r.MinuteToExpire, r.TimeInForce)
+}
+
 func (r *SendParentOrderRequest) CreateHTTPRequest(endpoint string) (*client.HTTPRequest, error) {
 	body, err := json.Marshal(r)
 	if err != nil {
@@ -0,0 +1,50 @@
+package private
+
+import (
+	"encoding/json"
+	"github.com/pkg/errors"
+	"github.com/potix/gobitflyer/api/types"
+	"github.com/potix/gobitflyer/client"
+)
+
+const (
+        withdrawPath string = "/v1/me/withdraw"
+)
+
+type WithdrawResponse struct {
+	MessageId string `json:"message_id"`
+}
+
+type WithdrawRequest struct {
+	Path          string             `json:"-"`
+	CurrencyCode  types.CurrencyCode `json:"currency_code"`
+	BankAccountId int64              `json:"bank_account_id"`
+	Amount        float64            `json:"amount"`
+	Code          string             `json:"code,omitempty"`
+}
+
+func (r *WithdrawRequest) CreateHTTPRequest(endpoint string) (*client.HTTPRequest, error) {
+	body, err := json.Marshal(r)
+	if err != nil {
+		return nil, errors.Wrapf(err, "can not create body of withdraw request")
+	}
+	headers := make(map[string]string)
+	headers["Content-Type"] = "application/json"
+        return &client.HTTPRequest {
+		PathQuery: r.Path,
+                URL: endpoint + r.Path,
+                Method: "POST",
+                Headers: headers,
+                Body: body,
+        }, nil
+}
+
+func NewWithdrawRequest(currencyCode types.CurrencyCode, bankAccountId int64, amount float64, code string) (*WithdrawRequest) {
+	return &WithdrawRequest{
+		Path:          withdrawPath,
+		CurrencyCode:  currencyCode,
+		BankAccountId: bankAccountId,
+		Amount:        amount,
+		Code:          code,
+	}
+}
@@ -49,8 +49,8 @@ func (r *GetBoardResponse) Clone() (*GetBoardResponse) {
 }
 
 type GetBoardBook struct {
-	Price float64 `json:"price"`
-	Size  float64 `json:"size"`
+	Price types.Decimal `json:"price"`
+	Size  types.Decimal `json:"size"`
 }
 
 type GetBoardRequest struct {
@@ -3,6 +3,7 @@ package public
 import (
 	"github.com/google/go-querystring/query"
 	"github.com/pkg/errors"
+	"github.com/potix/gobitflyer/api/types"
 	"github.com/potix/gobitflyer/client"
 )
 
@@ -13,9 +14,9 @@ const (
 type GetChatsResponse []*GetChatsChat
 
 type GetChatsChat struct {
-	Nickname string `json:"nickname"`
-	Message  string `json:"message"`
-	Date     string `json:"date"`
+	Nickname string     `json:"nickname"`
+	Message  string     `json:"message"`
+	Date     types.Time `json:"date"`
 }
 
 type GetChatsRequest struct {
@@ -14,13 +14,13 @@ const (
 type GetExecutionsResponse []*GetExecutionsExecution
 
 type GetExecutionsExecution struct {
-	Id                         int64   `json:"id"`
-	Side                       string  `json:"side"`
-	Price                      float64 `json:"price"`
-	Size                       float64 `json:"size"`
-	ExecDate                   string  `json:"exec_date"`
-	BuyChildOrderAcceptanceId  string  `json:"buy_child_order_acceptance_id"`
-	SellChildOrderAcceptanceId string  `json:"sell_child_order_acceptance_id"`
+	Id                         int64         `json:"id"`
+	Side                       types.Side    `json:"side"`
+	Price                      types.Decimal `json:"price"`
+	Size                       types.Decimal `json:"size"`
+	ExecDate                   types.Time    `json:"exec_date"`
+	BuyChildOrderAcceptanceId  string        `json:"buy_child_order_acceptance_id"`
+	SellChildOrderAcceptanceId string        `json:"sell_child_order_acceptance_id"`
 }
 
 type GetExecutionsRequest struct {
@@ -13,7 +13,7 @@ const (
 
 type GetTickerResponse struct {
 	ProductCode     types.ProductCode `json:"product_code"`
-	Timestamp       string            `json:"timestamp"`
+	Timestamp       types.Time        `json:"timestamp"`
 	TickId          int64             `json:"tick_id"`
 	BestBid         float64           `json:"best_bid"`
 	BestAsk         float64           `json:"best_ask"`
@@ -0,0 +1,130 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type RateLimitMode int
+
+const (
+	RateLimitModeWait RateLimitMode = 1
+	RateLimitModeFail RateLimitMode = 2
+)
+
+type RateLimitError struct {
+	Count      int64
+	Span       time.Duration
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() (string) {
+	return fmt.Sprintf("exceeded rate limit (count = %v, span = %v, retry after = %v)", e.Count, e.Span, e.RetryAfter)
+}
+
+// RateLimiter counts calls in a sliding window.
+// A nil *RateLimiter never limits and reports -1 as remaining count.
+type RateLimiter struct {
+	span    time.Duration
+	count   int64
+	mode    RateLimitMode
+	history []time.Time
+	mutex   *sync.Mutex
+}
+
+func (r *RateLimiter) expire(now time.Time) {
+	i := 0
+	for i < len(r.history) && now.Sub(r.history[i]) >= r.span {
+		i += 1
+	}
+	r.history = r.history[i:]
+}
+
+func (r *RateLimiter) Acquire() (error) {
+	return r.AcquireCtx(context.Background())
+}
+
+func (r *RateLimiter) AcquireCtx(ctx context.Context) (error) {
+	if r == nil {
+		return nil
+	}
+	for {
+		r.mutex.Lock()
+		now := time.Now()
+		r.expire(now)
+		if int64(len(r.history)) < r.count {
+			r.history = append(r.history, now)
+			r.mutex.Unlock()
+			return nil
+		}
+		wait := r.span - now.Sub(r.history[0])
+		r.mutex.Unlock()
+		if r.mode == RateLimitModeFail {
+			return &RateLimitError{
+				Count:      r.count,
+				Span:       r.span,
+				RetryAfter: wait,
+			}
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// waitRemainingCtx waits until more than reserve calls remain, so that a background caller leaves
+// reserve calls for others. It does not acquire a call.
+func (r *RateLimiter) waitRemainingCtx(ctx context.Context, reserve int64) (error) {
+	if r == nil || reserve <= 0 {
+		return nil
+	}
+	if reserve >= r.count {
+		reserve = r.count - 1
+	}
+	for {
+		r.mutex.Lock()
+		now := time.Now()
+		r.expire(now)
+		// the call which has to expire for reserve + 1 remaining calls
+		i := int64(len(r.history)) - (r.count - reserve)
+		if i < 0 {
+			r.mutex.Unlock()
+			return nil
+		}
+		wait := r.span - now.Sub(r.history[i])
+		r.mutex.Unlock()
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (r *RateLimiter) Remaining() (int64) {
+	if r == nil {
+		return -1
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.expire(time.Now())
+	return r.count - int64(len(r.history))
+}
+
+func NewRateLimiter(spanSeconds int64, count int64, mode RateLimitMode) (*RateLimiter) {
+	return &RateLimiter{
+		span:    time.Duration(spanSeconds) * time.Second,
+		count:   count,
+		mode:    mode,
+		history: make([]time.Time, 0, count),
+		mutex:   new(sync.Mutex),
+	}
+}
@@ -1,29 +1,105 @@
 package realtime
 
 import (
-        "github.com/potix/gobitflyer/client"
+        "time"
+        "encoding/json"
         "github.com/potix/gobitflyer/api/types"
         "github.com/potix/gobitflyer/api/public"
+        "github.com/potix/gobitflyer/orderbook"
 )
 
 type BoardSnapshotCallback func(productCode types.ProductCode, getBoardResponse *public.GetBoardResponse, callbackData interface{})
 type BoardCallback func(productCode types.ProductCode, getBoardResponse *public.GetBoardResponse, callbackData interface{})
+type OrderBookCallback func(productCode types.ProductCode, snapshot *orderbook.Snapshot, callbackData interface{})
+type BoardResyncCallback func(productCode types.ProductCode, boardResync *BoardResync, callbackData interface{})
 type TickerCallback func(productCode types.ProductCode, getTickerResponse *public.GetTickerResponse, callbackData interface{})
 type ExecutionsCallback func(productCode types.ProductCode, getExecutionsResponse public.GetExecutionsResponse, callbackData interface{})
+type ChildOrderEventsCallback func(childOrderEvents ChildOrderEvents, callbackData interface{})
+type ParentOrderEventsCallback func(parentOrderEvents ParentOrderEvents, callbackData interface{})
 
 type RealtimeChannel struct {
 	ProductCode           types.ProductCode
-	WsClient              *client.WSClient
 	RealtimeType          types.RealtimeType
 	BoardSnapshotCallback BoardSnapshotCallback
 	BoardCallback         BoardCallback
+	OrderBookCallback     OrderBookCallback
 	TickerCallback        TickerCallback
 	ExecutionsCallback    ExecutionsCallback
+	ChildOrderEventsCallback  ChildOrderEventsCallback
+	ParentOrderEventsCallback ParentOrderEventsCallback
+	Private               bool
 	CallbackData          interface{}
 	Subscribed            uint32
-	UnsubscribeChan       chan *JsonRPC2Subscribe
+	// closed when the channel is unsubscribed
+	FinishChan            chan int
 	Merge                 bool
-	GetBoardResponseFull  *public.GetBoardResponse
+	OrderBook             *orderbook.Book
+	Stale                 bool
+	StaleReason           BoardResyncReason
+	StaleSince            time.Time
+}
+
+type BoardResyncReason string
+
+const (
+	// crossed or locked book
+	BoardResyncReasonCrossed   BoardResyncReason = "CROSSED"
+	// mid price of the book disagrees with mid_price of the diff
+	BoardResyncReasonMidPrice  BoardResyncReason = "MID_PRICE"
+	BoardResyncReasonReconnect BoardResyncReason = "RECONNECT"
+)
+
+type BoardResyncSource string
+
+const (
+	BoardResyncSourceSnapshot BoardResyncSource = "SNAPSHOT"
+	BoardResyncSourceREST     BoardResyncSource = "REST"
+)
+
+type BoardResync struct {
+	Reason     BoardResyncReason
+	Source     BoardResyncSource
+	StaleSince time.Time
+	ResyncedAt time.Time
+}
+
+func ChannelName(realtimeType types.RealtimeType, productCode types.ProductCode) (string) {
+	switch realtimeType {
+	case types.RealtimeTypeBoardSnapshot:
+		return "lightning_board_snapshot_" + string(productCode)
+	case types.RealtimeTypeBoard:
+		return "lightning_board_" + string(productCode)
+	case types.RealtimeTypeTicker:
+		return "lightning_ticker_" + string(productCode)
+	case types.RealtimeTypeExecutions:
+		return "lightning_executions_" + string(productCode)
+	case types.RealtimeTypeChildOrderEvents:
+		return "child_order_events"
+	case types.RealtimeTypeParentOrderEvents:
+		return "parent_order_events"
+	default:
+		return ""
+	}
+}
+
+// JsonRPC2Notify also carries responses of requests with id (e.g. auth).
+type JsonRPC2Notify struct {
+	JsonRpc string                `json:"jsonrpc"`
+	Method  string                `json:"method"`
+	Params  *JsonRPC2NotifyParams `json:"params"`
+	Id      int64                 `json:"id"`
+	Result  json.RawMessage       `json:"result"`
+	Error   *JsonRPC2Error        `json:"error"`
+}
+
+type JsonRPC2Error struct {
+	Code    int64  `json:"code"`
+	Message string `json:"message"`
+}
+
+type JsonRPC2NotifyParams struct {
+	Channel string          `json:"channel"`
+	Message json.RawMessage `json:"message"`
 }
 
 type JsonRPC2BoardSnapshotNotify struct {
@@ -80,3 +156,56 @@ type JsonRPC2SubscribeParams struct {
 	Channel string `json:"channel"`
 }
 
+
+type JsonRPC2Auth struct {
+	JsonRpc string              `json:"jsonrpc"`
+	Method  string              `json:"method"`
+	Params  *JsonRPC2AuthParams `json:"params"`
+	Id      int64               `json:"id"`
+}
+
+type JsonRPC2AuthParams struct {
+	ApiKey    string `json:"api_key"`
+	Timestamp int64  `json:"timestamp"`
+	Nonce     string `json:"nonce"`
+	Signature string `json:"signature"`
+}
+
+type ChildOrderEvents []*ChildOrderEvent
+
+type ChildOrderEvent struct {
+	ProductCode            types.ProductCode `json:"product_code"`
+	ChildOrderId           string            `json:"child_order_id"`
+	ChildOrderAcceptanceId string            `json:"child_order_acceptance_id"`
+	EventDate              types.Time        `json:"event_date"`
+	EventType              types.EventType   `json:"event_type"`
+	ChildOrderType         types.OrderType   `json:"child_order_type"`
+	ExpireDate             types.Time        `json:"expire_date"`
+	Reason                 string            `json:"reason"`
+	ExecId                 int64             `json:"exec_id"`
+	Side                   types.Side        `json:"side"`
+	Price                  float64           `json:"price"`
+	Size                   float64           `json:"size"`
+	Commission             float64           `json:"commission"`
+	Sfd                    float64           `json:"sfd"`
+	OutstandingSize        float64           `json:"outstanding_size"`
+}
+
+type ParentOrderEvents []*ParentOrderEvent
+
+type ParentOrderEvent struct {
+	ProductCode             types.ProductCode     `json:"product_code"`
+	ParentOrderId           string                `json:"parent_order_id"`
+	ParentOrderAcceptanceId string                `json:"parent_order_acceptance_id"`
+	EventDate               types.Time            `json:"event_date"`
+	EventType               types.EventType       `json:"event_type"`
+	ParentOrderType         types.ParentOrderType `json:"parent_order_type"`
+	Reason                  string                `json:"reason"`
+	ChildOrderType          types.OrderType       `json:"child_order_type"`
+	ParameterIndex          int64                 `json:"parameter_index"`
+	ChildOrderAcceptanceId  string                `json:"child_order_acceptance_id"`
+	Side                    types.Side            `json:"side"`
+	Price                   float64               `json:"price"`
+	Size                    float64               `json:"size"`
+	ExpireDate              types.Time            `json:"expire_date"`
+}
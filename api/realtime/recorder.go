@@ -0,0 +1,174 @@
+package realtime
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+	"github.com/pkg/errors"
+)
+
+const (
+	// ReplaySpeedMax replays records as fast as possible.
+	ReplaySpeedMax float64 = 0
+	// ReplaySpeedOriginal replays records at the recorded intervals.
+	ReplaySpeedOriginal float64 = 1
+)
+
+const maxRecordSize int = 64 * 1024 * 1024
+
+// Record is a line of a recorded file.
+type Record struct {
+	ReceivedAt time.Time       `json:"received_at"`
+	Notify     *JsonRPC2Notify `json:"notify"`
+}
+
+// Recorder receives every channel message of RealAPIClient before it is dispatched.
+type Recorder interface {
+	Record(receivedAt time.Time, notify *JsonRPC2Notify) (error)
+}
+
+// FileRecorder appends records to a file as json lines.
+type FileRecorder struct {
+	mutex *sync.Mutex
+	file  *os.File
+}
+
+func (r *FileRecorder) Record(receivedAt time.Time, notify *JsonRPC2Notify) (error) {
+	line, err := json.Marshal(&Record{
+		ReceivedAt: receivedAt,
+		Notify:     notify,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "can not marshal record (channel = %v)", notify.Params.Channel)
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	// a line is written at once, so that a crash leaves at most a partial last line
+	_, err = r.file.Write(append(line, '\n'))
+	if err != nil {
+		return errors.Wrapf(err, "can not write record (path = %v)", r.file.Name())
+	}
+	return nil
+}
+
+func (r *FileRecorder) Close() (error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.file.Close()
+}
+
+// NewFileRecorder opens path for appending, records of an existing file are kept.
+func NewFileRecorder(path string) (*FileRecorder, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY | os.O_APPEND | os.O_CREATE, 0644)
+	if err != nil {
+		return nil, errors.Wrapf(err, "can not open file (path = %v)", path)
+	}
+	return &FileRecorder{
+		mutex: new(sync.Mutex),
+		file:  file,
+	}, nil
+}
+
+// Player reads records written by FileRecorder, plain or gzipped, and replays them in order.
+type Player struct {
+	reader io.Reader
+	closer io.Closer
+	speed  float64
+}
+
+// Play calls handler with every record. With a speed above 0 it waits for the recorded interval divided by speed
+// between records, e.g. 1 is the original speed and 10 is ten times faster.
+// A partial last line, left by a crash of the recorder, is ignored.
+func (p *Player) Play(ctx context.Context, handler func(record *Record) (error)) (error) {
+	reader := bufio.NewReader(p.reader)
+	magic, err := reader.Peek(2)
+	if err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gzipReader, err := gzip.NewReader(reader)
+		if err != nil {
+			return errors.Wrapf(err, "can not read gzip header")
+		}
+		defer gzipReader.Close()
+		reader = bufio.NewReader(gzipReader)
+	}
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64 * 1024), maxRecordSize)
+	var start time.Time
+	var first time.Time
+	var partial error
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		if partial != nil {
+			// only the last line can be partial
+			return partial
+		}
+		record := new(Record)
+		err := json.Unmarshal(line, record)
+		if err != nil {
+			partial = errors.Wrapf(err, "can not unmarshal record")
+			continue
+		}
+		if p.speed > 0 {
+			if start.IsZero() {
+				start = time.Now()
+				first = record.ReceivedAt
+			}
+			wait := time.Until(start.Add(time.Duration(float64(record.ReceivedAt.Sub(first)) / p.speed)))
+			if wait > 0 {
+				timer := time.NewTimer(wait)
+				select {
+				case <-ctx.Done():
+					timer.Stop()
+					return ctx.Err()
+				case <-timer.C:
+				}
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		err = handler(record)
+		if err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return errors.Wrapf(err, "can not read record")
+	}
+	return nil
+}
+
+func (p *Player) Close() (error) {
+	if p.closer == nil {
+		return nil
+	}
+	return p.closer.Close()
+}
+
+// NewPlayer replays records from reader at speed (ReplaySpeedMax, ReplaySpeedOriginal or a multiple).
+func NewPlayer(reader io.Reader, speed float64) (*Player) {
+	return &Player{
+		reader: reader,
+		speed:  speed,
+	}
+}
+
+// OpenPlayer replays records of the file at path. Close closes the file.
+func OpenPlayer(path string, speed float64) (*Player, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "can not open file (path = %v)", path)
+	}
+	return &Player{
+		reader: file,
+		closer: file,
+		speed:  speed,
+	}, nil
+}
@@ -0,0 +1,220 @@
+package realtime
+
+import (
+	"bytes"
+	"strconv"
+	"sync"
+	"time"
+	"encoding/json"
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+)
+
+// packet types of Engine.IO v3 and Socket.IO v2
+const (
+	engineIOOpen    byte = '0'
+	engineIOClose   byte = '1'
+	engineIOPing    byte = '2'
+	engineIOPong    byte = '3'
+	engineIOMessage byte = '4'
+	socketIOConnect    byte = '0'
+	socketIODisconnect byte = '1'
+	socketIOEvent      byte = '2'
+	socketIOAck        byte = '3'
+	socketIOError      byte = '4'
+)
+
+type socketIOOpen struct {
+	Sid          string `json:"sid"`
+	PingInterval int64  `json:"pingInterval"`
+	PingTimeout  int64  `json:"pingTimeout"`
+}
+
+type socketIOTransport struct {
+}
+
+func (t *socketIOTransport) Endpoint() (string) {
+	return "wss://io.lightstream.bitflyer.com/socket.io/?EIO=3&transport=websocket"
+}
+
+func (t *socketIOTransport) NewSession(conn *websocket.Conn) (Session, error) {
+	conn.SetReadDeadline(time.Now().Add(readTimeout))
+	_, message, err := conn.ReadMessage()
+	if err != nil {
+		return nil, errors.Wrapf(err, "can not read open packet")
+	}
+	if len(message) == 0 || message[0] != engineIOOpen {
+		return nil, errors.Errorf("unexpected open packet (packet = %v)", string(message))
+	}
+	open := new(socketIOOpen)
+	err = json.Unmarshal(message[1:], open)
+	if err != nil {
+		return nil, errors.Wrapf(err, "can not unmarshal open packet")
+	}
+	s := &socketIOSession{
+		conn:         conn,
+		writeMutex:   new(sync.Mutex),
+		pingInterval: time.Duration(open.PingInterval) * time.Millisecond,
+		pingTimeout:  time.Duration(open.PingTimeout) * time.Millisecond,
+		closeOnce:    new(sync.Once),
+		finishChan:   make(chan int),
+	}
+	if s.pingInterval > 0 {
+		go s.pingLoop()
+	}
+	return s, nil
+}
+
+// NewSocketIOTransport creates the transport of Socket.IO v2, which is an alternative when the JSON-RPC endpoint is blocked.
+func NewSocketIOTransport() (Transport) {
+	return &socketIOTransport{}
+}
+
+type socketIOSession struct {
+	conn         *websocket.Conn
+	writeMutex   *sync.Mutex
+	pingInterval time.Duration
+	pingTimeout  time.Duration
+	closeOnce    *sync.Once
+	finishChan   chan int
+}
+
+func (s *socketIOSession) write(packet []byte) (error) {
+	s.writeMutex.Lock()
+	defer s.writeMutex.Unlock()
+	s.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	return s.conn.WriteMessage(websocket.TextMessage, packet)
+}
+
+// pingLoop sends the ping of Engine.IO, the server closes the connection without it.
+func (s *socketIOSession) pingLoop() {
+	for {
+		select {
+		case <-s.finishChan:
+			return
+		case <-time.After(s.pingInterval):
+			err := s.write([]byte{engineIOPing})
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (s *socketIOSession) emit(id string, args ...interface{}) (error) {
+	body, err := json.Marshal(args)
+	if err != nil {
+		return errors.Wrapf(err, "can not marshal event")
+	}
+	packet := make([]byte, 0, len(body) + len(id) + 2)
+	packet = append(packet, engineIOMessage, socketIOEvent)
+	packet = append(packet, id...)
+	packet = append(packet, body...)
+	return s.write(packet)
+}
+
+func (s *socketIOSession) WriteRequest(method string, channel string) (error) {
+	return s.emit("", method, channel)
+}
+
+func (s *socketIOSession) WriteAuth(params *JsonRPC2AuthParams, id int64) (error) {
+	return s.emit(strconv.FormatInt(id, 10), "auth", params)
+}
+
+// splitId splits the packet id and the data of a socket.io packet.
+func splitId(packet []byte) (string, []byte) {
+	i := 0
+	for i < len(packet) && packet[i] >= '0' && packet[i] <= '9' {
+		i += 1
+	}
+	return string(packet[:i]), packet[i:]
+}
+
+func (s *socketIOSession) ReadMessage() (*JsonRPC2Notify, error) {
+	for {
+		s.conn.SetReadDeadline(time.Now().Add(s.pingInterval + s.pingTimeout + readTimeout))
+		_, packet, err := s.conn.ReadMessage()
+		if err != nil {
+			return nil, errors.Wrapf(err, "can not read message")
+		}
+		if len(packet) == 0 {
+			continue
+		}
+		switch packet[0] {
+		case engineIOPing:
+			err := s.write(append([]byte{engineIOPong}, packet[1:]...))
+			if err != nil {
+				return nil, errors.Wrapf(err, "can not write pong")
+			}
+			continue
+		case engineIOClose:
+			return nil, errors.Errorf("closed by server")
+		case engineIOMessage:
+		default:
+			continue
+		}
+		if len(packet) < 2 {
+			continue
+		}
+		switch packet[1] {
+		case socketIOEvent:
+			_, data := splitId(packet[2:])
+			args := make([]json.RawMessage, 0, 2)
+			err := json.Unmarshal(data, &args)
+			if err != nil {
+				return nil, errors.Wrapf(err, "can not unmarshal event (packet = %v)", string(packet))
+			}
+			if len(args) < 2 {
+				continue
+			}
+			var channel string
+			err = json.Unmarshal(args[0], &channel)
+			if err != nil {
+				return nil, errors.Wrapf(err, "can not unmarshal event name (packet = %v)", string(packet))
+			}
+			return &JsonRPC2Notify{
+				JsonRpc: "2.0",
+				Method:  "channelMessage",
+				Params:  &JsonRPC2NotifyParams{
+					Channel: channel,
+					Message: args[1],
+				},
+			}, nil
+		case socketIOAck:
+			id, data := splitId(packet[2:])
+			notify := &JsonRPC2Notify{
+				JsonRpc: "2.0",
+			}
+			notify.Id, err = strconv.ParseInt(id, 10, 64)
+			if err != nil {
+				return nil, errors.Wrapf(err, "can not parse ack id (packet = %v)", string(packet))
+			}
+			args := make([]json.RawMessage, 0, 1)
+			err = json.Unmarshal(data, &args)
+			if err != nil {
+				return nil, errors.Wrapf(err, "can not unmarshal ack (packet = %v)", string(packet))
+			}
+			// the first argument is an error, null on success
+			if len(args) == 0 || bytes.Equal(args[0], []byte("null")) {
+				notify.Result = json.RawMessage("true")
+			} else {
+				notify.Error = &JsonRPC2Error{
+					Message: string(args[0]),
+				}
+			}
+			return notify, nil
+		case socketIODisconnect:
+			return nil, errors.Errorf("disconnected by server")
+		case socketIOError:
+			return nil, errors.Errorf("error from server (packet = %v)", string(packet))
+		case socketIOConnect:
+			continue
+		default:
+			continue
+		}
+	}
+}
+
+func (s *socketIOSession) Close() {
+	s.closeOnce.Do(func() { close(s.finishChan) })
+}
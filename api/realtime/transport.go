@@ -0,0 +1,86 @@
+package realtime
+
+import (
+	"time"
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+)
+
+const (
+	writeTimeout time.Duration = 10 * time.Second
+	readTimeout  time.Duration = time.Minute
+)
+
+// Transport frames messages of the realtime api on a websocket connection.
+type Transport interface {
+	// Endpoint returns the default endpoint of the transport.
+	Endpoint() (string)
+	// NewSession starts a session on a new connection.
+	NewSession(conn *websocket.Conn) (Session, error)
+}
+
+type Session interface {
+	// WriteRequest writes subscribe or unsubscribe.
+	WriteRequest(method string, channel string) (error)
+	// WriteAuth writes auth. The response is returned by ReadMessage with the same id.
+	WriteAuth(params *JsonRPC2AuthParams, id int64) (error)
+	// ReadMessage returns a channel message (Params is not nil) or a response of auth.
+	ReadMessage() (*JsonRPC2Notify, error)
+	Close()
+}
+
+type jsonRPC2Transport struct {
+}
+
+func (t *jsonRPC2Transport) Endpoint() (string) {
+	return "wss://ws.lightstream.bitflyer.com/json-rpc"
+}
+
+func (t *jsonRPC2Transport) NewSession(conn *websocket.Conn) (Session, error) {
+	return &jsonRPC2Session{
+		conn: conn,
+	}, nil
+}
+
+// NewJsonRPC2Transport creates the transport of JSON-RPC 2.0 over WebSocket.
+func NewJsonRPC2Transport() (Transport) {
+	return &jsonRPC2Transport{}
+}
+
+type jsonRPC2Session struct {
+	conn *websocket.Conn
+}
+
+func (s *jsonRPC2Session) WriteRequest(method string, channel string) (error) {
+	s.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	return s.conn.WriteJSON(&JsonRPC2Subscribe{
+		JsonRpc: "2.0",
+		Method:  method,
+		Params:  JsonRPC2SubscribeParams{
+			Channel: channel,
+		},
+	})
+}
+
+func (s *jsonRPC2Session) WriteAuth(params *JsonRPC2AuthParams, id int64) (error) {
+	s.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	return s.conn.WriteJSON(&JsonRPC2Auth{
+		JsonRpc: "2.0",
+		Method:  "auth",
+		Params:  params,
+		Id:      id,
+	})
+}
+
+func (s *jsonRPC2Session) ReadMessage() (*JsonRPC2Notify, error) {
+	notify := new(JsonRPC2Notify)
+	s.conn.SetReadDeadline(time.Now().Add(readTimeout))
+	err := s.conn.ReadJSON(notify)
+	if err != nil {
+		return nil, errors.Wrapf(err, "can not read message")
+	}
+	return notify, nil
+}
+
+func (s *jsonRPC2Session) Close() {
+}
@@ -0,0 +1,334 @@
+package api
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"github.com/potix/gobitflyer/client"
+	"github.com/potix/gobitflyer/api/types"
+	"github.com/potix/gobitflyer/api/private"
+)
+
+const (
+	// tolerance of the clock difference between us and bitFlyer when looking up a sent order
+	orderLookupClockSkew   time.Duration = 5 * time.Second
+	// how long an accepted order is looked up before it is considered not accepted
+	orderLookupTimeout     time.Duration = 10 * time.Second
+	orderLookupInterval    time.Duration = 1 * time.Second
+	orderLookupCount       int64         = 100
+	// how long acceptance ids returned by the client are excluded from lookup
+	acceptedOrderRetention time.Duration = 1 * time.Hour
+)
+
+// acceptedOrders remembers acceptance ids which the client already returned, so that lookup does not
+// mistake an earlier order with the same parameters for the one being retried.
+type acceptedOrders struct {
+	mutex *sync.Mutex
+	ids   map[string]time.Time
+}
+
+func (a *acceptedOrders) add(acceptanceId string) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	now := time.Now()
+	for id, acceptedAt := range a.ids {
+		if now.Sub(acceptedAt) > acceptedOrderRetention {
+			delete(a.ids, id)
+		}
+	}
+	a.ids[acceptanceId] = now
+}
+
+func (a *acceptedOrders) contains(acceptanceId string) (bool) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	_, ok := a.ids[acceptanceId]
+	return ok
+}
+
+func newAcceptedOrders() (*acceptedOrders) {
+	return &acceptedOrders{
+		mutex: new(sync.Mutex),
+		ids:   make(map[string]time.Time),
+	}
+}
+
+type RetryPolicy struct {
+	MaxAttempts     int
+	BaseWait        time.Duration
+	MaxWait         time.Duration
+	RetryableStatus []int
+}
+
+func (p *RetryPolicy) canRetry(attempt int) (bool) {
+	return p != nil && attempt < p.MaxAttempts
+}
+
+func (p *RetryPolicy) isRetryableStatus(statusCode int) (bool) {
+	if p == nil {
+		return false
+	}
+	for _, s := range p.RetryableStatus {
+		if s == statusCode {
+			return true
+		}
+	}
+	return false
+}
+
+// isRetryableError reports whether a failed attempt may succeed if it is made again.
+func (p *RetryPolicy) isRetryableError(ctx context.Context, err error) (bool) {
+	if ctx.Err() != nil {
+		return false
+	}
+	if IsRateLimited(err) {
+		return false
+	}
+	return true
+}
+
+// backoff is exponential with full jitter.
+func (p *RetryPolicy) backoff(attempt int) (time.Duration) {
+	wait := p.BaseWait << uint(attempt - 1)
+	if wait <= 0 || wait > p.MaxWait {
+		wait = p.MaxWait
+	}
+	if wait <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(wait) + 1))
+}
+
+func (p *RetryPolicy) wait(ctx context.Context, attempt int) (error) {
+	timer := time.NewTimer(p.backoff(attempt))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func NewRetryPolicy(maxAttempts int, baseWait time.Duration, maxWait time.Duration, retryableStatus ...int) (*RetryPolicy) {
+	return &RetryPolicy{
+		MaxAttempts:     maxAttempts,
+		BaseWait:        baseWait,
+		MaxWait:         maxWait,
+		RetryableStatus: retryableStatus,
+	}
+}
+
+func DefaultRetryPolicy() (*RetryPolicy) {
+	return NewRetryPolicy(3, 500 * time.Millisecond, 5 * time.Second,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout)
+}
+
+type orderLookup func(ctx context.Context) (*http.Response, []byte, bool, error)
+
+// doRequestWithRetry retries requests which are safe to repeat (GET and cancel).
+func (c *APIClient) doRequestWithRetry(ctx context.Context, httpRequest *client.HTTPRequest, rateLimiter *RateLimiter, private bool) (*http.Response, []byte, error) {
+	for attempt := 1; ; attempt += 1 {
+		httpResponse, body, err := c.doRequest(ctx, httpRequest, rateLimiter, private)
+		if err == nil && !c.retryPolicy.isRetryableStatus(httpResponse.StatusCode) {
+			return httpResponse, body, nil
+		}
+		if !c.retryPolicy.canRetry(attempt) {
+			return httpResponse, body, err
+		}
+		if err != nil && !c.retryPolicy.isRetryableError(ctx, err) {
+			return httpResponse, body, err
+		}
+		if waitErr := c.retryPolicy.wait(ctx, attempt); waitErr != nil {
+			return httpResponse, body, err
+		}
+	}
+}
+
+// doOrderRequestWithRetry retries an order request only when bitFlyer explicitly did not accept the order
+// or when lookup keeps confirming that the order does not exist, so that a retry never creates a duplicate order.
+// When lookup finds the order, its response is returned as if the original request had succeeded.
+// When lookup can not tell whether the order was accepted, an error is returned instead of a retry.
+func (c *APIClient) doOrderRequestWithRetry(ctx context.Context, name string, httpRequest *client.HTTPRequest, lookup orderLookup) (*http.Response, []byte, error) {
+	for attempt := 1; ; attempt += 1 {
+		httpResponse, body, err := c.doRequest(ctx, httpRequest, c.orderRateLimiter, true)
+		if err == nil && httpResponse.StatusCode == http.StatusOK {
+			return httpResponse, body, nil
+		}
+		if !c.retryPolicy.canRetry(attempt) {
+			return httpResponse, body, err
+		}
+		if err != nil && !c.retryPolicy.isRetryableError(ctx, err) {
+			return httpResponse, body, err
+		}
+		if err == nil {
+			apiError := newAPIError(name, httpRequest, httpResponse, body)
+			if !IsServerBusy(apiError) && !c.retryPolicy.isRetryableStatus(httpResponse.StatusCode) {
+				return httpResponse, body, err
+			}
+			if apiError.Status == BFErrorStatusOrderNotAccepted {
+				// rejected without being accepted, safe to retry without lookup
+				if waitErr := c.retryPolicy.wait(ctx, attempt); waitErr != nil {
+					return httpResponse, body, err
+				}
+				continue
+			}
+		}
+		if waitErr := c.retryPolicy.wait(ctx, attempt); waitErr != nil {
+			return httpResponse, body, err
+		}
+		lookupResponse, lookupBody, found, lookupErr := c.pollOrderLookup(ctx, lookup)
+		if lookupErr != nil {
+			// the order may have been accepted, give up
+			return httpResponse, body, errors.Wrapf(lookupErr, "can not confirm whether %v was accepted (previous error = %v)", name, err)
+		}
+		if found {
+			return lookupResponse, lookupBody, nil
+		}
+	}
+}
+
+// pollOrderLookup repeats lookup until the order is found or orderLookupTimeout elapses,
+// because an accepted order may take a while to appear in the order list.
+func (c *APIClient) pollOrderLookup(ctx context.Context, lookup orderLookup) (*http.Response, []byte, bool, error) {
+	deadline := time.Now().Add(orderLookupTimeout)
+	for {
+		httpResponse, body, found, err := lookup(ctx)
+		if err != nil || found {
+			return httpResponse, body, found, err
+		}
+		if !time.Now().Before(deadline) {
+			return nil, nil, false, nil
+		}
+		timer := time.NewTimer(orderLookupInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, nil, false, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (c *APIClient) lookupChildOrder(ctx context.Context, sendChildOrderRequest *private.SendChildOrderRequest, sentAt time.Time) (*http.Response, []byte, bool, error) {
+	httpResponse, getChildOrdersResponse, err := c.PriGetChildOrdersCtx(ctx, sendChildOrderRequest.ProductCode, orderLookupCount, 0, 0, types.OrderStateNone)
+	if err != nil {
+		return nil, nil, false, errors.Wrapf(err, "can not get child orders")
+	}
+	if int64(len(getChildOrdersResponse)) >= orderLookupCount {
+		oldest := getChildOrdersResponse[len(getChildOrdersResponse) - 1]
+		if !oldest.ChildOrderDate.Before(sentAt.Add(-orderLookupClockSkew)) {
+			return nil, nil, false, errors.Errorf("too many recent child orders to look up (count = %v)", len(getChildOrdersResponse))
+		}
+	}
+	var matched *private.GetChildOrdersOrder
+	for _, order := range getChildOrdersResponse {
+		if order.ChildOrderDate.IsZero() || order.ChildOrderDate.Before(sentAt.Add(-orderLookupClockSkew)) {
+			continue
+		}
+		if c.acceptedOrders.contains(order.ChildOrderAcceptanceId) {
+			continue
+		}
+		if order.ChildOrderType != sendChildOrderRequest.ChildOrderType ||
+		   order.Side != sendChildOrderRequest.Side ||
+		   !order.Size.Equal(sendChildOrderRequest.Size) {
+			continue
+		}
+		if sendChildOrderRequest.ChildOrderType == types.OrderTypeLimit && !order.Price.Equal(sendChildOrderRequest.Price) {
+			continue
+		}
+		if matched != nil {
+			return nil, nil, false, errors.Errorf("ambiguous child orders (acceptance ids = %v, %v)", matched.ChildOrderAcceptanceId, order.ChildOrderAcceptanceId)
+		}
+		matched = order
+	}
+	if matched == nil {
+		return nil, nil, false, nil
+	}
+	body, err := json.Marshal(&private.SendChildOrderResponse{
+		ChildOrderAcceptanceId: matched.ChildOrderAcceptanceId,
+	})
+	if err != nil {
+		return nil, nil, false, errors.Wrapf(err, "can not marshal send child order response")
+	}
+	return httpResponse, body, true, nil
+}
+
+func (c *APIClient) lookupParentOrder(ctx context.Context, sendParentOrderRequest *private.SendParentOrderRequest, sentAt time.Time) (*http.Response, []byte, bool, error) {
+	if len(sendParentOrderRequest.Parameters) == 0 {
+		return nil, nil, false, errors.Errorf("no parameters in send parent order request")
+	}
+	firstParameter := sendParentOrderRequest.Parameters[0]
+	parentOrderType := types.NewParentOrderType(sendParentOrderRequest.OrderMethod, firstParameter.ConditionType)
+	httpResponse, getParentOrdersResponse, err := c.PriGetParentOrdersCtx(ctx, firstParameter.ProductCode, orderLookupCount, 0, 0, types.OrderStateNone)
+	if err != nil {
+		return nil, nil, false, errors.Wrapf(err, "can not get parent orders")
+	}
+	if int64(len(getParentOrdersResponse)) >= orderLookupCount {
+		oldest := getParentOrdersResponse[len(getParentOrdersResponse) - 1]
+		if !oldest.ParentOrderDate.Before(sentAt.Add(-orderLookupClockSkew)) {
+			return nil, nil, false, errors.Errorf("too many recent parent orders to look up (count = %v)", len(getParentOrdersResponse))
+		}
+	}
+	matchedId := ""
+	for _, order := range getParentOrdersResponse {
+		if order.ParentOrderDate.IsZero() || order.ParentOrderDate.Before(sentAt.Add(-orderLookupClockSkew)) {
+			continue
+		}
+		if c.acceptedOrders.contains(order.ParentOrderAcceptanceId) {
+			continue
+		}
+		if order.ParentOrderType != parentOrderType {
+			continue
+		}
+		_, getParentOrderResponse, err := c.PriGetParentOrderCtx(ctx, types.IdTypeParentOrderAcceptanceId, order.ParentOrderAcceptanceId)
+		if err != nil {
+			return nil, nil, false, errors.Wrapf(err, "can not get parent order (acceptance id = %v)", order.ParentOrderAcceptanceId)
+		}
+		if !matchParentOrder(sendParentOrderRequest, getParentOrderResponse) {
+			continue
+		}
+		if matchedId != "" {
+			return nil, nil, false, errors.Errorf("ambiguous parent orders (acceptance ids = %v, %v)", matchedId, order.ParentOrderAcceptanceId)
+		}
+		matchedId = order.ParentOrderAcceptanceId
+	}
+	if matchedId == "" {
+		return nil, nil, false, nil
+	}
+	body, err := json.Marshal(&private.SendParentOrderResponse{
+		ParentOrderAcceptanceId: matchedId,
+	})
+	if err != nil {
+		return nil, nil, false, errors.Wrapf(err, "can not marshal send parent order response")
+	}
+	return httpResponse, body, true, nil
+}
+
+// matchParentOrder compares every parameter of the sent order with the order on bitFlyer.
+func matchParentOrder(sendParentOrderRequest *private.SendParentOrderRequest, getParentOrderResponse *private.GetParentOrderResponse) (bool) {
+	if getParentOrderResponse.OrderMethod != sendParentOrderRequest.OrderMethod ||
+	   len(getParentOrderResponse.Parameters) != len(sendParentOrderRequest.Parameters) {
+		return false
+	}
+	for i, sent := range sendParentOrderRequest.Parameters {
+		got := getParentOrderResponse.Parameters[i]
+		if got.ProductCode != sent.ProductCode ||
+		   got.ConditionType != sent.ConditionType ||
+		   got.Side != sent.Side ||
+		   !got.Price.Equal(types.NewDecimalFromFloat(sent.Price)) ||
+		   !got.Size.Equal(types.NewDecimalFromFloat(sent.Size)) ||
+		   !got.TriggerPrice.Equal(types.NewDecimalFromFloat(sent.TriggerPrice)) ||
+		   !got.Offset.Equal(types.NewDecimalFromFloat(sent.Offset)) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,344 @@
+package api
+
+import (
+	"context"
+	"sync"
+	"github.com/pkg/errors"
+	"github.com/potix/gobitflyer/api/types"
+	"github.com/potix/gobitflyer/api/public"
+	"github.com/potix/gobitflyer/api/realtime"
+	"github.com/potix/gobitflyer/orderbook"
+)
+
+type OverflowPolicy int
+
+const (
+	// discard the oldest queued message
+	OverflowPolicyDropOldest OverflowPolicy = 0
+	// discard the new message
+	OverflowPolicyDropNewest OverflowPolicy = 1
+	// wait for the consumer, which also stops reading the realtime api
+	OverflowPolicyBlock      OverflowPolicy = 2
+	// discard all queued messages and keep only the new message
+	OverflowPolicyCoalesce   OverflowPolicy = 3
+)
+
+const (
+	defaultStreamBufferSize int = 64
+)
+
+type StreamOptions struct {
+	BufferSize     int
+	OverflowPolicy OverflowPolicy
+}
+
+// stream decouples realtime callbacks from a consumer. Callbacks push messages into the queue and
+// a goroutine sends them to the channel of the consumer.
+type stream struct {
+	mutex          *sync.Mutex
+	cond           *sync.Cond
+	queue          []interface{}
+	bufferSize     int
+	overflowPolicy OverflowPolicy
+	closed         bool
+	errChan        chan error
+	finishChan     chan int
+}
+
+func (s *stream) push(message interface{}) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed {
+		return
+	}
+	if len(s.queue) >= s.bufferSize {
+		switch s.overflowPolicy {
+		case OverflowPolicyDropNewest:
+			return
+		case OverflowPolicyBlock:
+			for len(s.queue) >= s.bufferSize && !s.closed {
+				s.cond.Wait()
+			}
+			if s.closed {
+				return
+			}
+		case OverflowPolicyCoalesce:
+			s.queue = s.queue[:0]
+		default:
+			s.queue = s.queue[1:]
+		}
+	}
+	s.queue = append(s.queue, message)
+	s.cond.Broadcast()
+}
+
+func (s *stream) pop() (interface{}, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for len(s.queue) == 0 && !s.closed {
+		s.cond.Wait()
+	}
+	if s.closed {
+		return nil, false
+	}
+	message := s.queue[0]
+	s.queue[0] = nil
+	s.queue = s.queue[1:]
+	s.cond.Broadcast()
+	return message, true
+}
+
+func (s *stream) close(err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	s.queue = nil
+	s.errChan <- err
+	close(s.errChan)
+	close(s.finishChan)
+	s.cond.Broadcast()
+}
+
+// run sends messages by send until the stream is closed, then calls finish.
+func (s *stream) run(send func(message interface{}) (bool), finish func()) {
+	go func() {
+		defer finish()
+		for {
+			message, ok := s.pop()
+			if !ok || !send(message) {
+				return
+			}
+		}
+	}()
+}
+
+func newStream(streamOptions *StreamOptions) (*stream) {
+	s := &stream{
+		mutex:          new(sync.Mutex),
+		bufferSize:     defaultStreamBufferSize,
+		overflowPolicy: OverflowPolicyDropOldest,
+		errChan:        make(chan error, 1),
+		finishChan:     make(chan int),
+	}
+	s.cond = sync.NewCond(s.mutex)
+	if streamOptions != nil {
+		if streamOptions.BufferSize > 0 {
+			s.bufferSize = streamOptions.BufferSize
+		}
+		s.overflowPolicy = streamOptions.OverflowPolicy
+	}
+	return s
+}
+
+// watchStream closes the stream with the reason when ctx is done, the channel is unsubscribed or the connection is closed.
+func (c *RealAPIClient) watchStream(ctx context.Context, s *stream, realtimeType types.RealtimeType, productCode types.ProductCode) {
+	c.mutex.Lock()
+	rc, ok := c.realtimeChannels[realtime.ChannelName(realtimeType, productCode)]
+	var wsDoneChan <-chan int
+	if c.player == nil {
+		wsDoneChan = c.wsClient.Done()
+	}
+	c.mutex.Unlock()
+	if !ok {
+		s.close(errors.Errorf("realtime channel was unsubscribed (type = %v, product code = %v)", realtimeType, productCode))
+		return
+	}
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.unsubscribeChannel(rc)
+			s.close(ctx.Err())
+		case <-rc.FinishChan:
+			if err := ctx.Err(); err != nil {
+				// unsubscribed by the context
+				s.close(err)
+				return
+			}
+			s.close(errors.Errorf("realtime channel was unsubscribed (type = %v, product code = %v)", realtimeType, productCode))
+		case <-wsDoneChan:
+			// the client gave up reconnecting, the channel is not delivered any more
+			c.unsubscribeChannel(rc)
+			s.close(errors.Errorf("realtime connection was closed"))
+		}
+	}()
+}
+
+// RealTickerStream is a channel based alternative of RealTickerStart. The error channel receives the reason
+// when the stream ends, and then both channels are closed.
+func (c *RealAPIClient) RealTickerStream(productCode types.ProductCode, streamOptions *StreamOptions) (<-chan *public.GetTickerResponse, <-chan error, error) {
+	return c.RealTickerStreamCtx(context.Background(), productCode, streamOptions)
+}
+
+func (c *RealAPIClient) RealTickerStreamCtx(ctx context.Context, productCode types.ProductCode, streamOptions *StreamOptions) (<-chan *public.GetTickerResponse, <-chan error, error) {
+	s := newStream(streamOptions)
+	err := c.RealTickerStartCtx(ctx, productCode, func(productCode types.ProductCode, getTickerResponse *public.GetTickerResponse, callbackData interface{}) {
+		s.push(getTickerResponse)
+	}, nil)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "can not start ticker stream")
+	}
+	tickerChan := make(chan *public.GetTickerResponse)
+	s.run(func(message interface{}) (bool) {
+		select {
+		case tickerChan <- message.(*public.GetTickerResponse):
+			return true
+		case <-s.finishChan:
+			return false
+		}
+	}, func() { close(tickerChan) })
+	c.watchStream(ctx, s, types.RealtimeTypeTicker, productCode)
+	return tickerChan, s.errChan, nil
+}
+
+func (c *RealAPIClient) RealExecutionsStream(productCode types.ProductCode, streamOptions *StreamOptions) (<-chan public.GetExecutionsResponse, <-chan error, error) {
+	return c.RealExecutionsStreamCtx(context.Background(), productCode, streamOptions)
+}
+
+func (c *RealAPIClient) RealExecutionsStreamCtx(ctx context.Context, productCode types.ProductCode, streamOptions *StreamOptions) (<-chan public.GetExecutionsResponse, <-chan error, error) {
+	s := newStream(streamOptions)
+	err := c.RealExecutionsStartCtx(ctx, productCode, func(productCode types.ProductCode, getExecutionsResponse public.GetExecutionsResponse, callbackData interface{}) {
+		s.push(getExecutionsResponse)
+	}, nil)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "can not start executions stream")
+	}
+	executionsChan := make(chan public.GetExecutionsResponse)
+	s.run(func(message interface{}) (bool) {
+		select {
+		case executionsChan <- message.(public.GetExecutionsResponse):
+			return true
+		case <-s.finishChan:
+			return false
+		}
+	}, func() { close(executionsChan) })
+	c.watchStream(ctx, s, types.RealtimeTypeExecutions, productCode)
+	return executionsChan, s.errChan, nil
+}
+
+func (c *RealAPIClient) RealBoardSnapshotStream(productCode types.ProductCode, streamOptions *StreamOptions) (<-chan *public.GetBoardResponse, <-chan error, error) {
+	return c.RealBoardSnapshotStreamCtx(context.Background(), productCode, streamOptions)
+}
+
+func (c *RealAPIClient) RealBoardSnapshotStreamCtx(ctx context.Context, productCode types.ProductCode, streamOptions *StreamOptions) (<-chan *public.GetBoardResponse, <-chan error, error) {
+	s := newStream(streamOptions)
+	err := c.RealBoardSnapshotStartCtx(ctx, productCode, func(productCode types.ProductCode, getBoardResponse *public.GetBoardResponse, callbackData interface{}) {
+		s.push(getBoardResponse)
+	}, nil)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "can not start board snapshot stream")
+	}
+	boardChan := make(chan *public.GetBoardResponse)
+	s.run(func(message interface{}) (bool) {
+		select {
+		case boardChan <- message.(*public.GetBoardResponse):
+			return true
+		case <-s.finishChan:
+			return false
+		}
+	}, func() { close(boardChan) })
+	c.watchStream(ctx, s, types.RealtimeTypeBoardSnapshot, productCode)
+	return boardChan, s.errChan, nil
+}
+
+func (c *RealAPIClient) RealBoardStream(productCode types.ProductCode, merge bool, streamOptions *StreamOptions) (<-chan *public.GetBoardResponse, <-chan error, error) {
+	return c.RealBoardStreamCtx(context.Background(), productCode, merge, streamOptions)
+}
+
+func (c *RealAPIClient) RealBoardStreamCtx(ctx context.Context, productCode types.ProductCode, merge bool, streamOptions *StreamOptions) (<-chan *public.GetBoardResponse, <-chan error, error) {
+	s := newStream(streamOptions)
+	err := c.RealBoardStartCtx(ctx, productCode, func(productCode types.ProductCode, getBoardResponse *public.GetBoardResponse, callbackData interface{}) {
+		s.push(getBoardResponse)
+	}, nil, merge)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "can not start board stream")
+	}
+	boardChan := make(chan *public.GetBoardResponse)
+	s.run(func(message interface{}) (bool) {
+		select {
+		case boardChan <- message.(*public.GetBoardResponse):
+			return true
+		case <-s.finishChan:
+			return false
+		}
+	}, func() { close(boardChan) })
+	c.watchStream(ctx, s, types.RealtimeTypeBoard, productCode)
+	return boardChan, s.errChan, nil
+}
+
+func (c *RealAPIClient) RealOrderBookStream(productCode types.ProductCode, streamOptions *StreamOptions) (<-chan *orderbook.Snapshot, <-chan error, error) {
+	return c.RealOrderBookStreamCtx(context.Background(), productCode, streamOptions)
+}
+
+func (c *RealAPIClient) RealOrderBookStreamCtx(ctx context.Context, productCode types.ProductCode, streamOptions *StreamOptions) (<-chan *orderbook.Snapshot, <-chan error, error) {
+	s := newStream(streamOptions)
+	err := c.RealOrderBookStartCtx(ctx, productCode, func(productCode types.ProductCode, snapshot *orderbook.Snapshot, callbackData interface{}) {
+		s.push(snapshot)
+	}, nil)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "can not start order book stream")
+	}
+	snapshotChan := make(chan *orderbook.Snapshot)
+	s.run(func(message interface{}) (bool) {
+		select {
+		case snapshotChan <- message.(*orderbook.Snapshot):
+			return true
+		case <-s.finishChan:
+			return false
+		}
+	}, func() { close(snapshotChan) })
+	c.watchStream(ctx, s, types.RealtimeTypeBoard, productCode)
+	return snapshotChan, s.errChan, nil
+}
+
+func (c *RealAPIClient) RealChildOrderEventsStream(streamOptions *StreamOptions) (<-chan realtime.ChildOrderEvents, <-chan error, error) {
+	return c.RealChildOrderEventsStreamCtx(context.Background(), streamOptions)
+}
+
+func (c *RealAPIClient) RealChildOrderEventsStreamCtx(ctx context.Context, streamOptions *StreamOptions) (<-chan realtime.ChildOrderEvents, <-chan error, error) {
+	s := newStream(streamOptions)
+	err := c.RealChildOrderEventsStartCtx(ctx, func(childOrderEvents realtime.ChildOrderEvents, callbackData interface{}) {
+		s.push(childOrderEvents)
+	}, nil)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "can not start child order events stream")
+	}
+	eventsChan := make(chan realtime.ChildOrderEvents)
+	s.run(func(message interface{}) (bool) {
+		select {
+		case eventsChan <- message.(realtime.ChildOrderEvents):
+			return true
+		case <-s.finishChan:
+			return false
+		}
+	}, func() { close(eventsChan) })
+	c.watchStream(ctx, s, types.RealtimeTypeChildOrderEvents, "")
+	return eventsChan, s.errChan, nil
+}
+
+func (c *RealAPIClient) RealParentOrderEventsStream(streamOptions *StreamOptions) (<-chan realtime.ParentOrderEvents, <-chan error, error) {
+	return c.RealParentOrderEventsStreamCtx(context.Background(), streamOptions)
+}
+
+func (c *RealAPIClient) RealParentOrderEventsStreamCtx(ctx context.Context, streamOptions *StreamOptions) (<-chan realtime.ParentOrderEvents, <-chan error, error) {
+	s := newStream(streamOptions)
+	err := c.RealParentOrderEventsStartCtx(ctx, func(parentOrderEvents realtime.ParentOrderEvents, callbackData interface{}) {
+		s.push(parentOrderEvents)
+	}, nil)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "can not start parent order events stream")
+	}
+	eventsChan := make(chan realtime.ParentOrderEvents)
+	s.run(func(message interface{}) (bool) {
+		select {
+		case eventsChan <- message.(realtime.ParentOrderEvents):
+			return true
+		case <-s.finishChan:
+			return false
+		}
+	}, func() { close(eventsChan) })
+	c.watchStream(ctx, s, types.RealtimeTypeParentOrderEvents, "")
+	return eventsChan, s.errChan, nil
+}
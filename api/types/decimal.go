@@ -0,0 +1,300 @@
+package types
+
+import (
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"github.com/pkg/errors"
+)
+
+type roundingMode int
+
+const (
+	roundHalfUp roundingMode = iota
+	roundDown
+	roundFloor
+	roundCeil
+)
+
+var bigTen = big.NewInt(10)
+
+// Decimal is a fixed-point decimal number for prices and sizes. It keeps the digits as they are on the wire,
+// so "0.10" is marshaled as 0.10. The zero value is 0.
+//
+// Decimal can be converted from and to the float64 fields of requests and responses without loss with
+// NewDecimalFromFloat and Float64, as long as the value has less than 16 significant digits.
+type Decimal struct {
+	value *big.Int // unscaled value, nil is 0
+	scale int32    // number of digits after the decimal point
+}
+
+// NewDecimal returns value * 10^-scale, e.g. NewDecimal(15, 1) is 1.5.
+func NewDecimal(value int64, scale int32) (Decimal) {
+	if scale < 0 {
+		return Decimal{value: big.NewInt(value)}.mul(pow10(-int64(scale)))
+	}
+	return Decimal{value: big.NewInt(value), scale: scale}
+}
+
+// NewDecimalFromFloat converts f with the shortest representation, which is the representation on the wire
+// when f is decoded from json. NaN and Inf are 0.
+func NewDecimalFromFloat(f float64) (Decimal) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return Decimal{}
+	}
+	d, err := ParseDecimal(strconv.FormatFloat(f, 'f', -1, 64))
+	if err != nil {
+		return Decimal{}
+	}
+	return d
+}
+
+// ParseDecimal parses a decimal number like "-12.345" or "1.5e-05".
+func ParseDecimal(s string) (Decimal, error) {
+	mantissa := s
+	exponent := int64(0)
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		e, err := strconv.ParseInt(s[i+1:], 10, 32)
+		if err != nil {
+			return Decimal{}, errors.Errorf("invalid decimal exponent (decimal = %v)", s)
+		}
+		mantissa = s[:i]
+		exponent = e
+	}
+	digits := mantissa
+	scale := int64(0)
+	if i := strings.IndexByte(mantissa, '.'); i >= 0 {
+		digits = mantissa[:i] + mantissa[i+1:]
+		scale = int64(len(mantissa) - i - 1)
+	}
+	unsigned := strings.TrimLeft(digits, "+-")
+	if len(unsigned) == 0 || len(digits) - len(unsigned) > 1 || strings.Trim(unsigned, "0123456789") != "" {
+		return Decimal{}, errors.Errorf("invalid decimal (decimal = %v)", s)
+	}
+	value, ok := new(big.Int).SetString(digits, 10)
+	if !ok {
+		return Decimal{}, errors.Errorf("invalid decimal (decimal = %v)", s)
+	}
+	scale -= exponent
+	if scale > math.MaxInt32 || scale < math.MinInt32 {
+		return Decimal{}, errors.Errorf("decimal exponent is out of range (decimal = %v)", s)
+	}
+	if scale < 0 {
+		return Decimal{value: value}.mul(pow10(-scale)), nil
+	}
+	return Decimal{value: value, scale: int32(scale)}, nil
+}
+
+// MustParseDecimal is like ParseDecimal but panics if s can not be parsed. It is intended for constants.
+func MustParseDecimal(s string) (Decimal) {
+	d, err := ParseDecimal(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func pow10(n int64) (*big.Int) {
+	return new(big.Int).Exp(bigTen, big.NewInt(n), nil)
+}
+
+func (d Decimal) unscaled() (*big.Int) {
+	if d.value == nil {
+		return new(big.Int)
+	}
+	return d.value
+}
+
+func (d Decimal) mul(n *big.Int) (Decimal) {
+	return Decimal{value: new(big.Int).Mul(d.unscaled(), n), scale: d.scale}
+}
+
+// rescale returns d with more digits after the decimal point, scale must not be less than d.scale.
+func (d Decimal) rescale(scale int32) (Decimal) {
+	if scale <= d.scale {
+		return Decimal{value: d.unscaled(), scale: d.scale}
+	}
+	return Decimal{value: new(big.Int).Mul(d.unscaled(), pow10(int64(scale - d.scale))), scale: scale}
+}
+
+// align returns a and b with the same scale, a nil value of either is replaced with 0.
+func align(a Decimal, b Decimal) (Decimal, Decimal) {
+	if a.scale < b.scale {
+		return a.rescale(b.scale), b.rescale(b.scale)
+	}
+	return a.rescale(a.scale), b.rescale(a.scale)
+}
+
+// roundQuo returns n / m rounded with mode, m must be positive.
+func roundQuo(n *big.Int, m *big.Int, mode roundingMode) (*big.Int) {
+	q, r := new(big.Int).QuoRem(n, m, new(big.Int))
+	if r.Sign() == 0 {
+		return q
+	}
+	switch mode {
+	case roundHalfUp:
+		if new(big.Int).Lsh(new(big.Int).Abs(r), 1).Cmp(m) >= 0 {
+			q.Add(q, big.NewInt(int64(n.Sign())))
+		}
+	case roundFloor:
+		if n.Sign() < 0 {
+			q.Sub(q, big.NewInt(1))
+		}
+	case roundCeil:
+		if n.Sign() > 0 {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	return q
+}
+
+func (d Decimal) Scale() (int32) {
+	return d.scale
+}
+
+func (d Decimal) Sign() (int) {
+	return d.unscaled().Sign()
+}
+
+func (d Decimal) IsZero() (bool) {
+	return d.Sign() == 0
+}
+
+// Cmp compares the values, 1.5 and 1.50 are equal.
+func (d Decimal) Cmp(e Decimal) (int) {
+	a, b := align(d, e)
+	return a.unscaled().Cmp(b.unscaled())
+}
+
+func (d Decimal) Equal(e Decimal) (bool) {
+	return d.Cmp(e) == 0
+}
+
+func (d Decimal) Neg() (Decimal) {
+	return Decimal{value: new(big.Int).Neg(d.unscaled()), scale: d.scale}
+}
+
+func (d Decimal) Abs() (Decimal) {
+	return Decimal{value: new(big.Int).Abs(d.unscaled()), scale: d.scale}
+}
+
+func (d Decimal) Add(e Decimal) (Decimal) {
+	a, b := align(d, e)
+	return Decimal{value: new(big.Int).Add(a.unscaled(), b.unscaled()), scale: a.scale}
+}
+
+func (d Decimal) Sub(e Decimal) (Decimal) {
+	a, b := align(d, e)
+	return Decimal{value: new(big.Int).Sub(a.unscaled(), b.unscaled()), scale: a.scale}
+}
+
+func (d Decimal) Mul(e Decimal) (Decimal) {
+	return Decimal{value: new(big.Int).Mul(d.unscaled(), e.unscaled()), scale: d.scale + e.scale}
+}
+
+// Div returns d / e rounded half up to scale digits after the decimal point. It panics if e is 0.
+func (d Decimal) Div(e Decimal, scale int32) (Decimal) {
+	// d / e = (dv * 10^(scale - ds + es)) / ev / 10^scale
+	n := d.unscaled()
+	m := e.unscaled()
+	shift := int64(scale) - int64(d.scale) + int64(e.scale)
+	if shift >= 0 {
+		n = new(big.Int).Mul(n, pow10(shift))
+	} else {
+		m = new(big.Int).Mul(m, pow10(-shift))
+	}
+	if m.Sign() < 0 {
+		n = new(big.Int).Neg(n)
+		m = new(big.Int).Neg(m)
+	}
+	return Decimal{value: roundQuo(n, m, roundHalfUp), scale: scale}
+}
+
+func (d Decimal) round(scale int32, mode roundingMode) (Decimal) {
+	if scale >= d.scale {
+		return d.rescale(scale)
+	}
+	return Decimal{value: roundQuo(d.unscaled(), pow10(int64(d.scale - scale)), mode), scale: scale}
+}
+
+// Round rounds half away from zero to scale digits after the decimal point.
+func (d Decimal) Round(scale int32) (Decimal) {
+	return d.round(scale, roundHalfUp)
+}
+
+// Truncate rounds toward zero to scale digits after the decimal point.
+func (d Decimal) Truncate(scale int32) (Decimal) {
+	return d.round(scale, roundDown)
+}
+
+func (d Decimal) roundToStep(step Decimal, mode roundingMode) (Decimal) {
+	if step.Sign() <= 0 {
+		return d
+	}
+	a, b := align(d, step)
+	q := roundQuo(a.unscaled(), b.unscaled(), mode)
+	// the result has the digits of step
+	return Decimal{value: q.Mul(q, step.unscaled()), scale: step.scale}
+}
+
+// RoundToTick rounds d to the nearest multiple of tick, half away from zero. d is returned as it is if tick is not positive.
+func (d Decimal) RoundToTick(tick Decimal) (Decimal) {
+	return d.roundToStep(tick, roundHalfUp)
+}
+
+// FloorToTick rounds d down to a multiple of tick, e.g. the price of a buy order which must not be higher.
+func (d Decimal) FloorToTick(tick Decimal) (Decimal) {
+	return d.roundToStep(tick, roundFloor)
+}
+
+// CeilToTick rounds d up to a multiple of tick, e.g. the price of a sell order which must not be lower.
+func (d Decimal) CeilToTick(tick Decimal) (Decimal) {
+	return d.roundToStep(tick, roundCeil)
+}
+
+// TruncateToLot rounds a size toward zero to a multiple of lot, so that the size never exceeds the available amount.
+func (d Decimal) TruncateToLot(lot Decimal) (Decimal) {
+	return d.roundToStep(lot, roundDown)
+}
+
+func (d Decimal) Float64() (float64) {
+	f, _ := strconv.ParseFloat(d.String(), 64)
+	return f
+}
+
+func (d Decimal) String() (string) {
+	digits := new(big.Int).Abs(d.unscaled()).String()
+	if d.scale > 0 {
+		if len(digits) <= int(d.scale) {
+			digits = strings.Repeat("0", int(d.scale) - len(digits) + 1) + digits
+		}
+		digits = digits[:len(digits) - int(d.scale)] + "." + digits[len(digits) - int(d.scale):]
+	}
+	if d.Sign() < 0 {
+		return "-" + digits
+	}
+	return digits
+}
+
+// MarshalJSON marshals d as a json number.
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalJSON accepts both a json number and a string, null leaves d as it is.
+func (d *Decimal) UnmarshalJSON(data []byte) (error) {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	if len(s) >= 2 && s[0] == '"' && s[len(s) - 1] == '"' {
+		s = s[1:len(s) - 1]
+	}
+	decimal, err := ParseDecimal(s)
+	if err != nil {
+		return errors.Wrapf(err, "can not unmarshal decimal")
+	}
+	*d = decimal
+	return nil
+}
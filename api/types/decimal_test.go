@@ -0,0 +1,100 @@
+package types_test
+
+import (
+	"encoding/json"
+	"testing"
+	"github.com/potix/gobitflyer/api/types"
+)
+
+func TestDecimal(t *testing.T) {
+	a := types.MustParseDecimal("0.1")
+	b := types.MustParseDecimal("0.2")
+	if sum := a.Add(b); sum.String() != "0.3" || !sum.Equal(types.NewDecimal(3, 1)) {
+		t.Errorf("unexpected sum: %v", sum)
+	}
+	var board struct {
+		Price types.Decimal `json:"price"`
+		Size  types.Decimal `json:"size"`
+	}
+	if err := json.Unmarshal([]byte(`{"price":1234567.50,"size":"1.5e-05"}`), &board); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if board.Price.String() != "1234567.50" || board.Size.String() != "0.000015" {
+		t.Errorf("unexpected board: %v %v", board.Price, board.Size)
+	}
+	data, err := json.Marshal(&board)
+	if err != nil || string(data) != `{"price":1234567.50,"size":0.000015}` {
+		t.Errorf("unexpected json: %v %v", string(data), err)
+	}
+	if d := types.NewDecimalFromFloat(0.1 + 0.2).Round(8); d.String() != "0.30000000" {
+		t.Errorf("unexpected round: %v", d)
+	}
+	if d := types.NewDecimalFromFloat(0.1); d.String() != "0.1" || d.Float64() != 0.1 {
+		t.Errorf("unexpected float: %v", d)
+	}
+	tick := types.MustParseDecimal("5")
+	price := types.MustParseDecimal("1234567.5")
+	if d := price.RoundToTick(tick); d.String() != "1234570" {
+		t.Errorf("unexpected round to tick: %v", d)
+	}
+	if d := price.FloorToTick(tick); d.String() != "1234565" {
+		t.Errorf("unexpected floor to tick: %v", d)
+	}
+	if d := price.Neg().CeilToTick(tick); d.String() != "-1234565" {
+		t.Errorf("unexpected ceil to tick: %v", d)
+	}
+	if d := types.MustParseDecimal("0.123456789").TruncateToLot(types.MustParseDecimal("0.00000001")); d.String() != "0.12345678" {
+		t.Errorf("unexpected truncate to lot: %v", d)
+	}
+	if d := types.MustParseDecimal("1").Div(types.MustParseDecimal("3"), 4); d.String() != "0.3333" {
+		t.Errorf("unexpected div: %v", d)
+	}
+	for _, s := range []string{"", ".", "1.2.3", "--1", "1e", "abc", "1-2"} {
+		if _, err := types.ParseDecimal(s); err == nil {
+			t.Errorf("no error (decimal = %v)", s)
+		}
+	}
+}
+
+func TestDecimalZeroValue(t *testing.T) {
+	one := types.MustParseDecimal("1.50")
+	tests := []struct {
+		name     string
+		result   types.Decimal
+		expected string
+	}{
+		{ "zero + d", types.Decimal{}.Add(one), "1.50" },
+		{ "d + zero", one.Add(types.Decimal{}), "1.50" },
+		{ "zero - d", types.Decimal{}.Sub(one), "-1.50" },
+		{ "d - zero", one.Sub(types.Decimal{}), "1.50" },
+		{ "zero + zero", types.Decimal{}.Add(types.Decimal{}), "0" },
+		{ "zero * d", types.Decimal{}.Mul(one), "0.00" },
+		{ "zero / d", types.Decimal{}.Div(one, 2), "0.00" },
+		{ "zero to tick", types.Decimal{}.RoundToTick(one), "0.00" },
+		{ "zero round", types.Decimal{}.Round(2), "0.00" },
+	}
+	for _, test := range tests {
+		if test.result.String() != test.expected {
+			t.Errorf("unexpected result of %v: %v", test.name, test.result)
+		}
+	}
+	compares := []struct {
+		a        types.Decimal
+		b        types.Decimal
+		expected int
+	}{
+		{ types.Decimal{}, one, -1 },
+		{ one, types.Decimal{}, 1 },
+		{ types.Decimal{}, types.Decimal{}, 0 },
+		{ types.Decimal{}, types.MustParseDecimal("0.000"), 0 },
+		{ types.MustParseDecimal("-0.1"), types.Decimal{}, -1 },
+	}
+	for _, compare := range compares {
+		if c := compare.a.Cmp(compare.b); c != compare.expected {
+			t.Errorf("unexpected compare of %v and %v: %v", compare.a, compare.b, c)
+		}
+		if compare.a.Equal(compare.b) != (compare.expected == 0) {
+			t.Errorf("unexpected equal of %v and %v", compare.a, compare.b)
+		}
+	}
+}
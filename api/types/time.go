@@ -0,0 +1,101 @@
+package types
+
+import (
+	"strings"
+	"time"
+	"github.com/pkg/errors"
+)
+
+// DefaultTimeLayout is the layout of the rest api, which is used by NewTime.
+const DefaultTimeLayout string = "2006-01-02T15:04:05.999"
+
+const timeLayoutBase string = "2006-01-02T15:04:05"
+
+// Time is a timestamp of bitFlyer in UTC. bitFlyer sends timestamps with or without Z and with variable
+// fractional digits (e.g. "2015-07-08T02:43:34.72", "2019-03-04T05:24:30.3835823Z"),
+// Time remembers the format and marshals the timestamp in it again.
+type Time struct {
+	time.Time
+	layout string
+}
+
+func NewTime(t time.Time) (Time) {
+	return Time{
+		Time:   t.UTC(),
+		layout: DefaultTimeLayout,
+	}
+}
+
+// ParseTime parses a timestamp in any format of bitFlyer. The time zone is UTC unless it is given,
+// a timestamp with a numeric zone is marshaled in UTC.
+func ParseTime(s string) (Time, error) {
+	value := s
+	zone := ""
+	if strings.HasSuffix(value, "Z") {
+		value = strings.TrimSuffix(value, "Z")
+		zone = "Z"
+	} else if len(value) > len(timeLayoutBase) {
+		// a numeric zone like +09:00
+		if i := strings.LastIndexAny(value[len(timeLayoutBase):], "+-"); i >= 0 {
+			zone = "Z07:00"
+			value = value[:len(timeLayoutBase) + i]
+		}
+	}
+	fraction := ""
+	if i := strings.IndexByte(value, '.'); i >= 0 {
+		fraction = "." + strings.Repeat("0", len(value) - i - 1)
+	}
+	layout := timeLayoutBase + fraction + zone
+	t, err := time.ParseInLocation(layout, s, time.UTC)
+	if err != nil {
+		return Time{}, errors.Wrapf(err, "can not parse time (time = %v)", s)
+	}
+	return Time{
+		Time:   t.UTC(),
+		layout: layout,
+	}, nil
+}
+
+// Layout returns the format of the timestamp.
+func (t Time) Layout() (string) {
+	return t.layout
+}
+
+// String formats t in the original format. A time built as a struct literal has no format and uses DefaultTimeLayout.
+func (t Time) String() (string) {
+	layout := t.layout
+	if layout == "" {
+		if t.Time.IsZero() {
+			return ""
+		}
+		layout = DefaultTimeLayout
+	}
+	return t.Time.UTC().Format(layout)
+}
+
+// MarshalJSON marshals t in the original format. The zero value is marshaled as "".
+func (t Time) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + t.String() + `"`), nil
+}
+
+// UnmarshalJSON accepts null and "" as the zero value.
+func (t *Time) UnmarshalJSON(data []byte) (error) {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	if len(s) < 2 || s[0] != '"' || s[len(s) - 1] != '"' {
+		return errors.Errorf("time is not a string (time = %v)", s)
+	}
+	s = s[1:len(s) - 1]
+	if s == "" {
+		*t = Time{}
+		return nil
+	}
+	parsed, err := ParseTime(s)
+	if err != nil {
+		return errors.Wrapf(err, "can not unmarshal time")
+	}
+	*t = parsed
+	return nil
+}
@@ -0,0 +1,45 @@
+package types_test
+
+import (
+	"time"
+	"testing"
+	"encoding/json"
+	"github.com/potix/gobitflyer/api/types"
+)
+
+func TestTime(t *testing.T) {
+	expected := time.Date(2019, 3, 4, 5, 24, 30, 0, time.UTC)
+	for _, s := range []string{"2019-03-04T05:24:30", "2019-03-04T05:24:30Z", "2019-03-04T05:24:30.72", "2019-03-04T05:24:30.720",
+	    "2019-03-04T05:24:30.3835823Z", "2019-03-04T14:24:30.383+09:00"} {
+		var ticker struct {
+			Timestamp types.Time `json:"timestamp"`
+		}
+		if err := json.Unmarshal([]byte(`{"timestamp":"` + s + `"}`), &ticker); err != nil {
+			t.Fatalf("error: %v", err)
+		}
+		if ticker.Timestamp.Location() != time.UTC || ticker.Timestamp.Truncate(time.Second) != expected {
+			t.Errorf("unexpected time: %v (time = %v)", ticker.Timestamp.Time, s)
+		}
+		data, err := json.Marshal(ticker.Timestamp)
+		if err != nil || (string(data) != `"` + s + `"` && string(data) != `"2019-03-04T05:24:30.383Z"`) {
+			t.Errorf("unexpected json: %v %v", string(data), err)
+		}
+	}
+	var order struct {
+		ExpireDate     types.Time `json:"expire_date"`
+		ChildOrderDate types.Time `json:"child_order_date"`
+	}
+	if err := json.Unmarshal([]byte(`{"expire_date":"","child_order_date":null}`), &order); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if !order.ExpireDate.IsZero() || !order.ChildOrderDate.IsZero() {
+		t.Errorf("unexpected time: %v %v", order.ExpireDate, order.ChildOrderDate)
+	}
+	if _, err := types.ParseTime("2019-03-04"); err == nil {
+		t.Errorf("no error")
+	}
+	data, err := json.Marshal(types.Time{Time: expected})
+	if err != nil || string(data) != `"2019-03-04T05:24:30"` {
+		t.Errorf("unexpected json: %v %v", string(data), err)
+	}
+}
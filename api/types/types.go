@@ -4,6 +4,23 @@ type ProductCode string
 
 type CurrencyCode string
 
+// minimum order size of products, products not listed here are not checked
+var minimumOrderSizes = map[ProductCode]float64{
+	"BTC_JPY":    0.001,
+	"FX_BTC_JPY": 0.01,
+	"ETH_JPY":    0.01,
+	"ETH_BTC":    0.01,
+	"BCH_BTC":    0.01,
+}
+
+func MinimumOrderSize(productCode ProductCode) (float64, bool) {
+	minimumOrderSize, ok := minimumOrderSizes[productCode]
+	return minimumOrderSize, ok
+}
+
+// MaxMinuteToExpire is 30 days.
+const MaxMinuteToExpire int64 = 43200
+
 type Pagination struct {
 	Count  int64 `json:"count,omitempty" url:"count,omitempty"`
 	Before int64 `json:"before,omitempty" url:"before,omitempty"`
@@ -17,6 +34,10 @@ const (
 	OrderTypeMarket OrderType = "MARKET"
 )
 
+func (o OrderType) IsValid() (bool) {
+	return o == OrderTypeLimit || o == OrderTypeMarket
+}
+
 type Side string
 
 const (
@@ -25,6 +46,10 @@ const (
 	SideSell Side = "SELL"
 )
 
+func (s Side) IsValid() (bool) {
+	return s == SideBuy || s == SideSell
+}
+
 type TimeInForce string
 
 const (
@@ -34,6 +59,11 @@ const (
 	TimeInForceFOK  TimeInForce = "FOK"
 )
 
+// IsValid reports whether t can be sent. TimeInForceNone means the default (GTC).
+func (t TimeInForce) IsValid() (bool) {
+	return t == TimeInForceNone || t == TimeInForceGTC || t == TimeInForceIOC || t == TimeInForceFOK
+}
+
 type IdType int
 
 const (
@@ -78,6 +108,20 @@ const (
 	OrderMethodIFDOCO OrderMethod = "IFDOCO"
 )
 
+// ParameterCount returns the number of parameters of the order method, or 0 if the method is unknown.
+func (o OrderMethod) ParameterCount() (int) {
+	switch o {
+	case OrderMethodNone, OrderMethodSimple:
+		return 1
+	case OrderMethodIFD, OrderMethodOCO:
+		return 2
+	case OrderMethodIFDOCO:
+		return 3
+	default:
+		return 0
+	}
+}
+
 type ConditionType string
 
 const (
@@ -88,6 +132,37 @@ const (
 	ConditionTypeTrail     ConditionType = "TRAIL"
 )
 
+func (c ConditionType) IsValid() (bool) {
+	switch c {
+	case ConditionTypeLimit, ConditionTypeMarket, ConditionTypeStop, ConditionTypeStopLimit, ConditionTypeTrail:
+		return true
+	default:
+		return false
+	}
+}
+
+// ParentOrderType is the type of a parent order in responses, the order method or the condition type of a simple order.
+type ParentOrderType string
+
+const (
+	ParentOrderTypeLimit     ParentOrderType = "LIMIT"
+	ParentOrderTypeMarket    ParentOrderType = "MARKET"
+	ParentOrderTypeStop      ParentOrderType = "STOP"
+	ParentOrderTypeStopLimit ParentOrderType = "STOP_LIMIT"
+	ParentOrderTypeTrail     ParentOrderType = "TRAIL"
+	ParentOrderTypeIFD       ParentOrderType = "IFD"
+	ParentOrderTypeOCO       ParentOrderType = "OCO"
+	ParentOrderTypeIFDOCO    ParentOrderType = "IFDOCO"
+)
+
+// NewParentOrderType returns the type of a parent order sent with orderMethod and conditionType of the first parameter.
+func NewParentOrderType(orderMethod OrderMethod, conditionType ConditionType) (ParentOrderType) {
+	if orderMethod == OrderMethodNone || orderMethod == OrderMethodSimple {
+		return ParentOrderType(conditionType)
+	}
+	return ParentOrderType(orderMethod)
+}
+
 type RealtimeType int
 
 const (
@@ -95,4 +170,19 @@ const (
 	RealtimeTypeBoard         RealtimeType = 2
 	RealtimeTypeTicker        RealtimeType = 3
 	RealtimeTypeExecutions    RealtimeType = 4
+	RealtimeTypeChildOrderEvents  RealtimeType = 5
+	RealtimeTypeParentOrderEvents RealtimeType = 6
+)
+
+type EventType string
+
+const (
+	EventTypeOrder        EventType = "ORDER"
+	EventTypeOrderFailed  EventType = "ORDER_FAILED"
+	EventTypeCancel       EventType = "CANCEL"
+	EventTypeCancelFailed EventType = "CANCEL_FAILED"
+	EventTypeExecution    EventType = "EXECUTION"
+	EventTypeTrigger      EventType = "TRIGGER"
+	EventTypeComplete     EventType = "COMPLETE"
+	EventTypeExpire       EventType = "EXPIRE"
 )
@@ -0,0 +1,515 @@
+package backtest
+
+import (
+	"context"
+	"net/http"
+	"github.com/pkg/errors"
+	"github.com/potix/gobitflyer/api/types"
+	"github.com/potix/gobitflyer/api/private"
+	"github.com/potix/gobitflyer/childorder"
+	"github.com/potix/gobitflyer/parentorder"
+)
+
+// The methods below have the signatures of APIClient, so that strategy code runs on the exchange unchanged.
+// They return a response of status 200 instead of a response of the rest api.
+
+func newHTTPResponse() (*http.Response) {
+	return &http.Response{
+		Status:     "200 OK",
+		StatusCode: 200,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     make(http.Header),
+		Body:       http.NoBody,
+	}
+}
+
+// paginate applies count, before and after (exclusive) to ids in descending order and returns the range to return.
+func paginate(ids []int64, count int64, before int64, after int64) ([]int) {
+	indexes := make([]int, 0)
+	for i := len(ids) - 1; i >= 0; i -= 1 {
+		if before > 0 && ids[i] >= before {
+			continue
+		}
+		if after > 0 && ids[i] <= after {
+			break
+		}
+		indexes = append(indexes, i)
+		if count > 0 && int64(len(indexes)) >= count {
+			break
+		}
+	}
+	return indexes
+}
+
+func (e *Exchange) PriGetBalance() (*http.Response, private.GetBalanceResponse, error) {
+	return e.PriGetBalanceCtx(context.Background())
+}
+
+// PriGetBalanceCtx returns the cash of the account in Options.CurrencyCode. Available excludes the required collateral.
+func (e *Exchange) PriGetBalanceCtx(ctx context.Context) (*http.Response, private.GetBalanceResponse, error) {
+	e.mutex.Lock()
+	defer e.unlock()
+	return newHTTPResponse(), private.GetBalanceResponse{
+		&private.GetBalanceAsset{
+			CurrencyCode: e.options.CurrencyCode,
+			Amount:       types.NewDecimalFromFloat(e.cash),
+			Available:    types.NewDecimalFromFloat(round(e.cash - e.requireCollateral())),
+		},
+	}, nil
+}
+
+func (e *Exchange) requireCollateral() (float64) {
+	require := float64(0)
+	for _, m := range e.markets {
+		if m.position.size < 0 {
+			require -= m.position.price * m.position.size / e.options.Leverage
+		} else {
+			require += m.position.price * m.position.size / e.options.Leverage
+		}
+	}
+	return round(require)
+}
+
+func (e *Exchange) PriGetCollateral() (*http.Response, *private.GetCollateralResponse, error) {
+	return e.PriGetCollateralCtx(context.Background())
+}
+
+func (e *Exchange) PriGetCollateralCtx(ctx context.Context) (*http.Response, *private.GetCollateralResponse, error) {
+	e.mutex.Lock()
+	defer e.unlock()
+	equity := e.equity()
+	require := e.requireCollateral()
+	keepRate := float64(0)
+	if require > 0 {
+		keepRate = equity / require
+	}
+	return newHTTPResponse(), &private.GetCollateralResponse{
+		Collateral:        e.cash,
+		OpenPositionPNL:   round(equity - e.cash),
+		RequireCollateral: require,
+		KeepRate:          keepRate,
+	}, nil
+}
+
+func (e *Exchange) PriGetPositions() (*http.Response, private.GetPositionsResponse, error) {
+	return e.PriGetPositionsCtx(context.Background())
+}
+
+// PriGetPositionsCtx returns a net position per product.
+func (e *Exchange) PriGetPositionsCtx(ctx context.Context) (*http.Response, private.GetPositionsResponse, error) {
+	e.mutex.Lock()
+	defer e.unlock()
+	positions := make(private.GetPositionsResponse, 0)
+	for _, productCode := range e.marketOrder {
+		m := e.markets[productCode]
+		if m.position.size == 0 {
+			continue
+		}
+		side := types.SideBuy
+		size := m.position.size
+		pnl := float64(0)
+		if m.ltp != 0 {
+			pnl = round((m.ltp - m.position.price) * size)
+		}
+		if size < 0 {
+			side = types.SideSell
+			size = -size
+		}
+		positions = append(positions, &private.GetPositionsPosition{
+			ProductCode:       productCode,
+			Side:              side,
+			Price:             m.position.price,
+			Size:              size,
+			Commission:        m.position.commission,
+			RequireCollateral: round(m.position.price * size / e.options.Leverage),
+			OpenDate:          types.NewTime(m.position.openDate),
+			Leverage:          e.options.Leverage,
+			Pnl:               pnl,
+		})
+	}
+	return newHTTPResponse(), positions, nil
+}
+
+func (e *Exchange) PriSendChildOrder(productCode types.ProductCode,
+                                     childOrderType types.OrderType,
+                                     side types.Side,
+                                     price float64,
+                                     size float64,
+                                     minuteToExpire int64,
+                                     timeInForce types.TimeInForce) (*http.Response, *private.SendChildOrderResponse, error) {
+	return e.PriSendChildOrderCtx(context.Background(), productCode, childOrderType, side, price, size, minuteToExpire, timeInForce)
+}
+
+// PriSendChildOrderCtx accepts an order, which reaches the exchange after Options.Latency.
+func (e *Exchange) PriSendChildOrderCtx(ctx context.Context, productCode types.ProductCode,
+                                        childOrderType types.OrderType,
+                                        side types.Side,
+                                        price float64,
+                                        size float64,
+                                        minuteToExpire int64,
+                                        timeInForce types.TimeInForce) (*http.Response, *private.SendChildOrderResponse, error) {
+	sendChildOrderRequest := private.NewSendChildOrderRequest(productCode, childOrderType, side, price, size, minuteToExpire, timeInForce)
+	err := sendChildOrderRequest.Validate()
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "invalid send child order request")
+	}
+	e.mutex.Lock()
+	defer e.unlock()
+	o := e.newChildOrder(productCode, childOrderType, side, price, size, minuteToExpire, timeInForce, nil, 0)
+	e.addRequest(&request{
+		arrival:    e.now.Add(e.options.Latency),
+		childOrder: o,
+	})
+	return newHTTPResponse(), &private.SendChildOrderResponse{
+		ChildOrderAcceptanceId: o.ChildOrderAcceptanceId,
+	}, nil
+}
+
+func (e *Exchange) findChildOrder(productCode types.ProductCode, idType types.IdType, orderId string) (*childorder.Order) {
+	for _, o := range e.childOrders.Orders() {
+		if o.ProductCode != productCode {
+			continue
+		}
+		if (idType == types.IdTypeChildOrderId && o.ChildOrderId == orderId) ||
+		   (idType == types.IdTypeChildOrderAcceptanceId && o.ChildOrderAcceptanceId == orderId) {
+			return o
+		}
+	}
+	return nil
+}
+
+func (e *Exchange) PriCancelChildOrder(productCode types.ProductCode, idType types.IdType, orderId string) (*http.Response, error) {
+	return e.PriCancelChildOrderCtx(context.Background(), productCode, idType, orderId)
+}
+
+// PriCancelChildOrderCtx cancels an order after Options.Latency. Like bitFlyer it succeeds for a finished order.
+func (e *Exchange) PriCancelChildOrderCtx(ctx context.Context, productCode types.ProductCode, idType types.IdType, orderId string) (*http.Response, error) {
+	e.mutex.Lock()
+	defer e.unlock()
+	o := e.findChildOrder(productCode, idType, orderId)
+	if o == nil {
+		return nil, errors.Errorf("not found child order (product code = %v, id type = %v, order id = %v)", productCode, idType, orderId)
+	}
+	e.addRequest(&request{
+		arrival:     e.now.Add(e.options.Latency),
+		cancelChild: o,
+	})
+	return newHTTPResponse(), nil
+}
+
+func (e *Exchange) PriCancelAllChildOrders(productCode types.ProductCode) (*http.Response, error) {
+	return e.PriCancelAllChildOrdersCtx(context.Background(), productCode)
+}
+
+func (e *Exchange) PriCancelAllChildOrdersCtx(ctx context.Context, productCode types.ProductCode) (*http.Response, error) {
+	e.mutex.Lock()
+	defer e.unlock()
+	for _, o := range e.childOrders.Orders() {
+		if o.ProductCode == productCode && o.Parent == nil && o.State() == types.OrderStateActive {
+			e.addRequest(&request{
+				arrival:     e.now.Add(e.options.Latency),
+				cancelChild: o,
+			})
+		}
+	}
+	return newHTTPResponse(), nil
+}
+
+func (e *Exchange) PriGetChildOrders(productCode types.ProductCode, count int64, before int64, after int64, orderState types.OrderState) (*http.Response, private.GetChildOrdersResponse, error) {
+	return e.PriGetChildOrdersCtx(context.Background(), productCode, count, before, after, orderState)
+}
+
+// PriGetChildOrdersCtx returns orders which reached the exchange, newest first.
+func (e *Exchange) PriGetChildOrdersCtx(ctx context.Context, productCode types.ProductCode, count int64, before int64, after int64, orderState types.OrderState) (*http.Response, private.GetChildOrdersResponse, error) {
+	e.mutex.Lock()
+	defer e.unlock()
+	orders := make([]*childorder.Order, 0)
+	ids := make([]int64, 0)
+	for _, o := range e.childOrders.Orders() {
+		if o.ProductCode != productCode || !o.Arrived || (orderState != types.OrderStateNone && o.State() != orderState) {
+			continue
+		}
+		orders = append(orders, o)
+		ids = append(ids, o.Id)
+	}
+	getChildOrdersResponse := make(private.GetChildOrdersResponse, 0)
+	for _, i := range paginate(ids, count, before, after) {
+		getChildOrdersResponse = append(getChildOrdersResponse, orders[i].Response())
+	}
+	return newHTTPResponse(), getChildOrdersResponse, nil
+}
+
+func (e *Exchange) PriGetChildOrdersById(productCode types.ProductCode, idType types.IdType, orderId string) (*http.Response, private.GetChildOrdersResponse, error) {
+	return e.PriGetChildOrdersByIdCtx(context.Background(), productCode, idType, orderId)
+}
+
+func (e *Exchange) PriGetChildOrdersByIdCtx(ctx context.Context, productCode types.ProductCode, idType types.IdType, orderId string) (*http.Response, private.GetChildOrdersResponse, error) {
+	if idType != types.IdTypeChildOrderId && idType != types.IdTypeChildOrderAcceptanceId {
+		return nil, nil, errors.Errorf("invalid id type (id type = %v)", idType)
+	}
+	e.mutex.Lock()
+	defer e.unlock()
+	getChildOrdersResponse := make(private.GetChildOrdersResponse, 0)
+	o := e.findChildOrder(productCode, idType, orderId)
+	if o != nil && o.Arrived {
+		getChildOrdersResponse = append(getChildOrdersResponse, o.Response())
+	}
+	return newHTTPResponse(), getChildOrdersResponse, nil
+}
+
+func (e *Exchange) PriGetExecutions(productCode types.ProductCode, count int64, before int64, after int64) (*http.Response, private.GetExecutionsResponse, error) {
+	return e.PriGetExecutionsCtx(context.Background(), productCode, count, before, after)
+}
+
+// PriGetExecutionsCtx returns fills of the account, newest first.
+func (e *Exchange) PriGetExecutionsCtx(ctx context.Context, productCode types.ProductCode, count int64, before int64, after int64) (*http.Response, private.GetExecutionsResponse, error) {
+	e.mutex.Lock()
+	defer e.unlock()
+	executions := make(private.GetExecutionsResponse, 0)
+	ids := make([]int64, 0)
+	for _, execution := range e.childOrders.Executions() {
+		if execution.ProductCode != productCode {
+			continue
+		}
+		executions = append(executions, execution.Execution)
+		ids = append(ids, execution.Execution.Id)
+	}
+	getExecutionsResponse := make(private.GetExecutionsResponse, 0)
+	for _, i := range paginate(ids, count, before, after) {
+		getExecutionsResponse = append(getExecutionsResponse, executions[i])
+	}
+	return newHTTPResponse(), getExecutionsResponse, nil
+}
+
+func (e *Exchange) PriGetExecutionsById(productCode types.ProductCode, idType types.IdType, orderId string) (*http.Response, private.GetExecutionsResponse, error) {
+	return e.PriGetExecutionsByIdCtx(context.Background(), productCode, idType, orderId)
+}
+
+func (e *Exchange) PriGetExecutionsByIdCtx(ctx context.Context, productCode types.ProductCode, idType types.IdType, orderId string) (*http.Response, private.GetExecutionsResponse, error) {
+	if idType != types.IdTypeChildOrderId && idType != types.IdTypeChildOrderAcceptanceId {
+		return nil, nil, errors.Errorf("invalid id type (id type = %v)", idType)
+	}
+	e.mutex.Lock()
+	defer e.unlock()
+	getExecutionsResponse := make(private.GetExecutionsResponse, 0)
+	executions := e.childOrders.Executions()
+	for i := len(executions) - 1; i >= 0; i -= 1 {
+		execution := executions[i]
+		if execution.ProductCode != productCode {
+			continue
+		}
+		if (idType == types.IdTypeChildOrderId && execution.Execution.ChildOrderId == orderId) ||
+		   (idType == types.IdTypeChildOrderAcceptanceId && execution.Execution.ChildOrderAcceptanceId == orderId) {
+			getExecutionsResponse = append(getExecutionsResponse, execution.Execution)
+		}
+	}
+	return newHTTPResponse(), getExecutionsResponse, nil
+}
+
+func (e *Exchange) PriGetTradingCommission(productCode types.ProductCode) (*http.Response, *private.GetTradingCommissionResponse, error) {
+	return e.PriGetTradingCommissionCtx(context.Background(), productCode)
+}
+
+func (e *Exchange) PriGetTradingCommissionCtx(ctx context.Context, productCode types.ProductCode) (*http.Response, *private.GetTradingCommissionResponse, error) {
+	e.mutex.Lock()
+	defer e.unlock()
+	return newHTTPResponse(), &private.GetTradingCommissionResponse{
+		CommissionRate: e.commissionRate(productCode),
+	}, nil
+}
+
+func (e *Exchange) PriSendParentOrder(orderMethod types.OrderMethod,
+                                      minuteToExpire int64,
+                                      timeInForce types.TimeInForce,
+                                      parameters ...*private.SendParentOrderParameter) (*http.Response, *private.SendParentOrderResponse, error) {
+	return e.PriSendParentOrderCtx(context.Background(), orderMethod, minuteToExpire, timeInForce, parameters...)
+}
+
+// PriSendParentOrderCtx accepts a parent order, which reaches the exchange after Options.Latency.
+// STOP, STOP_LIMIT and TRAIL conditions are triggered by the last traded price.
+func (e *Exchange) PriSendParentOrderCtx(ctx context.Context, orderMethod types.OrderMethod,
+                                         minuteToExpire int64,
+                                         timeInForce types.TimeInForce,
+                                         parameters ...*private.SendParentOrderParameter) (*http.Response, *private.SendParentOrderResponse, error) {
+	sendParentOrderRequest := private.NewSendParentOrderRequest(orderMethod, minuteToExpire, timeInForce, parameters...)
+	err := sendParentOrderRequest.Validate()
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "invalid send parent order request")
+	}
+	e.mutex.Lock()
+	defer e.unlock()
+	p := e.newParentOrder(orderMethod, minuteToExpire, timeInForce, parameters)
+	e.addRequest(&request{
+		arrival:     e.now.Add(e.options.Latency),
+		parentOrder: p,
+	})
+	return newHTTPResponse(), &private.SendParentOrderResponse{
+		ParentOrderAcceptanceId: p.ParentOrderAcceptanceId,
+	}, nil
+}
+
+func (e *Exchange) findParentOrder(idType types.IdType, orderId string) (*parentorder.Order) {
+	switch idType {
+	case types.IdTypeParentOrderId:
+		return e.parentOrders.Find(orderId, "")
+	case types.IdTypeParentOrderAcceptanceId:
+		return e.parentOrders.Find("", orderId)
+	default:
+		return nil
+	}
+}
+
+func (e *Exchange) PriCancelParentOrder(productCode types.ProductCode, idType types.IdType, orderId string) (*http.Response, error) {
+	return e.PriCancelParentOrderCtx(context.Background(), productCode, idType, orderId)
+}
+
+func (e *Exchange) PriCancelParentOrderCtx(ctx context.Context, productCode types.ProductCode, idType types.IdType, orderId string) (*http.Response, error) {
+	e.mutex.Lock()
+	defer e.unlock()
+	p := e.findParentOrder(idType, orderId)
+	if p == nil || p.ProductCode() != productCode {
+		return nil, errors.Errorf("not found parent order (product code = %v, id type = %v, order id = %v)", productCode, idType, orderId)
+	}
+	e.addRequest(&request{
+		arrival:      e.now.Add(e.options.Latency),
+		cancelParent: p,
+	})
+	return newHTTPResponse(), nil
+}
+
+func (e *Exchange) PriGetParentOrders(productCode types.ProductCode, count int64, before int64, after int64, orderState types.OrderState) (*http.Response, private.GetParentOrdersResponse, error) {
+	return e.PriGetParentOrdersCtx(context.Background(), productCode, count, before, after, orderState)
+}
+
+func (e *Exchange) PriGetParentOrdersCtx(ctx context.Context, productCode types.ProductCode, count int64, before int64, after int64, orderState types.OrderState) (*http.Response, private.GetParentOrdersResponse, error) {
+	e.mutex.Lock()
+	defer e.unlock()
+	orders := make([]*parentorder.Order, 0)
+	ids := make([]int64, 0)
+	for _, p := range e.parentOrders.Orders() {
+		if p.ProductCode() != productCode || !p.Accepted() || (orderState != types.OrderStateNone && p.State != orderState) {
+			continue
+		}
+		orders = append(orders, p)
+		ids = append(ids, p.Id)
+	}
+	getParentOrdersResponse := make(private.GetParentOrdersResponse, 0)
+	for _, i := range paginate(ids, count, before, after) {
+		getParentOrdersResponse = append(getParentOrdersResponse, orders[i].Response())
+	}
+	return newHTTPResponse(), getParentOrdersResponse, nil
+}
+
+func (e *Exchange) PriGetParentOrder(idType types.IdType, orderId string) (*http.Response, *private.GetParentOrderResponse, error) {
+	return e.PriGetParentOrderCtx(context.Background(), idType, orderId)
+}
+
+func (e *Exchange) PriGetParentOrderCtx(ctx context.Context, idType types.IdType, orderId string) (*http.Response, *private.GetParentOrderResponse, error) {
+	e.mutex.Lock()
+	defer e.unlock()
+	p := e.findParentOrder(idType, orderId)
+	if p == nil || !p.Accepted() {
+		return nil, nil, errors.Errorf("not found parent order (id type = %v, order id = %v)", idType, orderId)
+	}
+	return newHTTPResponse(), p.Detail(), nil
+}
+
+// The methods below are not simulated by the exchange.
+
+func (e *Exchange) PriGetPermissions() (*http.Response, *private.GetPermissionsResponse, error) {
+	return e.PriGetPermissionsCtx(context.Background())
+}
+
+func (e *Exchange) PriGetPermissionsCtx(ctx context.Context) (*http.Response, *private.GetPermissionsResponse, error) {
+	return nil, nil, errors.Errorf("get permissions is not supported by backtest")
+}
+
+func (e *Exchange) PriGetCollateralAccounts() (*http.Response, private.GetCollateralAccountsResponse, error) {
+	return e.PriGetCollateralAccountsCtx(context.Background())
+}
+
+func (e *Exchange) PriGetCollateralAccountsCtx(ctx context.Context) (*http.Response, private.GetCollateralAccountsResponse, error) {
+	return nil, nil, errors.Errorf("get collateral accounts is not supported by backtest")
+}
+
+func (e *Exchange) PriGetAddresses() (*http.Response, private.GetAddressesResponse, error) {
+	return e.PriGetAddressesCtx(context.Background())
+}
+
+func (e *Exchange) PriGetAddressesCtx(ctx context.Context) (*http.Response, private.GetAddressesResponse, error) {
+	return nil, nil, errors.Errorf("get addresses is not supported by backtest")
+}
+
+func (e *Exchange) PriGetCoinIns(count int64, before int64, after int64) (*http.Response, private.GetCoinInsResponse, error) {
+	return e.PriGetCoinInsCtx(context.Background(), count, before, after)
+}
+
+func (e *Exchange) PriGetCoinInsCtx(ctx context.Context, count int64, before int64, after int64) (*http.Response, private.GetCoinInsResponse, error) {
+	return nil, nil, errors.Errorf("get coin ins is not supported by backtest")
+}
+
+func (e *Exchange) PriGetCoinOuts(count int64, before int64, after int64) (*http.Response, private.GetCoinOutsResponse, error) {
+	return e.PriGetCoinOutsCtx(context.Background(), count, before, after)
+}
+
+func (e *Exchange) PriGetCoinOutsCtx(ctx context.Context, count int64, before int64, after int64) (*http.Response, private.GetCoinOutsResponse, error) {
+	return nil, nil, errors.Errorf("get coin outs is not supported by backtest")
+}
+
+func (e *Exchange) PriGetBankAccounts() (*http.Response, private.GetBankAccountsResponse, error) {
+	return e.PriGetBankAccountsCtx(context.Background())
+}
+
+func (e *Exchange) PriGetBankAccountsCtx(ctx context.Context) (*http.Response, private.GetBankAccountsResponse, error) {
+	return nil, nil, errors.Errorf("get bank accounts is not supported by backtest")
+}
+
+func (e *Exchange) PriGetDeposits(count int64, before int64, after int64) (*http.Response, private.GetDepositsResponse, error) {
+	return e.PriGetDepositsCtx(context.Background(), count, before, after)
+}
+
+func (e *Exchange) PriGetDepositsCtx(ctx context.Context, count int64, before int64, after int64) (*http.Response, private.GetDepositsResponse, error) {
+	return nil, nil, errors.Errorf("get deposits is not supported by backtest")
+}
+
+func (e *Exchange) PriGetWithdrawals(count int64, before int64, after int64) (*http.Response, private.GetWithdrawalsResponse, error) {
+	return e.PriGetWithdrawalsCtx(context.Background(), count, before, after)
+}
+
+func (e *Exchange) PriGetWithdrawalsCtx(ctx context.Context, count int64, before int64, after int64) (*http.Response, private.GetWithdrawalsResponse, error) {
+	return nil, nil, errors.Errorf("get withdrawals is not supported by backtest")
+}
+
+func (e *Exchange) PriGetWithdrawalsById(messageId string) (*http.Response, private.GetWithdrawalsResponse, error) {
+	return e.PriGetWithdrawalsByIdCtx(context.Background(), messageId)
+}
+
+func (e *Exchange) PriGetWithdrawalsByIdCtx(ctx context.Context, messageId string) (*http.Response, private.GetWithdrawalsResponse, error) {
+	return nil, nil, errors.Errorf("get withdrawals is not supported by backtest")
+}
+
+func (e *Exchange) PriGetBalanceHistory(currencyCode types.CurrencyCode, count int64, before int64, after int64) (*http.Response, private.GetBalanceHistoryResponse, error) {
+	return e.PriGetBalanceHistoryCtx(context.Background(), currencyCode, count, before, after)
+}
+
+func (e *Exchange) PriGetBalanceHistoryCtx(ctx context.Context, currencyCode types.CurrencyCode, count int64, before int64, after int64) (*http.Response, private.GetBalanceHistoryResponse, error) {
+	return nil, nil, errors.Errorf("get balance history is not supported by backtest")
+}
+
+func (e *Exchange) PriGetCollateralHistory(count int64, before int64, after int64) (*http.Response, private.GetCollateralHistoryResponse, error) {
+	return e.PriGetCollateralHistoryCtx(context.Background(), count, before, after)
+}
+
+func (e *Exchange) PriGetCollateralHistoryCtx(ctx context.Context, count int64, before int64, after int64) (*http.Response, private.GetCollateralHistoryResponse, error) {
+	return nil, nil, errors.Errorf("get collateral history is not supported by backtest")
+}
+
+func (e *Exchange) PriWithdraw(currencyCode types.CurrencyCode, bankAccountId int64, amount float64, code string) (*http.Response, *private.WithdrawResponse, error) {
+	return e.PriWithdrawCtx(context.Background(), currencyCode, bankAccountId, amount, code)
+}
+
+func (e *Exchange) PriWithdrawCtx(ctx context.Context, currencyCode types.CurrencyCode, bankAccountId int64, amount float64, code string) (*http.Response, *private.WithdrawResponse, error) {
+	return nil, nil, errors.Errorf("withdraw is not supported by backtest")
+}
@@ -0,0 +1,167 @@
+package backtest_test
+
+import (
+	"fmt"
+	"time"
+	"testing"
+	"github.com/potix/gobitflyer/api/types"
+	"github.com/potix/gobitflyer/api/public"
+	"github.com/potix/gobitflyer/api/private"
+	"github.com/potix/gobitflyer/api/realtime"
+	"github.com/potix/gobitflyer/executionstore"
+	"github.com/potix/gobitflyer/backtest"
+)
+
+func TestBacktest(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	trades := []struct {
+		side  types.Side
+		price float64
+		size  float64
+	}{
+		{ types.SideSell, 100, 1 },
+		{ types.SideSell, 100, 1 },
+		{ types.SideBuy, 101, 1 },
+		{ types.SideSell, 99, 0.5 },
+		{ types.SideSell, 99, 1 },
+		{ types.SideBuy, 110, 1 },
+		{ types.SideBuy, 110, 1 },
+	}
+	executions := make([]*public.GetExecutionsExecution, 0, len(trades))
+	for i, m := range trades {
+		executions = append(executions, &public.GetExecutionsExecution{
+			Id:       int64(i + 1),
+			Side:     m.side,
+			Price:    types.NewDecimalFromFloat(m.price),
+			Size:     types.NewDecimalFromFloat(m.size),
+			ExecDate: types.NewTime(start.Add(time.Duration(i) * time.Second)),
+		})
+	}
+	store, err := executionstore.Open(t.TempDir(), "FX_BTC_JPY")
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if err := store.Write(1, int64(len(executions)), executions); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	// buy at 99 and sell at 109
+	engine := backtest.NewEngine(backtest.NewStoreSource(store, "FX_BTC_JPY", time.Time{}, time.Time{}), &backtest.Options{
+		InitialCollateral: 10000,
+		Commission:        &private.GetTradingCommissionResponse{CommissionRate: 0.001},
+		Latency:           time.Second,
+	})
+	err = engine.RealExecutionsStart("FX_BTC_JPY", func(productCode types.ProductCode, getExecutionsResponse public.GetExecutionsResponse, callbackData interface{}) {
+		if getExecutionsResponse[0].Id != 1 {
+			return
+		}
+		if _, _, err := engine.PriSendChildOrder(productCode, types.OrderTypeLimit, types.SideBuy, 99, 1, 0, types.TimeInForceGTC); err != nil {
+			t.Errorf("error: %v", err)
+		}
+	}, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	eventTypes := make([]types.EventType, 0)
+	err = engine.RealChildOrderEventsStart(func(childOrderEvents realtime.ChildOrderEvents, callbackData interface{}) {
+		for _, event := range childOrderEvents {
+			eventTypes = append(eventTypes, event.EventType)
+			if event.EventType == types.EventTypeExecution && event.Side == types.SideBuy && event.OutstandingSize == 0 {
+				if _, _, err := engine.PriSendChildOrder("FX_BTC_JPY", types.OrderTypeLimit, types.SideSell, 109, 1, 0, types.TimeInForceGTC); err != nil {
+					t.Errorf("error: %v", err)
+				}
+			}
+		}
+	}, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	report, err := engine.Run()
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if fmt.Sprint(eventTypes) != "[ORDER EXECUTION EXECUTION ORDER EXECUTION]" {
+		t.Errorf("unexpected events: %v", eventTypes)
+	}
+	if len(report.Trades) != 3 || report.Trades[2].Price != 109 || !report.Trades[2].Maker || report.Trades[2].RealizedPnl != 10 {
+		t.Fatalf("unexpected trades: %v", report.Trades)
+	}
+	summary := report.Summary
+	if summary.Fees != 0.208 || summary.FinalEquity != 10009.792 || summary.NetProfit != 9.792 || summary.WinRate != 1 {
+		t.Errorf("unexpected summary: %#v", summary)
+	}
+	_, positions, err := engine.PriGetPositions()
+	if err != nil || len(positions) != 0 {
+		t.Errorf("unexpected positions: %v, %v", positions, err)
+	}
+	_, childOrders, err := engine.PriGetChildOrders("FX_BTC_JPY", 10, 0, 0, types.OrderStateCompleted)
+	if err != nil || len(childOrders) != 2 || childOrders[0].Side != types.SideSell || childOrders[1].AveragePrice.Float64() != 99 {
+		t.Errorf("unexpected child orders: %v, %v", childOrders, err)
+	}
+
+	if _, _, err := engine.PriGetBalanceHistory("JPY", 10, 0, 0); err == nil {
+		t.Errorf("balance history is not simulated")
+	}
+	if err := engine.RealBoardStart("FX_BTC_JPY", nil, nil, false); err == nil {
+		t.Errorf("board diffs are not simulated")
+	}
+	if err := engine.RealStop(); err != nil {
+		t.Errorf("error: %v", err)
+	}
+
+	// queue position, FOK and STOP on the exchange
+	exchange := backtest.NewExchange(&backtest.Options{InitialCollateral: 10000})
+	exchange.SetBoard("FX_BTC_JPY", &public.GetBoardResponse{
+		Bids: []*public.GetBoardBook{ {Price: types.MustParseDecimal("99"), Size: types.MustParseDecimal("2")} },
+		Asks: []*public.GetBoardBook{ {Price: types.MustParseDecimal("100"), Size: types.MustParseDecimal("0.3")} },
+	})
+	exchange.Advance(start)
+	_, limit, err := exchange.PriSendChildOrder("FX_BTC_JPY", types.OrderTypeLimit, types.SideBuy, 99, 1, 0, types.TimeInForceGTC)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	_, fok, err := exchange.PriSendChildOrder("FX_BTC_JPY", types.OrderTypeMarket, types.SideBuy, 0, 1, 0, types.TimeInForceFOK)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	_, marketOrder, err := exchange.PriSendChildOrder("FX_BTC_JPY", types.OrderTypeMarket, types.SideBuy, 0, 0.2, 0, types.TimeInForceGTC)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	exchange.ApplyExecutions("FX_BTC_JPY", public.GetExecutionsResponse{
+		{ Id: 1, Side: types.SideSell, Price: types.MustParseDecimal("99"), Size: types.MustParseDecimal("2.5"), ExecDate: types.NewTime(start) },
+	})
+	state := func(acceptanceId string) (*private.GetChildOrdersOrder) {
+		_, orders, err := exchange.PriGetChildOrdersById("FX_BTC_JPY", types.IdTypeChildOrderAcceptanceId, acceptanceId)
+		if err != nil || len(orders) != 1 {
+			t.Fatalf("unexpected orders: %v, %v", orders, err)
+		}
+		return orders[0]
+	}
+	if order := state(limit.ChildOrderAcceptanceId); order.ExecutedSize.Float64() != 0.5 || order.ChildOrderState != types.OrderStateActive {
+		t.Errorf("unexpected queue position: %#v", order)
+	}
+	if order := state(fok.ChildOrderAcceptanceId); !order.ExecutedSize.IsZero() || order.ChildOrderState != types.OrderStateCanceled {
+		t.Errorf("unexpected fok: %#v", order)
+	}
+	if order := state(marketOrder.ChildOrderAcceptanceId); order.AveragePrice.Float64() != 100 || order.ChildOrderState != types.OrderStateCompleted {
+		t.Errorf("unexpected market: %#v", order)
+	}
+	_, stop, err := exchange.PriSendParentOrder(types.OrderMethodSimple, 0, types.TimeInForceGTC,
+		private.NewStopParameter("FX_BTC_JPY", types.SideSell, 98, 0.7))
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	exchange.ApplyExecutions("FX_BTC_JPY", public.GetExecutionsResponse{
+		{ Id: 2, Side: types.SideSell, Price: types.MustParseDecimal("97"), Size: types.MustParseDecimal("0.1"), ExecDate: types.NewTime(start.Add(time.Second)) },
+	})
+	// a trade-through fills no more than the size of the execution
+	if order := state(limit.ChildOrderAcceptanceId); order.ExecutedSize.Float64() != 0.6 || order.ChildOrderState != types.OrderStateActive {
+		t.Errorf("unexpected trade-through: %#v", order)
+	}
+	_, parentOrders, err := exchange.PriGetParentOrders("FX_BTC_JPY", 10, 0, 0, types.OrderStateNone)
+	if err != nil || len(parentOrders) != 1 || parentOrders[0].ParentOrderAcceptanceId != stop.ParentOrderAcceptanceId ||
+	   parentOrders[0].ParentOrderState != types.OrderStateCompleted || parentOrders[0].AveragePrice.Float64() != 99 {
+		t.Errorf("unexpected parent orders: %v, %v", parentOrders, err)
+	}
+}
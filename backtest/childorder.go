@@ -0,0 +1,119 @@
+package backtest
+
+import (
+	"math"
+	"time"
+	"github.com/potix/gobitflyer/api/types"
+	"github.com/potix/gobitflyer/api/realtime"
+	"github.com/potix/gobitflyer/childorder"
+	"github.com/potix/gobitflyer/parentorder"
+)
+
+// childOrderHost fills child orders from the copies of the boards and keeps positions, and resting orders are
+// filled by fillResting.
+type childOrderHost struct {
+	exchange *Exchange
+}
+
+// Take fills an order from the board, or at the last traded price without a board.
+func (h *childOrderHost) Take(o *childorder.Order, now time.Time) {
+	e := h.exchange
+	m := e.market(o.ProductCode)
+	levels := m.levels(o.Side)
+	if len(*levels) == 0 {
+		if m.ltp != 0 && o.Crosses(m.ltp) {
+			e.childOrders.Fill(o, e.nextId(), m.ltp, o.OutstandingSize, false, now)
+		}
+		return
+	}
+	for len(*levels) != 0 && o.State() == types.OrderStateActive {
+		level := (*levels)[0]
+		if !o.Crosses(level.price) {
+			break
+		}
+		q := math.Min(level.size, o.OutstandingSize)
+		level.size = round(level.size - q)
+		if level.size <= 0 {
+			*levels = (*levels)[1:]
+		}
+		e.childOrders.Fill(o, e.nextId(), level.price, q, false, now)
+	}
+}
+
+func (h *childOrderHost) Fillable(o *childorder.Order) (bool) {
+	m := h.exchange.market(o.ProductCode)
+	levels := *m.levels(o.Side)
+	if len(levels) == 0 {
+		return m.ltp != 0 && o.Crosses(m.ltp)
+	}
+	size := float64(0)
+	for _, level := range levels {
+		if !o.Crosses(level.price) {
+			break
+		}
+		size = round(size + level.size)
+	}
+	return size >= o.OutstandingSize
+}
+
+// Rest queues an order behind the size of its level of the board with QueueModelBack.
+func (h *childOrderHost) Rest(o *childorder.Order, now time.Time) {
+	e := h.exchange
+	switch e.options.QueueModel {
+	case QueueModelBack:
+		e.queueAhead[o] = e.market(o.ProductCode).restingSize(o.Side, o.Price)
+	default:
+		e.queueAhead[o] = 0
+	}
+}
+
+func (h *childOrderHost) Unrest(o *childorder.Order, now time.Time) {
+	delete(h.exchange.queueAhead, o)
+}
+
+func (h *childOrderHost) CommissionRate(productCode types.ProductCode) (float64) {
+	return h.exchange.commissionRate(productCode)
+}
+
+// Settle applies a fill to the position, and pays the profit and the fee, which is the commission in the currency of the account.
+func (h *childOrderHost) Settle(o *childorder.Order, price float64, size float64, commission float64, maker bool, now time.Time) {
+	e := h.exchange
+	m := e.market(o.ProductCode)
+	fee := round(price * size * e.commissionRate(o.ProductCode))
+	realized := m.position.apply(o.Side, price, size, commission, now)
+	e.cash = round(e.cash + realized - fee)
+	e.trades = append(e.trades, &Trade{
+		Time:                   now,
+		ProductCode:            o.ProductCode,
+		ChildOrderAcceptanceId: o.ChildOrderAcceptanceId,
+		Side:                   o.Side,
+		Price:                  price,
+		Size:                   size,
+		Fee:                    fee,
+		Maker:                  maker,
+		RealizedPnl:            round(realized),
+		Position:               m.position.size,
+	})
+}
+
+// Release does nothing, the account is margin style and does not lock funds.
+func (h *childOrderHost) Release(o *childorder.Order) {
+}
+
+func (h *childOrderHost) Event(event *realtime.ChildOrderEvent) {
+	h.exchange.childOrderEvents = append(h.exchange.childOrderEvents, event)
+}
+
+// Done moves the parent order of a finished child order to the next stage.
+func (h *childOrderHost) Done(o *childorder.Order, now time.Time) {
+	h.exchange.parentOrders.ChildOrderDone(o.Parent, o, now)
+}
+
+// newChildOrder creates an order which reaches the exchange after the latency.
+func (e *Exchange) newChildOrder(productCode types.ProductCode, orderType types.OrderType, side types.Side, price float64, size float64,
+	minuteToExpire int64, timeInForce types.TimeInForce, parent *parentorder.Order, parameterIndex int) (*childorder.Order) {
+	o := childorder.NewOrder(e.nextId(), e.newOrderId("JOR"), e.newOrderId("JRF"), productCode, orderType, side, price, size,
+		minuteToExpire, timeInForce, parent, parameterIndex, e.now)
+	e.childOrders.Add(o)
+	return o
+}
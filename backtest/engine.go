@@ -0,0 +1,269 @@
+package backtest
+
+import (
+	"context"
+	"sync"
+	"github.com/pkg/errors"
+	"github.com/potix/gobitflyer/api"
+	"github.com/potix/gobitflyer/api/types"
+	"github.com/potix/gobitflyer/api/realtime"
+	"github.com/potix/gobitflyer/orderbook"
+)
+
+// Engine drives strategy code with events of a source. Strategy code subscribes market data with the Real*Start
+// methods of RealAPIClient and sends orders with the Pri* methods of APIClient, both of which the engine has.
+// Callbacks are called in order of events on the goroutine of Run.
+type Engine struct {
+	*Exchange
+	source           Source
+	mutex            *sync.Mutex
+	realtimeChannels map[string]*realtime.RealtimeChannel
+	equity           []*EquityPoint
+}
+
+var _ api.PrivateAPI = (*Engine)(nil)
+var _ api.RealtimeAPI = (*Engine)(nil)
+
+func (e *Engine) addRealtimeChannel(rc *realtime.RealtimeChannel) (error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	channel := realtime.ChannelName(rc.RealtimeType, rc.ProductCode)
+	if _, ok := e.realtimeChannels[channel]; ok {
+		return errors.Errorf("already subscribed channel (channel = %v)", channel)
+	}
+	e.realtimeChannels[channel] = rc
+	return nil
+}
+
+func (e *Engine) realtimeChannel(realtimeType types.RealtimeType, productCode types.ProductCode) (*realtime.RealtimeChannel, bool) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	rc, ok := e.realtimeChannels[realtime.ChannelName(realtimeType, productCode)]
+	return rc, ok
+}
+
+// RealBoardSnapshotStart calls callback with the whole board at every board event.
+func (e *Engine) RealBoardSnapshotStart(productCode types.ProductCode, callback realtime.BoardSnapshotCallback, callbackData interface{}) (error) {
+	return e.addRealtimeChannel(&realtime.RealtimeChannel{
+		ProductCode:           productCode,
+		RealtimeType:          types.RealtimeTypeBoardSnapshot,
+		BoardSnapshotCallback: callback,
+		CallbackData:          callbackData,
+	})
+}
+
+func (e *Engine) RealBoardSnapshotStartCtx(ctx context.Context, productCode types.ProductCode, callback realtime.BoardSnapshotCallback, callbackData interface{}) (error) {
+	return e.RealBoardSnapshotStart(productCode, callback, callbackData)
+}
+
+// RealBoardStart calls callback with the whole board at every board event. Sources have whole boards only, so merge
+// must be true.
+func (e *Engine) RealBoardStart(productCode types.ProductCode, callback realtime.BoardCallback, callbackData interface{}, merge bool) (error) {
+	if !merge {
+		return errors.Errorf("board diffs are not supported by backtest, merge must be true (product code = %v)", productCode)
+	}
+	return e.addRealtimeChannel(&realtime.RealtimeChannel{
+		ProductCode:   productCode,
+		RealtimeType:  types.RealtimeTypeBoard,
+		BoardCallback: callback,
+		CallbackData:  callbackData,
+		Merge:         true,
+	})
+}
+
+func (e *Engine) RealBoardStartCtx(ctx context.Context, productCode types.ProductCode, callback realtime.BoardCallback, callbackData interface{}, merge bool) (error) {
+	return e.RealBoardStart(productCode, callback, callbackData, merge)
+}
+
+// RealOrderBookStart calls callback with a snapshot of the whole board at every board event.
+// It can be stopped by RealUnsubscribe with types.RealtimeTypeBoard.
+func (e *Engine) RealOrderBookStart(productCode types.ProductCode, callback realtime.OrderBookCallback, callbackData interface{}) (error) {
+	return e.addRealtimeChannel(&realtime.RealtimeChannel{
+		ProductCode:       productCode,
+		RealtimeType:      types.RealtimeTypeBoard,
+		OrderBookCallback: callback,
+		CallbackData:      callbackData,
+		Merge:             true,
+		OrderBook:         orderbook.NewBook(),
+	})
+}
+
+func (e *Engine) RealOrderBookStartCtx(ctx context.Context, productCode types.ProductCode, callback realtime.OrderBookCallback, callbackData interface{}) (error) {
+	return e.RealOrderBookStart(productCode, callback, callbackData)
+}
+
+func (e *Engine) RealTickerStart(productCode types.ProductCode, callback realtime.TickerCallback, callbackData interface{}) (error) {
+	return e.addRealtimeChannel(&realtime.RealtimeChannel{
+		ProductCode:    productCode,
+		RealtimeType:   types.RealtimeTypeTicker,
+		TickerCallback: callback,
+		CallbackData:   callbackData,
+	})
+}
+
+func (e *Engine) RealTickerStartCtx(ctx context.Context, productCode types.ProductCode, callback realtime.TickerCallback, callbackData interface{}) (error) {
+	return e.RealTickerStart(productCode, callback, callbackData)
+}
+
+func (e *Engine) RealExecutionsStart(productCode types.ProductCode, callback realtime.ExecutionsCallback, callbackData interface{}) (error) {
+	return e.addRealtimeChannel(&realtime.RealtimeChannel{
+		ProductCode:        productCode,
+		RealtimeType:       types.RealtimeTypeExecutions,
+		ExecutionsCallback: callback,
+		CallbackData:       callbackData,
+	})
+}
+
+func (e *Engine) RealExecutionsStartCtx(ctx context.Context, productCode types.ProductCode, callback realtime.ExecutionsCallback, callbackData interface{}) (error) {
+	return e.RealExecutionsStart(productCode, callback, callbackData)
+}
+
+// RealChildOrderEventsStart calls callback with events of the simulated orders.
+func (e *Engine) RealChildOrderEventsStart(callback realtime.ChildOrderEventsCallback, callbackData interface{}) (error) {
+	err := e.addRealtimeChannel(&realtime.RealtimeChannel{
+		RealtimeType:             types.RealtimeTypeChildOrderEvents,
+		ChildOrderEventsCallback: callback,
+		CallbackData:             callbackData,
+		Private:                  true,
+	})
+	if err != nil {
+		return err
+	}
+	e.SetChildOrderEventsCallback(callback, callbackData)
+	return nil
+}
+
+func (e *Engine) RealChildOrderEventsStartCtx(ctx context.Context, callback realtime.ChildOrderEventsCallback, callbackData interface{}) (error) {
+	return e.RealChildOrderEventsStart(callback, callbackData)
+}
+
+// RealParentOrderEventsStart fails, the exchange does not report events of parent orders.
+func (e *Engine) RealParentOrderEventsStart(callback realtime.ParentOrderEventsCallback, callbackData interface{}) (error) {
+	return errors.Errorf("parent order events are not supported by backtest")
+}
+
+func (e *Engine) RealParentOrderEventsStartCtx(ctx context.Context, callback realtime.ParentOrderEventsCallback, callbackData interface{}) (error) {
+	return e.RealParentOrderEventsStart(callback, callbackData)
+}
+
+func (e *Engine) removeRealtimeChannel(channel string, rc *realtime.RealtimeChannel) {
+	delete(e.realtimeChannels, channel)
+	if rc.RealtimeType == types.RealtimeTypeChildOrderEvents {
+		e.SetChildOrderEventsCallback(nil, nil)
+	}
+}
+
+// RealUnsubscribe stops one channel started by Real*Start.
+func (e *Engine) RealUnsubscribe(realtimeType types.RealtimeType, productCode types.ProductCode) (error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	channel := realtime.ChannelName(realtimeType, productCode)
+	rc, ok := e.realtimeChannels[channel]
+	if !ok {
+		return errors.Errorf("not found realtime channel (type = %v, product code = %v)", realtimeType, productCode)
+	}
+	e.removeRealtimeChannel(channel, rc)
+	return nil
+}
+
+// RealStop stops all channels. Run plays the rest of the source without callbacks.
+func (e *Engine) RealStop() (error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if len(e.realtimeChannels) == 0 {
+		return errors.Errorf("not found realtime channel")
+	}
+	for channel, rc := range e.realtimeChannels {
+		e.removeRealtimeChannel(channel, rc)
+	}
+	return nil
+}
+
+func (e *Engine) sampleEquity(point *EquityPoint) {
+	if len(e.equity) > 0 {
+		last := e.equity[len(e.equity) - 1]
+		if point.Time.Before(last.Time.Add(e.options.EquityInterval)) {
+			return
+		}
+	}
+	e.equity = append(e.equity, point)
+}
+
+// handle updates the exchange with an event before the callbacks see it, so that fills by an execution are
+// reported before the execution.
+func (e *Engine) handle(event *Event) (error) {
+	e.Advance(event.Time)
+	if event.Board != nil {
+		e.SetBoard(event.ProductCode, event.Board)
+		if rc, ok := e.realtimeChannel(types.RealtimeTypeBoardSnapshot, event.ProductCode); ok {
+			rc.BoardSnapshotCallback(event.ProductCode, event.Board, rc.CallbackData)
+		}
+		if rc, ok := e.realtimeChannel(types.RealtimeTypeBoard, event.ProductCode); ok {
+			if rc.OrderBook != nil {
+				rc.OrderBookCallback(event.ProductCode, rc.OrderBook.Reset(event.Board), rc.CallbackData)
+			} else {
+				rc.BoardCallback(event.ProductCode, event.Board, rc.CallbackData)
+			}
+		}
+	}
+	if event.Ticker != nil {
+		if rc, ok := e.realtimeChannel(types.RealtimeTypeTicker, event.ProductCode); ok {
+			rc.TickerCallback(event.ProductCode, event.Ticker, rc.CallbackData)
+		}
+	}
+	if len(event.Executions) > 0 {
+		e.ApplyExecutions(event.ProductCode, event.Executions)
+		if rc, ok := e.realtimeChannel(types.RealtimeTypeExecutions, event.ProductCode); ok {
+			rc.ExecutionsCallback(event.ProductCode, event.Executions, rc.CallbackData)
+		}
+	}
+	e.sampleEquity(&EquityPoint{
+		Time:   e.Now(),
+		Equity: e.Equity(),
+	})
+	return nil
+}
+
+func (e *Engine) Run() (*Report, error) {
+	return e.RunCtx(context.Background())
+}
+
+// RunCtx plays the source to the end and returns the report. The report of the events until an error is also returned.
+func (e *Engine) RunCtx(ctx context.Context) (*Report, error) {
+	e.equity = make([]*EquityPoint, 0)
+	err := e.source.Play(ctx, e.handle)
+	if len(e.equity) > 0 {
+		last := e.equity[len(e.equity) - 1]
+		now := e.Now()
+		// the last point is the final equity
+		if now.After(last.Time) {
+			e.equity = append(e.equity, &EquityPoint{
+				Time:   now,
+				Equity: e.Equity(),
+			})
+		} else {
+			last.Equity = e.Equity()
+		}
+	}
+	trades := e.Trades()
+	report := &Report{
+		Trades:  trades,
+		Equity:  e.equity,
+		Summary: newSummary(e.options.InitialCollateral, trades, e.equity),
+	}
+	if err != nil {
+		return report, errors.Wrapf(err, "can not play source")
+	}
+	return report, nil
+}
+
+// NewEngine creates an engine on a new exchange, nil options are the defaults. Callbacks are called only for
+// data which the source has, e.g. an execution store has no boards.
+func NewEngine(source Source, options *Options) (*Engine) {
+	return &Engine{
+		Exchange:         NewExchange(options),
+		source:           source,
+		mutex:            new(sync.Mutex),
+		realtimeChannels: make(map[string]*realtime.RealtimeChannel),
+	}
+}
@@ -0,0 +1,413 @@
+package backtest
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+	"github.com/potix/gobitflyer/api/types"
+	"github.com/potix/gobitflyer/api/public"
+	"github.com/potix/gobitflyer/api/private"
+	"github.com/potix/gobitflyer/api/realtime"
+	"github.com/potix/gobitflyer/childorder"
+	"github.com/potix/gobitflyer/parentorder"
+)
+
+const (
+	defaultLeverage     float64            = 2
+	defaultCurrencyCode types.CurrencyCode = "JPY"
+)
+
+type QueueModel int
+
+const (
+	// a limit order joins the end of the board level, and fills after the size ahead of it is executed
+	QueueModelBack         QueueModel = 0
+	// a limit order fills as soon as its price is executed
+	QueueModelFront        QueueModel = 1
+	// a limit order fills only when the price trades through it
+	QueueModelTradeThrough QueueModel = 2
+)
+
+type Options struct {
+	// cash of the account in CurrencyCode
+	InitialCollateral float64
+	// the default is JPY
+	CurrencyCode      types.CurrencyCode
+	// commission of every product, SetTradingCommission overrides it per product
+	Commission        *private.GetTradingCommissionResponse
+	// the default is 2
+	Leverage          float64
+	// time for an order or a cancel to reach the exchange
+	Latency           time.Duration
+	QueueModel        QueueModel
+	// interval of points of the equity curve, the default is a minute
+	EquityInterval    time.Duration
+}
+
+// position is the net position of a product, size is negative for a short position.
+type position struct {
+	size       float64
+	price      float64
+	commission float64
+	openDate   time.Time
+}
+
+// apply adds a fill and returns the profit of the closed size.
+func (p *position) apply(side types.Side, price float64, size float64, commission float64, now time.Time) (float64) {
+	signed := size
+	if side == types.SideSell {
+		signed = -size
+	}
+	if p.size == 0 || (p.size > 0) == (signed > 0) {
+		if p.size == 0 {
+			p.openDate = now
+		}
+		p.price = (p.price * math.Abs(p.size) + price * size) / (math.Abs(p.size) + size)
+		p.size = round(p.size + signed)
+		p.commission = round(p.commission + commission)
+		return 0
+	}
+	closed := math.Min(math.Abs(p.size), size)
+	realized := (price - p.price) * closed
+	if p.size < 0 {
+		realized = -realized
+	}
+	p.size = round(p.size + signed)
+	if p.size == 0 {
+		p.price = 0
+		p.commission = 0
+	} else if (p.size > 0) == (signed > 0) {
+		// the position is reversed
+		p.price = price
+		p.commission = 0
+		p.openDate = now
+	}
+	return realized
+}
+
+type boardLevel struct {
+	price float64
+	size  float64
+}
+
+type market struct {
+	productCode types.ProductCode
+	// copies of the last board, reduced by fills of taker orders
+	bids        []*boardLevel
+	asks        []*boardLevel
+	ltp         float64
+	position    *position
+}
+
+func (m *market) levels(side types.Side) (*[]*boardLevel) {
+	if side == types.SideBuy {
+		return &m.asks
+	}
+	return &m.bids
+}
+
+// restingSize returns the size of the board at the price of a resting order.
+func (m *market) restingSize(side types.Side, price float64) (float64) {
+	levels := m.bids
+	if side == types.SideSell {
+		levels = m.asks
+	}
+	for _, level := range levels {
+		if level.price == price {
+			return level.size
+		}
+	}
+	return 0
+}
+
+// request is an order or a cancel on the way to the exchange.
+type request struct {
+	arrival      time.Time
+	childOrder   *childorder.Order
+	parentOrder  *parentorder.Order
+	cancelChild  *childorder.Order
+	cancelParent *parentorder.Order
+}
+
+// Exchange simulates an account on historical or live market data. The time of the exchange is advanced by
+// Advance, SetBoard and ApplyExecutions, and orders are filled by the executions of the market.
+// The account is margin style, every product has a net position and profits are paid in Options.CurrencyCode.
+type Exchange struct {
+	mutex                 *sync.Mutex
+	options               Options
+	now                   time.Time
+	lastId                int64
+	markets               map[types.ProductCode]*market
+	marketOrder           []types.ProductCode
+	commissions           map[types.ProductCode]*private.GetTradingCommissionResponse
+	cash                  float64
+	childOrders           *childorder.Engine
+	// size ahead of resting orders at their level of the board
+	queueAhead            map[*childorder.Order]float64
+	parentOrders          *parentorder.Engine
+	requests              []*request
+	trades                []*Trade
+	childOrderEvents      realtime.ChildOrderEvents
+	eventsCallback        realtime.ChildOrderEventsCallback
+	callbackData          interface{}
+}
+
+func round(v float64) (float64) {
+	return math.Round(v * 1e8) / 1e8
+}
+
+func (e *Exchange) nextId() (int64) {
+	e.lastId += 1
+	return e.lastId
+}
+
+func (e *Exchange) newOrderId(prefix string) (string) {
+	return fmt.Sprintf("%v%v-%06d", prefix, e.now.UTC().Format("20060102-150405"), e.nextId())
+}
+
+func (e *Exchange) market(productCode types.ProductCode) (*market) {
+	m, ok := e.markets[productCode]
+	if !ok {
+		m = &market{
+			productCode: productCode,
+			bids:        make([]*boardLevel, 0),
+			asks:        make([]*boardLevel, 0),
+			position:    new(position),
+		}
+		e.markets[productCode] = m
+		e.marketOrder = append(e.marketOrder, productCode)
+	}
+	return m
+}
+
+func (e *Exchange) commissionRate(productCode types.ProductCode) (float64) {
+	if commission, ok := e.commissions[productCode]; ok {
+		return commission.CommissionRate
+	}
+	if e.options.Commission != nil {
+		return e.options.Commission.CommissionRate
+	}
+	return 0
+}
+
+// unlock releases the mutex and then calls the child order events callback with the events since the last call.
+func (e *Exchange) unlock() {
+	events := e.childOrderEvents
+	e.childOrderEvents = make(realtime.ChildOrderEvents, 0)
+	callback := e.eventsCallback
+	callbackData := e.callbackData
+	e.mutex.Unlock()
+	if callback != nil && len(events) > 0 {
+		callback(events, callbackData)
+	}
+}
+
+func (e *Exchange) addRequest(r *request) {
+	e.requests = append(e.requests, r)
+	sort.SliceStable(e.requests, func(i, j int) (bool) { return e.requests[i].arrival.Before(e.requests[j].arrival) })
+}
+
+// fillResting fills resting orders of the product by an execution of the market.
+func (e *Exchange) fillResting(m *market, execution *public.GetExecutionsExecution) {
+	price := execution.Price.Float64()
+	available := execution.Size.Float64()
+	for _, o := range e.childOrders.Orders() {
+		if !o.Resting || o.ProductCode != m.productCode {
+			continue
+		}
+		// an execution without side (itayose) can fill both sides
+		if o.Side == execution.Side || !o.Crosses(price) {
+			continue
+		}
+		if available <= 0 {
+			continue
+		}
+		if price != o.Price {
+			// the level of the order was traded through, but not beyond the size of the execution
+			q := math.Min(o.OutstandingSize, available)
+			available = round(available - q)
+			e.childOrders.Fill(o, e.nextId(), o.Price, q, true, e.now)
+			continue
+		}
+		if e.options.QueueModel == QueueModelTradeThrough {
+			continue
+		}
+		if queueAhead := e.queueAhead[o]; queueAhead > 0 {
+			q := math.Min(queueAhead, available)
+			e.queueAhead[o] = round(queueAhead - q)
+			available = round(available - q)
+		}
+		if available <= 0 {
+			continue
+		}
+		q := math.Min(o.OutstandingSize, available)
+		available = round(available - q)
+		e.childOrders.Fill(o, e.nextId(), o.Price, q, true, e.now)
+	}
+}
+
+func (e *Exchange) expire() {
+	e.childOrders.Expire(e.now)
+	e.parentOrders.Expire(e.now)
+}
+
+func (e *Exchange) arrive(r *request) {
+	switch {
+	case r.childOrder != nil:
+		e.childOrders.Arrive(r.childOrder, e.now)
+	case r.parentOrder != nil:
+		e.parentOrders.Accept(r.parentOrder)
+	case r.cancelChild != nil:
+		e.childOrders.Cancel(r.cancelChild, types.OrderStateCanceled, false, e.now)
+	case r.cancelParent != nil:
+		e.parentOrders.Finish(r.cancelParent, types.OrderStateCanceled, "", e.now)
+	}
+}
+
+// advance processes requests which arrive until now, expirations and parent orders.
+func (e *Exchange) advance(now time.Time) {
+	for len(e.requests) != 0 && !e.requests[0].arrival.After(now) {
+		r := e.requests[0]
+		e.requests = e.requests[1:]
+		if r.arrival.After(e.now) {
+			e.now = r.arrival
+		}
+		e.arrive(r)
+		e.flush()
+	}
+	if now.After(e.now) {
+		e.now = now
+	}
+	e.expire()
+	e.flush()
+}
+
+// Advance moves the time of the exchange to now. Orders and cancels which reach the exchange until now are processed.
+// The time never goes back.
+func (e *Exchange) Advance(now time.Time) {
+	e.mutex.Lock()
+	defer e.unlock()
+	e.advance(now)
+}
+
+// SetBoard replaces the board of the product, which taker orders take until the next board. The queue ahead of
+// resting orders shrinks to the size of their level.
+func (e *Exchange) SetBoard(productCode types.ProductCode, getBoardResponse *public.GetBoardResponse) {
+	e.mutex.Lock()
+	defer e.unlock()
+	m := e.market(productCode)
+	m.bids = make([]*boardLevel, 0, len(getBoardResponse.Bids))
+	for _, bid := range getBoardResponse.Bids {
+		if bid.Size.Sign() > 0 {
+			m.bids = append(m.bids, &boardLevel{price: bid.Price.Float64(), size: bid.Size.Float64()})
+		}
+	}
+	m.asks = make([]*boardLevel, 0, len(getBoardResponse.Asks))
+	for _, ask := range getBoardResponse.Asks {
+		if ask.Size.Sign() > 0 {
+			m.asks = append(m.asks, &boardLevel{price: ask.Price.Float64(), size: ask.Size.Float64()})
+		}
+	}
+	sort.Slice(m.bids, func(i, j int) (bool) { return m.bids[i].price > m.bids[j].price })
+	sort.Slice(m.asks, func(i, j int) (bool) { return m.asks[i].price < m.asks[j].price })
+	for o, queueAhead := range e.queueAhead {
+		if o.ProductCode == productCode {
+			e.queueAhead[o] = math.Min(queueAhead, m.restingSize(o.Side, o.Price))
+		}
+	}
+}
+
+// ApplyExecutions advances the time to each execution of the market, and fills resting orders and triggers
+// parent orders by it.
+func (e *Exchange) ApplyExecutions(productCode types.ProductCode, executions public.GetExecutionsResponse) {
+	e.mutex.Lock()
+	defer e.unlock()
+	m := e.market(productCode)
+	for _, execution := range executions {
+		e.advance(execution.ExecDate.Time)
+		m.ltp = execution.Price.Float64()
+		e.fillResting(m, execution)
+		e.flush()
+	}
+}
+
+// SetTradingCommission sets the commission of a product, which is returned by PriGetTradingCommission.
+func (e *Exchange) SetTradingCommission(productCode types.ProductCode, commission *private.GetTradingCommissionResponse) {
+	e.mutex.Lock()
+	defer e.unlock()
+	e.commissions[productCode] = commission
+}
+
+// SetChildOrderEventsCallback sets a callback of child order events, which is called without the lock of the exchange.
+func (e *Exchange) SetChildOrderEventsCallback(callback realtime.ChildOrderEventsCallback, callbackData interface{}) {
+	e.mutex.Lock()
+	defer e.unlock()
+	e.eventsCallback = callback
+	e.callbackData = callbackData
+}
+
+func (e *Exchange) Now() (time.Time) {
+	e.mutex.Lock()
+	defer e.unlock()
+	return e.now
+}
+
+func (e *Exchange) equity() (float64) {
+	equity := e.cash
+	for _, m := range e.markets {
+		if m.position.size != 0 && m.ltp != 0 {
+			equity += (m.ltp - m.position.price) * m.position.size
+		}
+	}
+	return round(equity)
+}
+
+// Equity returns the cash and the profit of open positions at the last traded prices.
+func (e *Exchange) Equity() (float64) {
+	e.mutex.Lock()
+	defer e.unlock()
+	return e.equity()
+}
+
+// Trades returns fills of the account in order.
+func (e *Exchange) Trades() ([]*Trade) {
+	e.mutex.Lock()
+	defer e.unlock()
+	trades := make([]*Trade, len(e.trades))
+	copy(trades, e.trades)
+	return trades
+}
+
+// NewExchange creates an exchange at the time zero, nil options are the defaults.
+func NewExchange(options *Options) (*Exchange) {
+	o := Options{}
+	if options != nil {
+		o = *options
+	}
+	if o.CurrencyCode == "" {
+		o.CurrencyCode = defaultCurrencyCode
+	}
+	if o.Leverage <= 0 {
+		o.Leverage = defaultLeverage
+	}
+	if o.EquityInterval <= 0 {
+		o.EquityInterval = time.Minute
+	}
+	e := &Exchange{
+		mutex:              new(sync.Mutex),
+		options:            o,
+		markets:            make(map[types.ProductCode]*market),
+		marketOrder:        make([]types.ProductCode, 0),
+		commissions:        make(map[types.ProductCode]*private.GetTradingCommissionResponse),
+		cash:               o.InitialCollateral,
+		queueAhead:         make(map[*childorder.Order]float64),
+		requests:           make([]*request, 0),
+		trades:             make([]*Trade, 0),
+		childOrderEvents:   make(realtime.ChildOrderEvents, 0),
+	}
+	e.childOrders = childorder.NewEngine(&childOrderHost{exchange: e})
+	e.parentOrders = parentorder.NewEngine(&parentOrderHost{exchange: e})
+	return e
+}
@@ -0,0 +1,49 @@
+package backtest
+
+import (
+	"time"
+	"github.com/potix/gobitflyer/api/types"
+	"github.com/potix/gobitflyer/api/private"
+	"github.com/potix/gobitflyer/childorder"
+	"github.com/potix/gobitflyer/parentorder"
+)
+
+// parentOrderHost places child orders of parent orders on the exchange, which does not emit parent order events.
+type parentOrderHost struct {
+	exchange *Exchange
+}
+
+// NewChildOrder places a child order which does not wait for the latency.
+func (h *parentOrderHost) NewChildOrder(p *parentorder.Order, index int, orderType types.OrderType, price float64, now time.Time) (parentorder.ChildOrder, string) {
+	parameter := p.Parameters[index]
+	o := h.exchange.newChildOrder(parameter.ProductCode, orderType, parameter.Side, price, parameter.Size, p.MinuteToExpire, p.TimeInForce, p, index)
+	o.ExpireDate = p.ExpireDate
+	return o, ""
+}
+
+func (h *parentOrderHost) ExecuteChildOrder(o parentorder.ChildOrder, now time.Time) {
+	h.exchange.childOrders.Arrive(o.(*childorder.Order), now)
+}
+
+func (h *parentOrderHost) CancelChildOrder(o parentorder.ChildOrder, state types.OrderState, now time.Time) {
+	h.exchange.childOrders.Cancel(o.(*childorder.Order), state, true, now)
+}
+
+func (h *parentOrderHost) LastTradedPrice(productCode types.ProductCode) (float64) {
+	return h.exchange.market(productCode).ltp
+}
+
+func (h *parentOrderHost) Event(p *parentorder.Order, eventType types.EventType, index int, o parentorder.ChildOrder, reason string, now time.Time) {
+}
+
+// newParentOrder creates a parent order which reaches the exchange after the latency.
+func (e *Exchange) newParentOrder(orderMethod types.OrderMethod, minuteToExpire int64, timeInForce types.TimeInForce,
+	parameters []*private.SendParentOrderParameter) (*parentorder.Order) {
+	p := parentorder.NewOrder(e.nextId(), e.newOrderId("JCO"), e.newOrderId("JRF"), orderMethod, minuteToExpire, timeInForce, parameters, e.now)
+	e.parentOrders.Add(p)
+	return p
+}
+
+func (e *Exchange) flush() {
+	e.parentOrders.Flush(e.now)
+}
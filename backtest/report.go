@@ -0,0 +1,133 @@
+package backtest
+
+import (
+	"math"
+	"time"
+	"github.com/potix/gobitflyer/api/types"
+)
+
+// Trade is a fill of the account.
+type Trade struct {
+	Time                   time.Time
+	ProductCode            types.ProductCode
+	ChildOrderAcceptanceId string
+	Side                   types.Side
+	Price                  float64
+	Size                   float64
+	// commission in the currency of the account
+	Fee                    float64
+	Maker                  bool
+	// profit of the closed size without the fee
+	RealizedPnl            float64
+	// net position after the fill, negative for a short position
+	Position               float64
+}
+
+type EquityPoint struct {
+	Time   time.Time
+	Equity float64
+}
+
+type Summary struct {
+	Start            time.Time
+	End              time.Time
+	InitialEquity    float64
+	FinalEquity      float64
+	// FinalEquity - InitialEquity, fees are included
+	NetProfit        float64
+	Return           float64
+	// sums of realized profits and losses without fees
+	GrossProfit      float64
+	GrossLoss        float64
+	Fees             float64
+	Trades           int64
+	Volume           float64
+	// trades which closed a position
+	ClosingTrades    int64
+	WinningTrades    int64
+	WinRate          float64
+	// GrossProfit / -GrossLoss, 0 without losses
+	ProfitFactor     float64
+	MaxDrawdown      float64
+	MaxDrawdownRatio float64
+	// mean / standard deviation of returns between points of the equity curve, not annualized
+	Sharpe           float64
+}
+
+type Report struct {
+	Trades  []*Trade
+	Equity  []*EquityPoint
+	Summary *Summary
+}
+
+func newSummary(initialEquity float64, trades []*Trade, equity []*EquityPoint) (*Summary) {
+	summary := &Summary{
+		InitialEquity: initialEquity,
+		FinalEquity:   initialEquity,
+	}
+	if len(equity) > 0 {
+		summary.Start = equity[0].Time
+		summary.End = equity[len(equity) - 1].Time
+		summary.FinalEquity = equity[len(equity) - 1].Equity
+	}
+	summary.NetProfit = round(summary.FinalEquity - summary.InitialEquity)
+	if initialEquity != 0 {
+		summary.Return = summary.NetProfit / initialEquity
+	}
+	for _, trade := range trades {
+		summary.Trades += 1
+		summary.Volume = round(summary.Volume + trade.Size)
+		summary.Fees = round(summary.Fees + trade.Fee)
+		if trade.RealizedPnl == 0 {
+			continue
+		}
+		summary.ClosingTrades += 1
+		if trade.RealizedPnl > 0 {
+			summary.WinningTrades += 1
+			summary.GrossProfit = round(summary.GrossProfit + trade.RealizedPnl)
+		} else {
+			summary.GrossLoss = round(summary.GrossLoss + trade.RealizedPnl)
+		}
+	}
+	if summary.ClosingTrades > 0 {
+		summary.WinRate = float64(summary.WinningTrades) / float64(summary.ClosingTrades)
+	}
+	if summary.GrossLoss < 0 {
+		summary.ProfitFactor = summary.GrossProfit / -summary.GrossLoss
+	}
+	peak := initialEquity
+	returns := make([]float64, 0, len(equity))
+	previous := initialEquity
+	for _, point := range equity {
+		if point.Equity > peak {
+			peak = point.Equity
+		}
+		drawdown := peak - point.Equity
+		if drawdown > summary.MaxDrawdown {
+			summary.MaxDrawdown = round(drawdown)
+			if peak != 0 {
+				summary.MaxDrawdownRatio = drawdown / peak
+			}
+		}
+		if previous != 0 {
+			returns = append(returns, point.Equity / previous - 1)
+		}
+		previous = point.Equity
+	}
+	if len(returns) > 1 {
+		mean := float64(0)
+		for _, r := range returns {
+			mean += r
+		}
+		mean /= float64(len(returns))
+		variance := float64(0)
+		for _, r := range returns {
+			variance += (r - mean) * (r - mean)
+		}
+		variance /= float64(len(returns) - 1)
+		if variance > 0 {
+			summary.Sharpe = mean / math.Sqrt(variance)
+		}
+	}
+	return summary
+}
@@ -0,0 +1,162 @@
+package backtest
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+	"github.com/pkg/errors"
+	"github.com/potix/gobitflyer/api/types"
+	"github.com/potix/gobitflyer/api/public"
+	"github.com/potix/gobitflyer/api/realtime"
+	"github.com/potix/gobitflyer/executionstore"
+	"github.com/potix/gobitflyer/orderbook"
+)
+
+// Event is market data of a product at a time. Board is a whole board.
+type Event struct {
+	Time        time.Time
+	ProductCode types.ProductCode
+	Board       *public.GetBoardResponse
+	Ticker      *public.GetTickerResponse
+	Executions  public.GetExecutionsResponse
+}
+
+// Source provides events in order of time.
+type Source interface {
+	Play(ctx context.Context, handler func(event *Event) (error)) (error)
+}
+
+type storeSource struct {
+	store       *executionstore.Store
+	productCode types.ProductCode
+	since       time.Time
+	until       time.Time
+}
+
+// Play calls handler with executions of the same time at once.
+func (s *storeSource) Play(ctx context.Context, handler func(event *Event) (error)) (error) {
+	var event *Event
+	err := s.store.Read(s.since, s.until, func(execution *public.GetExecutionsExecution) (error) {
+		if event != nil && !execution.ExecDate.Equal(event.Time) {
+			err := handler(event)
+			if err != nil {
+				return err
+			}
+			event = nil
+		}
+		if event == nil {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			event = &Event{
+				Time:        execution.ExecDate.Time,
+				ProductCode: s.productCode,
+				Executions:  make(public.GetExecutionsResponse, 0, 1),
+			}
+		}
+		event.Executions = append(event.Executions, execution)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if event != nil {
+		return handler(event)
+	}
+	return nil
+}
+
+// NewStoreSource provides executions of productCode downloaded into store between since and until (zero means no bound).
+func NewStoreSource(store *executionstore.Store, productCode types.ProductCode, since time.Time, until time.Time) (Source) {
+	return &storeSource{
+		store:       store,
+		productCode: productCode,
+		since:       since,
+		until:       until,
+	}
+}
+
+type recordingSource struct {
+	player *realtime.Player
+}
+
+var channelPrefixes = []struct {
+	prefix       string
+	realtimeType types.RealtimeType
+}{
+	// lightning_board_snapshot_ before lightning_board_
+	{ "lightning_board_snapshot_", types.RealtimeTypeBoardSnapshot },
+	{ "lightning_board_", types.RealtimeTypeBoard },
+	{ "lightning_ticker_", types.RealtimeTypeTicker },
+	{ "lightning_executions_", types.RealtimeTypeExecutions },
+}
+
+func parseChannel(channel string) (types.RealtimeType, types.ProductCode, bool) {
+	for _, channelPrefix := range channelPrefixes {
+		if strings.HasPrefix(channel, channelPrefix.prefix) {
+			return channelPrefix.realtimeType, types.ProductCode(strings.TrimPrefix(channel, channelPrefix.prefix)), true
+		}
+	}
+	return 0, "", false
+}
+
+// Play calls handler with every market message at the time it was received. Board diffs are applied to the last
+// snapshot, and diffs before the first snapshot are skipped.
+func (s *recordingSource) Play(ctx context.Context, handler func(event *Event) (error)) (error) {
+	books := make(map[types.ProductCode]*orderbook.Book)
+	return s.player.Play(ctx, func(record *realtime.Record) (error) {
+		if record.Notify == nil || record.Notify.Params == nil {
+			return nil
+		}
+		realtimeType, productCode, ok := parseChannel(record.Notify.Params.Channel)
+		if !ok {
+			return nil
+		}
+		event := &Event{
+			Time:        record.ReceivedAt,
+			ProductCode: productCode,
+		}
+		message := record.Notify.Params.Message
+		switch realtimeType {
+		case types.RealtimeTypeBoardSnapshot, types.RealtimeTypeBoard:
+			getBoardResponse := new(public.GetBoardResponse)
+			err := json.Unmarshal(message, getBoardResponse)
+			if err != nil {
+				return errors.Wrapf(err, "can not unmarshal board (channel = %v)", record.Notify.Params.Channel)
+			}
+			book, ok := books[productCode]
+			if realtimeType == types.RealtimeTypeBoardSnapshot {
+				if !ok {
+					book = orderbook.NewBook()
+					books[productCode] = book
+				}
+				event.Board = book.Reset(getBoardResponse).GetBoardResponse()
+			} else if ok {
+				event.Board = book.Apply(getBoardResponse).GetBoardResponse()
+			} else {
+				return nil
+			}
+		case types.RealtimeTypeTicker:
+			event.Ticker = new(public.GetTickerResponse)
+			err := json.Unmarshal(message, event.Ticker)
+			if err != nil {
+				return errors.Wrapf(err, "can not unmarshal ticker (channel = %v)", record.Notify.Params.Channel)
+			}
+		case types.RealtimeTypeExecutions:
+			event.Executions = make(public.GetExecutionsResponse, 0)
+			err := json.Unmarshal(message, &event.Executions)
+			if err != nil {
+				return errors.Wrapf(err, "can not unmarshal executions (channel = %v)", record.Notify.Params.Channel)
+			}
+		}
+		return handler(event)
+	})
+}
+
+// NewRecordingSource provides market messages recorded by realtime.FileRecorder. Use realtime.ReplaySpeedMax for a backtest.
+func NewRecordingSource(player *realtime.Player) (Source) {
+	return &recordingSource{
+		player: player,
+	}
+}
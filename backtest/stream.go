@@ -0,0 +1,70 @@
+package backtest
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"github.com/potix/gobitflyer/api"
+	"github.com/potix/gobitflyer/api/types"
+	"github.com/potix/gobitflyer/api/public"
+	"github.com/potix/gobitflyer/api/realtime"
+	"github.com/potix/gobitflyer/orderbook"
+)
+
+// Streams fail on the engine. Callbacks of Real*Start are called on the goroutine of Run in order of events, which a
+// channel read by another goroutine can not keep.
+
+func (e *Engine) RealTickerStream(productCode types.ProductCode, streamOptions *api.StreamOptions) (<-chan *public.GetTickerResponse, <-chan error, error) {
+	return e.RealTickerStreamCtx(context.Background(), productCode, streamOptions)
+}
+
+func (e *Engine) RealTickerStreamCtx(ctx context.Context, productCode types.ProductCode, streamOptions *api.StreamOptions) (<-chan *public.GetTickerResponse, <-chan error, error) {
+	return nil, nil, errors.Errorf("streams are not supported by backtest, use Real*Start")
+}
+
+func (e *Engine) RealExecutionsStream(productCode types.ProductCode, streamOptions *api.StreamOptions) (<-chan public.GetExecutionsResponse, <-chan error, error) {
+	return e.RealExecutionsStreamCtx(context.Background(), productCode, streamOptions)
+}
+
+func (e *Engine) RealExecutionsStreamCtx(ctx context.Context, productCode types.ProductCode, streamOptions *api.StreamOptions) (<-chan public.GetExecutionsResponse, <-chan error, error) {
+	return nil, nil, errors.Errorf("streams are not supported by backtest, use Real*Start")
+}
+
+func (e *Engine) RealBoardSnapshotStream(productCode types.ProductCode, streamOptions *api.StreamOptions) (<-chan *public.GetBoardResponse, <-chan error, error) {
+	return e.RealBoardSnapshotStreamCtx(context.Background(), productCode, streamOptions)
+}
+
+func (e *Engine) RealBoardSnapshotStreamCtx(ctx context.Context, productCode types.ProductCode, streamOptions *api.StreamOptions) (<-chan *public.GetBoardResponse, <-chan error, error) {
+	return nil, nil, errors.Errorf("streams are not supported by backtest, use Real*Start")
+}
+
+func (e *Engine) RealBoardStream(productCode types.ProductCode, merge bool, streamOptions *api.StreamOptions) (<-chan *public.GetBoardResponse, <-chan error, error) {
+	return e.RealBoardStreamCtx(context.Background(), productCode, merge, streamOptions)
+}
+
+func (e *Engine) RealBoardStreamCtx(ctx context.Context, productCode types.ProductCode, merge bool, streamOptions *api.StreamOptions) (<-chan *public.GetBoardResponse, <-chan error, error) {
+	return nil, nil, errors.Errorf("streams are not supported by backtest, use Real*Start")
+}
+
+func (e *Engine) RealOrderBookStream(productCode types.ProductCode, streamOptions *api.StreamOptions) (<-chan *orderbook.Snapshot, <-chan error, error) {
+	return e.RealOrderBookStreamCtx(context.Background(), productCode, streamOptions)
+}
+
+func (e *Engine) RealOrderBookStreamCtx(ctx context.Context, productCode types.ProductCode, streamOptions *api.StreamOptions) (<-chan *orderbook.Snapshot, <-chan error, error) {
+	return nil, nil, errors.Errorf("streams are not supported by backtest, use Real*Start")
+}
+
+func (e *Engine) RealChildOrderEventsStream(streamOptions *api.StreamOptions) (<-chan realtime.ChildOrderEvents, <-chan error, error) {
+	return e.RealChildOrderEventsStreamCtx(context.Background(), streamOptions)
+}
+
+func (e *Engine) RealChildOrderEventsStreamCtx(ctx context.Context, streamOptions *api.StreamOptions) (<-chan realtime.ChildOrderEvents, <-chan error, error) {
+	return nil, nil, errors.Errorf("streams are not supported by backtest, use Real*Start")
+}
+
+func (e *Engine) RealParentOrderEventsStream(streamOptions *api.StreamOptions) (<-chan realtime.ParentOrderEvents, <-chan error, error) {
+	return e.RealParentOrderEventsStreamCtx(context.Background(), streamOptions)
+}
+
+func (e *Engine) RealParentOrderEventsStreamCtx(ctx context.Context, streamOptions *api.StreamOptions) (<-chan realtime.ParentOrderEvents, <-chan error, error) {
+	return nil, nil, errors.Errorf("streams are not supported by backtest, use Real*Start")
+}
@@ -0,0 +1,20 @@
+package bitflyertest
+
+import (
+	"testing"
+	"github.com/potix/gobitflyer/client"
+	"github.com/potix/gobitflyer/api"
+)
+
+// NewClients starts a server which is closed when t ends, and returns a rest api client and a realtime api client
+// connected to it with the api key of the server. The realtime api client does not connect until a channel is started.
+func NewClients(t *testing.T) (*Server, *api.APIClient, *api.RealAPIClient) {
+	server := NewServer()
+	t.Cleanup(server.Close)
+	authenticator := api.NewAuthenticatorFromKey(server.APIKey(), server.APISecret())
+	httpClient := client.NewHTTPClient(30, 0, 180, nil)
+	apiClient := api.NewAPIClient(httpClient, authenticator, api.WithEndpoint(server.URL()))
+	wsClient := client.NewWSClient(0, 0, 60, 1, nil)
+	realApiClient := api.NewRealAPIClient(wsClient, api.WithRealtimeEndpoint(server.RealtimeURL()), api.WithAuthenticator(authenticator))
+	return server, apiClient, realApiClient
+}
@@ -0,0 +1,332 @@
+package bitflyertest
+
+import (
+	"math"
+	"sort"
+	"time"
+	"github.com/potix/gobitflyer/api/types"
+	"github.com/potix/gobitflyer/api/public"
+	"github.com/potix/gobitflyer/childorder"
+)
+
+type marketSpec struct {
+	productCode types.ProductCode
+	base        types.CurrencyCode
+	quote       types.CurrencyCode
+	fx          bool
+	midPrice    float64
+	tick        float64
+	minSize     float64
+	levelSize   float64
+}
+
+var defaultMarketSpecs = []*marketSpec{
+	{ productCode: "BTC_JPY",    base: "BTC", quote: "JPY", fx: false, midPrice: 1000000, tick: 1000,    minSize: 0.001, levelSize: 0.5 },
+	{ productCode: "FX_BTC_JPY", base: "BTC", quote: "JPY", fx: true,  midPrice: 1010000, tick: 1000,    minSize: 0.01,  levelSize: 1 },
+	{ productCode: "ETH_JPY",    base: "ETH", quote: "JPY", fx: false, midPrice: 30000,   tick: 10,      minSize: 0.01,  levelSize: 5 },
+	{ productCode: "ETH_BTC",    base: "ETH", quote: "BTC", fx: false, midPrice: 0.03,    tick: 0.00001, minSize: 0.01,  levelSize: 5 },
+	{ productCode: "BCH_BTC",    base: "BCH", quote: "BTC", fx: false, midPrice: 0.03,    tick: 0.00001, minSize: 0.01,  levelSize: 5 },
+}
+
+const (
+	defaultBoardDepth int = 20
+)
+
+// boardLevel holds the liquidity of other participants (size) and resting orders of the account at one price.
+type boardLevel struct {
+	price  float64
+	size   float64
+	orders []*childorder.Order
+}
+
+func (l *boardLevel) total() (float64) {
+	total := l.size
+	for _, o := range l.orders {
+		total += o.OutstandingSize
+	}
+	return round(total)
+}
+
+func (l *boardLevel) removeOrder(o *childorder.Order) {
+	for i, order := range l.orders {
+		if order == o {
+			l.orders = append(l.orders[:i], l.orders[i+1:]...)
+			return
+		}
+	}
+}
+
+type market struct {
+	spec              *marketSpec
+	productCode       types.ProductCode
+	health            string
+	state             string
+	commissionRate    float64
+	bids              []*boardLevel
+	asks              []*boardLevel
+	ltp               float64
+	volume            float64
+	tickId            int64
+	executions        public.GetExecutionsResponse
+	pendingExecutions public.GetExecutionsResponse
+	changedBids       map[float64]bool
+	changedAsks       map[float64]bool
+	dropBoardDiffs    int
+	dropBoardSnapshots int
+}
+
+func (m *market) levels(bid bool) (*[]*boardLevel) {
+	if bid {
+		return &m.bids
+	}
+	return &m.asks
+}
+
+func (m *market) changed(bid bool, price float64) {
+	if bid {
+		m.changedBids[price] = true
+	} else {
+		m.changedAsks[price] = true
+	}
+}
+
+// better reports whether a is a better price than b on the side.
+func (m *market) better(bid bool, a float64, b float64) (bool) {
+	if bid {
+		return a > b
+	}
+	return a < b
+}
+
+func (m *market) findLevel(bid bool, price float64, create bool) (*boardLevel) {
+	levels := m.levels(bid)
+	i := sort.Search(len(*levels), func(i int) bool {
+		return !m.better(bid, (*levels)[i].price, price)
+	})
+	if i < len(*levels) && (*levels)[i].price == price {
+		return (*levels)[i]
+	}
+	if !create {
+		return nil
+	}
+	level := &boardLevel{
+		price:  price,
+		orders: make([]*childorder.Order, 0),
+	}
+	*levels = append(*levels, nil)
+	copy((*levels)[i+1:], (*levels)[i:])
+	(*levels)[i] = level
+	return level
+}
+
+func (m *market) removeEmptyLevels(bid bool) {
+	levels := m.levels(bid)
+	remain := (*levels)[:0]
+	for _, level := range *levels {
+		if level.total() > 0 {
+			remain = append(remain, level)
+		}
+	}
+	*levels = remain
+}
+
+func (m *market) setBoard(bids []*public.GetBoardBook, asks []*public.GetBoardBook) {
+	for _, bid := range []bool{true, false} {
+		for _, level := range *m.levels(bid) {
+			level.size = 0
+			m.changed(bid, level.price)
+		}
+		books := asks
+		if bid {
+			books = bids
+		}
+		for _, book := range books {
+			if book.Price.Sign() <= 0 || book.Size.Sign() <= 0 {
+				continue
+			}
+			level := m.findLevel(bid, book.Price.Float64(), true)
+			level.size = round(level.size + book.Size.Float64())
+			m.changed(bid, level.price)
+		}
+		m.removeEmptyLevels(bid)
+	}
+}
+
+func (m *market) best(bid bool) (*boardLevel) {
+	levels := m.levels(bid)
+	if len(*levels) == 0 {
+		return nil
+	}
+	return (*levels)[0]
+}
+
+func (m *market) midPrice() (float64) {
+	bestBid := m.best(true)
+	bestAsk := m.best(false)
+	switch {
+	case bestBid != nil && bestAsk != nil:
+		return round((bestBid.price + bestAsk.price) / 2)
+	case bestBid != nil:
+		return bestBid.price
+	case bestAsk != nil:
+		return bestAsk.price
+	default:
+		return m.ltp
+	}
+}
+
+func (m *market) books(bid bool) ([]*public.GetBoardBook) {
+	books := make([]*public.GetBoardBook, 0, len(*m.levels(bid)))
+	for _, level := range *m.levels(bid) {
+		books = append(books, &public.GetBoardBook{
+			Price: types.NewDecimalFromFloat(level.price),
+			Size:  types.NewDecimalFromFloat(level.total()),
+		})
+	}
+	return books
+}
+
+// dropBoardSnapshot reports whether the next board snapshot is discarded.
+func (m *market) dropBoardSnapshot() (bool) {
+	if m.dropBoardSnapshots == 0 {
+		return false
+	}
+	m.dropBoardSnapshots -= 1
+	return true
+}
+
+func (m *market) board() (*public.GetBoardResponse) {
+	return &public.GetBoardResponse{
+		MidPrice: m.midPrice(),
+		Bids:     m.books(true),
+		Asks:     m.books(false),
+	}
+}
+
+func (m *market) hasBoardDiff() (bool) {
+	return len(m.changedBids) != 0 || len(m.changedAsks) != 0
+}
+
+// boardDiff returns changed levels since last call. size 0 means the level was removed.
+func (m *market) boardDiff() (*public.GetBoardResponse) {
+	diff := &public.GetBoardResponse{
+		MidPrice: m.midPrice(),
+		Bids:     make([]*public.GetBoardBook, 0, len(m.changedBids)),
+		Asks:     make([]*public.GetBoardBook, 0, len(m.changedAsks)),
+	}
+	for _, bid := range []bool{true, false} {
+		changed := m.changedAsks
+		if bid {
+			changed = m.changedBids
+		}
+		prices := make([]float64, 0, len(changed))
+		for price := range changed {
+			prices = append(prices, price)
+		}
+		sort.Slice(prices, func(i int, j int) bool {
+			return m.better(bid, prices[i], prices[j])
+		})
+		books := make([]*public.GetBoardBook, 0, len(changed))
+		for _, price := range prices {
+			size := float64(0)
+			if level := m.findLevel(bid, price, false); level != nil {
+				size = level.total()
+			}
+			books = append(books, &public.GetBoardBook{
+				Price: types.NewDecimalFromFloat(price),
+				Size:  types.NewDecimalFromFloat(size),
+			})
+		}
+		if bid {
+			diff.Bids = books
+		} else {
+			diff.Asks = books
+		}
+	}
+	m.changedBids = make(map[float64]bool)
+	m.changedAsks = make(map[float64]bool)
+	return diff
+}
+
+func (m *market) depth(bid bool) (float64) {
+	depth := float64(0)
+	for _, level := range *m.levels(bid) {
+		depth += level.total()
+	}
+	return round(depth)
+}
+
+func (m *market) ticker(now time.Time) (*public.GetTickerResponse) {
+	m.tickId += 1
+	ticker := &public.GetTickerResponse{
+		ProductCode:     m.productCode,
+		Timestamp:       formatDate(now),
+		TickId:          m.tickId,
+		TotalBidDepth:   m.depth(true),
+		TotalAskDepth:   m.depth(false),
+		LTP:             m.ltp,
+		Volume:          m.volume,
+		VolumeByProduct: m.volume,
+	}
+	if bestBid := m.best(true); bestBid != nil {
+		ticker.BestBid = bestBid.price
+		ticker.BestBidSize = bestBid.total()
+	}
+	if bestAsk := m.best(false); bestAsk != nil {
+		ticker.BestAsk = bestAsk.price
+		ticker.BestAskSize = bestAsk.total()
+	}
+	return ticker
+}
+
+func (m *market) addExecution(id int64, side types.Side, price float64, size float64, buyChildOrderAcceptanceId string, sellChildOrderAcceptanceId string, now time.Time) {
+	execution := &public.GetExecutionsExecution{
+		Id:                         id,
+		Side:                       side,
+		Price:                      types.NewDecimalFromFloat(price),
+		Size:                       types.NewDecimalFromFloat(size),
+		ExecDate:                   formatDate(now),
+		BuyChildOrderAcceptanceId:  buyChildOrderAcceptanceId,
+		SellChildOrderAcceptanceId: sellChildOrderAcceptanceId,
+	}
+	m.executions = append(m.executions, execution)
+	m.pendingExecutions = append(m.pendingExecutions, execution)
+	m.ltp = price
+	m.volume = round(m.volume + size)
+}
+
+func round(v float64) (float64) {
+	return math.Round(v * 1e8) / 1e8
+}
+
+func newMarket(spec *marketSpec) (*market) {
+	m := &market{
+		spec:              spec,
+		productCode:       spec.productCode,
+		health:            "NORMAL",
+		state:             "RUNNING",
+		bids:              make([]*boardLevel, 0),
+		asks:              make([]*boardLevel, 0),
+		ltp:               spec.midPrice,
+		executions:        make(public.GetExecutionsResponse, 0),
+		pendingExecutions: make(public.GetExecutionsResponse, 0),
+		changedBids:       make(map[float64]bool),
+		changedAsks:       make(map[float64]bool),
+	}
+	bids := make([]*public.GetBoardBook, 0, defaultBoardDepth)
+	asks := make([]*public.GetBoardBook, 0, defaultBoardDepth)
+	for i := 1; i <= defaultBoardDepth; i += 1 {
+		bids = append(bids, &public.GetBoardBook{
+			Price: types.NewDecimalFromFloat(round(spec.midPrice - spec.tick * float64(i))),
+			Size:  types.NewDecimalFromFloat(spec.levelSize),
+		})
+		asks = append(asks, &public.GetBoardBook{
+			Price: types.NewDecimalFromFloat(round(spec.midPrice + spec.tick * float64(i))),
+			Size:  types.NewDecimalFromFloat(spec.levelSize),
+		})
+	}
+	m.setBoard(bids, asks)
+	m.changedBids = make(map[float64]bool)
+	m.changedAsks = make(map[float64]bool)
+	return m
+}
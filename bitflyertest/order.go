@@ -0,0 +1,494 @@
+package bitflyertest
+
+import (
+	"time"
+	"github.com/potix/gobitflyer/api/types"
+	"github.com/potix/gobitflyer/api/public"
+	"github.com/potix/gobitflyer/api/private"
+	"github.com/potix/gobitflyer/api/realtime"
+	"github.com/potix/gobitflyer/childorder"
+	"github.com/potix/gobitflyer/parentorder"
+)
+
+const (
+	defaultLeverage float64 = 2
+)
+
+type asset struct {
+	amount float64
+	locked float64
+}
+
+func (a *asset) available() (float64) {
+	return round(a.amount - a.locked)
+}
+
+type account struct {
+	assets                   map[types.CurrencyCode]*asset
+	assetOrder               []types.CurrencyCode
+	collateral               float64
+	childOrders              *childorder.Engine
+	parentOrders             *parentorder.Engine
+	positions                private.GetPositionsResponse
+	balanceHistory           private.GetBalanceHistoryResponse
+	collateralHistory        private.GetCollateralHistoryResponse
+	addresses                private.GetAddressesResponse
+	bankAccounts             private.GetBankAccountsResponse
+	coinIns                  private.GetCoinInsResponse
+	coinOuts                 private.GetCoinOutsResponse
+	deposits                 private.GetDepositsResponse
+	withdrawals              private.GetWithdrawalsResponse
+	withdrawalMessageIds     map[string]int64
+	pendingChildOrderEvents  realtime.ChildOrderEvents
+	pendingParentOrderEvents realtime.ParentOrderEvents
+}
+
+func (a *account) asset(currencyCode types.CurrencyCode) (*asset) {
+	ast, ok := a.assets[currencyCode]
+	if !ok {
+		ast = new(asset)
+		a.assets[currencyCode] = ast
+		a.assetOrder = append(a.assetOrder, currencyCode)
+	}
+	return ast
+}
+
+func (s *Server) parentOrderEvent(p *parentorder.Order, eventType types.EventType, now time.Time) (*realtime.ParentOrderEvent) {
+	event := &realtime.ParentOrderEvent{
+		ProductCode:             p.ProductCode(),
+		ParentOrderId:           p.ParentOrderId,
+		ParentOrderAcceptanceId: p.ParentOrderAcceptanceId,
+		EventDate:               formatDate(now),
+		EventType:               eventType,
+	}
+	s.account.pendingParentOrderEvents = append(s.account.pendingParentOrderEvents, event)
+	return event
+}
+
+// estimateCost returns the quote amount needed to take size from the board, or false if the board is too thin.
+func (s *Server) estimateCost(m *market, side types.Side, price float64, size float64) (float64, bool) {
+	bid := side == types.SideSell
+	cost := float64(0)
+	remaining := size
+	for _, level := range *m.levels(bid) {
+		if remaining <= 0 || (price != 0 && m.better(bid, price, level.price)) {
+			break
+		}
+		q := level.total()
+		if q > remaining {
+			q = remaining
+		}
+		cost += level.price * q
+		remaining = round(remaining - q)
+	}
+	return round(cost), remaining <= 0
+}
+
+// newChildOrder validates and accepts an order. It is matched by Engine.Arrive.
+func (s *Server) newChildOrder(m *market, orderType types.OrderType, side types.Side, price float64, size float64,
+	minuteToExpire int64, timeInForce types.TimeInForce, parent *parentorder.Order, parameterIndex int, now time.Time) (*childorder.Order, int64, string) {
+	if side != types.SideBuy && side != types.SideSell {
+		return nil, errorStatusInvalidParameter, "Invalid side"
+	}
+	if orderType != types.OrderTypeLimit && orderType != types.OrderTypeMarket {
+		return nil, errorStatusInvalidParameter, "Invalid order type"
+	}
+	if size < m.spec.minSize {
+		return nil, errorStatusInvalidSize, "The minimum order size is " + formatFloat(m.spec.minSize) + " " + string(m.spec.base) + "."
+	}
+	if orderType == types.OrderTypeLimit && price <= 0 {
+		return nil, errorStatusInvalidPrice, "Invalid price"
+	}
+	if orderType == types.OrderTypeMarket {
+		price = 0
+	}
+	if !m.spec.fx {
+		if side == types.SideBuy {
+			need := round(price * size)
+			if orderType == types.OrderTypeMarket {
+				need, _ = s.estimateCost(m, side, 0, size)
+			}
+			if s.account.asset(m.spec.quote).available() < need {
+				return nil, errorStatusInsufficientFunds, "Insufficient funds"
+			}
+		} else if s.account.asset(m.spec.base).available() < size {
+			return nil, errorStatusInsufficientFunds, "Insufficient funds"
+		}
+	}
+	o := childorder.NewOrder(s.nextId(), s.newOrderId("JOR", now), s.newOrderId("JRF", now), m.productCode, orderType, side, price, size,
+		minuteToExpire, timeInForce, parent, parameterIndex, now)
+	if !m.spec.fx && orderType == types.OrderTypeLimit {
+		if side == types.SideBuy {
+			s.account.asset(m.spec.quote).locked += round(price * size)
+		} else {
+			s.account.asset(m.spec.base).locked += size
+		}
+	}
+	s.account.childOrders.Add(o)
+	return o, 0, ""
+}
+
+// match takes the board on the opposite side of takerSide up to price (0 means no limit).
+// taker is nil when the taker is another participant.
+func (s *Server) match(m *market, takerSide types.Side, price float64, size float64, taker *childorder.Order, now time.Time) {
+	bid := takerSide == types.SideSell
+	takerAcceptanceId := ""
+	if taker != nil {
+		takerAcceptanceId = taker.ChildOrderAcceptanceId
+	} else {
+		takerAcceptanceId = s.newOrderId("JRF", now)
+	}
+	remaining := size
+	for remaining > 0 {
+		level := m.best(bid)
+		if level == nil || (price != 0 && m.better(bid, price, level.price)) {
+			break
+		}
+		for len(level.orders) != 0 && remaining > 0 {
+			maker := level.orders[0]
+			q := maker.OutstandingSize
+			if q > remaining {
+				q = remaining
+			}
+			execId := s.nextId()
+			s.addExecution(m, execId, takerSide, level.price, q, takerAcceptanceId, maker.ChildOrderAcceptanceId, now)
+			s.account.childOrders.Fill(maker, execId, level.price, q, true, now)
+			if taker != nil {
+				s.account.childOrders.Fill(taker, execId, level.price, q, false, now)
+			}
+			remaining = round(remaining - q)
+		}
+		if remaining > 0 && level.size > 0 {
+			q := level.size
+			if q > remaining {
+				q = remaining
+			}
+			level.size = round(level.size - q)
+			execId := s.nextId()
+			s.addExecution(m, execId, takerSide, level.price, q, takerAcceptanceId, s.newOrderId("JRF", now), now)
+			if taker != nil {
+				s.account.childOrders.Fill(taker, execId, level.price, q, false, now)
+			}
+			remaining = round(remaining - q)
+		}
+		m.changed(bid, level.price)
+		m.removeEmptyLevels(bid)
+	}
+}
+
+func (s *Server) addExecution(m *market, execId int64, takerSide types.Side, price float64, size float64, takerAcceptanceId string, makerAcceptanceId string, now time.Time) {
+	if takerSide == types.SideBuy {
+		m.addExecution(execId, takerSide, price, size, takerAcceptanceId, makerAcceptanceId, now)
+	} else {
+		m.addExecution(execId, takerSide, price, size, makerAcceptanceId, takerAcceptanceId, now)
+	}
+}
+
+func (s *Server) addBalanceHistory(m *market, currencyCode types.CurrencyCode, tradeType types.TradeType, price float64, amount float64, quantity float64, commission float64, orderId string, now time.Time) {
+	s.account.balanceHistory = append(s.account.balanceHistory, &private.GetBalanceHistoryEvent{
+		Id:           s.nextId(),
+		TradeDate:    formatDate(now),
+		ProductCode:  m.productCode,
+		CurrencyCode: currencyCode,
+		TradeType:    tradeType,
+		Price:        types.NewDecimalFromFloat(price),
+		Amount:       types.NewDecimalFromFloat(amount),
+		Quantity:     types.NewDecimalFromFloat(quantity),
+		Commission:   types.NewDecimalFromFloat(commission),
+		Balance:      types.NewDecimalFromFloat(s.account.asset(currencyCode).amount),
+		OrderId:      orderId,
+	})
+}
+
+func (s *Server) updatePosition(m *market, side types.Side, price float64, size float64, commission float64, now time.Time) {
+	remaining := size
+	positions := s.account.positions[:0]
+	for _, position := range s.account.positions {
+		if remaining > 0 && position.ProductCode == m.productCode && position.Side != side {
+			q := position.Size
+			if q > remaining {
+				q = remaining
+			}
+			pnl := (price - position.Price) * q
+			if position.Side == types.SideSell {
+				pnl = -pnl
+			}
+			s.account.collateral = round(s.account.collateral + pnl)
+			position.Size = round(position.Size - q)
+			position.RequireCollateral = round(position.Price * position.Size / position.Leverage)
+			remaining = round(remaining - q)
+		}
+		if position.Size > 0 {
+			positions = append(positions, position)
+		}
+	}
+	s.account.positions = positions
+	if remaining <= 0 {
+		return
+	}
+	s.account.positions = append(s.account.positions, &private.GetPositionsPosition{
+		ProductCode:       m.productCode,
+		Side:              side,
+		Price:             price,
+		Size:              remaining,
+		Commission:        commission,
+		RequireCollateral: round(price * remaining / defaultLeverage),
+		OpenDate:          formatDate(now),
+		Leverage:          defaultLeverage,
+	})
+}
+
+// childOrderHost matches child orders on the board of the server and keeps the account.
+type childOrderHost struct {
+	server *Server
+}
+
+// Take matches an order with the board, resting orders of the account are filled as makers.
+func (h *childOrderHost) Take(o *childorder.Order, now time.Time) {
+	h.server.match(h.server.markets[o.ProductCode], o.Side, o.Price, o.OutstandingSize, o, now)
+}
+
+func (h *childOrderHost) Fillable(o *childorder.Order) (bool) {
+	_, ok := h.server.estimateCost(h.server.markets[o.ProductCode], o.Side, o.Price, o.OutstandingSize)
+	return ok
+}
+
+func (h *childOrderHost) Rest(o *childorder.Order, now time.Time) {
+	m := h.server.markets[o.ProductCode]
+	bid := o.Side == types.SideBuy
+	level := m.findLevel(bid, o.Price, true)
+	level.orders = append(level.orders, o)
+	m.changed(bid, o.Price)
+}
+
+func (h *childOrderHost) Unrest(o *childorder.Order, now time.Time) {
+	m := h.server.markets[o.ProductCode]
+	bid := o.Side == types.SideBuy
+	if level := m.findLevel(bid, o.Price, false); level != nil {
+		level.removeOrder(o)
+	}
+	m.changed(bid, o.Price)
+	m.removeEmptyLevels(bid)
+}
+
+func (h *childOrderHost) CommissionRate(productCode types.ProductCode) (float64) {
+	return h.server.markets[productCode].commissionRate
+}
+
+// Settle opens or closes positions of a fx product, and exchanges the assets of a spot product.
+func (h *childOrderHost) Settle(o *childorder.Order, price float64, size float64, commission float64, maker bool, now time.Time) {
+	s := h.server
+	m := s.markets[o.ProductCode]
+	if m.spec.fx {
+		s.updatePosition(m, o.Side, price, size, commission, now)
+		return
+	}
+	base := s.account.asset(m.spec.base)
+	quote := s.account.asset(m.spec.quote)
+	if o.Side == types.SideBuy {
+		if o.ChildOrderType == types.OrderTypeLimit {
+			quote.locked = round(quote.locked - o.Price * size)
+		}
+		quote.amount = round(quote.amount - price * size)
+		base.amount = round(base.amount + size - commission)
+		s.addBalanceHistory(m, m.spec.quote, types.TradeTypeBuy, price, round(-price * size), size, 0, o.ChildOrderId, now)
+		s.addBalanceHistory(m, m.spec.base, types.TradeTypeBuy, price, size, size, commission, o.ChildOrderId, now)
+	} else {
+		if o.ChildOrderType == types.OrderTypeLimit {
+			base.locked = round(base.locked - size)
+		}
+		base.amount = round(base.amount - size)
+		quote.amount = round(quote.amount + price * (size - commission))
+		s.addBalanceHistory(m, m.spec.base, types.TradeTypeSell, price, -size, size, commission, o.ChildOrderId, now)
+		s.addBalanceHistory(m, m.spec.quote, types.TradeTypeSell, price, round(price * (size - commission)), size, 0, o.ChildOrderId, now)
+	}
+}
+
+// Release unlocks the assets locked for the outstanding size of a spot limit order.
+func (h *childOrderHost) Release(o *childorder.Order) {
+	s := h.server
+	m := s.markets[o.ProductCode]
+	if m.spec.fx || o.ChildOrderType != types.OrderTypeLimit {
+		return
+	}
+	if o.Side == types.SideBuy {
+		quote := s.account.asset(m.spec.quote)
+		quote.locked = round(quote.locked - o.Price * o.OutstandingSize)
+	} else {
+		base := s.account.asset(m.spec.base)
+		base.locked = round(base.locked - o.OutstandingSize)
+	}
+}
+
+func (h *childOrderHost) Event(event *realtime.ChildOrderEvent) {
+	h.server.account.pendingChildOrderEvents = append(h.server.account.pendingChildOrderEvents, event)
+}
+
+func (h *childOrderHost) Done(o *childorder.Order, now time.Time) {
+	h.server.account.parentOrders.ChildOrderDone(o.Parent, o, now)
+}
+
+// parentOrderHost places child orders of parent orders on the server and publishes parent order events.
+type parentOrderHost struct {
+	server *Server
+}
+
+func (h *parentOrderHost) NewChildOrder(p *parentorder.Order, index int, orderType types.OrderType, price float64, now time.Time) (parentorder.ChildOrder, string) {
+	parameter := p.Parameters[index]
+	m := h.server.markets[parameter.ProductCode]
+	minuteToExpire := int64(p.ExpireDate.Sub(now) / time.Minute)
+	if minuteToExpire <= 0 {
+		minuteToExpire = 1
+	}
+	o, _, errorMessage := h.server.newChildOrder(m, orderType, parameter.Side, price, parameter.Size, minuteToExpire, p.TimeInForce, p, index, now)
+	if o == nil {
+		return nil, errorMessage
+	}
+	o.ExpireDate = p.ExpireDate
+	return o, ""
+}
+
+func (h *parentOrderHost) ExecuteChildOrder(o parentorder.ChildOrder, now time.Time) {
+	h.server.account.childOrders.Arrive(o.(*childorder.Order), now)
+}
+
+func (h *parentOrderHost) CancelChildOrder(o parentorder.ChildOrder, state types.OrderState, now time.Time) {
+	h.server.account.childOrders.Cancel(o.(*childorder.Order), state, true, now)
+}
+
+func (h *parentOrderHost) LastTradedPrice(productCode types.ProductCode) (float64) {
+	m, ok := h.server.markets[productCode]
+	if !ok {
+		return 0
+	}
+	return m.ltp
+}
+
+func (h *parentOrderHost) Event(p *parentorder.Order, eventType types.EventType, index int, o parentorder.ChildOrder, reason string, now time.Time) {
+	event := h.server.parentOrderEvent(p, eventType, now)
+	event.Reason = reason
+	if o == nil {
+		return
+	}
+	childOrder := o.(*childorder.Order)
+	event.ParameterIndex = int64(index + 1)
+	event.ChildOrderAcceptanceId = childOrder.ChildOrderAcceptanceId
+	if eventType != types.EventTypeTrigger {
+		return
+	}
+	event.ChildOrderType = childOrder.ChildOrderType
+	event.Side = childOrder.Side
+	event.Price = childOrder.Price
+	event.Size = childOrder.Size
+	event.ExpireDate = formatDate(childOrder.ExpireDate)
+}
+
+func (s *Server) expire(now time.Time) {
+	s.account.childOrders.Expire(now)
+	s.account.parentOrders.Expire(now)
+}
+
+func (s *Server) deposit(currencyCode types.CurrencyCode, amount float64, now time.Time) {
+	ast := s.account.asset(currencyCode)
+	ast.amount = round(ast.amount + amount)
+	if currencyCode == "JPY" {
+		s.account.deposits = append(s.account.deposits, &private.GetDepositsDeposit{
+			Id:           s.nextId(),
+			OrderId:      s.newOrderId("MDP", now),
+			CurrencyCode: currencyCode,
+			Amount:       amount,
+			Status:       "COMPLETED",
+			EventDate:    formatDate(now),
+		})
+	} else {
+		address := ""
+		for _, a := range s.account.addresses {
+			if a.CurrencyCode == currencyCode {
+				address = a.Address
+			}
+		}
+		s.account.coinIns = append(s.account.coinIns, &private.GetCoinInsCoinIn{
+			Id:           s.nextId(),
+			OrderId:      s.newOrderId("CDP", now),
+			CurrencyCode: currencyCode,
+			Amount:       amount,
+			Address:      address,
+			TxHash:       randomHex(32),
+			Status:       "COMPLETED",
+			EventDate:    formatDate(now),
+		})
+	}
+	s.account.balanceHistory = append(s.account.balanceHistory, &private.GetBalanceHistoryEvent{
+		Id:           s.nextId(),
+		TradeDate:    formatDate(now),
+		CurrencyCode: currencyCode,
+		TradeType:    types.TradeTypeDeposit,
+		Amount:       types.NewDecimalFromFloat(amount),
+		Balance:      types.NewDecimalFromFloat(ast.amount),
+	})
+}
+
+// flush activates next stages of parent orders, checks triggers and publishes pending realtime messages.
+func (s *Server) flush() {
+	now := time.Now()
+	s.account.parentOrders.Flush(now)
+	for _, productCode := range s.marketOrder {
+		m := s.markets[productCode]
+		changed := false
+		if len(m.pendingExecutions) != 0 {
+			s.publish(realtime.ChannelName(types.RealtimeTypeExecutions, productCode), m.pendingExecutions)
+			m.pendingExecutions = make(public.GetExecutionsResponse, 0)
+			changed = true
+		}
+		if m.hasBoardDiff() {
+			if m.dropBoardDiffs > 0 {
+				m.boardDiff()
+				m.dropBoardDiffs -= 1
+			} else {
+				s.publish(realtime.ChannelName(types.RealtimeTypeBoard, productCode), m.boardDiff())
+			}
+			changed = true
+		}
+		if changed {
+			s.publish(realtime.ChannelName(types.RealtimeTypeTicker, productCode), m.ticker(now))
+		}
+	}
+	if len(s.account.pendingChildOrderEvents) != 0 {
+		s.publish(realtime.ChannelName(types.RealtimeTypeChildOrderEvents, ""), s.account.pendingChildOrderEvents)
+		s.account.pendingChildOrderEvents = make(realtime.ChildOrderEvents, 0)
+	}
+	if len(s.account.pendingParentOrderEvents) != 0 {
+		s.publish(realtime.ChannelName(types.RealtimeTypeParentOrderEvents, ""), s.account.pendingParentOrderEvents)
+		s.account.pendingParentOrderEvents = make(realtime.ParentOrderEvents, 0)
+	}
+}
+
+func newAccount() (*account) {
+	a := &account{
+		assets:                   make(map[types.CurrencyCode]*asset),
+		assetOrder:               make([]types.CurrencyCode, 0),
+		collateral:               1000000,
+		positions:                make(private.GetPositionsResponse, 0),
+		balanceHistory:           make(private.GetBalanceHistoryResponse, 0),
+		collateralHistory:        make(private.GetCollateralHistoryResponse, 0),
+		addresses:                private.GetAddressesResponse{
+			{ Type: "NORMAL", CurrencyCode: "BTC", Address: "3AYrDq8zhF82NJ2ZaLwBMPmaNziaKPaxa7" },
+			{ Type: "NORMAL", CurrencyCode: "ETH", Address: "0x7fbB2CC24a3C0cd3789a44e9073381Ca6470853f" },
+		},
+		bankAccounts:             private.GetBankAccountsResponse{
+			{ Id: 3402, IsVerified: true, BankName: "bitflyertest bank", BranchName: "main", AccountType: "NORMAL", AccountNumber: "1111111", AccountName: "BITFLYERTEST" },
+		},
+		coinIns:                  make(private.GetCoinInsResponse, 0),
+		coinOuts:                 make(private.GetCoinOutsResponse, 0),
+		deposits:                 make(private.GetDepositsResponse, 0),
+		withdrawals:              make(private.GetWithdrawalsResponse, 0),
+		withdrawalMessageIds:     make(map[string]int64),
+		pendingChildOrderEvents:  make(realtime.ChildOrderEvents, 0),
+		pendingParentOrderEvents: make(realtime.ParentOrderEvents, 0),
+	}
+	a.asset("JPY").amount = 10000000
+	a.asset("BTC").amount = 10
+	a.asset("ETH").amount = 100
+	a.asset("BCH").amount = 100
+	return a
+}
@@ -0,0 +1,485 @@
+package bitflyertest
+
+import (
+	"sort"
+	"time"
+	"net/http"
+	"encoding/json"
+	"github.com/potix/gobitflyer/api/types"
+	"github.com/potix/gobitflyer/api/private"
+	"github.com/potix/gobitflyer/childorder"
+	"github.com/potix/gobitflyer/parentorder"
+)
+
+func (s *Server) unrealizedPnl(position *private.GetPositionsPosition) (float64) {
+	m, ok := s.markets[position.ProductCode]
+	if !ok {
+		return 0
+	}
+	pnl := (m.ltp - position.Price) * position.Size
+	if position.Side == types.SideSell {
+		pnl = -pnl
+	}
+	return round(pnl)
+}
+
+func (s *Server) getPermissions(r *http.Request, body []byte, now time.Time) (int, interface{}) {
+	getPermissionsResponse := make(private.GetPermissionsResponse, 0)
+	for path, rt := range s.routes {
+		if rt.private {
+			getPermissionsResponse = append(getPermissionsResponse, path)
+		}
+	}
+	sort.Strings(getPermissionsResponse)
+	return http.StatusOK, getPermissionsResponse
+}
+
+func (s *Server) getBalance(r *http.Request, body []byte, now time.Time) (int, interface{}) {
+	getBalanceResponse := make(private.GetBalanceResponse, 0, len(s.account.assetOrder))
+	for _, currencyCode := range s.account.assetOrder {
+		ast := s.account.assets[currencyCode]
+		getBalanceResponse = append(getBalanceResponse, &private.GetBalanceAsset{
+			CurrencyCode: currencyCode,
+			Amount:       types.NewDecimalFromFloat(ast.amount),
+			Available:    types.NewDecimalFromFloat(ast.available()),
+		})
+	}
+	return http.StatusOK, getBalanceResponse
+}
+
+func (s *Server) getCollateral(r *http.Request, body []byte, now time.Time) (int, interface{}) {
+	getCollateralResponse := &private.GetCollateralResponse{
+		Collateral: s.account.collateral,
+	}
+	for _, position := range s.account.positions {
+		getCollateralResponse.OpenPositionPNL = round(getCollateralResponse.OpenPositionPNL + s.unrealizedPnl(position))
+		getCollateralResponse.RequireCollateral = round(getCollateralResponse.RequireCollateral + position.RequireCollateral)
+	}
+	if getCollateralResponse.RequireCollateral > 0 {
+		getCollateralResponse.KeepRate = (getCollateralResponse.Collateral + getCollateralResponse.OpenPositionPNL) / getCollateralResponse.RequireCollateral
+	}
+	return http.StatusOK, getCollateralResponse
+}
+
+func (s *Server) getCollateralAccounts(r *http.Request, body []byte, now time.Time) (int, interface{}) {
+	return http.StatusOK, private.GetCollateralAccountsResponse{
+		{ CurrencyCode: "JPY", Amount: s.account.collateral },
+		{ CurrencyCode: "BTC", Amount: 0 },
+	}
+}
+
+func (s *Server) getAddresses(r *http.Request, body []byte, now time.Time) (int, interface{}) {
+	return http.StatusOK, s.account.addresses
+}
+
+func (s *Server) getBankAccounts(r *http.Request, body []byte, now time.Time) (int, interface{}) {
+	return http.StatusOK, s.account.bankAccounts
+}
+
+func (s *Server) getCoinIns(r *http.Request, body []byte, now time.Time) (int, interface{}) {
+	coinIns := s.account.coinIns
+	indexes := paginate(len(coinIns), func(i int) (int64) {
+		return coinIns[i].Id
+	}, nil, queryPagination(r))
+	getCoinInsResponse := make(private.GetCoinInsResponse, 0, len(indexes))
+	for _, i := range indexes {
+		getCoinInsResponse = append(getCoinInsResponse, coinIns[i])
+	}
+	return http.StatusOK, getCoinInsResponse
+}
+
+func (s *Server) getCoinOuts(r *http.Request, body []byte, now time.Time) (int, interface{}) {
+	coinOuts := s.account.coinOuts
+	indexes := paginate(len(coinOuts), func(i int) (int64) {
+		return coinOuts[i].Id
+	}, nil, queryPagination(r))
+	getCoinOutsResponse := make(private.GetCoinOutsResponse, 0, len(indexes))
+	for _, i := range indexes {
+		getCoinOutsResponse = append(getCoinOutsResponse, coinOuts[i])
+	}
+	return http.StatusOK, getCoinOutsResponse
+}
+
+func (s *Server) getDeposits(r *http.Request, body []byte, now time.Time) (int, interface{}) {
+	deposits := s.account.deposits
+	indexes := paginate(len(deposits), func(i int) (int64) {
+		return deposits[i].Id
+	}, nil, queryPagination(r))
+	getDepositsResponse := make(private.GetDepositsResponse, 0, len(indexes))
+	for _, i := range indexes {
+		getDepositsResponse = append(getDepositsResponse, deposits[i])
+	}
+	return http.StatusOK, getDepositsResponse
+}
+
+func (s *Server) getWithdrawals(r *http.Request, body []byte, now time.Time) (int, interface{}) {
+	withdrawals := s.account.withdrawals
+	messageId := r.URL.Query().Get("message_id")
+	indexes := paginate(len(withdrawals), func(i int) (int64) {
+		return withdrawals[i].Id
+	}, func(i int) (bool) {
+		return messageId == "" || s.account.withdrawalMessageIds[messageId] == withdrawals[i].Id
+	}, queryPagination(r))
+	getWithdrawalsResponse := make(private.GetWithdrawalsResponse, 0, len(indexes))
+	for _, i := range indexes {
+		getWithdrawalsResponse = append(getWithdrawalsResponse, withdrawals[i])
+	}
+	return http.StatusOK, getWithdrawalsResponse
+}
+
+func (s *Server) withdraw(r *http.Request, body []byte, now time.Time) (int, interface{}) {
+	withdrawRequest := new(private.WithdrawRequest)
+	if err := json.Unmarshal(body, withdrawRequest); err != nil {
+		return badRequest(errorStatusInvalidParameter, "Invalid request")
+	}
+	if withdrawRequest.CurrencyCode != "JPY" {
+		return badRequest(errorStatusInvalidParameter, "Invalid currency code")
+	}
+	if s.authenticationCode != "" && withdrawRequest.Code != s.authenticationCode {
+		return badRequest(errorStatusInvalidCode, "Invalid authentication code")
+	}
+	found := false
+	for _, bankAccount := range s.account.bankAccounts {
+		if bankAccount.Id == withdrawRequest.BankAccountId {
+			found = true
+		}
+	}
+	if !found {
+		return badRequest(errorStatusInvalidParameter, "Invalid bank account id")
+	}
+	ast := s.account.asset("JPY")
+	if withdrawRequest.Amount <= 0 {
+		return badRequest(errorStatusInvalidParameter, "Invalid amount")
+	}
+	if ast.available() < withdrawRequest.Amount {
+		return badRequest(errorStatusInsufficientFunds, "Insufficient funds")
+	}
+	ast.amount = round(ast.amount - withdrawRequest.Amount)
+	withdrawal := &private.GetWithdrawalsWithdrawal{
+		Id:           s.nextId(),
+		OrderId:      s.newOrderId("MWD", now),
+		CurrencyCode: withdrawRequest.CurrencyCode,
+		Amount:       withdrawRequest.Amount,
+		Status:       "PENDING",
+		EventDate:    formatDate(now),
+	}
+	s.account.withdrawals = append(s.account.withdrawals, withdrawal)
+	messageId := randomHex(16)
+	s.account.withdrawalMessageIds[messageId] = withdrawal.Id
+	s.account.balanceHistory = append(s.account.balanceHistory, &private.GetBalanceHistoryEvent{
+		Id:           s.nextId(),
+		TradeDate:    formatDate(now),
+		CurrencyCode: withdrawRequest.CurrencyCode,
+		TradeType:    types.TradeTypeWithdraw,
+		Amount:       types.NewDecimalFromFloat(-withdrawRequest.Amount),
+		Balance:      types.NewDecimalFromFloat(ast.amount),
+		OrderId:      withdrawal.OrderId,
+	})
+	return http.StatusOK, &private.WithdrawResponse{
+		MessageId: messageId,
+	}
+}
+
+func (s *Server) sendChildOrder(r *http.Request, body []byte, now time.Time) (int, interface{}) {
+	sendChildOrderRequest := new(private.SendChildOrderRequest)
+	if err := json.Unmarshal(body, sendChildOrderRequest); err != nil {
+		return badRequest(errorStatusInvalidParameter, "Invalid request")
+	}
+	m, ok := s.markets[sendChildOrderRequest.ProductCode]
+	if !ok {
+		return badRequest(errorStatusInvalidParameter, "Invalid product")
+	}
+	o, status, errorMessage := s.newChildOrder(m, sendChildOrderRequest.ChildOrderType, sendChildOrderRequest.Side,
+		sendChildOrderRequest.Price.Float64(), sendChildOrderRequest.Size.Float64(), sendChildOrderRequest.MinuteToExpire,
+		sendChildOrderRequest.TimeInForce, nil, 0, now)
+	if o == nil {
+		return badRequest(status, errorMessage)
+	}
+	s.account.childOrders.Arrive(o, now)
+	return http.StatusOK, &private.SendChildOrderResponse{
+		ChildOrderAcceptanceId: o.ChildOrderAcceptanceId,
+	}
+}
+
+func (s *Server) findChildOrder(productCode types.ProductCode, childOrderId string, childOrderAcceptanceId string) (*childorder.Order) {
+	for _, o := range s.account.childOrders.Orders() {
+		if o.ProductCode != productCode {
+			continue
+		}
+		if (childOrderId != "" && o.ChildOrderId == childOrderId) ||
+		   (childOrderAcceptanceId != "" && o.ChildOrderAcceptanceId == childOrderAcceptanceId) {
+			return o
+		}
+	}
+	return nil
+}
+
+func (s *Server) cancelChildOrderHandler(r *http.Request, body []byte, now time.Time) (int, interface{}) {
+	cancelChildOrderRequest := new(private.CancelChildOrderRequest)
+	if err := json.Unmarshal(body, cancelChildOrderRequest); err != nil {
+		return badRequest(errorStatusInvalidParameter, "Invalid request")
+	}
+	// like bitFlyer, an unknown or finished order is not an error
+	o := s.findChildOrder(cancelChildOrderRequest.ProductCode, cancelChildOrderRequest.ChildOrderId, cancelChildOrderRequest.ChildOrderAcceptanceId)
+	if o != nil {
+		s.account.childOrders.Cancel(o, types.OrderStateCanceled, false, now)
+	}
+	return http.StatusOK, nil
+}
+
+func (s *Server) cancelAllChildOrders(r *http.Request, body []byte, now time.Time) (int, interface{}) {
+	cancelAllChildOrdersRequest := new(private.CancelAllChildOrdersRequest)
+	if err := json.Unmarshal(body, cancelAllChildOrdersRequest); err != nil {
+		return badRequest(errorStatusInvalidParameter, "Invalid request")
+	}
+	for _, o := range s.account.childOrders.Orders() {
+		if o.ProductCode == cancelAllChildOrdersRequest.ProductCode {
+			s.account.childOrders.Cancel(o, types.OrderStateCanceled, false, now)
+		}
+	}
+	return http.StatusOK, nil
+}
+
+func (s *Server) validateParentOrderParameter(parameter *private.SendParentOrderParameter) (int64, string) {
+	m, ok := s.markets[parameter.ProductCode]
+	if !ok {
+		return errorStatusInvalidParameter, "Invalid product"
+	}
+	if parameter.Side != types.SideBuy && parameter.Side != types.SideSell {
+		return errorStatusInvalidParameter, "Invalid side"
+	}
+	if parameter.Size < m.spec.minSize {
+		return errorStatusInvalidSize, "The minimum order size is " + formatFloat(m.spec.minSize) + " " + string(m.spec.base) + "."
+	}
+	switch parameter.ConditionType {
+	case types.ConditionTypeLimit:
+		if parameter.Price <= 0 {
+			return errorStatusInvalidPrice, "Invalid price"
+		}
+	case types.ConditionTypeMarket:
+	case types.ConditionTypeStop:
+		if parameter.TriggerPrice <= 0 {
+			return errorStatusInvalidPrice, "Invalid trigger price"
+		}
+	case types.ConditionTypeStopLimit:
+		if parameter.Price <= 0 || parameter.TriggerPrice <= 0 {
+			return errorStatusInvalidPrice, "Invalid price"
+		}
+	case types.ConditionTypeTrail:
+		if parameter.Offset <= 0 {
+			return errorStatusInvalidPrice, "Invalid offset"
+		}
+	default:
+		return errorStatusInvalidParameter, "Invalid condition type"
+	}
+	return 0, ""
+}
+
+func (s *Server) sendParentOrder(r *http.Request, body []byte, now time.Time) (int, interface{}) {
+	sendParentOrderRequest := new(private.SendParentOrderRequest)
+	if err := json.Unmarshal(body, sendParentOrderRequest); err != nil {
+		return badRequest(errorStatusInvalidParameter, "Invalid request")
+	}
+	stages := parentorder.Stages(sendParentOrderRequest.OrderMethod)
+	if stages == nil {
+		return badRequest(errorStatusInvalidParameter, "Invalid order method")
+	}
+	count := 0
+	for _, stage := range stages {
+		count += len(stage)
+	}
+	if len(sendParentOrderRequest.Parameters) != count {
+		return badRequest(errorStatusInvalidParameter, "Invalid number of parameters")
+	}
+	for _, parameter := range sendParentOrderRequest.Parameters {
+		if status, errorMessage := s.validateParentOrderParameter(parameter); status != 0 {
+			return badRequest(status, errorMessage)
+		}
+	}
+	p := parentorder.NewOrder(s.nextId(), s.newOrderId("JCO", now), s.newOrderId("JRF", now), sendParentOrderRequest.OrderMethod,
+		sendParentOrderRequest.MinuteToExpire, sendParentOrderRequest.TimeInForce, sendParentOrderRequest.Parameters, now)
+	s.account.parentOrders.Add(p)
+	event := s.parentOrderEvent(p, types.EventTypeOrder, now)
+	event.ParentOrderType = p.ParentOrderType()
+	event.ExpireDate = formatDate(p.ExpireDate)
+	s.account.parentOrders.Accept(p)
+	return http.StatusOK, &private.SendParentOrderResponse{
+		ParentOrderAcceptanceId: p.ParentOrderAcceptanceId,
+	}
+}
+
+func (s *Server) cancelParentOrderHandler(r *http.Request, body []byte, now time.Time) (int, interface{}) {
+	cancelParentOrderRequest := new(private.CancelParentOrderRequest)
+	if err := json.Unmarshal(body, cancelParentOrderRequest); err != nil {
+		return badRequest(errorStatusInvalidParameter, "Invalid request")
+	}
+	p := s.account.parentOrders.Find(cancelParentOrderRequest.ParentOrderId, cancelParentOrderRequest.ParentOrderAcceptanceId)
+	if p != nil && p.ProductCode() == cancelParentOrderRequest.ProductCode {
+		s.account.parentOrders.Finish(p, types.OrderStateCanceled, "", now)
+	}
+	return http.StatusOK, nil
+}
+
+func (s *Server) getChildOrders(r *http.Request, body []byte, now time.Time) (int, interface{}) {
+	query := r.URL.Query()
+	productCode := queryProductCode(r)
+	childOrderState := types.OrderState(query.Get("child_order_state"))
+	childOrderId := query.Get("child_order_id")
+	childOrderAcceptanceId := query.Get("child_order_acceptance_id")
+	parentOrderId := query.Get("parent_order_id")
+	childOrders := s.account.childOrders.Orders()
+	indexes := paginate(len(childOrders), func(i int) (int64) {
+		return childOrders[i].Id
+	}, func(i int) (bool) {
+		o := childOrders[i]
+		if o.ProductCode != productCode {
+			return false
+		}
+		if childOrderState != types.OrderStateNone && o.State() != childOrderState {
+			return false
+		}
+		if childOrderId != "" && o.ChildOrderId != childOrderId {
+			return false
+		}
+		if childOrderAcceptanceId != "" && o.ChildOrderAcceptanceId != childOrderAcceptanceId {
+			return false
+		}
+		if parentOrderId != "" && (o.Parent == nil || o.Parent.ParentOrderId != parentOrderId) {
+			return false
+		}
+		return true
+	}, queryPagination(r))
+	getChildOrdersResponse := make(private.GetChildOrdersResponse, 0, len(indexes))
+	for _, i := range indexes {
+		getChildOrdersResponse = append(getChildOrdersResponse, childOrders[i].Response())
+	}
+	return http.StatusOK, getChildOrdersResponse
+}
+
+func (s *Server) getParentOrders(r *http.Request, body []byte, now time.Time) (int, interface{}) {
+	productCode := queryProductCode(r)
+	parentOrderState := types.OrderState(r.URL.Query().Get("parent_order_state"))
+	parentOrders := s.account.parentOrders.Orders()
+	indexes := paginate(len(parentOrders), func(i int) (int64) {
+		return parentOrders[i].Id
+	}, func(i int) (bool) {
+		p := parentOrders[i]
+		return p.ProductCode() == productCode && (parentOrderState == types.OrderStateNone || p.State == parentOrderState)
+	}, queryPagination(r))
+	getParentOrdersResponse := make(private.GetParentOrdersResponse, 0, len(indexes))
+	for _, i := range indexes {
+		getParentOrdersResponse = append(getParentOrdersResponse, parentOrders[i].Response())
+	}
+	return http.StatusOK, getParentOrdersResponse
+}
+
+func (s *Server) getParentOrder(r *http.Request, body []byte, now time.Time) (int, interface{}) {
+	query := r.URL.Query()
+	p := s.account.parentOrders.Find(query.Get("parent_order_id"), query.Get("parent_order_acceptance_id"))
+	if p == nil {
+		return badRequest(errorStatusOrderNotFound, "Order not found")
+	}
+	return http.StatusOK, p.Detail()
+}
+
+func (s *Server) getAccountExecutions(r *http.Request, body []byte, now time.Time) (int, interface{}) {
+	query := r.URL.Query()
+	productCode := queryProductCode(r)
+	childOrderId := query.Get("child_order_id")
+	childOrderAcceptanceId := query.Get("child_order_acceptance_id")
+	executions := s.account.childOrders.Executions()
+	indexes := paginate(len(executions), func(i int) (int64) {
+		return executions[i].Execution.Id
+	}, func(i int) (bool) {
+		e := executions[i]
+		return e.ProductCode == productCode &&
+		       (childOrderId == "" || e.Execution.ChildOrderId == childOrderId) &&
+		       (childOrderAcceptanceId == "" || e.Execution.ChildOrderAcceptanceId == childOrderAcceptanceId)
+	}, queryPagination(r))
+	getExecutionsResponse := make(private.GetExecutionsResponse, 0, len(indexes))
+	for _, i := range indexes {
+		getExecutionsResponse = append(getExecutionsResponse, executions[i].Execution)
+	}
+	return http.StatusOK, getExecutionsResponse
+}
+
+func (s *Server) getBalanceHistory(r *http.Request, body []byte, now time.Time) (int, interface{}) {
+	currencyCode := types.CurrencyCode(r.URL.Query().Get("currency_code"))
+	if currencyCode == "" {
+		currencyCode = "JPY"
+	}
+	balanceHistory := s.account.balanceHistory
+	indexes := paginate(len(balanceHistory), func(i int) (int64) {
+		return balanceHistory[i].Id
+	}, func(i int) (bool) {
+		return balanceHistory[i].CurrencyCode == currencyCode
+	}, queryPagination(r))
+	getBalanceHistoryResponse := make(private.GetBalanceHistoryResponse, 0, len(indexes))
+	for _, i := range indexes {
+		getBalanceHistoryResponse = append(getBalanceHistoryResponse, balanceHistory[i])
+	}
+	return http.StatusOK, getBalanceHistoryResponse
+}
+
+func (s *Server) getPositions(r *http.Request, body []byte, now time.Time) (int, interface{}) {
+	productCode := queryProductCode(r)
+	getPositionsResponse := make(private.GetPositionsResponse, 0)
+	for _, position := range s.account.positions {
+		if position.ProductCode != productCode {
+			continue
+		}
+		p := *position
+		p.Pnl = s.unrealizedPnl(position)
+		getPositionsResponse = append(getPositionsResponse, &p)
+	}
+	return http.StatusOK, getPositionsResponse
+}
+
+func (s *Server) getCollateralHistory(r *http.Request, body []byte, now time.Time) (int, interface{}) {
+	collateralHistory := s.account.collateralHistory
+	indexes := paginate(len(collateralHistory), func(i int) (int64) {
+		return collateralHistory[i].Id
+	}, nil, queryPagination(r))
+	getCollateralHistoryResponse := make(private.GetCollateralHistoryResponse, 0, len(indexes))
+	for _, i := range indexes {
+		getCollateralHistoryResponse = append(getCollateralHistoryResponse, collateralHistory[i])
+	}
+	return http.StatusOK, getCollateralHistoryResponse
+}
+
+func (s *Server) getTradingCommission(r *http.Request, body []byte, now time.Time) (int, interface{}) {
+	m, statusCode, response := s.market(r)
+	if m == nil {
+		return statusCode, response
+	}
+	return http.StatusOK, &private.GetTradingCommissionResponse{
+		CommissionRate: m.commissionRate,
+	}
+}
+
+func (s *Server) addPrivateRoutes() {
+	s.addRoute("/v1/me/getpermissions", "GET", true, s.getPermissions)
+	s.addRoute("/v1/me/getbalance", "GET", true, s.getBalance)
+	s.addRoute("/v1/me/getcollateral", "GET", true, s.getCollateral)
+	s.addRoute("/v1/me/getcollateralaccounts", "GET", true, s.getCollateralAccounts)
+	s.addRoute("/v1/me/getaddresses", "GET", true, s.getAddresses)
+	s.addRoute("/v1/me/getcoinins", "GET", true, s.getCoinIns)
+	s.addRoute("/v1/me/getcoinouts", "GET", true, s.getCoinOuts)
+	s.addRoute("/v1/me/getbankaccounts", "GET", true, s.getBankAccounts)
+	s.addRoute("/v1/me/getdeposits", "GET", true, s.getDeposits)
+	s.addRoute("/v1/me/withdraw", "POST", true, s.withdraw)
+	s.addRoute("/v1/me/getwithdrawals", "GET", true, s.getWithdrawals)
+	s.addRoute("/v1/me/sendchildorder", "POST", true, s.sendChildOrder)
+	s.addRoute("/v1/me/cancelchildorder", "POST", true, s.cancelChildOrderHandler)
+	s.addRoute("/v1/me/sendparentorder", "POST", true, s.sendParentOrder)
+	s.addRoute("/v1/me/cancelparentorder", "POST", true, s.cancelParentOrderHandler)
+	s.addRoute("/v1/me/cancelallchildorders", "POST", true, s.cancelAllChildOrders)
+	s.addRoute("/v1/me/getchildorders", "GET", true, s.getChildOrders)
+	s.addRoute("/v1/me/getparentorders", "GET", true, s.getParentOrders)
+	s.addRoute("/v1/me/getparentorder", "GET", true, s.getParentOrder)
+	s.addRoute("/v1/me/getexecutions", "GET", true, s.getAccountExecutions)
+	s.addRoute("/v1/me/getbalancehistory", "GET", true, s.getBalanceHistory)
+	s.addRoute("/v1/me/getpositions", "GET", true, s.getPositions)
+	s.addRoute("/v1/me/getcollateralhistory", "GET", true, s.getCollateralHistory)
+	s.addRoute("/v1/me/gettradingcommission", "GET", true, s.getTradingCommission)
+}
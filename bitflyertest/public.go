@@ -0,0 +1,103 @@
+package bitflyertest
+
+import (
+	"time"
+	"net/http"
+	"github.com/potix/gobitflyer/api/public"
+)
+
+func (s *Server) market(r *http.Request) (*market, int, interface{}) {
+	m, ok := s.markets[queryProductCode(r)]
+	if !ok {
+		statusCode, response := badRequest(errorStatusInvalidParameter, "Invalid product")
+		return nil, statusCode, response
+	}
+	return m, 0, nil
+}
+
+func (s *Server) getMarkets(r *http.Request, body []byte, now time.Time) (int, interface{}) {
+	getMarketsResponse := make(public.GetMarketsResponse, 0, len(s.marketOrder))
+	for _, productCode := range s.marketOrder {
+		getMarketsResponse = append(getMarketsResponse, &public.GetMarketsMarket{
+			ProductCode: productCode,
+		})
+	}
+	return http.StatusOK, getMarketsResponse
+}
+
+func (s *Server) getBoard(r *http.Request, body []byte, now time.Time) (int, interface{}) {
+	m, statusCode, response := s.market(r)
+	if m == nil {
+		return statusCode, response
+	}
+	return http.StatusOK, m.board()
+}
+
+func (s *Server) getTicker(r *http.Request, body []byte, now time.Time) (int, interface{}) {
+	m, statusCode, response := s.market(r)
+	if m == nil {
+		return statusCode, response
+	}
+	return http.StatusOK, m.ticker(now)
+}
+
+func (s *Server) getExecutions(r *http.Request, body []byte, now time.Time) (int, interface{}) {
+	m, statusCode, response := s.market(r)
+	if m == nil {
+		return statusCode, response
+	}
+	indexes := paginate(len(m.executions), func(i int) (int64) {
+		return m.executions[i].Id
+	}, nil, queryPagination(r))
+	getExecutionsResponse := make(public.GetExecutionsResponse, 0, len(indexes))
+	for _, i := range indexes {
+		getExecutionsResponse = append(getExecutionsResponse, m.executions[i])
+	}
+	return http.StatusOK, getExecutionsResponse
+}
+
+func (s *Server) getBoardState(r *http.Request, body []byte, now time.Time) (int, interface{}) {
+	m, statusCode, response := s.market(r)
+	if m == nil {
+		return statusCode, response
+	}
+	return http.StatusOK, &public.GetBoardStateResponse{
+		Health: m.health,
+		State:  m.state,
+		Data:   &public.GetBoardStateData{
+			SpecialQuotation: int64(m.ltp),
+		},
+	}
+}
+
+func (s *Server) getHealth(r *http.Request, body []byte, now time.Time) (int, interface{}) {
+	m, statusCode, response := s.market(r)
+	if m == nil {
+		return statusCode, response
+	}
+	return http.StatusOK, &public.GetHealthResponse{
+		Status: m.health,
+	}
+}
+
+func (s *Server) getChats(r *http.Request, body []byte, now time.Time) (int, interface{}) {
+	fromDate := time.Unix(queryInt(r, "from_date"), 0)
+	getChatsResponse := make(public.GetChatsResponse, 0, len(s.chats))
+	for _, chat := range s.chats {
+		if chat.Date.Before(fromDate) {
+			continue
+		}
+		getChatsResponse = append(getChatsResponse, chat)
+	}
+	return http.StatusOK, getChatsResponse
+}
+
+func (s *Server) addPublicRoutes() {
+	s.addRoute("/v1/getmarkets", "GET", false, s.getMarkets)
+	s.addRoute("/v1/getboard", "GET", false, s.getBoard)
+	s.addRoute("/v1/getticker", "GET", false, s.getTicker)
+	s.addRoute("/v1/getexecutions", "GET", false, s.getExecutions)
+	s.addRoute("/v1/getboardstate", "GET", false, s.getBoardState)
+	s.addRoute("/v1/gethealth", "GET", false, s.getHealth)
+	s.addRoute("/v1/getchats", "GET", false, s.getChats)
+}
@@ -0,0 +1,396 @@
+package bitflyertest
+
+import (
+	"sync"
+	"strings"
+	"time"
+	"strconv"
+	"net/http"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+	"github.com/potix/gobitflyer/api/types"
+	"github.com/potix/gobitflyer/api/realtime"
+)
+
+const (
+	wsSendBufferSize int           = 1024
+	wsWriteTimeout   time.Duration = 10 * time.Second
+)
+
+const (
+	socketIOOpen        string = `0{"sid":"bitflyertest","upgrades":[],"pingInterval":25000,"pingTimeout":5000}`
+	socketIOConnect     string = "40"
+	socketIOPing        string = "2"
+	socketIOPong        string = "3"
+	socketIOEventPrefix string = "42"
+	socketIOAckPrefix   string = "43"
+)
+
+const (
+	jsonRPC2ErrorInvalidParams  int64 = -32602
+	jsonRPC2ErrorMethodNotFound int64 = -32601
+	jsonRPC2ErrorUnauthorized   int64 = -32000
+)
+
+type jsonRPC2Request struct {
+	JsonRpc string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+	Id      json.RawMessage `json:"id"`
+}
+
+type jsonRPC2Response struct {
+	JsonRpc string                  `json:"jsonrpc"`
+	Id      json.RawMessage         `json:"id"`
+	Result  interface{}             `json:"result,omitempty"`
+	Error   *realtime.JsonRPC2Error `json:"error,omitempty"`
+}
+
+type jsonRPC2Notify struct {
+	JsonRpc string                         `json:"jsonrpc"`
+	Method  string                         `json:"method"`
+	Params  *realtime.JsonRPC2NotifyParams `json:"params"`
+}
+
+type wsConn struct {
+	conn          *websocket.Conn
+	sendChan      chan []byte
+	channels      map[string]bool
+	authenticated bool
+	socketIO      bool
+	closeOnce     *sync.Once
+	finishChan    chan int
+}
+
+func (wc *wsConn) send(message []byte) (bool) {
+	select {
+	case wc.sendChan <- message:
+		return true
+	default:
+		return false
+	}
+}
+
+func (wc *wsConn) close() {
+	wc.closeOnce.Do(func() {
+		close(wc.finishChan)
+		wc.conn.Close()
+	})
+}
+
+func (wc *wsConn) writeLoop() {
+	for {
+		select {
+		case <-wc.finishChan:
+			return
+		case message := <-wc.sendChan:
+			wc.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+			err := wc.conn.WriteMessage(websocket.TextMessage, message)
+			if err != nil {
+				wc.close()
+				return
+			}
+		}
+	}
+}
+
+func isPrivateChannel(channel string) (bool) {
+	return channel == realtime.ChannelName(types.RealtimeTypeChildOrderEvents, "") ||
+	       channel == realtime.ChannelName(types.RealtimeTypeParentOrderEvents, "")
+}
+
+func (s *Server) notifyMessage(socketIO bool, channel string, message interface{}) ([]byte, bool) {
+	rawMessage, err := json.Marshal(message)
+	if err != nil {
+		return nil, false
+	}
+	if socketIO {
+		event, err := json.Marshal([]interface{}{channel, json.RawMessage(rawMessage)})
+		if err != nil {
+			return nil, false
+		}
+		return append([]byte(socketIOEventPrefix), event...), true
+	}
+	notify, err := json.Marshal(&jsonRPC2Notify{
+		JsonRpc: "2.0",
+		Method:  "channelMessage",
+		Params:  &realtime.JsonRPC2NotifyParams{
+			Channel: channel,
+			Message: rawMessage,
+		},
+	})
+	if err != nil {
+		return nil, false
+	}
+	return notify, true
+}
+
+// publish sends a message to subscribers of the channel. A subscriber which can not keep up is disconnected.
+func (s *Server) publish(channel string, message interface{}) {
+	// notifications by protocol, json-rpc and socket.io
+	notifies := make(map[bool][]byte)
+	for wc := range s.wsConns {
+		if !wc.channels[channel] || (isPrivateChannel(channel) && !wc.authenticated) {
+			continue
+		}
+		notify, ok := notifies[wc.socketIO]
+		if !ok {
+			notify, ok = s.notifyMessage(wc.socketIO, channel, message)
+			if !ok {
+				return
+			}
+			notifies[wc.socketIO] = notify
+		}
+		if !wc.send(notify) {
+			wc.close()
+		}
+	}
+}
+
+func (s *Server) reply(wc *wsConn, request *jsonRPC2Request, result interface{}, code int64, message string) {
+	if len(request.Id) == 0 || string(request.Id) == "null" {
+		// notification, no response
+		return
+	}
+	if wc.socketIO {
+		s.replySocketIO(wc, request, code, message)
+		return
+	}
+	response := &jsonRPC2Response{
+		JsonRpc: "2.0",
+		Id:      request.Id,
+	}
+	if code != 0 {
+		response.Error = &realtime.JsonRPC2Error{
+			Code:    code,
+			Message: message,
+		}
+	} else {
+		response.Result = result
+	}
+	body, err := json.Marshal(response)
+	if err != nil {
+		return
+	}
+	if !wc.send(body) {
+		wc.close()
+	}
+}
+
+// replySocketIO sends an ack whose first argument is an error, null on success.
+func (s *Server) replySocketIO(wc *wsConn, request *jsonRPC2Request, code int64, message string) {
+	var ackError *realtime.JsonRPC2Error
+	if code != 0 {
+		ackError = &realtime.JsonRPC2Error{
+			Code:    code,
+			Message: message,
+		}
+	}
+	args, err := json.Marshal([]interface{}{ackError})
+	if err != nil {
+		return
+	}
+	if !wc.send(append([]byte(socketIOAckPrefix + string(request.Id)), args...)) {
+		wc.close()
+	}
+}
+
+// parseSocketIOEvent converts an event of socket.io (e.g. 42["subscribe","channel"]) to a json-rpc request.
+func parseSocketIOEvent(message []byte) (*jsonRPC2Request, bool) {
+	if !strings.HasPrefix(string(message), socketIOEventPrefix) {
+		return nil, false
+	}
+	data := message[len(socketIOEventPrefix):]
+	i := 0
+	for i < len(data) && data[i] >= '0' && data[i] <= '9' {
+		i += 1
+	}
+	request := &jsonRPC2Request{
+		JsonRpc: "2.0",
+	}
+	if i > 0 {
+		request.Id = json.RawMessage(data[:i])
+	}
+	args := make([]json.RawMessage, 0, 2)
+	if err := json.Unmarshal(data[i:], &args); err != nil || len(args) < 2 {
+		return nil, false
+	}
+	if err := json.Unmarshal(args[0], &request.Method); err != nil {
+		return nil, false
+	}
+	var channel string
+	if err := json.Unmarshal(args[1], &channel); err == nil {
+		// subscribe and unsubscribe take a channel name
+		params, err := json.Marshal(&realtime.JsonRPC2SubscribeParams{Channel: channel})
+		if err != nil {
+			return nil, false
+		}
+		request.Params = params
+	} else {
+		request.Params = args[1]
+	}
+	return request, true
+}
+
+func (s *Server) verifyRealtimeAuth(params *realtime.JsonRPC2AuthParams) (bool) {
+	if params.ApiKey != s.apiKey {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(s.apiSecret))
+	mac.Write([]byte(strconv.FormatInt(params.Timestamp, 10)))
+	mac.Write([]byte(params.Nonce))
+	expected := hex.EncodeToString(mac.Sum(nil))
+	return hmac.Equal([]byte(expected), []byte(params.Signature))
+}
+
+// notifySubscribe wakes up WaitSubscribed, the mutex must be held.
+func (s *Server) notifySubscribe() {
+	close(s.subscribeChan)
+	s.subscribeChan = make(chan int)
+}
+
+func (s *Server) subscribing(channel string) (bool) {
+	for wc := range s.wsConns {
+		if wc.channels[channel] {
+			return true
+		}
+	}
+	return false
+}
+
+// WaitSubscribed waits until every channel is subscribed by a connection (subscribed is true) or by no
+// connection (subscribed is false), or returns an error after timeout.
+func (s *Server) WaitSubscribed(subscribed bool, timeout time.Duration, channels ...string) (error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		s.mutex.Lock()
+		done := true
+		for _, channel := range channels {
+			if s.subscribing(channel) != subscribed {
+				done = false
+				break
+			}
+		}
+		subscribeChan := s.subscribeChan
+		s.mutex.Unlock()
+		if done {
+			return nil
+		}
+		select {
+		case <-subscribeChan:
+		case <-timer.C:
+			return errors.Errorf("timeout of waiting for subscription (channels = %v, subscribed = %v)", channels, subscribed)
+		}
+	}
+}
+
+func (s *Server) handleRealtimeRequest(wc *wsConn, request *jsonRPC2Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	switch request.Method {
+	case "auth":
+		params := new(realtime.JsonRPC2AuthParams)
+		if err := json.Unmarshal(request.Params, params); err != nil {
+			s.reply(wc, request, nil, jsonRPC2ErrorInvalidParams, "Invalid params")
+			return
+		}
+		if !s.verifyRealtimeAuth(params) {
+			s.reply(wc, request, nil, jsonRPC2ErrorUnauthorized, "Invalid signature")
+			return
+		}
+		wc.authenticated = true
+		s.reply(wc, request, true, 0, "")
+	case "subscribe", "unsubscribe":
+		params := new(realtime.JsonRPC2SubscribeParams)
+		if err := json.Unmarshal(request.Params, params); err != nil || params.Channel == "" {
+			s.reply(wc, request, nil, jsonRPC2ErrorInvalidParams, "Invalid params")
+			return
+		}
+		if request.Method == "unsubscribe" {
+			delete(wc.channels, params.Channel)
+			s.notifySubscribe()
+			s.reply(wc, request, true, 0, "")
+			return
+		}
+		if isPrivateChannel(params.Channel) && !wc.authenticated {
+			s.reply(wc, request, nil, jsonRPC2ErrorUnauthorized, "Not authenticated")
+			return
+		}
+		wc.channels[params.Channel] = true
+		s.notifySubscribe()
+		s.reply(wc, request, true, 0, "")
+		for productCode, m := range s.markets {
+			if params.Channel == realtime.ChannelName(types.RealtimeTypeBoardSnapshot, productCode) && !m.dropBoardSnapshot() {
+				if notify, ok := s.notifyMessage(wc.socketIO, params.Channel, m.board()); ok && !wc.send(notify) {
+					wc.close()
+				}
+			}
+		}
+	default:
+		s.reply(wc, request, nil, jsonRPC2ErrorMethodNotFound, "Method not found")
+	}
+}
+
+func (s *Server) serveRealtime(w http.ResponseWriter, r *http.Request, socketIO bool) {
+	upgrader := &websocket.Upgrader{
+		CheckOrigin: func(r *http.Request) bool {
+			return true
+		},
+	}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	wc := &wsConn{
+		conn:       conn,
+		sendChan:   make(chan []byte, wsSendBufferSize),
+		channels:   make(map[string]bool),
+		socketIO:   socketIO,
+		closeOnce:  new(sync.Once),
+		finishChan: make(chan int),
+	}
+	s.mutex.Lock()
+	s.wsConns[wc] = true
+	s.mutex.Unlock()
+	defer func() {
+		s.mutex.Lock()
+		delete(s.wsConns, wc)
+		s.notifySubscribe()
+		s.mutex.Unlock()
+		wc.close()
+	}()
+	go wc.writeLoop()
+	if socketIO {
+		wc.send([]byte(socketIOOpen))
+		wc.send([]byte(socketIOConnect))
+	}
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if socketIO {
+			if string(message) == socketIOPing {
+				wc.send([]byte(socketIOPong))
+				continue
+			}
+			request, ok := parseSocketIOEvent(message)
+			if !ok {
+				continue
+			}
+			s.handleRealtimeRequest(wc, request)
+			continue
+		}
+		request := new(jsonRPC2Request)
+		if err := json.Unmarshal(message, request); err != nil {
+			// the id is unknown, so no response can be sent
+			continue
+		}
+		s.handleRealtimeRequest(wc, request)
+	}
+}
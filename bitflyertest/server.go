@@ -0,0 +1,455 @@
+package bitflyertest
+
+import (
+	"fmt"
+	"sync"
+	"time"
+	"strconv"
+	"strings"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"github.com/potix/gobitflyer/api/types"
+	"github.com/potix/gobitflyer/api/public"
+	"github.com/potix/gobitflyer/api/realtime"
+	"github.com/potix/gobitflyer/childorder"
+	"github.com/potix/gobitflyer/parentorder"
+)
+
+const (
+	DefaultAPIKey             string = "bitflyertest-api-key"
+	DefaultAPISecret          string = "bitflyertest-api-secret"
+	DefaultAuthenticationCode string = "012345"
+	DefaultPublishInterval    time.Duration = time.Second
+)
+
+const (
+	realtimePath string = "/json-rpc"
+	socketIOPath string = "/socket.io/"
+)
+
+const (
+	errorStatusInvalidParameter int64 = -100
+	errorStatusInvalidSize      int64 = -110
+	errorStatusInvalidPrice     int64 = -111
+	errorStatusInsufficientFunds int64 = -200
+	errorStatusOrderNotFound    int64 = -300
+	errorStatusInvalidCode      int64 = -400
+	errorStatusInvalidSignature int64 = -500
+)
+
+type errorResponse struct {
+	Status       int64       `json:"status"`
+	ErrorMessage string      `json:"error_message"`
+	Data         interface{} `json:"data"`
+}
+
+type handler func(r *http.Request, body []byte, now time.Time) (int, interface{})
+
+type route struct {
+	method  string
+	private bool
+	handler handler
+}
+
+// Server emulates bitFlyer Lightning HTTP API and Realtime API (JSON-RPC 2.0 over WebSocket).
+// All state (markets, account, orders) is kept in memory.
+type Server struct {
+	apiKey             string
+	apiSecret          string
+	authenticationCode string
+	httpServer         *httptest.Server
+	routes             map[string]*route
+	mutex              *sync.Mutex
+	markets            map[types.ProductCode]*market
+	marketOrder        []types.ProductCode
+	account            *account
+	chats              public.GetChatsResponse
+	lastId             int64
+	wsConns            map[*wsConn]bool
+	// closed and replaced whenever subscriptions change
+	subscribeChan      chan int
+	publishInterval    time.Duration
+	finishChan         chan int
+	finishOnce         *sync.Once
+	finishWait         *sync.WaitGroup
+}
+
+func (s *Server) URL() (string) {
+	return s.httpServer.URL
+}
+
+func (s *Server) RealtimeURL() (string) {
+	return "ws" + strings.TrimPrefix(s.httpServer.URL, "http") + realtimePath
+}
+
+// SocketIOURL returns the url of Socket.IO v2 endpoint of Realtime API.
+func (s *Server) SocketIOURL() (string) {
+	return "ws" + strings.TrimPrefix(s.httpServer.URL, "http") + socketIOPath + "?EIO=3&transport=websocket"
+}
+
+func (s *Server) APIKey() (string) {
+	return s.apiKey
+}
+
+func (s *Server) APISecret() (string) {
+	return s.apiSecret
+}
+
+func (s *Server) AuthenticationCode() (string) {
+	return s.authenticationCode
+}
+
+func (s *Server) SetPublishInterval(publishInterval time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.publishInterval = publishInterval
+}
+
+// SetBoard replaces the liquidity of other participants. Resting orders of the account are kept.
+func (s *Server) SetBoard(productCode types.ProductCode, bids []*public.GetBoardBook, asks []*public.GetBoardBook) (error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	m, ok := s.markets[productCode]
+	if !ok {
+		return errors.Errorf("unknown product code (product code = %v)", productCode)
+	}
+	m.setBoard(bids, asks)
+	s.flush()
+	return nil
+}
+
+// Execute emulates an order of another participant which takes the board up to price (0 means market order).
+// Resting orders of the account have priority over other liquidity at the same price.
+func (s *Server) Execute(productCode types.ProductCode, side types.Side, price float64, size float64) (error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	m, ok := s.markets[productCode]
+	if !ok {
+		return errors.Errorf("unknown product code (product code = %v)", productCode)
+	}
+	if side != types.SideBuy && side != types.SideSell {
+		return errors.Errorf("invalid side (side = %v)", side)
+	}
+	if size <= 0 {
+		return errors.Errorf("invalid size (size = %v)", size)
+	}
+	now := time.Now()
+	s.expire(now)
+	s.match(m, side, price, size, nil, now)
+	s.flush()
+	return nil
+}
+
+// DropBoardDiffs discards the next count board diffs of productCode to emulate lost messages.
+func (s *Server) DropBoardDiffs(productCode types.ProductCode, count int) (error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	m, ok := s.markets[productCode]
+	if !ok {
+		return errors.Errorf("unknown product code (product code = %v)", productCode)
+	}
+	m.dropBoardDiffs = count
+	return nil
+}
+
+// DropBoardSnapshots discards the next count board snapshots of productCode, including the ones sent on subscribe.
+func (s *Server) DropBoardSnapshots(productCode types.ProductCode, count int) (error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	m, ok := s.markets[productCode]
+	if !ok {
+		return errors.Errorf("unknown product code (product code = %v)", productCode)
+	}
+	m.dropBoardSnapshots = count
+	return nil
+}
+
+func (s *Server) SetBalance(currencyCode types.CurrencyCode, amount float64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.account.asset(currencyCode).amount = amount
+}
+
+// Deposit credits the account and records a deposit (JPY) or a coin in (others).
+func (s *Server) Deposit(currencyCode types.CurrencyCode, amount float64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.deposit(currencyCode, amount, time.Now())
+}
+
+func (s *Server) SetCollateral(amount float64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.account.collateral = amount
+}
+
+func (s *Server) SetCommissionRate(productCode types.ProductCode, commissionRate float64) (error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	m, ok := s.markets[productCode]
+	if !ok {
+		return errors.Errorf("unknown product code (product code = %v)", productCode)
+	}
+	m.commissionRate = commissionRate
+	return nil
+}
+
+func (s *Server) SetBoardState(productCode types.ProductCode, health string, state string) (error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	m, ok := s.markets[productCode]
+	if !ok {
+		return errors.Errorf("unknown product code (product code = %v)", productCode)
+	}
+	m.health = health
+	m.state = state
+	return nil
+}
+
+func (s *Server) AddChat(nickname string, message string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.chats = append(s.chats, &public.GetChatsChat{
+		Nickname: nickname,
+		Message:  message,
+		Date:     formatDate(time.Now()),
+	})
+}
+
+func (s *Server) Close() {
+	s.finishOnce.Do(func() {
+		close(s.finishChan)
+		s.finishWait.Wait()
+		s.mutex.Lock()
+		for wc := range s.wsConns {
+			wc.close()
+		}
+		s.mutex.Unlock()
+		s.httpServer.CloseClientConnections()
+		s.httpServer.Close()
+	})
+}
+
+func (s *Server) nextId() (int64) {
+	s.lastId += 1
+	return s.lastId
+}
+
+func (s *Server) newOrderId(prefix string, now time.Time) (string) {
+	return fmt.Sprintf("%v%v-%06d", prefix, now.UTC().Format("20060102-150405"), s.nextId())
+}
+
+func (s *Server) verifySign(r *http.Request, body []byte) (bool) {
+	apiKey := r.Header.Get("ACCESS-KEY")
+	timestamp := r.Header.Get("ACCESS-TIMESTAMP")
+	sign := r.Header.Get("ACCESS-SIGN")
+	if apiKey != s.apiKey || timestamp == "" || sign == "" {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(s.apiSecret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte(r.Method))
+	mac.Write([]byte(r.RequestURI))
+	mac.Write(body)
+	expected := hex.EncodeToString(mac.Sum(nil))
+	return hmac.Equal([]byte(expected), []byte(sign))
+}
+
+func (s *Server) writeJSON(w http.ResponseWriter, statusCode int, response interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(statusCode)
+	if response == nil {
+		return
+	}
+	body, err := json.Marshal(response)
+	if err != nil {
+		return
+	}
+	w.Write(body)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == realtimePath {
+		s.serveRealtime(w, r, false)
+		return
+	}
+	if r.URL.Path == socketIOPath {
+		s.serveRealtime(w, r, true)
+		return
+	}
+	rt, ok := s.routes[r.URL.Path]
+	if !ok {
+		s.writeJSON(w, http.StatusNotFound, newErrorResponse(errorStatusInvalidParameter, "Not found"))
+		return
+	}
+	if r.Method != rt.method {
+		s.writeJSON(w, http.StatusMethodNotAllowed, newErrorResponse(errorStatusInvalidParameter, "Method not allowed"))
+		return
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		s.writeJSON(w, http.StatusBadRequest, newErrorResponse(errorStatusInvalidParameter, "Can not read body"))
+		return
+	}
+	if rt.private && !s.verifySign(r, body) {
+		s.writeJSON(w, http.StatusUnauthorized, newErrorResponse(errorStatusInvalidSignature, "Invalid signature"))
+		return
+	}
+	now := time.Now()
+	s.mutex.Lock()
+	s.expire(now)
+	statusCode, response := rt.handler(r, body, now)
+	s.flush()
+	s.mutex.Unlock()
+	s.writeJSON(w, statusCode, response)
+}
+
+func (s *Server) publishLoop() {
+	defer s.finishWait.Done()
+	for {
+		s.mutex.Lock()
+		publishInterval := s.publishInterval
+		s.mutex.Unlock()
+		select {
+		case <-s.finishChan:
+			return
+		case <-time.After(publishInterval):
+			s.mutex.Lock()
+			now := time.Now()
+			s.expire(now)
+			for _, productCode := range s.marketOrder {
+				m := s.markets[productCode]
+				s.publish(realtime.ChannelName(types.RealtimeTypeTicker, productCode), m.ticker(now))
+				if !m.dropBoardSnapshot() {
+					s.publish(realtime.ChannelName(types.RealtimeTypeBoardSnapshot, productCode), m.board())
+				}
+			}
+			s.flush()
+			s.mutex.Unlock()
+		}
+	}
+}
+
+func (s *Server) addRoute(path string, method string, private bool, h handler) {
+	s.routes[path] = &route{
+		method:  method,
+		private: private,
+		handler: h,
+	}
+}
+
+func newErrorResponse(status int64, errorMessage string) (*errorResponse) {
+	return &errorResponse{
+		Status:       status,
+		ErrorMessage: errorMessage,
+	}
+}
+
+func badRequest(status int64, errorMessage string) (int, interface{}) {
+	return http.StatusBadRequest, newErrorResponse(status, errorMessage)
+}
+
+func formatDate(t time.Time) (types.Time) {
+	return types.NewTime(t)
+}
+
+func formatFloat(v float64) (string) {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func randomHex(n int) (string) {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func queryInt(r *http.Request, name string) (int64) {
+	v, err := strconv.ParseInt(r.URL.Query().Get(name), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return v
+}
+
+func queryProductCode(r *http.Request) (types.ProductCode) {
+	productCode := r.URL.Query().Get("product_code")
+	if productCode == "" {
+		return "BTC_JPY"
+	}
+	return types.ProductCode(productCode)
+}
+
+func queryPagination(r *http.Request) (*types.Pagination) {
+	return &types.Pagination{
+		Count:  queryInt(r, "count"),
+		Before: queryInt(r, "before"),
+		After:  queryInt(r, "after"),
+	}
+}
+
+// paginate returns indexes of matched items newest first. items must be sorted by id in ascending order.
+func paginate(length int, idOf func(i int) (int64), match func(i int) (bool), pagination *types.Pagination) ([]int) {
+	count := pagination.Count
+	if count <= 0 {
+		count = 100
+	}
+	indexes := make([]int, 0)
+	for i := length - 1; i >= 0 && int64(len(indexes)) < count; i -= 1 {
+		id := idOf(i)
+		if pagination.Before != 0 && id >= pagination.Before {
+			continue
+		}
+		if pagination.After != 0 && id <= pagination.After {
+			continue
+		}
+		if match != nil && !match(i) {
+			continue
+		}
+		indexes = append(indexes, i)
+	}
+	return indexes
+}
+
+func NewServer() (*Server) {
+	s := &Server{
+		apiKey:             DefaultAPIKey,
+		apiSecret:          DefaultAPISecret,
+		authenticationCode: DefaultAuthenticationCode,
+		routes:             make(map[string]*route),
+		mutex:              new(sync.Mutex),
+		markets:            make(map[types.ProductCode]*market),
+		marketOrder:        make([]types.ProductCode, 0),
+		account:            newAccount(),
+		chats:              make(public.GetChatsResponse, 0),
+		wsConns:            make(map[*wsConn]bool),
+		subscribeChan:      make(chan int),
+		publishInterval:    DefaultPublishInterval,
+		finishChan:         make(chan int),
+		finishOnce:         new(sync.Once),
+		finishWait:         new(sync.WaitGroup),
+	}
+	for _, spec := range defaultMarketSpecs {
+		s.markets[spec.productCode] = newMarket(spec)
+		s.marketOrder = append(s.marketOrder, spec.productCode)
+	}
+	s.chats = append(s.chats, &public.GetChatsChat{
+		Nickname: "bitflyertest",
+		Message:  "hello",
+		Date:     formatDate(time.Now()),
+	})
+	s.account.childOrders = childorder.NewEngine(&childOrderHost{server: s})
+	s.account.parentOrders = parentorder.NewEngine(&parentOrderHost{server: s})
+	s.addPublicRoutes()
+	s.addPrivateRoutes()
+	s.httpServer = httptest.NewServer(s)
+	s.finishWait.Add(1)
+	go s.publishLoop()
+	return s
+}
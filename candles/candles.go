@@ -0,0 +1,366 @@
+package candles
+
+import (
+	"sort"
+	"sync"
+	"time"
+	"github.com/pkg/errors"
+	"github.com/potix/gobitflyer/api/types"
+	"github.com/potix/gobitflyer/api/public"
+)
+
+type BarType int
+
+const (
+	// a bar per resolution
+	BarTypeTime   BarType = 0
+	// a bar per threshold executions
+	BarTypeTick   BarType = 1
+	// a bar per threshold size
+	BarTypeVolume BarType = 2
+	// a bar per threshold price * size
+	BarTypeDollar BarType = 3
+)
+
+type Candle struct {
+	ProductCode types.ProductCode
+	// the start and the end of a time bar, the first and the last execution of other bars
+	Start       time.Time
+	End         time.Time
+	Open        float64
+	High        float64
+	Low         float64
+	Close       float64
+	Volume      float64
+	BuyVolume   float64
+	SellVolume  float64
+	// sum of price * size
+	Notional    float64
+	Count       int64
+	FirstId     int64
+	LastId      int64
+}
+
+// VWAP returns the volume weighted average price, Close for an empty bar.
+func (c *Candle) VWAP() (float64) {
+	if c.Volume == 0 {
+		return c.Close
+	}
+	return c.Notional / c.Volume
+}
+
+func (c *Candle) add(execution *public.GetExecutionsExecution) {
+	price := execution.Price.Float64()
+	size := execution.Size.Float64()
+	if c.Count == 0 {
+		c.Open = price
+		c.High = price
+		c.Low = price
+		c.FirstId = execution.Id
+	}
+	if price > c.High {
+		c.High = price
+	}
+	if price < c.Low {
+		c.Low = price
+	}
+	c.Close = price
+	c.Volume += size
+	c.Notional += price * size
+	switch execution.Side {
+	case types.SideBuy:
+		c.BuyVolume += size
+	case types.SideSell:
+		c.SellVolume += size
+	}
+	c.Count += 1
+	if execution.Id > c.LastId {
+		c.LastId = execution.Id
+	}
+	if execution.Id < c.FirstId {
+		c.FirstId = execution.Id
+	}
+}
+
+type CandleCallback func(productCode types.ProductCode, candle *Candle, callbackData interface{})
+
+type Options struct {
+	BarType    BarType
+	// the length of a time bar
+	Resolution time.Duration
+	// executions, size or notional of a tick, volume or dollar bar
+	Threshold  float64
+	// a time bar is closed when an execution which is Lateness after the end arrives (or by Advance),
+	// executions of a closed bar are dropped
+	Lateness   time.Duration
+	// emit time bars without executions with the previous close
+	FillEmpty  bool
+}
+
+type Stats struct {
+	Duplicates int64
+	// executions of closed bars including duplicates of them
+	Late       int64
+}
+
+type bar struct {
+	candle *Candle
+	ids    []int64
+}
+
+// Aggregator aggregates executions into bars and calls the callback with every closed bar in order.
+// Executions can come in any order and more than once, e.g. from pages of PubGetExecutions and RealExecutionsStart.
+type Aggregator struct {
+	productCode  types.ProductCode
+	options      Options
+	callback     CandleCallback
+	callbackData interface{}
+	mutex        *sync.Mutex
+	emitMutex    *sync.Mutex
+	// open bars, only time bars have more than one
+	bars         map[int64]*bar
+	seen         map[int64]bool
+	watermark    time.Time
+	closedEnd    time.Time
+	closedId     int64
+	lastClose    float64
+	lastId       int64
+	stats        Stats
+}
+
+func (a *Aggregator) timeBarStart(t time.Time) (time.Time) {
+	return t.UTC().Truncate(a.options.Resolution)
+}
+
+func (a *Aggregator) newCandle(start time.Time, end time.Time) (*Candle) {
+	return &Candle{
+		ProductCode: a.productCode,
+		Start:       start,
+		End:         end,
+	}
+}
+
+func (a *Aggregator) closeBar(key int64, closed []*Candle) ([]*Candle) {
+	b := a.bars[key]
+	delete(a.bars, key)
+	for _, id := range b.ids {
+		delete(a.seen, id)
+	}
+	if b.candle.LastId > a.closedId {
+		a.closedId = b.candle.LastId
+	}
+	a.lastClose = b.candle.Close
+	return append(closed, b.candle)
+}
+
+// closeTimeBars closes time bars which end at or before t.
+func (a *Aggregator) closeTimeBars(t time.Time, closed []*Candle) ([]*Candle) {
+	keys := make([]int64, 0, len(a.bars))
+	for key, b := range a.bars {
+		if !b.candle.End.After(t) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) (bool) { return keys[i] < keys[j] })
+	for _, key := range keys {
+		start := a.bars[key].candle.Start
+		closed = a.fillEmpty(start, closed)
+		closed = a.closeBar(key, closed)
+		a.closedEnd = start.Add(a.options.Resolution)
+	}
+	return a.fillEmpty(a.timeBarStart(t), closed)
+}
+
+// fillEmpty emits empty bars from the end of the last closed bar to until.
+func (a *Aggregator) fillEmpty(until time.Time, closed []*Candle) ([]*Candle) {
+	if !a.options.FillEmpty || a.closedEnd.IsZero() {
+		return closed
+	}
+	for a.closedEnd.Before(until) {
+		if _, ok := a.bars[a.closedEnd.UnixNano()]; ok {
+			break
+		}
+		candle := a.newCandle(a.closedEnd, a.closedEnd.Add(a.options.Resolution))
+		candle.Open = a.lastClose
+		candle.High = a.lastClose
+		candle.Low = a.lastClose
+		candle.Close = a.lastClose
+		closed = append(closed, candle)
+		a.closedEnd = candle.End
+	}
+	return closed
+}
+
+func (a *Aggregator) add(execution *public.GetExecutionsExecution, closed []*Candle) ([]*Candle) {
+	if a.seen[execution.Id] {
+		a.stats.Duplicates += 1
+		return closed
+	}
+	if execution.Id <= a.closedId {
+		a.stats.Late += 1
+		return closed
+	}
+	if execution.Id > a.lastId {
+		a.lastId = execution.Id
+	}
+	if a.options.BarType == BarTypeTime {
+		start := a.timeBarStart(execution.ExecDate.Time)
+		if !a.closedEnd.IsZero() && start.Before(a.closedEnd) {
+			a.stats.Late += 1
+			return closed
+		}
+		key := start.UnixNano()
+		b, ok := a.bars[key]
+		if !ok {
+			b = &bar{candle: a.newCandle(start, start.Add(a.options.Resolution))}
+			a.bars[key] = b
+		}
+		b.candle.add(execution)
+		b.ids = append(b.ids, execution.Id)
+		a.seen[execution.Id] = true
+		if execution.ExecDate.After(a.watermark) {
+			a.watermark = execution.ExecDate.Time
+		}
+		return a.closeTimeBars(a.watermark.Add(-a.options.Lateness), closed)
+	}
+	b, ok := a.bars[0]
+	if !ok {
+		b = &bar{candle: a.newCandle(execution.ExecDate.Time, execution.ExecDate.Time)}
+		a.bars[0] = b
+	}
+	b.candle.add(execution)
+	b.candle.End = execution.ExecDate.Time
+	b.ids = append(b.ids, execution.Id)
+	a.seen[execution.Id] = true
+	var value float64
+	switch a.options.BarType {
+	case BarTypeTick:
+		value = float64(b.candle.Count)
+	case BarTypeVolume:
+		value = b.candle.Volume
+	case BarTypeDollar:
+		value = b.candle.Notional
+	}
+	if value >= a.options.Threshold {
+		closed = a.closeBar(0, closed)
+	}
+	return closed
+}
+
+func (a *Aggregator) emit(closed []*Candle) {
+	// emitMutex keeps the order of bars between concurrent calls
+	a.emitMutex.Lock()
+	a.mutex.Unlock()
+	defer a.emitMutex.Unlock()
+	for _, candle := range closed {
+		a.callback(a.productCode, candle, a.callbackData)
+	}
+}
+
+// Add aggregates executions, which are sorted by id before they are added.
+func (a *Aggregator) Add(executions public.GetExecutionsResponse) {
+	sorted := make(public.GetExecutionsResponse, len(executions))
+	copy(sorted, executions)
+	sort.Slice(sorted, func(i, j int) (bool) { return sorted[i].Id < sorted[j].Id })
+	a.mutex.Lock()
+	closed := make([]*Candle, 0)
+	for _, execution := range sorted {
+		closed = a.add(execution, closed)
+	}
+	a.emit(closed)
+}
+
+// ExecutionsCallback is a realtime.ExecutionsCallback, e.g. RealExecutionsStart(productCode, aggregator.ExecutionsCallback, nil).
+func (a *Aggregator) ExecutionsCallback(productCode types.ProductCode, getExecutionsResponse public.GetExecutionsResponse, callbackData interface{}) {
+	a.Add(getExecutionsResponse)
+}
+
+// Advance closes time bars which end at or before now - Lateness, so that bars close without executions.
+func (a *Aggregator) Advance(now time.Time) {
+	a.mutex.Lock()
+	closed := make([]*Candle, 0)
+	if a.options.BarType == BarTypeTime {
+		closed = a.closeTimeBars(now.Add(-a.options.Lateness), closed)
+	}
+	a.emit(closed)
+}
+
+// Flush closes all open bars, e.g. at the end of historical executions.
+func (a *Aggregator) Flush() {
+	a.mutex.Lock()
+	keys := make([]int64, 0, len(a.bars))
+	for key := range a.bars {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) (bool) { return keys[i] < keys[j] })
+	closed := make([]*Candle, 0, len(keys))
+	for _, key := range keys {
+		if a.options.BarType == BarTypeTime {
+			closed = a.fillEmpty(a.bars[key].candle.Start, closed)
+			a.closedEnd = a.bars[key].candle.End
+		}
+		closed = a.closeBar(key, closed)
+	}
+	a.emit(closed)
+}
+
+// Current returns a copy of the latest open bar.
+func (a *Aggregator) Current() (*Candle, bool) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	var current *Candle
+	for _, b := range a.bars {
+		if current == nil || b.candle.Start.After(current.Start) {
+			current = b.candle
+		}
+	}
+	if current == nil {
+		return nil, false
+	}
+	candle := *current
+	return &candle, true
+}
+
+// LastId returns the largest id of added executions.
+func (a *Aggregator) LastId() (int64) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.lastId
+}
+
+func (a *Aggregator) Stats() (Stats) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.stats
+}
+
+func NewAggregator(productCode types.ProductCode, options *Options, callback CandleCallback, callbackData interface{}) (*Aggregator, error) {
+	if options == nil {
+		return nil, errors.Errorf("no options")
+	}
+	if callback == nil {
+		return nil, errors.Errorf("no callback")
+	}
+	switch options.BarType {
+	case BarTypeTime:
+		if options.Resolution <= 0 {
+			return nil, errors.Errorf("invalid resolution (resolution = %v)", options.Resolution)
+		}
+	case BarTypeTick, BarTypeVolume, BarTypeDollar:
+		if options.Threshold <= 0 {
+			return nil, errors.Errorf("invalid threshold (threshold = %v)", options.Threshold)
+		}
+	default:
+		return nil, errors.Errorf("invalid bar type (bar type = %v)", options.BarType)
+	}
+	return &Aggregator{
+		productCode:  productCode,
+		options:      *options,
+		callback:     callback,
+		callbackData: callbackData,
+		mutex:        new(sync.Mutex),
+		emitMutex:    new(sync.Mutex),
+		bars:         make(map[int64]*bar),
+		seen:         make(map[int64]bool),
+	}, nil
+}
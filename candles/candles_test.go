@@ -0,0 +1,146 @@
+package candles_test
+
+import (
+	"context"
+	"time"
+	"testing"
+	"github.com/potix/gobitflyer/api/types"
+	"github.com/potix/gobitflyer/api/public"
+	"github.com/potix/gobitflyer/api/realtime"
+	"github.com/potix/gobitflyer/bitflyertest"
+	"github.com/potix/gobitflyer/candles"
+)
+
+func TestCandles(t *testing.T) {
+	base := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	execution := func(id int64, seconds int, side types.Side, price float64, size float64) (*public.GetExecutionsExecution) {
+		return &public.GetExecutionsExecution{
+			Id:       id,
+			Side:     side,
+			Price:    types.NewDecimalFromFloat(price),
+			Size:     types.NewDecimalFromFloat(size),
+			ExecDate: types.NewTime(base.Add(time.Duration(seconds) * time.Second)),
+		}
+	}
+	closed := make([]*candles.Candle, 0)
+	aggregator, err := candles.NewAggregator("BTC_JPY", &candles.Options{
+		BarType:    candles.BarTypeTime,
+		Resolution: time.Minute,
+		Lateness:   10 * time.Second,
+		FillEmpty:  true,
+	}, func(productCode types.ProductCode, candle *candles.Candle, callbackData interface{}) {
+		closed = append(closed, candle)
+	}, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	aggregator.Add(public.GetExecutionsResponse{
+		execution(3, 30, types.SideSell, 90, 2),
+		execution(1, 0, types.SideBuy, 100, 1),
+		execution(2, 10, types.SideBuy, 110, 1),
+	})
+	// a duplicate, the first bar is closed at 1:10 and a late execution is dropped
+	aggregator.Add(public.GetExecutionsResponse{execution(2, 10, types.SideBuy, 110, 1), execution(4, 65, types.SideBuy, 95, 1)})
+	aggregator.Add(public.GetExecutionsResponse{execution(5, 70, types.SideBuy, 96, 1), execution(6, 50, types.SideBuy, 80, 1)})
+	if len(closed) != 1 {
+		t.Fatalf("unexpected bars: %v", len(closed))
+	}
+	first := closed[0]
+	if first.Open != 100 || first.High != 110 || first.Low != 90 || first.Close != 90 || first.Volume != 4 ||
+	    first.BuyVolume != 2 || first.SellVolume != 2 || first.Count != 3 || first.VWAP() != 97.5 || !first.Start.Equal(base) {
+		t.Errorf("unexpected bar: %#v", first)
+	}
+	if stats := aggregator.Stats(); stats.Duplicates != 1 || stats.Late != 1 {
+		t.Errorf("unexpected stats: %#v", stats)
+	}
+	// the second bar and two empty bars are closed
+	aggregator.Advance(base.Add(4 * time.Minute + 10 * time.Second))
+	if len(closed) != 4 || closed[1].Count != 2 || closed[2].Count != 0 || closed[3].Close != 96 || !closed[3].Start.Equal(base.Add(3 * time.Minute)) {
+		t.Fatalf("unexpected bars: %v", len(closed))
+	}
+
+	closed = closed[:0]
+	aggregator, err = candles.NewAggregator("BTC_JPY", &candles.Options{
+		BarType:   candles.BarTypeVolume,
+		Threshold: 2,
+	}, func(productCode types.ProductCode, candle *candles.Candle, callbackData interface{}) {
+		closed = append(closed, candle)
+	}, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	aggregator.Add(public.GetExecutionsResponse{
+		execution(1, 0, types.SideBuy, 100, 1),
+		execution(2, 1, types.SideBuy, 101, 1.5),
+		execution(3, 2, types.SideBuy, 102, 0.5),
+		execution(4, 3, types.SideBuy, 103, 0.5),
+	})
+	if len(closed) != 1 || closed[0].Count != 2 || closed[0].LastId != 2 {
+		t.Fatalf("unexpected bars: %v", len(closed))
+	}
+	aggregator.Flush()
+	if len(closed) != 2 || closed[1].Count != 2 || closed[1].Close != 103 {
+		t.Fatalf("unexpected bars: %v", len(closed))
+	}
+	if _, err := candles.NewAggregator("BTC_JPY", &candles.Options{BarType: candles.BarTypeTick}, func(productCode types.ProductCode, candle *candles.Candle, callbackData interface{}) {}, nil); err == nil {
+		t.Errorf("no error")
+	}
+}
+
+func TestCandlesRun(t *testing.T) {
+	server, apiClient, realApiClient := bitflyertest.NewClients(t)
+	for i := 0; i < 5; i += 1 {
+		if err := server.Execute("BTC_JPY", types.SideBuy, 0, 0.01); err != nil {
+			t.Fatalf("error: %v", err)
+		}
+	}
+	_, getExecutionsResponse, err := apiClient.PubGetExecutions("BTC_JPY", 500, 0, 0)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	candleChan := make(chan *candles.Candle, 1000)
+	aggregator, err := candles.NewAggregator("BTC_JPY", &candles.Options{
+		BarType:   candles.BarTypeTick,
+		Threshold: 1,
+	}, func(productCode types.ProductCode, candle *candles.Candle, callbackData interface{}) {
+		candleChan <- candle
+	}, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- aggregator.Run(ctx, apiClient, realApiClient, time.Now().Add(-time.Hour))
+	}()
+	lastId := int64(0)
+	for i := 0; i < len(getExecutionsResponse); i += 1 {
+		select {
+		case candle := <-candleChan:
+			if candle.LastId <= lastId {
+				t.Fatalf("unexpected order: %v <= %v", candle.LastId, lastId)
+			}
+			lastId = candle.LastId
+		case <-time.After(10 * time.Second):
+			t.Fatalf("no backfilled bars")
+		}
+	}
+	if err := server.WaitSubscribed(true, 10 * time.Second, realtime.ChannelName(types.RealtimeTypeExecutions, "BTC_JPY")); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if err := server.Execute("BTC_JPY", types.SideSell, 0, 0.01); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	select {
+	case candle := <-candleChan:
+		if candle.LastId <= lastId {
+			t.Errorf("unexpected order: %v <= %v", candle.LastId, lastId)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("no realtime bars")
+	}
+	cancel()
+	if err := <-errChan; err != context.Canceled {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
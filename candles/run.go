@@ -0,0 +1,107 @@
+package candles
+
+import (
+	"context"
+	"time"
+	"github.com/pkg/errors"
+	"github.com/potix/gobitflyer/api"
+	"github.com/potix/gobitflyer/api/public"
+)
+
+const (
+	backfillBatchSize int           = 500
+	liveBufferSize    int           = 10000
+	advanceInterval   time.Duration = time.Second
+)
+
+// backfill adds executions after afterId and before beforeId, or since with the rest api in ascending order of id.
+// A zero id is not compared.
+func (a *Aggregator) backfill(ctx context.Context, apiClient *api.APIClient, afterId int64, beforeId int64, since time.Time) (error) {
+	iterator := apiClient.PubGetExecutionsIterator(a.productCode, &api.PageOptions{
+		Count:     int64(backfillBatchSize),
+		Direction: api.PageDirectionForward,
+		AfterId:   afterId,
+		BeforeId:  beforeId,
+		Since:     since,
+	})
+	executions := make(public.GetExecutionsResponse, 0, backfillBatchSize)
+	for {
+		execution, err := iterator.NextCtx(ctx)
+		if err == api.ErrIteratorDone {
+			break
+		}
+		if err != nil {
+			return errors.Wrapf(err, "can not get executions")
+		}
+		executions = append(executions, execution)
+		if len(executions) >= backfillBatchSize {
+			a.Add(executions)
+			executions = executions[:0]
+		}
+	}
+	a.Add(executions)
+	return nil
+}
+
+// Backfill adds executions since since with the rest api. Pages are fetched from the newest execution
+// down to since before the first bar is emitted.
+func (a *Aggregator) Backfill(ctx context.Context, apiClient *api.APIClient, since time.Time) (error) {
+	return a.backfill(ctx, apiClient, 0, 0, since)
+}
+
+// Run backfills bars since since and then keeps them up to date with the realtime executions until ctx is done.
+// The realtime executions are buffered during the backfill. The buffer drops the oldest executions when it overflows,
+// so executions between the backfill and the oldest buffered execution are fetched again with the rest api.
+func (a *Aggregator) Run(ctx context.Context, apiClient *api.APIClient, realAPIClient *api.RealAPIClient, since time.Time) (error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	executionsChan, errChan, err := realAPIClient.RealExecutionsStreamCtx(ctx, a.productCode, &api.StreamOptions{
+		BufferSize:     liveBufferSize,
+		OverflowPolicy: api.OverflowPolicyDropOldest,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "can not start executions stream")
+	}
+	err = a.Backfill(ctx, apiClient, since)
+	if err != nil {
+		return errors.Wrapf(err, "can not backfill executions")
+	}
+	switched := false
+	ticker := time.NewTicker(advanceInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case executions, ok := <-executionsChan:
+			if !ok {
+				err := <-errChan
+				if err == nil {
+					err = errors.Errorf("executions stream is closed")
+				}
+				return err
+			}
+			if !switched && len(executions) != 0 {
+				// executions during the backfill which were not buffered
+				oldestId := executions[0].Id
+				for _, execution := range executions {
+					if execution.Id < oldestId {
+						oldestId = execution.Id
+					}
+				}
+				if lastId := a.LastId(); oldestId > lastId + 1 {
+					err := a.backfill(ctx, apiClient, lastId, oldestId, since)
+					if err != nil {
+						return errors.Wrapf(err, "can not backfill executions")
+					}
+				}
+				switched = true
+			}
+			a.Add(executions)
+		case now := <-ticker.C:
+			if a.options.BarType == BarTypeTime {
+				a.Advance(now)
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
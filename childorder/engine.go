@@ -0,0 +1,187 @@
+package childorder
+
+import (
+	"time"
+	"github.com/potix/gobitflyer/api/types"
+	"github.com/potix/gobitflyer/api/private"
+	"github.com/potix/gobitflyer/api/realtime"
+)
+
+// Host is an exchange simulator which keeps the board and the account of child orders run by Engine.
+type Host interface {
+	// Take fills an arrived order as a taker with Engine.Fill, as far as the board allows.
+	Take(o *Order, now time.Time)
+	// Fillable reports whether the outstanding size of an arrived order can be filled at once, which FOK requires.
+	Fillable(o *Order) (bool)
+	// Rest places the remainder of a GTC limit order on the board.
+	Rest(o *Order, now time.Time)
+	// Unrest removes an order from the board when it is completed or canceled.
+	Unrest(o *Order, now time.Time)
+	CommissionRate(productCode types.ProductCode) (float64)
+	// Settle books a fill on the account. commission is in the base currency like bitFlyer.
+	Settle(o *Order, price float64, size float64, commission float64, maker bool, now time.Time)
+	// Release releases what the account locked for the outstanding size of an order which is canceled.
+	Release(o *Order)
+	Event(event *realtime.ChildOrderEvent)
+	// Done notifies that an order finished by itself, and not by its parent order.
+	Done(o *Order, now time.Time)
+}
+
+// Execution is a fill of the account.
+type Execution struct {
+	ProductCode types.ProductCode
+	Execution   *private.GetExecutionsExecution
+}
+
+// Engine matches child orders on a host, and keeps the orders and the fills of the account.
+// It is not safe for concurrent use, the host calls it under its own lock.
+type Engine struct {
+	host       Host
+	orders     []*Order
+	executions []*Execution
+}
+
+// Add tracks an order which was sent. It is matched after Arrive.
+func (e *Engine) Add(o *Order) {
+	e.orders = append(e.orders, o)
+}
+
+// Orders returns orders in order of Add. The slice must not be modified.
+func (e *Engine) Orders() ([]*Order) {
+	return e.orders
+}
+
+// Executions returns fills in order. The slice must not be modified.
+func (e *Engine) Executions() ([]*Execution) {
+	return e.executions
+}
+
+func (e *Engine) event(o *Order, eventType types.EventType, now time.Time) (*realtime.ChildOrderEvent) {
+	return &realtime.ChildOrderEvent{
+		ProductCode:            o.ProductCode,
+		ChildOrderId:           o.ChildOrderId,
+		ChildOrderAcceptanceId: o.ChildOrderAcceptanceId,
+		EventDate:              types.NewTime(now),
+		EventType:              eventType,
+	}
+}
+
+// Arrive accepts an order at the exchange and matches it.
+func (e *Engine) Arrive(o *Order, now time.Time) {
+	if o.state != types.OrderStateActive {
+		return
+	}
+	o.Arrived = true
+	event := e.event(o, types.EventTypeOrder, now)
+	event.ChildOrderType = o.ChildOrderType
+	event.Side = o.Side
+	event.Price = o.Price
+	event.Size = o.Size
+	event.ExpireDate = types.NewTime(o.ExpireDate)
+	e.host.Event(event)
+	e.execute(o, now)
+}
+
+// execute matches an arrived order and rests or cancels the remainder.
+func (e *Engine) execute(o *Order, now time.Time) {
+	if o.TimeInForce == types.TimeInForceFOK && !e.host.Fillable(o) {
+		e.Cancel(o, types.OrderStateCanceled, false, now)
+		return
+	}
+	e.host.Take(o, now)
+	if o.state != types.OrderStateActive {
+		return
+	}
+	if o.ChildOrderType == types.OrderTypeLimit && o.TimeInForce == types.TimeInForceGTC {
+		o.Resting = true
+		e.host.Rest(o, now)
+		return
+	}
+	state := types.OrderStateCanceled
+	if o.ExecutedSize > 0 {
+		state = types.OrderStateCompleted
+	}
+	e.Cancel(o, state, false, now)
+}
+
+// Fill fills size of an order at price. execId is the id of the execution of the market.
+func (e *Engine) Fill(o *Order, execId int64, price float64, size float64, maker bool, now time.Time) {
+	commission := round(size * e.host.CommissionRate(o.ProductCode))
+	o.AveragePrice = round((o.AveragePrice * o.ExecutedSize + price * size) / (o.ExecutedSize + size))
+	o.ExecutedSize = round(o.ExecutedSize + size)
+	o.OutstandingSize = round(o.OutstandingSize - size)
+	o.TotalCommission = round(o.TotalCommission + commission)
+	e.host.Settle(o, price, size, commission, maker, now)
+	e.executions = append(e.executions, &Execution{
+		ProductCode: o.ProductCode,
+		Execution:   &private.GetExecutionsExecution{
+			Id:                     execId,
+			ChildOrderId:           o.ChildOrderId,
+			Side:                   o.Side,
+			Price:                  types.NewDecimalFromFloat(price),
+			Size:                   types.NewDecimalFromFloat(size),
+			Commission:             types.NewDecimalFromFloat(commission),
+			ExecDate:               types.NewTime(now),
+			ChildOrderAcceptanceId: o.ChildOrderAcceptanceId,
+		},
+	})
+	event := e.event(o, types.EventTypeExecution, now)
+	event.ExecId = execId
+	event.Side = o.Side
+	event.Price = price
+	event.Size = size
+	event.Commission = commission
+	event.OutstandingSize = o.OutstandingSize
+	e.host.Event(event)
+	if o.OutstandingSize > 0 {
+		return
+	}
+	o.state = types.OrderStateCompleted
+	if o.Resting {
+		o.Resting = false
+		e.host.Unrest(o, now)
+	}
+	e.host.Done(o, now)
+}
+
+// Cancel finishes an active order with state. byParent is true when the parent order cancels its children.
+func (e *Engine) Cancel(o *Order, state types.OrderState, byParent bool, now time.Time) {
+	if o.state != types.OrderStateActive {
+		return
+	}
+	e.host.Release(o)
+	if o.Resting {
+		o.Resting = false
+		e.host.Unrest(o, now)
+	}
+	o.CancelSize = round(o.CancelSize + o.OutstandingSize)
+	o.OutstandingSize = 0
+	o.state = state
+	if o.Arrived {
+		if state == types.OrderStateExpired {
+			e.host.Event(e.event(o, types.EventTypeExpire, now))
+		} else {
+			e.host.Event(e.event(o, types.EventTypeCancel, now))
+		}
+	}
+	if !byParent {
+		e.host.Done(o, now)
+	}
+}
+
+// Expire expires active orders without a parent order whose expire date is before now. Parent orders expire their children.
+func (e *Engine) Expire(now time.Time) {
+	for _, o := range e.orders {
+		if o.state == types.OrderStateActive && o.Parent == nil && now.After(o.ExpireDate) {
+			e.Cancel(o, types.OrderStateExpired, false, now)
+		}
+	}
+}
+
+func NewEngine(host Host) (*Engine) {
+	return &Engine{
+		host:       host,
+		orders:     make([]*Order, 0),
+		executions: make([]*Execution, 0),
+	}
+}
@@ -0,0 +1,113 @@
+package childorder
+
+import (
+	"math"
+	"time"
+	"github.com/potix/gobitflyer/api/types"
+	"github.com/potix/gobitflyer/api/private"
+	"github.com/potix/gobitflyer/parentorder"
+)
+
+const (
+	defaultMinuteToExpire int64 = 43200
+)
+
+// Order is a child order. The fields are read by the host, and the sizes and the state are changed only by Engine.
+type Order struct {
+	Id                     int64
+	ChildOrderId           string
+	ChildOrderAcceptanceId string
+	ProductCode            types.ProductCode
+	Side                   types.Side
+	ChildOrderType         types.OrderType
+	TimeInForce            types.TimeInForce
+	Price                  float64
+	AveragePrice           float64
+	Size                   float64
+	OutstandingSize        float64
+	CancelSize             float64
+	ExecutedSize           float64
+	TotalCommission        float64
+	ExpireDate             time.Time
+	ChildOrderDate         time.Time
+	// false until the order reaches the exchange
+	Arrived                bool
+	// true while the order is on the board
+	Resting                bool
+	// nil for an order without a parent order
+	Parent                 *parentorder.Order
+	ParameterIndex         int
+	state                  types.OrderState
+}
+
+// Crosses reports whether the order can take price.
+func (o *Order) Crosses(price float64) (bool) {
+	if o.ChildOrderType == types.OrderTypeMarket {
+		return true
+	}
+	if o.Side == types.SideBuy {
+		return price <= o.Price
+	}
+	return price >= o.Price
+}
+
+func (o *Order) State() (types.OrderState) {
+	return o.state
+}
+
+func (o *Order) Response() (*private.GetChildOrdersOrder) {
+	return &private.GetChildOrdersOrder{
+		Id:                     o.Id,
+		ChildOrderId:           o.ChildOrderId,
+		ProductCode:            o.ProductCode,
+		Side:                   o.Side,
+		ChildOrderType:         o.ChildOrderType,
+		Price:                  types.NewDecimalFromFloat(o.Price),
+		AveragePrice:           types.NewDecimalFromFloat(o.AveragePrice),
+		Size:                   types.NewDecimalFromFloat(o.Size),
+		ChildOrderState:        o.state,
+		ExpireDate:             types.NewTime(o.ExpireDate),
+		ChildOrderDate:         types.NewTime(o.ChildOrderDate),
+		ChildOrderAcceptanceId: o.ChildOrderAcceptanceId,
+		OutstandingSize:        types.NewDecimalFromFloat(o.OutstandingSize),
+		CancelSize:             types.NewDecimalFromFloat(o.CancelSize),
+		ExecutedSize:           types.NewDecimalFromFloat(o.ExecutedSize),
+		TotalCommission:        types.NewDecimalFromFloat(o.TotalCommission),
+	}
+}
+
+// NewOrder creates an active order sent at now. It is run by Engine after Add and Arrive.
+func NewOrder(id int64, childOrderId string, childOrderAcceptanceId string, productCode types.ProductCode, orderType types.OrderType,
+	side types.Side, price float64, size float64, minuteToExpire int64, timeInForce types.TimeInForce, parent *parentorder.Order,
+	parameterIndex int, now time.Time) (*Order) {
+	if orderType == types.OrderTypeMarket {
+		price = 0
+	}
+	if minuteToExpire <= 0 {
+		minuteToExpire = defaultMinuteToExpire
+	}
+	if timeInForce == types.TimeInForceNone {
+		timeInForce = types.TimeInForceGTC
+	}
+	return &Order{
+		Id:                     id,
+		ChildOrderId:           childOrderId,
+		ChildOrderAcceptanceId: childOrderAcceptanceId,
+		ProductCode:            productCode,
+		Side:                   side,
+		ChildOrderType:         orderType,
+		TimeInForce:            timeInForce,
+		Price:                  price,
+		Size:                   size,
+		OutstandingSize:        size,
+		ExpireDate:             now.Add(time.Duration(minuteToExpire) * time.Minute),
+		ChildOrderDate:         now,
+		Parent:                 parent,
+		ParameterIndex:         parameterIndex,
+		state:                  types.OrderStateActive,
+	}
+}
+
+func round(v float64) (float64) {
+	return math.Round(v * 1e8) / 1e8
+}
@@ -1,6 +1,7 @@
 package client
 
 import (
+	"context"
 	"log"
 	"fmt"
 	"bytes"
@@ -97,7 +98,7 @@ func (c *HTTPClient) newHTTPTransport(scheme string, host string) (*http.Transpo
 func (c *HTTPClient) newClient(scheme string, host string) (*http.Client) {
 	c.clientsCacheMutex.Lock()
 	defer c.clientsCacheMutex.Unlock()
-        clientId := fmt.Sprintf("%v,%v,%v", scheme, host)
+        clientId := fmt.Sprintf("%v,%v", scheme, host)
 	cachedHttpClient, ok := c.clientsCache[clientId]
 	if ok {
 		return cachedHttpClient
@@ -112,12 +113,16 @@ func (c *HTTPClient) newClient(scheme string, host string) (*http.Client) {
 }
 
 func (c *HTTPClient) DoRequest(request *HTTPRequest) (*http.Response, []byte, error) {
+	return c.DoRequestCtx(context.Background(), request)
+}
+
+func (c *HTTPClient) DoRequestCtx(ctx context.Context, request *HTTPRequest) (*http.Response, []byte, error) {
 	parsedURL, err := url.Parse(request.URL)
 	if err != nil {
 		return nil, nil, errors.Wrapf(err, "can not parse url (url = %v)", request.URL)
 	}
 	client := c.newClient(parsedURL.Scheme, parsedURL.Host)
-	req, err := http.NewRequest(request.Method, request.URL, bytes.NewBuffer(request.Body))
+	req, err := http.NewRequestWithContext(ctx, request.Method, request.URL, bytes.NewBuffer(request.Body))
 	if err != nil {
 		return nil, nil, errors.Wrapf(err, "can not create request (method = %v, url = %v, request body = %v)", request.Method, request.URL, request.Body)
 	}
@@ -1,7 +1,9 @@
 package client
 
 import (
+	"context"
 	"log"
+	"sync"
 	"time"
 	"crypto/tls"
 	"net"
@@ -26,6 +28,7 @@ type WSClient struct {
 	started            uint32
 	finishRequestChan  chan int
 	finishResponseChan chan int
+	finishOnce         *sync.Once
 }
 
 type WSRequest struct {
@@ -85,11 +88,22 @@ func (w *WSClient) stopPing(pingCtx *pingContext) {
 	return
 }
 
-func  (w *WSClient) connect(request *WSRequest, callback WSCallback, callbackData interface{}, header http.Header, dialer *websocket.Dialer) (bool) {
-	conn, response, err := dialer.Dial(request.URL, header)
+func (w *WSClient) waitRetry() (bool) {
+	select {
+	case <-w.finishRequestChan:
+		return false
+	case <-time.After(time.Duration(w.retryWait) * time.Second):
+		return true
+	}
+}
+
+func  (w *WSClient) connect(ctx context.Context, request *WSRequest, callback WSCallback, callbackData interface{}, header http.Header, dialer *websocket.Dialer) (bool) {
+	conn, response, err := dialer.DialContext(ctx, request.URL, header)
 	if err != nil {
 		log.Printf("can not dial (url = %v, reason = %v)", request.URL, err)
-		time.Sleep(time.Duration(w.retryWait) * time.Second)
+		if !w.waitRetry() {
+			return false
+		}
 		w.retry += 1
 		if w.retry > w.retryMax {
 			log.Printf("give up retry (url = %v)", request.URL)
@@ -100,7 +114,9 @@ func  (w *WSClient) connect(request *WSRequest, callback WSCallback, callbackDat
 	defer conn.Close()
 	if response.StatusCode < 200 && response.StatusCode >= 300 {
 		log.Printf("error status code (url = %v, status code == %v)", request.URL, response.StatusCode)
-		time.Sleep(time.Duration(w.retryWait) * time.Second)
+		if !w.waitRetry() {
+			return false
+		}
 		w.retry += 1
 		if w.retry > w.retryMax {
 			log.Printf("give up retry (url = %v)", request.URL)
@@ -109,17 +125,27 @@ func  (w *WSClient) connect(request *WSRequest, callback WSCallback, callbackDat
 		return true
 	}
 	w.retry = 0
+	connFinishChan := make(chan int)
+	defer close(connFinishChan)
+	go func() {
+		select {
+		case <-w.finishRequestChan:
+			// interrupt read blocked in callback
+			conn.UnderlyingConn().Close()
+		case <-connFinishChan:
+		}
+	}()
 	pingContext := w.startPing(conn)
 	finish := w.messageLoop(conn, callback, callbackData)
 	w.stopPing(pingContext)
 	if !finish {
-		time.Sleep(time.Duration(w.retryWait) * time.Second)
-		return true
+		return w.waitRetry()
 	}
 	return false
 }
 
-func (w *WSClient) connectLoop(request *WSRequest, callback WSCallback, callbackData interface{}) {
+func (w *WSClient) connectLoop(ctx context.Context, request *WSRequest, callback WSCallback, callbackData interface{}) {
+	finishResponseChan := w.finishResponseChan
 	header := http.Header{}
 	for k, v := range request.Headers {
 		header.Set(k, v)
@@ -133,36 +159,61 @@ func (w *WSClient) connectLoop(request *WSRequest, callback WSCallback, callback
 			netDialer := &net.Dialer{LocalAddr: &net.TCPAddr{IP: w.localAddr}}
 			dialer.NetDialContext = netDialer.DialContext
 		}
-		retryable := w.connect(request, callback, callbackData, header, dialer)
+		retryable := w.connect(ctx, request, callback, callbackData, header, dialer)
 		if retryable {
 			continue
 		}
-		close(w.finishResponseChan)
 		break
 	}
 	atomic.StoreUint32(&w.started, 0)
+	close(finishResponseChan)
+}
+
+func (w *WSClient) watchContext(ctx context.Context, finishOnce *sync.Once, finishRequestChan chan int, finishResponseChan chan int) {
+	select {
+	case <-ctx.Done():
+		finishOnce.Do(func() { close(finishRequestChan) })
+	case <-finishResponseChan:
+	}
 }
 
 func (w *WSClient) Start(request *WSRequest, callback WSCallback, callbackData interface{}) (error) {
-	if atomic.LoadUint32(&w.started) != 0 {
+	return w.StartCtx(context.Background(), request, callback, callbackData)
+}
+
+func (w *WSClient) StartCtx(ctx context.Context, request *WSRequest, callback WSCallback, callbackData interface{}) (error) {
+	if !atomic.CompareAndSwapUint32(&w.started, 0, 1) {
 		return errors.Errorf("already started (url = %v)", request.URL)
 	}
-	atomic.StoreUint32(&w.started, 1)
 	parsedURL, err := url.Parse(request.URL)
 	if err != nil {
+		atomic.StoreUint32(&w.started, 0)
 		return errors.Wrapf(err, "can not parse url (url = %v)", request.URL)
 	}
 	request.parsedURL = parsedURL
-	go w.connectLoop(request, callback, callbackData)
+	w.retry = 0
+	w.finishRequestChan = make(chan int)
+	w.finishResponseChan = make(chan int)
+	w.finishOnce = new(sync.Once)
+	go w.watchContext(ctx, w.finishOnce, w.finishRequestChan, w.finishResponseChan)
+	go w.connectLoop(ctx, request, callback, callbackData)
 	return nil
 }
 
+func (w *WSClient) IsStarted() (bool) {
+	return atomic.LoadUint32(&w.started) != 0
+}
+
+func (w *WSClient) Done() (<-chan int) {
+	return w.finishResponseChan
+}
+
 func (w *WSClient) Stop() {
 	if atomic.LoadUint32(&w.started) == 0 {
 		log.Printf("not started")
 		return
 	}
-	close(w.finishRequestChan)
+	w.finishOnce.Do(func() { close(w.finishRequestChan) })
 	<-w.finishResponseChan
 }
 
@@ -185,5 +236,6 @@ func NewWSClient(readBufSize int, writeBufSize int, retryMax int, retryWait int,
 		started:               0,
 		finishRequestChan:     make(chan int),
 		finishResponseChan:    make(chan int),
+		finishOnce:            new(sync.Once),
 	}
 }
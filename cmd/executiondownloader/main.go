@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+	"github.com/potix/gobitflyer/api"
+	"github.com/potix/gobitflyer/api/types"
+	"github.com/potix/gobitflyer/executionstore"
+)
+
+// executiondownloader backfills executions of a product into gzipped json lines partitioned by day.
+// It resumes from the checkpoint, so it can be stopped and run again at any time.
+//
+//     executiondownloader -dir data -product BTC_JPY -days 30
+//     executiondownloader -dir data -product BTC_JPY -verify
+func main() {
+	dir := flag.String("dir", "executions", "directory to store executions")
+	productCode := flag.String("product", "BTC_JPY", "product code")
+	days := flag.Int("days", 1, "days to backfill")
+	update := flag.Bool("update", true, "download executions newer than the downloaded executions")
+	fillGaps := flag.Bool("fill-gaps", true, "download executions between the downloaded executions")
+	verify := flag.Bool("verify", false, "verify the downloaded executions only")
+	chunkSize := flag.Int("chunk-size", 10000, "executions written at once")
+	reserve := flag.Int64("reserve", 0, "read requests left for other clients")
+	endpoint := flag.String("endpoint", "", "rest api endpoint")
+	flag.Parse()
+
+	store, err := executionstore.Open(*dir, types.ProductCode(*productCode))
+	if err != nil {
+		log.Printf("can not open store: %v", err)
+		os.Exit(1)
+	}
+	if *verify {
+		report, err := store.Verify()
+		if err != nil {
+			log.Printf("can not verify store: %v", err)
+			os.Exit(1)
+		}
+		log.Printf("chunks %v, executions %v, ranges %v, gaps %v", report.Chunks, report.Executions, len(report.Ranges), len(report.Gaps))
+		for _, gap := range report.Gaps {
+			log.Printf("gap: %v - %v", gap.From, gap.To)
+		}
+		for _, problem := range report.Problems {
+			log.Printf("problem: %v", problem)
+		}
+		if len(report.Gaps) > 0 || len(report.Problems) > 0 {
+			os.Exit(2)
+		}
+		return
+	}
+
+	options := make([]api.ClientOption, 0)
+	if *endpoint != "" {
+		options = append(options, api.WithEndpoint(*endpoint))
+	}
+	apiClient := api.NewAPIClient(nil, nil, options...)
+	downloader := executionstore.NewDownloader(apiClient, store, types.ProductCode(*productCode))
+	downloader.SetChunkSize(*chunkSize)
+	downloader.SetReserve(*reserve)
+	downloader.SetVerbose(true)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	signalChan := make(chan os.Signal, 1)
+	signal.Notify(signalChan, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-signalChan
+		log.Printf("interrupted, the download resumes from the checkpoint next time")
+		cancel()
+	}()
+
+	if *update {
+		err := downloader.Update(ctx)
+		if err != nil {
+			log.Printf("can not update executions: %v", err)
+			os.Exit(1)
+		}
+	}
+	if *fillGaps {
+		err := downloader.FillGaps(ctx)
+		if err != nil {
+			log.Printf("can not fill gaps: %v", err)
+			os.Exit(1)
+		}
+	}
+	err = downloader.Backfill(ctx, time.Now().AddDate(0, 0, -*days))
+	if err != nil {
+		log.Printf("can not backfill executions: %v", err)
+		os.Exit(1)
+	}
+	log.Printf("downloaded ranges: %v", store.Ranges())
+}
@@ -0,0 +1,150 @@
+package executionstore
+
+import (
+	"context"
+	"log"
+	"time"
+	"github.com/pkg/errors"
+	"github.com/potix/gobitflyer/api"
+	"github.com/potix/gobitflyer/api/types"
+	"github.com/potix/gobitflyer/api/public"
+)
+
+const (
+	defaultChunkSize int   = 10000
+	maxPageCount     int64 = 500
+)
+
+// Downloader downloads executions into a store backward by id.
+type Downloader struct {
+	apiClient   *api.APIClient
+	store       *Store
+	productCode types.ProductCode
+	chunkSize   int
+	reserve     int64
+	verbose     bool
+}
+
+// SetChunkSize sets the number of executions written at once, which is also the work lost by an interruption.
+func (d *Downloader) SetChunkSize(chunkSize int) {
+	if chunkSize > 0 {
+		d.chunkSize = chunkSize
+	}
+}
+
+// SetReserve leaves reserve read requests of the rate limiter for other callers.
+func (d *Downloader) SetReserve(reserve int64) {
+	d.reserve = reserve
+}
+
+func (d *Downloader) SetVerbose(verbose bool) {
+	d.verbose = verbose
+}
+
+// download walks backward from before (0 is the newest) down to after (0 is no bound) or since,
+// and writes chunks of the ranges walked through.
+func (d *Downloader) download(ctx context.Context, before int64, after int64, since time.Time) (error) {
+	iterator := d.apiClient.PubGetExecutionsIterator(d.productCode, &api.PageOptions{
+		Count:    maxPageCount,
+		BeforeId: before,
+		AfterId:  after,
+		Since:    since,
+		Reserve:  d.reserve,
+	})
+	// executions from upper down to the last execution are known
+	upper := before - 1
+	executions := make([]*public.GetExecutionsExecution, 0, d.chunkSize)
+	flush := func(from int64) (error) {
+		if upper < from {
+			return nil
+		}
+		err := d.store.Write(from, upper, executions)
+		if err != nil {
+			return errors.Wrapf(err, "can not write executions (from = %v, to = %v)", from, upper)
+		}
+		if d.verbose {
+			log.Printf("downloaded %v executions (product code = %v, from = %v, to = %v)", len(executions), d.productCode, from, upper)
+		}
+		upper = from - 1
+		executions = executions[:0]
+		return nil
+	}
+	for {
+		execution, err := iterator.NextCtx(ctx)
+		if err == api.ErrIteratorDone {
+			break
+		}
+		if err != nil {
+			if len(executions) > 0 {
+				if flushErr := flush(executions[len(executions) - 1].Id); flushErr != nil {
+					log.Printf("can not save executions before error: %v", flushErr)
+				}
+			}
+			return errors.Wrapf(err, "can not get executions")
+		}
+		if before == 0 && upper == -1 {
+			upper = execution.Id
+		}
+		if len(executions) > 0 && executionDay(execution) != executionDay(executions[len(executions) - 1]) {
+			// the chunk of the later day starts after this execution
+			err := flush(execution.Id + 1)
+			if err != nil {
+				return err
+			}
+		}
+		executions = append(executions, execution)
+		if len(executions) >= d.chunkSize {
+			err := flush(execution.Id)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if after != 0 {
+		return flush(after + 1)
+	}
+	if len(executions) > 0 {
+		return flush(executions[len(executions) - 1].Id)
+	}
+	return nil
+}
+
+// Backfill downloads executions older than the downloaded ranges down to since. It resumes from the oldest
+// downloaded id, so it can be called again after an interruption.
+func (d *Downloader) Backfill(ctx context.Context, since time.Time) (error) {
+	ranges := d.store.Ranges()
+	before := int64(0)
+	if len(ranges) > 0 {
+		before = ranges[0].From
+	}
+	return d.download(ctx, before, 0, since)
+}
+
+// Update downloads executions newer than the downloaded ranges. It does nothing for an empty store, use Backfill.
+func (d *Downloader) Update(ctx context.Context) (error) {
+	ranges := d.store.Ranges()
+	if len(ranges) == 0 {
+		return nil
+	}
+	return d.download(ctx, 0, ranges[len(ranges) - 1].To, time.Time{})
+}
+
+// FillGaps downloads executions between the downloaded ranges.
+func (d *Downloader) FillGaps(ctx context.Context) (error) {
+	for _, gap := range d.store.Gaps() {
+		err := d.download(ctx, gap.To + 1, gap.From - 1, time.Time{})
+		if err != nil {
+			return errors.Wrapf(err, "can not fill gap (from = %v, to = %v)", gap.From, gap.To)
+		}
+	}
+	return nil
+}
+
+func NewDownloader(apiClient *api.APIClient, store *Store, productCode types.ProductCode) (*Downloader) {
+	return &Downloader{
+		apiClient:   apiClient,
+		store:       store,
+		productCode: productCode,
+		chunkSize:   defaultChunkSize,
+	}
+}
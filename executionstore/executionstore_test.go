@@ -0,0 +1,104 @@
+package executionstore_test
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"testing"
+	"github.com/potix/gobitflyer/api/types"
+	"github.com/potix/gobitflyer/api/public"
+	"github.com/potix/gobitflyer/bitflyertest"
+	"github.com/potix/gobitflyer/executionstore"
+)
+
+func TestExecutionStore(t *testing.T) {
+	server, apiClient, _ := bitflyertest.NewClients(t)
+	for i := 0; i < 25; i += 1 {
+		if err := server.Execute("BTC_JPY", types.SideSell, 0, 0.01); err != nil {
+			t.Fatalf("error: %v", err)
+		}
+	}
+	readAll := func(store *executionstore.Store) ([]int64) {
+		ids := make([]int64, 0)
+		err := store.Read(time.Time{}, time.Time{}, func(execution *public.GetExecutionsExecution) (error) {
+			ids = append(ids, execution.Id)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("error: %v", err)
+		}
+		return ids
+	}
+	verify := func(store *executionstore.Store, count int) {
+		report, err := store.Verify()
+		if err != nil {
+			t.Fatalf("error: %v", err)
+		}
+		if len(report.Gaps) != 0 || len(report.Problems) != 0 || report.Executions != int64(count) {
+			t.Fatalf("unexpected report: %#v", report)
+		}
+	}
+	dir := t.TempDir()
+	store, err := executionstore.Open(dir, "BTC_JPY")
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	downloader := executionstore.NewDownloader(apiClient, store, "BTC_JPY")
+	downloader.SetChunkSize(4)
+	if err := downloader.Backfill(context.Background(), time.Time{}); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	_, getExecutionsResponse, err := apiClient.PubGetExecutions("BTC_JPY", 500, 0, 0)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	ids := readAll(store)
+	if len(ids) != len(getExecutionsResponse) {
+		t.Fatalf("unexpected count: %v != %v", len(ids), len(getExecutionsResponse))
+	}
+	verify(store, len(ids))
+	for i := 0; i < 5; i += 1 {
+		if err := server.Execute("BTC_JPY", types.SideBuy, 0, 0.01); err != nil {
+			t.Fatalf("error: %v", err)
+		}
+	}
+	if err := downloader.Update(context.Background()); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	_, getExecutionsResponse, err = apiClient.PubGetExecutions("BTC_JPY", 500, 0, 0)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	ids = readAll(store)
+	if len(ids) != len(getExecutionsResponse) || ids[len(ids) - 1] != getExecutionsResponse[0].Id {
+		t.Fatalf("unexpected ids: %v", ids)
+	}
+	verify(store, len(ids))
+	// a store with a gap is filled and equals to the first store after reopen
+	gapStore, err := executionstore.Open(t.TempDir(), "BTC_JPY")
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	newest := getExecutionsResponse[:3]
+	oldest := getExecutionsResponse[len(getExecutionsResponse) - 3:]
+	if err := gapStore.Write(newest[2].Id, newest[0].Id, newest); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if err := gapStore.Write(oldest[2].Id, oldest[0].Id, oldest); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if gaps := gapStore.Gaps(); len(gaps) != 1 || gaps[0].From != oldest[0].Id + 1 || gaps[0].To != newest[2].Id - 1 {
+		t.Fatalf("unexpected gaps: %v", gaps)
+	}
+	if err := executionstore.NewDownloader(apiClient, gapStore, "BTC_JPY").FillGaps(context.Background()); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	reopened, err := executionstore.Open(dir, "BTC_JPY")
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if filled := readAll(gapStore); fmt.Sprint(filled) != fmt.Sprint(readAll(reopened)) {
+		t.Fatalf("unexpected ids: %v", filled)
+	}
+	verify(gapStore, len(ids))
+}
@@ -0,0 +1,409 @@
+package executionstore
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"github.com/pkg/errors"
+	"github.com/potix/gobitflyer/api/types"
+	"github.com/potix/gobitflyer/api/public"
+)
+
+const (
+	dayLayout      string = "2006-01-02"
+	chunkSuffix    string = ".jsonl.gz"
+	checkpointName string = "checkpoint.json"
+)
+
+// Range is an inclusive range of execution ids.
+type Range struct {
+	From int64 `json:"from"`
+	To   int64 `json:"to"`
+}
+
+type checkpoint struct {
+	Ranges []*Range `json:"ranges"`
+}
+
+// mergeRanges returns sorted ranges without overlaps, adjacent ranges are merged.
+func mergeRanges(ranges []*Range) ([]*Range) {
+	sorted := make([]*Range, 0, len(ranges))
+	for _, r := range ranges {
+		sorted = append(sorted, &Range{From: r.From, To: r.To})
+	}
+	sort.Slice(sorted, func(i, j int) (bool) { return sorted[i].From < sorted[j].From })
+	merged := make([]*Range, 0, len(sorted))
+	for _, r := range sorted {
+		if len(merged) > 0 && r.From <= merged[len(merged) - 1].To + 1 {
+			if r.To > merged[len(merged) - 1].To {
+				merged[len(merged) - 1].To = r.To
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+type chunk struct {
+	day  string
+	path string
+	from int64
+	to   int64
+}
+
+// Store keeps executions of a product in gzipped json lines partitioned by day (UTC).
+// A day directory has chunk files named by the id range they cover, <from>-<to>.jsonl.gz,
+// and checkpoint.json records every downloaded id range including ranges without executions.
+//
+//     <dir>/<product code>/checkpoint.json
+//     <dir>/<product code>/<yyyy-mm-dd>/<from>-<to>.jsonl.gz
+type Store struct {
+	dir    string
+	mutex  *sync.Mutex
+	ranges []*Range
+}
+
+func parseChunkName(name string) (int64, int64, bool) {
+	if !strings.HasSuffix(name, chunkSuffix) {
+		return 0, 0, false
+	}
+	ids := strings.SplitN(strings.TrimSuffix(name, chunkSuffix), "-", 2)
+	if len(ids) != 2 {
+		return 0, 0, false
+	}
+	from, err := strconv.ParseInt(ids[0], 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	to, err := strconv.ParseInt(ids[1], 10, 64)
+	if err != nil || to < from {
+		return 0, 0, false
+	}
+	return from, to, true
+}
+
+// chunks returns chunk files sorted by id.
+func (s *Store) chunks() ([]*chunk, error) {
+	dayInfos, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "can not read directory (dir = %v)", s.dir)
+	}
+	chunks := make([]*chunk, 0)
+	for _, dayInfo := range dayInfos {
+		if !dayInfo.IsDir() {
+			continue
+		}
+		if _, err := time.Parse(dayLayout, dayInfo.Name()); err != nil {
+			continue
+		}
+		dayDir := filepath.Join(s.dir, dayInfo.Name())
+		chunkInfos, err := ioutil.ReadDir(dayDir)
+		if err != nil {
+			return nil, errors.Wrapf(err, "can not read directory (dir = %v)", dayDir)
+		}
+		for _, chunkInfo := range chunkInfos {
+			from, to, ok := parseChunkName(chunkInfo.Name())
+			if !ok {
+				continue
+			}
+			chunks = append(chunks, &chunk{
+				day:  dayInfo.Name(),
+				path: filepath.Join(dayDir, chunkInfo.Name()),
+				from: from,
+				to:   to,
+			})
+		}
+	}
+	sort.Slice(chunks, func(i, j int) (bool) { return chunks[i].from < chunks[j].from })
+	return chunks, nil
+}
+
+func (s *Store) saveCheckpoint() (error) {
+	data, err := json.Marshal(&checkpoint{Ranges: s.ranges})
+	if err != nil {
+		return errors.Wrapf(err, "can not marshal checkpoint")
+	}
+	return writeFileAtomic(filepath.Join(s.dir, checkpointName), data)
+}
+
+func writeFileAtomic(path string, data []byte) (error) {
+	tmpFile, err := ioutil.TempFile(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return errors.Wrapf(err, "can not create temporary file (path = %v)", path)
+	}
+	_, err = tmpFile.Write(data)
+	if err == nil {
+		err = tmpFile.Sync()
+	}
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpFile.Name())
+		return errors.Wrapf(err, "can not write temporary file (path = %v)", path)
+	}
+	err = os.Rename(tmpFile.Name(), path)
+	if err != nil {
+		os.Remove(tmpFile.Name())
+		return errors.Wrapf(err, "can not rename temporary file (path = %v)", path)
+	}
+	return nil
+}
+
+// Ranges returns the downloaded id ranges in ascending order.
+func (s *Store) Ranges() ([]Range) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	ranges := make([]Range, 0, len(s.ranges))
+	for _, r := range s.ranges {
+		ranges = append(ranges, *r)
+	}
+	return ranges
+}
+
+// Gaps returns the id ranges between the downloaded ranges.
+func (s *Store) Gaps() ([]Range) {
+	ranges := s.Ranges()
+	gaps := make([]Range, 0)
+	for i := 1; i < len(ranges); i += 1 {
+		gaps = append(gaps, Range{From: ranges[i - 1].To + 1, To: ranges[i].From - 1})
+	}
+	return gaps
+}
+
+func executionDay(execution *public.GetExecutionsExecution) (string) {
+	return execution.ExecDate.UTC().Format(dayLayout)
+}
+
+func (s *Store) writeChunk(day string, from int64, to int64, executions []*public.GetExecutionsExecution) (error) {
+	dayDir := filepath.Join(s.dir, day)
+	err := os.MkdirAll(dayDir, 0755)
+	if err != nil {
+		return errors.Wrapf(err, "can not create directory (dir = %v)", dayDir)
+	}
+	buffer := new(bytes.Buffer)
+	gzipWriter := gzip.NewWriter(buffer)
+	encoder := json.NewEncoder(gzipWriter)
+	for _, execution := range executions {
+		err := encoder.Encode(execution)
+		if err != nil {
+			return errors.Wrapf(err, "can not encode execution (id = %v)", execution.Id)
+		}
+	}
+	err = gzipWriter.Close()
+	if err != nil {
+		return errors.Wrapf(err, "can not compress chunk")
+	}
+	return writeFileAtomic(filepath.Join(dayDir, fmt.Sprintf("%d-%d%s", from, to, chunkSuffix)), buffer.Bytes())
+}
+
+// Write stores executions which are all executions of the product with ids from from to to.
+// The executions are split into chunks by day and the range is recorded in the checkpoint.
+func (s *Store) Write(from int64, to int64, executions []*public.GetExecutionsExecution) (error) {
+	if to < from {
+		return errors.Errorf("invalid range (from = %v, to = %v)", from, to)
+	}
+	sorted := make([]*public.GetExecutionsExecution, 0, len(executions))
+	for _, execution := range executions {
+		if execution.Id < from || execution.Id > to {
+			return errors.Errorf("execution is out of range (id = %v, from = %v, to = %v)", execution.Id, from, to)
+		}
+		sorted = append(sorted, execution)
+	}
+	sort.Slice(sorted, func(i, j int) (bool) { return sorted[i].Id < sorted[j].Id })
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	// chunks left by an interrupted write of the same range are replaced
+	chunks, err := s.chunks()
+	if err != nil {
+		return err
+	}
+	for _, c := range chunks {
+		if c.from >= from && c.to <= to {
+			err := os.Remove(c.path)
+			if err != nil {
+				return errors.Wrapf(err, "can not remove chunk (path = %v)", c.path)
+			}
+		}
+	}
+	chunkFrom := from
+	start := 0
+	for i := 1; i <= len(sorted); i += 1 {
+		if i < len(sorted) && executionDay(sorted[i]) == executionDay(sorted[start]) {
+			continue
+		}
+		// the chunk of a day covers ids up to the first execution of the next day
+		chunkTo := to
+		if i < len(sorted) {
+			chunkTo = sorted[i].Id - 1
+		}
+		err := s.writeChunk(executionDay(sorted[start]), chunkFrom, chunkTo, sorted[start:i])
+		if err != nil {
+			return err
+		}
+		chunkFrom = chunkTo + 1
+		start = i
+	}
+	s.ranges = mergeRanges(append(s.ranges, &Range{From: from, To: to}))
+	return s.saveCheckpoint()
+}
+
+func readChunk(path string, callback func(execution *public.GetExecutionsExecution) (error)) (error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return errors.Wrapf(err, "can not open chunk (path = %v)", path)
+	}
+	defer file.Close()
+	gzipReader, err := gzip.NewReader(file)
+	if err != nil {
+		return errors.Wrapf(err, "can not read chunk (path = %v)", path)
+	}
+	defer gzipReader.Close()
+	scanner := bufio.NewScanner(gzipReader)
+	scanner.Buffer(make([]byte, 0, 64 * 1024), 1024 * 1024)
+	for scanner.Scan() {
+		execution := new(public.GetExecutionsExecution)
+		err := json.Unmarshal(scanner.Bytes(), execution)
+		if err != nil {
+			return errors.Wrapf(err, "can not unmarshal execution (path = %v)", path)
+		}
+		err = callback(execution)
+		if err != nil {
+			return err
+		}
+	}
+	err = scanner.Err()
+	if err != nil {
+		return errors.Wrapf(err, "can not read chunk (path = %v)", path)
+	}
+	return nil
+}
+
+// Read calls callback with executions between since and until (zero means no bound) in ascending order of id.
+func (s *Store) Read(since time.Time, until time.Time, callback func(execution *public.GetExecutionsExecution) (error)) (error) {
+	chunks, err := s.chunks()
+	if err != nil {
+		return err
+	}
+	sinceDay := ""
+	if !since.IsZero() {
+		sinceDay = since.UTC().Format(dayLayout)
+	}
+	untilDay := ""
+	if !until.IsZero() {
+		untilDay = until.UTC().Format(dayLayout)
+	}
+	for _, c := range chunks {
+		if (sinceDay != "" && c.day < sinceDay) || (untilDay != "" && c.day > untilDay) {
+			continue
+		}
+		err := readChunk(c.path, func(execution *public.GetExecutionsExecution) (error) {
+			if !since.IsZero() && execution.ExecDate.Before(since) {
+				return nil
+			}
+			if !until.IsZero() && !execution.ExecDate.Before(until) {
+				return nil
+			}
+			return callback(execution)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type Report struct {
+	Chunks     int64
+	Executions int64
+	Ranges     []Range
+	Gaps       []Range
+	// inconsistencies, e.g. overlapped chunks or executions out of order
+	Problems   []string
+}
+
+// Verify reads every chunk and checks that ids are in ascending order without duplicates,
+// executions are in the range and the day of the chunk, and the ranges are continuous.
+func (s *Store) Verify() (*Report, error) {
+	chunks, err := s.chunks()
+	if err != nil {
+		return nil, err
+	}
+	report := &Report{
+		Ranges:   s.Ranges(),
+		Gaps:     s.Gaps(),
+		Problems: make([]string, 0),
+	}
+	lastId := int64(0)
+	lastTo := int64(0)
+	for _, c := range chunks {
+		report.Chunks += 1
+		if lastTo != 0 && c.from <= lastTo {
+			report.Problems = append(report.Problems, fmt.Sprintf("chunk overlaps the previous chunk (path = %v, previous to = %v)", c.path, lastTo))
+		}
+		lastTo = c.to
+		err := readChunk(c.path, func(execution *public.GetExecutionsExecution) (error) {
+			report.Executions += 1
+			if execution.Id <= lastId {
+				report.Problems = append(report.Problems, fmt.Sprintf("execution is out of order (path = %v, id = %v, previous id = %v)", c.path, execution.Id, lastId))
+			}
+			lastId = execution.Id
+			if execution.Id < c.from || execution.Id > c.to {
+				report.Problems = append(report.Problems, fmt.Sprintf("execution is out of range (path = %v, id = %v)", c.path, execution.Id))
+			}
+			if executionDay(execution) != c.day {
+				report.Problems = append(report.Problems, fmt.Sprintf("execution is in another day (path = %v, id = %v, date = %v)", c.path, execution.Id, execution.ExecDate))
+			}
+			return nil
+		})
+		if err != nil {
+			report.Problems = append(report.Problems, err.Error())
+		}
+	}
+	return report, nil
+}
+
+// Open opens the store of productCode under dir. The ranges of chunk files which are not in the checkpoint
+// (e.g. after an interruption) are recovered.
+func Open(dir string, productCode types.ProductCode) (*Store, error) {
+	s := &Store{
+		dir:    filepath.Join(dir, string(productCode)),
+		mutex:  new(sync.Mutex),
+		ranges: make([]*Range, 0),
+	}
+	err := os.MkdirAll(s.dir, 0755)
+	if err != nil {
+		return nil, errors.Wrapf(err, "can not create directory (dir = %v)", s.dir)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(s.dir, checkpointName))
+	if err == nil {
+		cp := new(checkpoint)
+		err := json.Unmarshal(data, cp)
+		if err != nil {
+			return nil, errors.Wrapf(err, "can not unmarshal checkpoint")
+		}
+		s.ranges = append(s.ranges, cp.Ranges...)
+	} else if !os.IsNotExist(err) {
+		return nil, errors.Wrapf(err, "can not read checkpoint")
+	}
+	chunks, err := s.chunks()
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range chunks {
+		s.ranges = append(s.ranges, &Range{From: c.from, To: c.to})
+	}
+	s.ranges = mergeRanges(s.ranges)
+	return s, nil
+}
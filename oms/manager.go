@@ -0,0 +1,557 @@
+package oms
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+	"github.com/pkg/errors"
+	"github.com/potix/gobitflyer/api"
+	"github.com/potix/gobitflyer/api/types"
+	"github.com/potix/gobitflyer/api/private"
+	"github.com/potix/gobitflyer/api/realtime"
+)
+
+const (
+	defaultNotFoundTimeout time.Duration = time.Minute
+	// items per page of a sync
+	syncCount              int64         = 100
+)
+
+type Options struct {
+	// an order which the exchange does not report within this time after it was sent is a mismatch,
+	// the default is a minute
+	NotFoundTimeout time.Duration
+}
+
+// Manager tracks child orders sent through its middleware. The state is updated by Sync from the rest api
+// and by HandleChildOrderEvents from the realtime api, and the rest api is authoritative.
+type Manager struct {
+	privateAPI       api.PrivateAPI
+	options          *Options
+	mutex            *sync.Mutex
+	orders           map[string]*Order
+	childOrderIds    map[string]string
+	lastExecutionIds map[types.ProductCode]int64
+	// executions before this of orders which are not tracked are ignored, in milliseconds as exec_date
+	createdAt        time.Time
+	cancel           context.CancelFunc
+}
+
+func (m *Manager) track(productCode types.ProductCode, childOrderType types.OrderType, side types.Side, price float64, size float64, childOrderAcceptanceId string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if _, ok := m.orders[childOrderAcceptanceId]; ok {
+		// already reported by the realtime api
+		return
+	}
+	now := time.Now()
+	m.orders[childOrderAcceptanceId] = &Order{
+		ProductCode:            productCode,
+		ChildOrderAcceptanceId: childOrderAcceptanceId,
+		ChildOrderType:         childOrderType,
+		Side:                   side,
+		Price:                  price,
+		Size:                   size,
+		SentAt:                 now,
+		UpdatedAt:              now,
+		Fills:                  make([]*Fill, 0),
+	}
+}
+
+func (m *Manager) lookup(idType types.IdType, orderId string) (*Order, bool) {
+	if idType == types.IdTypeChildOrderId {
+		childOrderAcceptanceId, ok := m.childOrderIds[orderId]
+		if !ok {
+			return nil, false
+		}
+		orderId = childOrderAcceptanceId
+	}
+	order, ok := m.orders[orderId]
+	return order, ok
+}
+
+// cancelRequested marks open orders of productCode, or only the order of orderId unless it is empty.
+func (m *Manager) cancelRequested(productCode types.ProductCode, idType types.IdType, orderId string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for _, order := range m.orders {
+		if order.ProductCode != productCode || !order.Open() {
+			continue
+		}
+		if orderId == "" || (idType == types.IdTypeChildOrderId && order.ChildOrderId == orderId) ||
+		   (idType == types.IdTypeChildOrderAcceptanceId && order.ChildOrderAcceptanceId == orderId) {
+			order.CancelRequested = true
+		}
+	}
+}
+
+func (m *Manager) observe(call *api.Call, result interface{}) {
+	switch call.Method {
+	case "PriSendChildOrder":
+		sendChildOrderResponse, ok := result.(*private.SendChildOrderResponse)
+		if !ok || sendChildOrderResponse == nil || len(call.Args) < 5 {
+			return
+		}
+		productCode, _ := call.Args[0].(types.ProductCode)
+		childOrderType, _ := call.Args[1].(types.OrderType)
+		side, _ := call.Args[2].(types.Side)
+		price, _ := call.Args[3].(float64)
+		size, _ := call.Args[4].(float64)
+		m.track(productCode, childOrderType, side, price, size, sendChildOrderResponse.ChildOrderAcceptanceId)
+	case "PriCancelChildOrder":
+		if len(call.Args) < 3 {
+			return
+		}
+		productCode, _ := call.Args[0].(types.ProductCode)
+		idType, _ := call.Args[1].(types.IdType)
+		orderId, _ := call.Args[2].(string)
+		if orderId == "" {
+			return
+		}
+		m.cancelRequested(productCode, idType, orderId)
+	case "PriCancelAllChildOrders":
+		if len(call.Args) < 1 {
+			return
+		}
+		productCode, _ := call.Args[0].(types.ProductCode)
+		m.cancelRequested(productCode, 0, "")
+	}
+}
+
+// Middleware records orders and cancels which succeeded through a chain, e.g. api.ChainAPI(apiClient, manager.Middleware()).
+func (m *Manager) Middleware() (api.Middleware) {
+	return func(next api.Handler) (api.Handler) {
+		return func(ctx context.Context, call *api.Call) (*http.Response, interface{}, error) {
+			response, result, err := next(ctx, call)
+			if err == nil {
+				m.observe(call, result)
+			}
+			return response, result, err
+		}
+	}
+}
+
+func (m *Manager) addFill(order *Order, fill *Fill) (bool) {
+	if order.hasFill(fill.Id) {
+		return false
+	}
+	order.Fills = append(order.Fills, fill)
+	return true
+}
+
+// HandleChildOrderEvents is a realtime.ChildOrderEventsCallback, e.g. RealChildOrderEventsStart(manager.HandleChildOrderEvents, nil).
+// Orders which were not sent through the middleware are tracked from their ORDER events.
+func (m *Manager) HandleChildOrderEvents(childOrderEvents realtime.ChildOrderEvents, callbackData interface{}) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for _, event := range childOrderEvents {
+		order, ok := m.orders[event.ChildOrderAcceptanceId]
+		if !ok {
+			if event.EventType != types.EventTypeOrder {
+				continue
+			}
+			order = &Order{
+				ProductCode:            event.ProductCode,
+				ChildOrderAcceptanceId: event.ChildOrderAcceptanceId,
+				ChildOrderType:         event.ChildOrderType,
+				Side:                   event.Side,
+				Price:                  event.Price,
+				Size:                   event.Size,
+				SentAt:                 event.EventDate.Time,
+				Fills:                  make([]*Fill, 0),
+			}
+			m.orders[event.ChildOrderAcceptanceId] = order
+		}
+		if event.ChildOrderId != "" {
+			order.ChildOrderId = event.ChildOrderId
+			m.childOrderIds[event.ChildOrderId] = event.ChildOrderAcceptanceId
+		}
+		switch event.EventType {
+		case types.EventTypeOrder:
+			if order.State == types.OrderStateNone {
+				order.State = types.OrderStateActive
+			}
+		case types.EventTypeOrderFailed:
+			order.State = types.OrderStateRejected
+		case types.EventTypeCancel:
+			order.State = types.OrderStateCanceled
+			order.CancelRequested = false
+		case types.EventTypeCancelFailed:
+			order.CancelRequested = false
+		case types.EventTypeExecution:
+			added := m.addFill(order, &Fill{
+				Id:                     event.ExecId,
+				ProductCode:            order.ProductCode,
+				ChildOrderId:           event.ChildOrderId,
+				ChildOrderAcceptanceId: event.ChildOrderAcceptanceId,
+				Side:                   event.Side,
+				Price:                  event.Price,
+				Size:                   event.Size,
+				Commission:             event.Commission,
+				ExecDate:               event.EventDate.Time,
+			})
+			if added {
+				executedSize := round(order.ExecutedSize + event.Size)
+				order.AveragePrice = (order.AveragePrice * order.ExecutedSize + event.Price * event.Size) / executedSize
+				order.ExecutedSize = executedSize
+				order.TotalCommission = round(order.TotalCommission + event.Commission)
+			}
+			if event.OutstandingSize == 0 {
+				order.State = types.OrderStateCompleted
+				order.CancelRequested = false
+			}
+		case types.EventTypeExpire:
+			order.State = types.OrderStateExpired
+			order.CancelRequested = false
+		}
+		order.UpdatedAt = event.EventDate.Time
+	}
+}
+
+// applyOrder overwrites the local state with the exchange, and records the disagreement of the state before it.
+func (m *Manager) applyOrder(childOrder *private.GetChildOrdersOrder, now time.Time) {
+	order, ok := m.orders[childOrder.ChildOrderAcceptanceId]
+	if !ok {
+		order = &Order{
+			ProductCode:            childOrder.ProductCode,
+			ChildOrderAcceptanceId: childOrder.ChildOrderAcceptanceId,
+			ChildOrderType:         childOrder.ChildOrderType,
+			Side:                   childOrder.Side,
+			Price:                  childOrder.Price.Float64(),
+			Size:                   childOrder.Size.Float64(),
+			SentAt:                 childOrder.ChildOrderDate.Time,
+			Fills:                  make([]*Fill, 0),
+		}
+		m.orders[childOrder.ChildOrderAcceptanceId] = order
+	}
+	mismatch := ""
+	if !order.Open() && order.State != childOrder.ChildOrderState {
+		mismatch = fmt.Sprintf("local state %v, exchange state %v", order.State, childOrder.ChildOrderState)
+	}
+	order.ChildOrderId = childOrder.ChildOrderId
+	m.childOrderIds[childOrder.ChildOrderId] = childOrder.ChildOrderAcceptanceId
+	order.State = childOrder.ChildOrderState
+	order.ExecutedSize = childOrder.ExecutedSize.Float64()
+	order.AveragePrice = childOrder.AveragePrice.Float64()
+	order.TotalCommission = childOrder.TotalCommission.Float64()
+	if !order.Open() {
+		order.CancelRequested = false
+	}
+	order.Mismatch = mismatch
+	order.UpdatedAt = now
+}
+
+// checkFills records the disagreement of fills with the executed size on the exchange.
+func (m *Manager) checkFills(order *Order) {
+	if filledSize := order.filledSize(); order.Mismatch == "" && filledSize != order.ExecutedSize {
+		order.Mismatch = fmt.Sprintf("filled size %v, exchange executed size %v", filledSize, order.ExecutedSize)
+	}
+}
+
+// syncSince returns the time from which executions are needed at the first sync of productCode.
+func (m *Manager) syncSince(productCode types.ProductCode, reported map[string]*private.GetChildOrdersOrder) (time.Time) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	since := m.createdAt
+	for _, order := range m.orders {
+		if order.ProductCode == productCode && order.SentAt.Before(since) {
+			since = order.SentAt
+		}
+	}
+	for _, childOrder := range reported {
+		if childOrder.ChildOrderDate.Before(since) {
+			since = childOrder.ChildOrderDate.Time
+		}
+	}
+	return since
+}
+
+// getExecutions returns executions of the account after the last sync, or executions since since at the first sync,
+// in order of id.
+func (m *Manager) getExecutions(ctx context.Context, productCode types.ProductCode, since time.Time) (private.GetExecutionsResponse, error) {
+	m.mutex.Lock()
+	after := m.lastExecutionIds[productCode]
+	m.mutex.Unlock()
+	executions := make(private.GetExecutionsResponse, 0)
+	before := int64(0)
+	for {
+		_, getExecutionsResponse, err := m.privateAPI.PriGetExecutionsCtx(ctx, productCode, syncCount, before, after)
+		if err != nil {
+			return nil, errors.Wrapf(err, "can not get executions (product code = %v)", productCode)
+		}
+		executions = append(executions, getExecutionsResponse...)
+		if int64(len(getExecutionsResponse)) < syncCount {
+			break
+		}
+		oldest := getExecutionsResponse[len(getExecutionsResponse) - 1]
+		if after == 0 && oldest.ExecDate.Before(since) {
+			break
+		}
+		before = oldest.Id
+	}
+	sort.Slice(executions, func(i, j int) (bool) {
+		return executions[i].Id < executions[j].Id
+	})
+	return executions, nil
+}
+
+func (m *Manager) Sync(productCode types.ProductCode) (error) {
+	return m.SyncCtx(context.Background(), productCode)
+}
+
+// SyncCtx updates orders and fills of productCode from the rest api. Active orders on the exchange which are not
+// tracked yet are tracked from now, and so are orders executed after the manager was created.
+func (m *Manager) SyncCtx(ctx context.Context, productCode types.ProductCode) (error) {
+	_, activeOrders, err := m.privateAPI.PriGetChildOrdersCtx(ctx, productCode, syncCount, 0, 0, types.OrderStateActive)
+	if err != nil {
+		return errors.Wrapf(err, "can not get active child orders (product code = %v)", productCode)
+	}
+	reported := make(map[string]*private.GetChildOrdersOrder)
+	for _, childOrder := range activeOrders {
+		reported[childOrder.ChildOrderAcceptanceId] = childOrder
+	}
+	// open orders which are not active on the exchange any more
+	m.mutex.Lock()
+	finished := make([]string, 0)
+	for childOrderAcceptanceId, order := range m.orders {
+		if _, ok := reported[childOrderAcceptanceId]; !ok && order.ProductCode == productCode && order.Open() {
+			finished = append(finished, childOrderAcceptanceId)
+		}
+	}
+	m.mutex.Unlock()
+	notFound := make(map[string]bool)
+	for _, childOrderAcceptanceId := range finished {
+		childOrder, ok, err := m.getChildOrder(ctx, productCode, childOrderAcceptanceId)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			notFound[childOrderAcceptanceId] = true
+			continue
+		}
+		reported[childOrderAcceptanceId] = childOrder
+	}
+	executions, err := m.getExecutions(ctx, productCode, m.syncSince(productCode, reported))
+	if err != nil {
+		return err
+	}
+	// orders of executions which are not known yet
+	m.mutex.Lock()
+	unknown := make([]string, 0)
+	for _, execution := range executions {
+		_, tracked := m.orders[execution.ChildOrderAcceptanceId]
+		_, ok := reported[execution.ChildOrderAcceptanceId]
+		if !tracked && !ok && !notFound[execution.ChildOrderAcceptanceId] && !execution.ExecDate.Before(m.createdAt) {
+			unknown = append(unknown, execution.ChildOrderAcceptanceId)
+		}
+	}
+	m.mutex.Unlock()
+	for _, childOrderAcceptanceId := range unknown {
+		if _, ok := reported[childOrderAcceptanceId]; ok || notFound[childOrderAcceptanceId] {
+			continue
+		}
+		childOrder, ok, err := m.getChildOrder(ctx, productCode, childOrderAcceptanceId)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			notFound[childOrderAcceptanceId] = true
+			continue
+		}
+		reported[childOrderAcceptanceId] = childOrder
+	}
+	now := time.Now()
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for _, childOrder := range reported {
+		m.applyOrder(childOrder, now)
+	}
+	// the cursor stops before the first execution whose order can not be resolved, so that it is retried at the next sync
+	resolved := true
+	for _, execution := range executions {
+		order, ok := m.orders[execution.ChildOrderAcceptanceId]
+		if !ok {
+			if !execution.ExecDate.Before(m.createdAt) {
+				resolved = false
+			}
+			// executed before the manager was created, skipped
+		} else {
+			m.addFill(order, &Fill{
+				Id:                     execution.Id,
+				ProductCode:            productCode,
+				ChildOrderId:           execution.ChildOrderId,
+				ChildOrderAcceptanceId: execution.ChildOrderAcceptanceId,
+				Side:                   execution.Side,
+				Price:                  execution.Price.Float64(),
+				Size:                   execution.Size.Float64(),
+				Commission:             execution.Commission.Float64(),
+				ExecDate:               execution.ExecDate.Time,
+			})
+		}
+		if resolved && execution.Id > m.lastExecutionIds[productCode] {
+			m.lastExecutionIds[productCode] = execution.Id
+		}
+	}
+	for childOrderAcceptanceId := range reported {
+		m.checkFills(m.orders[childOrderAcceptanceId])
+	}
+	for childOrderAcceptanceId := range notFound {
+		order, ok := m.orders[childOrderAcceptanceId]
+		if !ok {
+			continue
+		}
+		if order.State == types.OrderStateNone && now.Sub(order.SentAt) < m.options.NotFoundTimeout {
+			// not accepted by the exchange yet
+			order.Mismatch = ""
+			continue
+		}
+		order.Mismatch = fmt.Sprintf("local state %v, not found on the exchange", order.State)
+	}
+	return nil
+}
+
+// getChildOrder returns the order of childOrderAcceptanceId on the exchange, or false if the exchange does not report it.
+func (m *Manager) getChildOrder(ctx context.Context, productCode types.ProductCode, childOrderAcceptanceId string) (*private.GetChildOrdersOrder, bool, error) {
+	_, childOrders, err := m.privateAPI.PriGetChildOrdersByIdCtx(ctx, productCode, types.IdTypeChildOrderAcceptanceId, childOrderAcceptanceId)
+	if err != nil {
+		return nil, false, errors.Wrapf(err, "can not get child order (acceptance id = %v)", childOrderAcceptanceId)
+	}
+	if len(childOrders) == 0 {
+		return nil, false, nil
+	}
+	return childOrders[0], true, nil
+}
+
+// Start syncs productCodes every interval until Stop. Errors are logged.
+func (m *Manager) Start(interval time.Duration, productCodes ...types.ProductCode) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.cancel != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancel = cancel
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				for _, productCode := range productCodes {
+					if err := m.SyncCtx(ctx, productCode); err != nil && ctx.Err() == nil {
+						log.Printf("can not sync orders (product code = %v): %v", productCode, err)
+					}
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+func (m *Manager) Stop() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.cancel != nil {
+		m.cancel()
+		m.cancel = nil
+	}
+}
+
+// Order returns a copy of the order of childOrderAcceptanceId.
+func (m *Manager) Order(childOrderAcceptanceId string) (*Order, bool) {
+	return m.OrderById(types.IdTypeChildOrderAcceptanceId, childOrderAcceptanceId)
+}
+
+// OrderById returns a copy of the order of types.IdTypeChildOrderId or types.IdTypeChildOrderAcceptanceId.
+func (m *Manager) OrderById(idType types.IdType, orderId string) (*Order, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	order, ok := m.lookup(idType, orderId)
+	if !ok {
+		return nil, false
+	}
+	return order.copy(), true
+}
+
+func (m *Manager) selectOrders(productCode types.ProductCode, selector func(order *Order) (bool)) ([]*Order) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	orders := make([]*Order, 0)
+	for _, order := range m.orders {
+		if order.ProductCode == productCode && selector(order) {
+			orders = append(orders, order.copy())
+		}
+	}
+	sort.Slice(orders, func(i, j int) (bool) {
+		return orders[i].SentAt.Before(orders[j].SentAt)
+	})
+	return orders
+}
+
+// OpenOrders returns copies of open orders of productCode in order of sending.
+func (m *Manager) OpenOrders(productCode types.ProductCode) ([]*Order) {
+	return m.selectOrders(productCode, func(order *Order) (bool) {
+		return order.Open()
+	})
+}
+
+// Mismatches returns copies of orders of productCode which disagreed with the exchange at the latest sync.
+func (m *Manager) Mismatches(productCode types.ProductCode) ([]*Order) {
+	return m.selectOrders(productCode, func(order *Order) (bool) {
+		return order.Mismatch != ""
+	})
+}
+
+// Fills returns fills of tracked orders of productCode in order of execution.
+func (m *Manager) Fills(productCode types.ProductCode) ([]*Fill) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	fills := make([]*Fill, 0)
+	for _, order := range m.orders {
+		if order.ProductCode == productCode {
+			fills = append(fills, order.Fills...)
+		}
+	}
+	sort.Slice(fills, func(i, j int) (bool) {
+		return fills[i].Id < fills[j].Id
+	})
+	return fills
+}
+
+// Prune forgets finished orders which were updated before.
+func (m *Manager) Prune(before time.Time) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for childOrderAcceptanceId, order := range m.orders {
+		if order.Open() || !order.UpdatedAt.Before(before) {
+			continue
+		}
+		delete(m.orders, childOrderAcceptanceId)
+		delete(m.childOrderIds, order.ChildOrderId)
+	}
+}
+
+// NewManager creates a manager which syncs with privateAPI, nil options are the defaults.
+func NewManager(privateAPI api.PrivateAPI, options *Options) (*Manager) {
+	o := Options{}
+	if options != nil {
+		o = *options
+	}
+	if o.NotFoundTimeout <= 0 {
+		o.NotFoundTimeout = defaultNotFoundTimeout
+	}
+	return &Manager{
+		privateAPI:       privateAPI,
+		options:          &o,
+		mutex:            new(sync.Mutex),
+		orders:           make(map[string]*Order),
+		childOrderIds:    make(map[string]string),
+		lastExecutionIds: make(map[types.ProductCode]int64),
+		createdAt:        time.Now().Truncate(time.Millisecond),
+	}
+}
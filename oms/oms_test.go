@@ -0,0 +1,135 @@
+package oms_test
+
+import (
+	"time"
+	"testing"
+	"github.com/potix/gobitflyer/api/types"
+	"github.com/potix/gobitflyer/api/realtime"
+	"github.com/potix/gobitflyer/api"
+	"github.com/potix/gobitflyer/bitflyertest"
+	"github.com/potix/gobitflyer/oms"
+)
+
+func TestOrderManagement(t *testing.T) {
+	server, apiClient, realApiClient := bitflyertest.NewClients(t)
+	manager := oms.NewManager(apiClient, nil)
+	chained := api.ChainAPI(apiClient, manager.Middleware())
+	_, sendChildOrderResponse, err := chained.PriSendChildOrder("BTC_JPY", types.OrderTypeLimit, types.SideBuy, 1000000, 0.3, 0, types.TimeInForceGTC)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	acceptanceId := sendChildOrderResponse.ChildOrderAcceptanceId
+	if orders := manager.OpenOrders("BTC_JPY"); len(orders) != 1 || orders[0].State != types.OrderStateNone {
+		t.Fatalf("unexpected open orders: %v", orders)
+	}
+	if err := server.Execute("BTC_JPY", types.SideSell, 1000000, 0.1); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if err := manager.Sync("BTC_JPY"); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	order, ok := manager.Order(acceptanceId)
+	if !ok || order.State != types.OrderStateActive || order.ChildOrderId == "" || order.ExecutedSize != 0.1 || len(order.Fills) != 1 || order.Mismatch != "" {
+		t.Fatalf("unexpected order: %#v", order)
+	}
+	if byId, ok := manager.OrderById(types.IdTypeChildOrderId, order.ChildOrderId); !ok || byId.ChildOrderAcceptanceId != acceptanceId {
+		t.Errorf("unexpected order by id: %#v", byId)
+	}
+
+	if _, err := chained.PriCancelChildOrder("BTC_JPY", types.IdTypeChildOrderAcceptanceId, acceptanceId); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if order, _ := manager.Order(acceptanceId); !order.CancelRequested {
+		t.Errorf("cancel is not recorded: %#v", order)
+	}
+	if err := manager.Sync("BTC_JPY"); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if order, _ := manager.Order(acceptanceId); order.State != types.OrderStateCanceled || order.CancelRequested {
+		t.Errorf("unexpected order: %#v", order)
+	}
+	if orders := manager.OpenOrders("BTC_JPY"); len(orders) != 0 {
+		t.Errorf("unexpected open orders: %v", orders)
+	}
+	if fills := manager.Fills("BTC_JPY"); len(fills) != 1 || fills[0].Price != 1000000 || fills[0].Size != 0.1 {
+		t.Errorf("unexpected fills: %v", fills)
+	}
+
+	// an order sent elsewhere is tracked from the realtime api, and a cancel event which the exchange denies is a mismatch
+	eventChan := make(chan *realtime.ChildOrderEvent, 100)
+	err = realApiClient.RealChildOrderEventsStart(func(childOrderEvents realtime.ChildOrderEvents, callbackData interface{}) {
+		manager.HandleChildOrderEvents(childOrderEvents, callbackData)
+		for _, childOrderEvent := range childOrderEvents {
+			eventChan <- childOrderEvent
+		}
+	}, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	defer realApiClient.RealStop()
+	if err := server.WaitSubscribed(true, 10 * time.Second, realtime.ChannelName(types.RealtimeTypeChildOrderEvents, "")); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	_, sendChildOrderResponse, err = apiClient.PriSendChildOrder("BTC_JPY", types.OrderTypeLimit, types.SideBuy, 900000, 0.1, 0, types.TimeInForceGTC)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	acceptanceId = sendChildOrderResponse.ChildOrderAcceptanceId
+	for received := false; !received; {
+		select {
+		case childOrderEvent := <-eventChan:
+			received = childOrderEvent.ChildOrderAcceptanceId == acceptanceId && childOrderEvent.EventType == types.EventTypeOrder
+		case <-time.After(10 * time.Second):
+			t.Fatalf("no order event")
+		}
+	}
+	order, ok = manager.Order(acceptanceId)
+	if !ok || order.State != types.OrderStateActive || order.Price != 900000 {
+		t.Fatalf("unexpected order: %#v", order)
+	}
+	manager.HandleChildOrderEvents(realtime.ChildOrderEvents{
+		{ ProductCode: "BTC_JPY", ChildOrderAcceptanceId: acceptanceId, EventType: types.EventTypeCancel, EventDate: types.NewTime(time.Now()) },
+	}, nil)
+	if err := manager.Sync("BTC_JPY"); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if orders := manager.Mismatches("BTC_JPY"); len(orders) != 1 || orders[0].ChildOrderAcceptanceId != acceptanceId || orders[0].State != types.OrderStateActive {
+		t.Errorf("unexpected mismatches: %v", orders)
+	}
+}
+
+func TestOrderManagementSync(t *testing.T) {
+	server, apiClient, _ := bitflyertest.NewClients(t)
+	manager := oms.NewManager(apiClient, nil)
+	if err := server.SetBoard("BTC_JPY", nil, nil); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	_, active, err := apiClient.PriSendChildOrder("BTC_JPY", types.OrderTypeLimit, types.SideBuy, 1000000, 0.3, 0, types.TimeInForceGTC)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	_, completed, err := apiClient.PriSendChildOrder("BTC_JPY", types.OrderTypeLimit, types.SideSell, 1100000, 0.1, 0, types.TimeInForceGTC)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if err := server.Execute("BTC_JPY", types.SideSell, 1000000, 0.1); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if err := server.Execute("BTC_JPY", types.SideBuy, 1100000, 0.1); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if err := manager.Sync("BTC_JPY"); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	order, ok := manager.Order(active.ChildOrderAcceptanceId)
+	if !ok || order.State != types.OrderStateActive || len(order.Fills) != 1 || order.Mismatch != "" {
+		t.Fatalf("unexpected order: %#v", order)
+	}
+	order, ok = manager.Order(completed.ChildOrderAcceptanceId)
+	if !ok || order.State != types.OrderStateCompleted || len(order.Fills) != 1 || order.Mismatch != "" {
+		t.Fatalf("unexpected order: %#v", order)
+	}
+	if fills := manager.Fills("BTC_JPY"); len(fills) != 2 {
+		t.Errorf("unexpected fills: %v", fills)
+	}
+}
@@ -0,0 +1,75 @@
+package oms
+
+import (
+	"math"
+	"time"
+	"github.com/potix/gobitflyer/api/types"
+)
+
+// Fill is an execution of a tracked order.
+type Fill struct {
+	Id                     int64
+	ProductCode            types.ProductCode
+	ChildOrderId           string
+	ChildOrderAcceptanceId string
+	Side                   types.Side
+	Price                  float64
+	Size                   float64
+	Commission             float64
+	ExecDate               time.Time
+}
+
+// Order is the local state of a child order. State is types.OrderStateNone until the exchange reports the order.
+type Order struct {
+	ProductCode            types.ProductCode
+	ChildOrderAcceptanceId string
+	// empty until the exchange reports the order
+	ChildOrderId           string
+	ChildOrderType         types.OrderType
+	Side                   types.Side
+	Price                  float64
+	Size                   float64
+	State                  types.OrderState
+	ExecutedSize           float64
+	AveragePrice           float64
+	TotalCommission        float64
+	// a cancel was accepted, and the order is not finished yet
+	CancelRequested        bool
+	SentAt                 time.Time
+	UpdatedAt              time.Time
+	Fills                  []*Fill
+	// disagreement with the exchange found by the latest sync, empty when both agree
+	Mismatch               string
+}
+
+// Open returns whether the order can still be executed.
+func (o *Order) Open() (bool) {
+	return o.State == types.OrderStateNone || o.State == types.OrderStateActive
+}
+
+func (o *Order) copy() (*Order) {
+	order := *o
+	order.Fills = append([]*Fill(nil), o.Fills...)
+	return &order
+}
+
+func (o *Order) hasFill(id int64) (bool) {
+	for _, fill := range o.Fills {
+		if fill.Id == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (o *Order) filledSize() (float64) {
+	size := float64(0)
+	for _, fill := range o.Fills {
+		size = round(size + fill.Size)
+	}
+	return size
+}
+
+func round(v float64) (float64) {
+	return math.Round(v * 1e8) / 1e8
+}
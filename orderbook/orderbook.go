@@ -0,0 +1,187 @@
+package orderbook
+
+import (
+	"sync"
+	"github.com/potix/gobitflyer/api/types"
+	"github.com/potix/gobitflyer/api/public"
+)
+
+type Side int
+
+const (
+	Bids Side = 0
+	Asks Side = 1
+)
+
+type Level struct {
+	Price float64
+	Size  float64
+}
+
+// Snapshot is an immutable state of an order book. It is safe to share between goroutines.
+type Snapshot struct {
+	midPrice float64
+	bids     *node
+	asks     *node
+}
+
+func (s *Snapshot) root(side Side) (*node, bool) {
+	if side == Bids {
+		return s.bids, true
+	}
+	return s.asks, false
+}
+
+func (s *Snapshot) MidPrice() (float64) {
+	return s.midPrice
+}
+
+func (s *Snapshot) Len(side Side) (int) {
+	n, _ := s.root(side)
+	return n.len()
+}
+
+func (s *Snapshot) best(side Side) (Level, bool) {
+	n := s.bestNode(side)
+	if n == nil {
+		return Level{}, false
+	}
+	return Level{Price: n.price, Size: n.size}, true
+}
+
+func (s *Snapshot) bestNode(side Side) (*node) {
+	n, descending := s.root(side)
+	return n.first(descending)
+}
+
+func (s *Snapshot) BestBid() (Level, bool) {
+	return s.best(Bids)
+}
+
+func (s *Snapshot) BestAsk() (Level, bool) {
+	return s.best(Asks)
+}
+
+// Levels returns up to count levels from the best price. All levels are returned when count <= 0.
+func (s *Snapshot) Levels(side Side, count int) ([]Level) {
+	n, descending := s.root(side)
+	if count <= 0 || count > n.len() {
+		count = n.len()
+	}
+	levels := make([]Level, 0, count)
+	n.walk(descending, func(n *node) (bool) {
+		if len(levels) == count {
+			return false
+		}
+		levels = append(levels, Level{Price: n.price, Size: n.size})
+		return true
+	})
+	return levels
+}
+
+// CumulativeSize returns the total size from the best price to price, inclusive.
+func (s *Snapshot) CumulativeSize(side Side, price float64) (float64) {
+	n, descending := s.root(side)
+	size, _ := n.sumTo(price, descending)
+	return size
+}
+
+// Depth returns the total size within ticks ticks of the best price, inclusive.
+func (s *Snapshot) Depth(side Side, ticks int, tickSize float64) (float64) {
+	best := s.bestNode(side)
+	if best == nil {
+		return 0
+	}
+	// half a tick absorbs the rounding error of float prices
+	distance := (float64(ticks) + 0.5) * tickSize
+	if side == Bids {
+		return s.CumulativeSize(side, best.price - distance)
+	}
+	return s.CumulativeSize(side, best.price + distance)
+}
+
+// VWAP returns the average price to take size from the side, e.g. Asks for a market buy.
+// It returns false when the side does not have enough size.
+func (s *Snapshot) VWAP(side Side, size float64) (float64, bool) {
+	if size <= 0 {
+		return 0, false
+	}
+	n, descending := s.root(side)
+	value, ok := n.fill(size, descending)
+	if !ok {
+		return 0, false
+	}
+	return value / size, true
+}
+
+func (s *Snapshot) GetBoardResponse() (*public.GetBoardResponse) {
+	getBoardResponse := &public.GetBoardResponse{
+		MidPrice: s.midPrice,
+		Bids:     make([]*public.GetBoardBook, 0, s.bids.len()),
+		Asks:     make([]*public.GetBoardBook, 0, s.asks.len()),
+	}
+	s.bids.walk(true, func(n *node) (bool) {
+		getBoardResponse.Bids = append(getBoardResponse.Bids, &public.GetBoardBook{Price: types.NewDecimalFromFloat(n.price), Size: types.NewDecimalFromFloat(n.size)})
+		return true
+	})
+	s.asks.walk(false, func(n *node) (bool) {
+		getBoardResponse.Asks = append(getBoardResponse.Asks, &public.GetBoardBook{Price: types.NewDecimalFromFloat(n.price), Size: types.NewDecimalFromFloat(n.size)})
+		return true
+	})
+	return getBoardResponse
+}
+
+// Book maintains an order book from lightning_board_snapshot and lightning_board messages.
+// Each update costs O(log n) per changed level and the previous snapshots are left intact.
+type Book struct {
+	mutex    *sync.Mutex
+	snapshot *Snapshot
+}
+
+func applyBooks(n *node, books []*public.GetBoardBook) (*node) {
+	for _, book := range books {
+		if book == nil || book.Price.IsZero() {
+			continue
+		}
+		n = set(n, book.Price.Float64(), book.Size.Float64())
+	}
+	return n
+}
+
+// Reset replaces the whole book with a board snapshot.
+func (b *Book) Reset(getBoardResponse *public.GetBoardResponse) (*Snapshot) {
+	snapshot := &Snapshot{
+		midPrice: getBoardResponse.MidPrice,
+		bids:     applyBooks(nil, getBoardResponse.Bids),
+		asks:     applyBooks(nil, getBoardResponse.Asks),
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.snapshot = snapshot
+	return snapshot
+}
+
+// Apply applies a board diff. A level of size 0 removes the price.
+func (b *Book) Apply(getBoardResponseDiff *public.GetBoardResponse) (*Snapshot) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.snapshot = &Snapshot{
+		midPrice: getBoardResponseDiff.MidPrice,
+		bids:     applyBooks(b.snapshot.bids, getBoardResponseDiff.Bids),
+		asks:     applyBooks(b.snapshot.asks, getBoardResponseDiff.Asks),
+	}
+	return b.snapshot
+}
+
+func (b *Book) Snapshot() (*Snapshot) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.snapshot
+}
+
+func NewBook() (*Book) {
+	return &Book{
+		mutex:    new(sync.Mutex),
+		snapshot: new(Snapshot),
+	}
+}
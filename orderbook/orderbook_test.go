@@ -0,0 +1,54 @@
+package orderbook_test
+
+import (
+	"fmt"
+	"testing"
+	"github.com/potix/gobitflyer/api/types"
+	"github.com/potix/gobitflyer/api/public"
+	"github.com/potix/gobitflyer/orderbook"
+)
+
+func boardBook(price string, size string) (*public.GetBoardBook) {
+	return &public.GetBoardBook{Price: types.MustParseDecimal(price), Size: types.MustParseDecimal(size)}
+}
+
+func TestOrderBook(t *testing.T) {
+	book := orderbook.NewBook()
+	old := book.Reset(&public.GetBoardResponse{
+		MidPrice: 1000,
+		Bids:     []*public.GetBoardBook{boardBook("999", "1"), boardBook("998", "2"), boardBook("996", "3")},
+		Asks:     []*public.GetBoardBook{boardBook("1003", "3"), boardBook("1001", "1"), boardBook("1002", "2")},
+	})
+	snapshot := book.Apply(&public.GetBoardResponse{
+		MidPrice: 1000.5,
+		Bids:     []*public.GetBoardBook{boardBook("999", "0"), boardBook("1000", "0.5"), boardBook("997", "1")},
+		Asks:     []*public.GetBoardBook{boardBook("1002", "4"), boardBook("1005", "0")},
+	})
+	if bid, ok := snapshot.BestBid(); !ok || bid.Price != 1000 || bid.Size != 0.5 {
+		t.Errorf("unexpected best bid: %v", bid)
+	}
+	if ask, ok := snapshot.BestAsk(); !ok || ask.Price != 1001 || ask.Size != 1 {
+		t.Errorf("unexpected best ask: %v", ask)
+	}
+	if levels := snapshot.Levels(orderbook.Bids, 0); fmt.Sprint(levels) != "[{1000 0.5} {998 2} {997 1} {996 3}]" {
+		t.Errorf("unexpected bids: %v", levels)
+	}
+	if levels := snapshot.Levels(orderbook.Asks, 2); fmt.Sprint(levels) != "[{1001 1} {1002 4}]" {
+		t.Errorf("unexpected asks: %v", levels)
+	}
+	if size := snapshot.CumulativeSize(orderbook.Bids, 997); size != 3.5 {
+		t.Errorf("unexpected cumulative size: %v", size)
+	}
+	if size := snapshot.Depth(orderbook.Asks, 1, 1); size != 5 {
+		t.Errorf("unexpected depth: %v", size)
+	}
+	if vwap, ok := snapshot.VWAP(orderbook.Asks, 3); !ok || vwap != (1001 + 2 * 1002) / 3.0 {
+		t.Errorf("unexpected vwap: %v", vwap)
+	}
+	if _, ok := snapshot.VWAP(orderbook.Asks, 9); ok {
+		t.Errorf("vwap beyond the board")
+	}
+	if bid, _ := old.BestBid(); bid.Price != 999 || old.Len(orderbook.Asks) != 3 {
+		t.Errorf("old snapshot was modified")
+	}
+}
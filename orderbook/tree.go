@@ -0,0 +1,207 @@
+package orderbook
+
+import (
+	"math"
+)
+
+// node is a node of a persistent treap keyed by price. Nodes are never modified after creation,
+// so an update copies only the path from the root and the old root stays valid as a snapshot.
+type node struct {
+	price    float64
+	size     float64
+	priority uint64
+	left     *node
+	right    *node
+	count    int
+	sumSize  float64
+	sumValue float64
+}
+
+func pricePriority(price float64) (uint64) {
+	// splitmix64, a deterministic priority keeps the tree balanced without a random source
+	x := math.Float64bits(price) + 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
+
+func newNode(price float64, size float64, priority uint64, left *node, right *node) (*node) {
+	n := &node{
+		price:    price,
+		size:     size,
+		priority: priority,
+		left:     left,
+		right:    right,
+		count:    1,
+		sumSize:  size,
+		sumValue: price * size,
+	}
+	if left != nil {
+		n.count += left.count
+		n.sumSize += left.sumSize
+		n.sumValue += left.sumValue
+	}
+	if right != nil {
+		n.count += right.count
+		n.sumSize += right.sumSize
+		n.sumValue += right.sumValue
+	}
+	return n
+}
+
+func (n *node) withChildren(left *node, right *node) (*node) {
+	return newNode(n.price, n.size, n.priority, left, right)
+}
+
+func (n *node) len() (int) {
+	if n == nil {
+		return 0
+	}
+	return n.count
+}
+
+// split returns the nodes whose price is less than price and the others.
+func split(n *node, price float64) (*node, *node) {
+	if n == nil {
+		return nil, nil
+	}
+	if n.price < price {
+		l, r := split(n.right, price)
+		return n.withChildren(n.left, l), r
+	}
+	l, r := split(n.left, price)
+	return l, n.withChildren(r, n.right)
+}
+
+func merge(l *node, r *node) (*node) {
+	if l == nil {
+		return r
+	}
+	if r == nil {
+		return l
+	}
+	if l.priority > r.priority {
+		return l.withChildren(l.left, merge(l.right, r))
+	}
+	return r.withChildren(merge(l, r.left), r.right)
+}
+
+func find(n *node, price float64) (*node) {
+	for n != nil {
+		if price == n.price {
+			return n
+		} else if price < n.price {
+			n = n.left
+		} else {
+			n = n.right
+		}
+	}
+	return nil
+}
+
+// update replaces the size of an existing price, or removes it when size is 0.
+func update(n *node, price float64, size float64) (*node) {
+	if price == n.price {
+		if size == 0 {
+			return merge(n.left, n.right)
+		}
+		return newNode(n.price, size, n.priority, n.left, n.right)
+	} else if price < n.price {
+		return n.withChildren(update(n.left, price, size), n.right)
+	}
+	return n.withChildren(n.left, update(n.right, price, size))
+}
+
+// set returns a new root in which price has size. A size of 0 removes the price.
+func set(n *node, price float64, size float64) (*node) {
+	if find(n, price) != nil {
+		return update(n, price, size)
+	}
+	if size == 0 {
+		return n
+	}
+	l, r := split(n, price)
+	return merge(merge(l, newNode(price, size, pricePriority(price), nil, nil)), r)
+}
+
+// children returns the children in the order of iteration.
+func (n *node) children(descending bool) (*node, *node) {
+	if descending {
+		return n.right, n.left
+	}
+	return n.left, n.right
+}
+
+func (n *node) first(descending bool) (*node) {
+	if n == nil {
+		return nil
+	}
+	for {
+		c, _ := n.children(descending)
+		if c == nil {
+			return n
+		}
+		n = c
+	}
+}
+
+// walk calls f in the order of iteration until f returns false.
+func (n *node) walk(descending bool, f func(n *node) (bool)) (bool) {
+	if n == nil {
+		return true
+	}
+	c1, c2 := n.children(descending)
+	return c1.walk(descending, f) && f(n) && c2.walk(descending, f)
+}
+
+// before reports whether a comes before b in the order of iteration.
+func before(a float64, b float64, descending bool) (bool) {
+	if descending {
+		return a > b
+	}
+	return a < b
+}
+
+// sumTo returns the total size and count of prices which come before or at price in the order of iteration.
+func (n *node) sumTo(price float64, descending bool) (float64, int) {
+	var size float64
+	var count int
+	for n != nil {
+		c1, c2 := n.children(descending)
+		if before(price, n.price, descending) {
+			n = c1
+			continue
+		}
+		if c1 != nil {
+			size += c1.sumSize
+			count += c1.count
+		}
+		size += n.size
+		count += 1
+		n = c2
+	}
+	return size, count
+}
+
+// fill returns the price * size needed to take size in the order of iteration, and whether there was enough size.
+func (n *node) fill(size float64, descending bool) (float64, bool) {
+	var value float64
+	for n != nil {
+		c1, c2 := n.children(descending)
+		if c1 != nil {
+			if size <= c1.sumSize {
+				n = c1
+				continue
+			}
+			size -= c1.sumSize
+			value += c1.sumValue
+		}
+		if size <= n.size {
+			return value + size * n.price, true
+		}
+		size -= n.size
+		value += n.size * n.price
+		n = c2
+	}
+	return value, false
+}
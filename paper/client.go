@@ -0,0 +1,431 @@
+package paper
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+	"github.com/pkg/errors"
+	"github.com/potix/gobitflyer/api"
+	"github.com/potix/gobitflyer/api/types"
+	"github.com/potix/gobitflyer/api/public"
+	"github.com/potix/gobitflyer/api/private"
+	"github.com/potix/gobitflyer/backtest"
+	"github.com/potix/gobitflyer/orderbook"
+)
+
+const (
+	// orders and cancels reach the exchange within this interval without executions
+	advanceInterval time.Duration = 100 * time.Millisecond
+	// levels of the board which taker orders can take
+	boardDepth      int           = 100
+)
+
+// Client is an api.API whose orders and account are simulated by backtest.Exchange on the live market.
+// Fills are driven by the realtime executions of the products given to Start. Public methods call the rest api,
+// and private methods which the exchange does not simulate fail, so that nothing reaches the real account.
+type Client struct {
+	publicAPI     api.PublicAPI
+	realAPIClient *api.RealAPIClient
+	exchange      *backtest.Exchange
+	mutex         *sync.Mutex
+	cancel        context.CancelFunc
+}
+
+var _ api.API = (*Client)(nil)
+
+func (c *Client) executionsCallback(productCode types.ProductCode, getExecutionsResponse public.GetExecutionsResponse, callbackData interface{}) {
+	// the exchange advances to the time of each execution, orders which reach it later are not filled by them
+	c.exchange.ApplyExecutions(productCode, getExecutionsResponse)
+}
+
+func (c *Client) orderBookCallback(productCode types.ProductCode, snapshot *orderbook.Snapshot, callbackData interface{}) {
+	getBoardResponse := &public.GetBoardResponse{
+		MidPrice: snapshot.MidPrice(),
+		Bids:     make([]*public.GetBoardBook, 0, boardDepth),
+		Asks:     make([]*public.GetBoardBook, 0, boardDepth),
+	}
+	for _, level := range snapshot.Levels(orderbook.Bids, boardDepth) {
+		getBoardResponse.Bids = append(getBoardResponse.Bids, &public.GetBoardBook{Price: types.NewDecimalFromFloat(level.Price), Size: types.NewDecimalFromFloat(level.Size)})
+	}
+	for _, level := range snapshot.Levels(orderbook.Asks, boardDepth) {
+		getBoardResponse.Asks = append(getBoardResponse.Asks, &public.GetBoardBook{Price: types.NewDecimalFromFloat(level.Price), Size: types.NewDecimalFromFloat(level.Size)})
+	}
+	c.exchange.Advance(time.Now())
+	c.exchange.SetBoard(productCode, getBoardResponse)
+}
+
+func (c *Client) Start(productCode types.ProductCode, board bool) (error) {
+	return c.StartCtx(context.Background(), productCode, board)
+}
+
+// StartCtx simulates orders of productCode with its realtime executions. With board, taker orders take the
+// realtime board, otherwise they are filled at the last traded price.
+func (c *Client) StartCtx(ctx context.Context, productCode types.ProductCode, board bool) (error) {
+	err := c.realAPIClient.RealExecutionsStartCtx(ctx, productCode, c.executionsCallback, nil)
+	if err != nil {
+		return errors.Wrapf(err, "can not start executions (product code = %v)", productCode)
+	}
+	if board {
+		err := c.realAPIClient.RealOrderBookStartCtx(ctx, productCode, c.orderBookCallback, nil)
+		if err != nil {
+			c.realAPIClient.RealUnsubscribe(types.RealtimeTypeExecutions, productCode)
+			return errors.Wrapf(err, "can not start board (product code = %v)", productCode)
+		}
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.cancel == nil {
+		advanceCtx, cancel := context.WithCancel(context.Background())
+		c.cancel = cancel
+		go c.advance(advanceCtx)
+	}
+	return nil
+}
+
+// advance processes orders and cancels which reach the exchange while no execution arrives.
+func (c *Client) advance(ctx context.Context) {
+	ticker := time.NewTicker(advanceInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			c.exchange.Advance(now)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Stop stops the realtime api client given to NewClient. The simulated account is kept.
+func (c *Client) Stop() (error) {
+	c.mutex.Lock()
+	if c.cancel != nil {
+		c.cancel()
+		c.cancel = nil
+	}
+	c.mutex.Unlock()
+	return c.realAPIClient.RealStop()
+}
+
+// Exchange returns the simulated exchange, e.g. for Trades, Equity or SetChildOrderEventsCallback.
+func (c *Client) Exchange() (*backtest.Exchange) {
+	return c.exchange
+}
+
+func (c *Client) PubGetMarkets() (*http.Response, public.GetMarketsResponse, error) {
+	return c.PubGetMarketsCtx(context.Background())
+}
+
+func (c *Client) PubGetMarketsCtx(ctx context.Context) (*http.Response, public.GetMarketsResponse, error) {
+	return c.publicAPI.PubGetMarketsCtx(ctx)
+}
+
+func (c *Client) PubGetBoard(productCode types.ProductCode) (*http.Response, *public.GetBoardResponse, error) {
+	return c.PubGetBoardCtx(context.Background(), productCode)
+}
+
+func (c *Client) PubGetBoardCtx(ctx context.Context, productCode types.ProductCode) (*http.Response, *public.GetBoardResponse, error) {
+	return c.publicAPI.PubGetBoardCtx(ctx, productCode)
+}
+
+func (c *Client) PubGetTicker(productCode types.ProductCode) (*http.Response, *public.GetTickerResponse, error) {
+	return c.PubGetTickerCtx(context.Background(), productCode)
+}
+
+func (c *Client) PubGetTickerCtx(ctx context.Context, productCode types.ProductCode) (*http.Response, *public.GetTickerResponse, error) {
+	return c.publicAPI.PubGetTickerCtx(ctx, productCode)
+}
+
+func (c *Client) PubGetExecutions(productCode types.ProductCode, count int64, before int64, after int64) (*http.Response, public.GetExecutionsResponse, error) {
+	return c.PubGetExecutionsCtx(context.Background(), productCode, count, before, after)
+}
+
+func (c *Client) PubGetExecutionsCtx(ctx context.Context, productCode types.ProductCode, count int64, before int64, after int64) (*http.Response, public.GetExecutionsResponse, error) {
+	return c.publicAPI.PubGetExecutionsCtx(ctx, productCode, count, before, after)
+}
+
+func (c *Client) PubGetBoardState(productCode types.ProductCode) (*http.Response, *public.GetBoardStateResponse, error) {
+	return c.PubGetBoardStateCtx(context.Background(), productCode)
+}
+
+func (c *Client) PubGetBoardStateCtx(ctx context.Context, productCode types.ProductCode) (*http.Response, *public.GetBoardStateResponse, error) {
+	return c.publicAPI.PubGetBoardStateCtx(ctx, productCode)
+}
+
+func (c *Client) PubGetHealth(productCode types.ProductCode) (*http.Response, *public.GetHealthResponse, error) {
+	return c.PubGetHealthCtx(context.Background(), productCode)
+}
+
+func (c *Client) PubGetHealthCtx(ctx context.Context, productCode types.ProductCode) (*http.Response, *public.GetHealthResponse, error) {
+	return c.publicAPI.PubGetHealthCtx(ctx, productCode)
+}
+
+func (c *Client) PubGetChats(fromDate int64) (*http.Response, *public.GetChatsResponse, error) {
+	return c.PubGetChatsCtx(context.Background(), fromDate)
+}
+
+func (c *Client) PubGetChatsCtx(ctx context.Context, fromDate int64) (*http.Response, *public.GetChatsResponse, error) {
+	return c.publicAPI.PubGetChatsCtx(ctx, fromDate)
+}
+
+func (c *Client) PriGetBalance() (*http.Response, private.GetBalanceResponse, error) {
+	return c.PriGetBalanceCtx(context.Background())
+}
+
+func (c *Client) PriGetBalanceCtx(ctx context.Context) (*http.Response, private.GetBalanceResponse, error) {
+	c.exchange.Advance(time.Now())
+	return c.exchange.PriGetBalanceCtx(ctx)
+}
+
+func (c *Client) PriGetCollateral() (*http.Response, *private.GetCollateralResponse, error) {
+	return c.PriGetCollateralCtx(context.Background())
+}
+
+func (c *Client) PriGetCollateralCtx(ctx context.Context) (*http.Response, *private.GetCollateralResponse, error) {
+	c.exchange.Advance(time.Now())
+	return c.exchange.PriGetCollateralCtx(ctx)
+}
+
+func (c *Client) PriGetPositions() (*http.Response, private.GetPositionsResponse, error) {
+	return c.PriGetPositionsCtx(context.Background())
+}
+
+func (c *Client) PriGetPositionsCtx(ctx context.Context) (*http.Response, private.GetPositionsResponse, error) {
+	c.exchange.Advance(time.Now())
+	return c.exchange.PriGetPositionsCtx(ctx)
+}
+
+func (c *Client) PriGetPermissions() (*http.Response, *private.GetPermissionsResponse, error) {
+	return c.PriGetPermissionsCtx(context.Background())
+}
+
+func (c *Client) PriGetPermissionsCtx(ctx context.Context) (*http.Response, *private.GetPermissionsResponse, error) {
+	return nil, nil, errors.Errorf("get permissions is not supported by paper trading")
+}
+
+func (c *Client) PriGetCollateralAccounts() (*http.Response, private.GetCollateralAccountsResponse, error) {
+	return c.PriGetCollateralAccountsCtx(context.Background())
+}
+
+func (c *Client) PriGetCollateralAccountsCtx(ctx context.Context) (*http.Response, private.GetCollateralAccountsResponse, error) {
+	return nil, nil, errors.Errorf("get collateral accounts is not supported by paper trading")
+}
+
+func (c *Client) PriGetAddresses() (*http.Response, private.GetAddressesResponse, error) {
+	return c.PriGetAddressesCtx(context.Background())
+}
+
+func (c *Client) PriGetAddressesCtx(ctx context.Context) (*http.Response, private.GetAddressesResponse, error) {
+	return nil, nil, errors.Errorf("get addresses is not supported by paper trading")
+}
+
+func (c *Client) PriGetCoinIns(count int64, before int64, after int64) (*http.Response, private.GetCoinInsResponse, error) {
+	return c.PriGetCoinInsCtx(context.Background(), count, before, after)
+}
+
+func (c *Client) PriGetCoinInsCtx(ctx context.Context, count int64, before int64, after int64) (*http.Response, private.GetCoinInsResponse, error) {
+	return nil, nil, errors.Errorf("get coin ins is not supported by paper trading")
+}
+
+func (c *Client) PriGetCoinOuts(count int64, before int64, after int64) (*http.Response, private.GetCoinOutsResponse, error) {
+	return c.PriGetCoinOutsCtx(context.Background(), count, before, after)
+}
+
+func (c *Client) PriGetCoinOutsCtx(ctx context.Context, count int64, before int64, after int64) (*http.Response, private.GetCoinOutsResponse, error) {
+	return nil, nil, errors.Errorf("get coin outs is not supported by paper trading")
+}
+
+func (c *Client) PriGetBankAccounts() (*http.Response, private.GetBankAccountsResponse, error) {
+	return c.PriGetBankAccountsCtx(context.Background())
+}
+
+func (c *Client) PriGetBankAccountsCtx(ctx context.Context) (*http.Response, private.GetBankAccountsResponse, error) {
+	return nil, nil, errors.Errorf("get bank accounts is not supported by paper trading")
+}
+
+func (c *Client) PriGetDeposits(count int64, before int64, after int64) (*http.Response, private.GetDepositsResponse, error) {
+	return c.PriGetDepositsCtx(context.Background(), count, before, after)
+}
+
+func (c *Client) PriGetDepositsCtx(ctx context.Context, count int64, before int64, after int64) (*http.Response, private.GetDepositsResponse, error) {
+	return nil, nil, errors.Errorf("get deposits is not supported by paper trading")
+}
+
+func (c *Client) PriGetWithdrawals(count int64, before int64, after int64) (*http.Response, private.GetWithdrawalsResponse, error) {
+	return c.PriGetWithdrawalsCtx(context.Background(), count, before, after)
+}
+
+func (c *Client) PriGetWithdrawalsCtx(ctx context.Context, count int64, before int64, after int64) (*http.Response, private.GetWithdrawalsResponse, error) {
+	return nil, nil, errors.Errorf("get withdrawals is not supported by paper trading")
+}
+
+func (c *Client) PriGetWithdrawalsById(messageId string) (*http.Response, private.GetWithdrawalsResponse, error) {
+	return c.PriGetWithdrawalsByIdCtx(context.Background(), messageId)
+}
+
+func (c *Client) PriGetWithdrawalsByIdCtx(ctx context.Context, messageId string) (*http.Response, private.GetWithdrawalsResponse, error) {
+	return nil, nil, errors.Errorf("get withdrawals is not supported by paper trading")
+}
+
+func (c *Client) PriGetBalanceHistory(currencyCode types.CurrencyCode, count int64, before int64, after int64) (*http.Response, private.GetBalanceHistoryResponse, error) {
+	return c.PriGetBalanceHistoryCtx(context.Background(), currencyCode, count, before, after)
+}
+
+func (c *Client) PriGetBalanceHistoryCtx(ctx context.Context, currencyCode types.CurrencyCode, count int64, before int64, after int64) (*http.Response, private.GetBalanceHistoryResponse, error) {
+	return nil, nil, errors.Errorf("get balance history is not supported by paper trading")
+}
+
+func (c *Client) PriGetCollateralHistory(count int64, before int64, after int64) (*http.Response, private.GetCollateralHistoryResponse, error) {
+	return c.PriGetCollateralHistoryCtx(context.Background(), count, before, after)
+}
+
+func (c *Client) PriGetCollateralHistoryCtx(ctx context.Context, count int64, before int64, after int64) (*http.Response, private.GetCollateralHistoryResponse, error) {
+	return nil, nil, errors.Errorf("get collateral history is not supported by paper trading")
+}
+
+func (c *Client) PriWithdraw(currencyCode types.CurrencyCode, bankAccountId int64, amount float64, code string) (*http.Response, *private.WithdrawResponse, error) {
+	return c.PriWithdrawCtx(context.Background(), currencyCode, bankAccountId, amount, code)
+}
+
+func (c *Client) PriWithdrawCtx(ctx context.Context, currencyCode types.CurrencyCode, bankAccountId int64, amount float64, code string) (*http.Response, *private.WithdrawResponse, error) {
+	return nil, nil, errors.Errorf("withdraw is not supported by paper trading")
+}
+
+func (c *Client) PriSendChildOrder(productCode types.ProductCode,
+                                   childOrderType types.OrderType,
+                                   side types.Side,
+                                   price float64,
+                                   size float64,
+                                   minuteToExpire int64,
+                                   timeInForce types.TimeInForce) (*http.Response, *private.SendChildOrderResponse, error) {
+	return c.PriSendChildOrderCtx(context.Background(), productCode, childOrderType, side, price, size, minuteToExpire, timeInForce)
+}
+
+func (c *Client) PriSendChildOrderCtx(ctx context.Context, productCode types.ProductCode,
+                                      childOrderType types.OrderType,
+                                      side types.Side,
+                                      price float64,
+                                      size float64,
+                                      minuteToExpire int64,
+                                      timeInForce types.TimeInForce) (*http.Response, *private.SendChildOrderResponse, error) {
+	c.exchange.Advance(time.Now())
+	return c.exchange.PriSendChildOrderCtx(ctx, productCode, childOrderType, side, price, size, minuteToExpire, timeInForce)
+}
+
+func (c *Client) PriCancelChildOrder(productCode types.ProductCode, idType types.IdType, orderId string) (*http.Response, error) {
+	return c.PriCancelChildOrderCtx(context.Background(), productCode, idType, orderId)
+}
+
+func (c *Client) PriCancelChildOrderCtx(ctx context.Context, productCode types.ProductCode, idType types.IdType, orderId string) (*http.Response, error) {
+	c.exchange.Advance(time.Now())
+	return c.exchange.PriCancelChildOrderCtx(ctx, productCode, idType, orderId)
+}
+
+func (c *Client) PriCancelAllChildOrders(productCode types.ProductCode) (*http.Response, error) {
+	return c.PriCancelAllChildOrdersCtx(context.Background(), productCode)
+}
+
+func (c *Client) PriCancelAllChildOrdersCtx(ctx context.Context, productCode types.ProductCode) (*http.Response, error) {
+	c.exchange.Advance(time.Now())
+	return c.exchange.PriCancelAllChildOrdersCtx(ctx, productCode)
+}
+
+func (c *Client) PriGetChildOrders(productCode types.ProductCode, count int64, before int64, after int64, orderState types.OrderState) (*http.Response, private.GetChildOrdersResponse, error) {
+	return c.PriGetChildOrdersCtx(context.Background(), productCode, count, before, after, orderState)
+}
+
+func (c *Client) PriGetChildOrdersCtx(ctx context.Context, productCode types.ProductCode, count int64, before int64, after int64, orderState types.OrderState) (*http.Response, private.GetChildOrdersResponse, error) {
+	c.exchange.Advance(time.Now())
+	return c.exchange.PriGetChildOrdersCtx(ctx, productCode, count, before, after, orderState)
+}
+
+func (c *Client) PriGetChildOrdersById(productCode types.ProductCode, idType types.IdType, orderId string) (*http.Response, private.GetChildOrdersResponse, error) {
+	return c.PriGetChildOrdersByIdCtx(context.Background(), productCode, idType, orderId)
+}
+
+func (c *Client) PriGetChildOrdersByIdCtx(ctx context.Context, productCode types.ProductCode, idType types.IdType, orderId string) (*http.Response, private.GetChildOrdersResponse, error) {
+	c.exchange.Advance(time.Now())
+	return c.exchange.PriGetChildOrdersByIdCtx(ctx, productCode, idType, orderId)
+}
+
+func (c *Client) PriGetExecutions(productCode types.ProductCode, count int64, before int64, after int64) (*http.Response, private.GetExecutionsResponse, error) {
+	return c.PriGetExecutionsCtx(context.Background(), productCode, count, before, after)
+}
+
+func (c *Client) PriGetExecutionsCtx(ctx context.Context, productCode types.ProductCode, count int64, before int64, after int64) (*http.Response, private.GetExecutionsResponse, error) {
+	c.exchange.Advance(time.Now())
+	return c.exchange.PriGetExecutionsCtx(ctx, productCode, count, before, after)
+}
+
+func (c *Client) PriGetExecutionsById(productCode types.ProductCode, idType types.IdType, orderId string) (*http.Response, private.GetExecutionsResponse, error) {
+	return c.PriGetExecutionsByIdCtx(context.Background(), productCode, idType, orderId)
+}
+
+func (c *Client) PriGetExecutionsByIdCtx(ctx context.Context, productCode types.ProductCode, idType types.IdType, orderId string) (*http.Response, private.GetExecutionsResponse, error) {
+	c.exchange.Advance(time.Now())
+	return c.exchange.PriGetExecutionsByIdCtx(ctx, productCode, idType, orderId)
+}
+
+func (c *Client) PriGetTradingCommission(productCode types.ProductCode) (*http.Response, *private.GetTradingCommissionResponse, error) {
+	return c.PriGetTradingCommissionCtx(context.Background(), productCode)
+}
+
+func (c *Client) PriGetTradingCommissionCtx(ctx context.Context, productCode types.ProductCode) (*http.Response, *private.GetTradingCommissionResponse, error) {
+	return c.exchange.PriGetTradingCommissionCtx(ctx, productCode)
+}
+
+func (c *Client) PriSendParentOrder(orderMethod types.OrderMethod,
+                                    minuteToExpire int64,
+                                    timeInForce types.TimeInForce,
+                                    parameters ...*private.SendParentOrderParameter) (*http.Response, *private.SendParentOrderResponse, error) {
+	return c.PriSendParentOrderCtx(context.Background(), orderMethod, minuteToExpire, timeInForce, parameters...)
+}
+
+func (c *Client) PriSendParentOrderCtx(ctx context.Context, orderMethod types.OrderMethod,
+                                       minuteToExpire int64,
+                                       timeInForce types.TimeInForce,
+                                       parameters ...*private.SendParentOrderParameter) (*http.Response, *private.SendParentOrderResponse, error) {
+	c.exchange.Advance(time.Now())
+	return c.exchange.PriSendParentOrderCtx(ctx, orderMethod, minuteToExpire, timeInForce, parameters...)
+}
+
+func (c *Client) PriCancelParentOrder(productCode types.ProductCode, idType types.IdType, orderId string) (*http.Response, error) {
+	return c.PriCancelParentOrderCtx(context.Background(), productCode, idType, orderId)
+}
+
+func (c *Client) PriCancelParentOrderCtx(ctx context.Context, productCode types.ProductCode, idType types.IdType, orderId string) (*http.Response, error) {
+	c.exchange.Advance(time.Now())
+	return c.exchange.PriCancelParentOrderCtx(ctx, productCode, idType, orderId)
+}
+
+func (c *Client) PriGetParentOrders(productCode types.ProductCode, count int64, before int64, after int64, orderState types.OrderState) (*http.Response, private.GetParentOrdersResponse, error) {
+	return c.PriGetParentOrdersCtx(context.Background(), productCode, count, before, after, orderState)
+}
+
+func (c *Client) PriGetParentOrdersCtx(ctx context.Context, productCode types.ProductCode, count int64, before int64, after int64, orderState types.OrderState) (*http.Response, private.GetParentOrdersResponse, error) {
+	c.exchange.Advance(time.Now())
+	return c.exchange.PriGetParentOrdersCtx(ctx, productCode, count, before, after, orderState)
+}
+
+func (c *Client) PriGetParentOrder(idType types.IdType, orderId string) (*http.Response, *private.GetParentOrderResponse, error) {
+	return c.PriGetParentOrderCtx(context.Background(), idType, orderId)
+}
+
+func (c *Client) PriGetParentOrderCtx(ctx context.Context, idType types.IdType, orderId string) (*http.Response, *private.GetParentOrderResponse, error) {
+	c.exchange.Advance(time.Now())
+	return c.exchange.PriGetParentOrderCtx(ctx, idType, orderId)
+}
+
+// NewClient creates a paper trading client. publicAPI serves the public methods, and realAPIClient is used only by
+// the paper trading client, since a channel can not be subscribed twice.
+func NewClient(publicAPI api.PublicAPI, realAPIClient *api.RealAPIClient, options *backtest.Options) (*Client) {
+	exchange := backtest.NewExchange(options)
+	exchange.Advance(time.Now())
+	return &Client{
+		publicAPI:     publicAPI,
+		realAPIClient: realAPIClient,
+		exchange:      exchange,
+		mutex:         new(sync.Mutex),
+	}
+}
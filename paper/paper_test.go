@@ -0,0 +1,106 @@
+package paper_test
+
+import (
+	"time"
+	"testing"
+	"github.com/potix/gobitflyer/api/types"
+	"github.com/potix/gobitflyer/api/public"
+	"github.com/potix/gobitflyer/api/realtime"
+	"github.com/potix/gobitflyer/bitflyertest"
+	"github.com/potix/gobitflyer/backtest"
+	"github.com/potix/gobitflyer/paper"
+)
+
+func TestPaperTrading(t *testing.T) {
+	server, apiClient, realApiClient := bitflyertest.NewClients(t)
+	err := server.SetBoard("FX_BTC_JPY",
+		[]*public.GetBoardBook{ {Price: types.MustParseDecimal("1000000"), Size: types.MustParseDecimal("1")}, {Price: types.MustParseDecimal("990000"), Size: types.MustParseDecimal("1")} },
+		[]*public.GetBoardBook{ {Price: types.MustParseDecimal("1010000"), Size: types.MustParseDecimal("1")} })
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	paperClient := paper.NewClient(apiClient, realApiClient, &backtest.Options{InitialCollateral: 1000000})
+	if err := paperClient.Start("FX_BTC_JPY", true); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	defer paperClient.Stop()
+	err = server.WaitSubscribed(true, 10 * time.Second,
+		realtime.ChannelName(types.RealtimeTypeExecutions, "FX_BTC_JPY"),
+		realtime.ChannelName(types.RealtimeTypeBoardSnapshot, "FX_BTC_JPY"),
+		realtime.ChannelName(types.RealtimeTypeBoard, "FX_BTC_JPY"))
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	eventChan := make(chan *realtime.ChildOrderEvent, 100)
+	paperClient.Exchange().SetChildOrderEventsCallback(func(childOrderEvents realtime.ChildOrderEvents, callbackData interface{}) {
+		for _, childOrderEvent := range childOrderEvents {
+			eventChan <- childOrderEvent
+		}
+	}, nil)
+	waitCompleted := func(childOrderAcceptanceId string) {
+		timeout := time.After(10 * time.Second)
+		for {
+			select {
+			case childOrderEvent := <-eventChan:
+				if childOrderEvent.ChildOrderAcceptanceId == childOrderAcceptanceId &&
+				   childOrderEvent.EventType == types.EventTypeExecution && childOrderEvent.OutstandingSize == 0 {
+					return
+				}
+			case <-timeout:
+				t.Fatalf("order is not completed (child order acceptance id = %v)", childOrderAcceptanceId)
+			}
+		}
+	}
+
+	_, limit, err := paperClient.PriSendChildOrder("FX_BTC_JPY", types.OrderTypeLimit, types.SideBuy, 995000, 0.1, 0, types.TimeInForceGTC)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	// trades through the paper order at 990000
+	if err := server.Execute("FX_BTC_JPY", types.SideSell, 0, 1.5); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	waitCompleted(limit.ChildOrderAcceptanceId)
+	_, childOrders, err := paperClient.PriGetChildOrdersById("FX_BTC_JPY", types.IdTypeChildOrderAcceptanceId, limit.ChildOrderAcceptanceId)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if len(childOrders) != 1 || childOrders[0].ChildOrderState != types.OrderStateCompleted || childOrders[0].AveragePrice.Float64() != 995000 {
+		t.Fatalf("unexpected child orders: %v", childOrders)
+	}
+	_, positions, err := paperClient.PriGetPositions()
+	if err != nil || len(positions) != 1 || positions[0].Side != types.SideBuy || positions[0].Size != 0.1 || positions[0].Price != 995000 {
+		t.Errorf("unexpected positions: %v, %v", positions, err)
+	}
+	_, realChildOrders, err := apiClient.PriGetChildOrders("FX_BTC_JPY", 10, 0, 0, types.OrderStateNone)
+	if err != nil || len(realChildOrders) != 0 {
+		t.Errorf("unexpected real child orders: %v, %v", realChildOrders, err)
+	}
+
+	_, marketOrder, err := paperClient.PriSendChildOrder("FX_BTC_JPY", types.OrderTypeMarket, types.SideSell, 0, 0.1, 0, types.TimeInForceGTC)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	waitCompleted(marketOrder.ChildOrderAcceptanceId)
+	_, childOrders, err = paperClient.PriGetChildOrdersById("FX_BTC_JPY", types.IdTypeChildOrderAcceptanceId, marketOrder.ChildOrderAcceptanceId)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if len(childOrders) != 1 || childOrders[0].AveragePrice.Float64() != 990000 {
+		t.Fatalf("unexpected child orders: %v", childOrders)
+	}
+	_, positions, err = paperClient.PriGetPositions()
+	if err != nil || len(positions) != 0 {
+		t.Errorf("unexpected positions: %v, %v", positions, err)
+	}
+	if _, _, err := paperClient.PriWithdraw("JPY", 1, 1000, ""); err == nil {
+		t.Errorf("withdraw must fail")
+	}
+	// the real account is not reached
+	if _, _, err := paperClient.PriGetBalanceHistory("JPY", 10, 0, 0); err == nil {
+		t.Errorf("balance history must fail")
+	}
+	if _, board, err := paperClient.PubGetBoard("FX_BTC_JPY"); err != nil || board == nil {
+		t.Errorf("unexpected board: %v, %v", board, err)
+	}
+}
@@ -0,0 +1,240 @@
+package parentorder
+
+import (
+	"time"
+	"github.com/potix/gobitflyer/api/types"
+)
+
+// Host is an exchange simulator which matches child orders placed by Engine.
+type Host interface {
+	// NewChildOrder accepts a child order of the parameter at index without matching it, or returns the reason
+	// of the rejection.
+	NewChildOrder(p *Order, index int, orderType types.OrderType, price float64, now time.Time) (ChildOrder, string)
+	// ExecuteChildOrder matches a child order accepted by NewChildOrder.
+	ExecuteChildOrder(o ChildOrder, now time.Time)
+	// CancelChildOrder finishes an active child order with state. It does not call Engine.ChildOrderDone.
+	CancelChildOrder(o ChildOrder, state types.OrderState, now time.Time)
+	LastTradedPrice(productCode types.ProductCode) (float64)
+	// Event notifies a change of a parent order, o is nil unless the event is of a child order.
+	Event(p *Order, eventType types.EventType, index int, o ChildOrder, reason string, now time.Time)
+}
+
+// Engine runs IFD, OCO and IFDOCO orders and STOP, STOP_LIMIT and TRAIL conditions on a host.
+// It is not safe for concurrent use, the host calls it under its own lock.
+type Engine struct {
+	host               Host
+	orders             []*Order
+	pendingActivations []*Order
+}
+
+// Add tracks an order which was sent. It is activated after Accept.
+func (e *Engine) Add(p *Order) {
+	e.orders = append(e.orders, p)
+}
+
+// Accept activates the first stage of an order at the next Flush.
+func (e *Engine) Accept(p *Order) {
+	if p.State != types.OrderStateActive {
+		return
+	}
+	p.accepted = true
+	e.pendingActivations = append(e.pendingActivations, p)
+}
+
+// Orders returns orders in order of Add. The slice must not be modified.
+func (e *Engine) Orders() ([]*Order) {
+	return e.orders
+}
+
+// Find returns the order of parentOrderId or parentOrderAcceptanceId, an empty id is not compared.
+func (e *Engine) Find(parentOrderId string, parentOrderAcceptanceId string) (*Order) {
+	for _, p := range e.orders {
+		if (parentOrderId != "" && p.ParentOrderId == parentOrderId) ||
+		   (parentOrderAcceptanceId != "" && p.ParentOrderAcceptanceId == parentOrderAcceptanceId) {
+			return p
+		}
+	}
+	return nil
+}
+
+// ChildOrderDone moves the parent order of a finished child order to the next stage, or finishes it when the child
+// order was not completed. p is the parent order of o, and nil for a child order without a parent.
+func (e *Engine) ChildOrderDone(p *Order, o ChildOrder, now time.Time) {
+	if p == nil || p.State != types.OrderStateActive {
+		return
+	}
+	state := o.State()
+	if state != types.OrderStateCompleted {
+		e.Finish(p, state, "", now)
+		return
+	}
+	index := 0
+	for _, c := range p.children {
+		if c.order == o {
+			index = c.parameterIndex
+		}
+	}
+	e.host.Event(p, types.EventTypeComplete, index, o, "", now)
+	// the other orders of an OCO stage are canceled
+	for _, index := range p.stages[p.stage] {
+		delete(p.waiting, index)
+	}
+	for _, c := range p.children {
+		if c.order != o && c.order.State() == types.OrderStateActive {
+			e.host.CancelChildOrder(c.order, types.OrderStateCanceled, now)
+		}
+	}
+	p.stage += 1
+	if p.stage >= len(p.stages) {
+		p.State = types.OrderStateCompleted
+		return
+	}
+	e.pendingActivations = append(e.pendingActivations, p)
+}
+
+// Finish cancels, expires or rejects an active order and its active child orders.
+func (e *Engine) Finish(p *Order, state types.OrderState, reason string, now time.Time) {
+	if p.State != types.OrderStateActive {
+		return
+	}
+	p.State = state
+	p.waiting = make(map[int]float64)
+	for _, c := range p.children {
+		if c.order.State() == types.OrderStateActive {
+			e.host.CancelChildOrder(c.order, state, now)
+		}
+	}
+	if !p.accepted {
+		return
+	}
+	if state == types.OrderStateExpired {
+		e.host.Event(p, types.EventTypeExpire, 0, nil, reason, now)
+	} else {
+		e.host.Event(p, types.EventTypeCancel, 0, nil, reason, now)
+	}
+}
+
+// Expire expires active orders whose expire date is before now.
+func (e *Engine) Expire(now time.Time) {
+	for _, p := range e.orders {
+		if p.State == types.OrderStateActive && now.After(p.ExpireDate) {
+			e.Finish(p, types.OrderStateExpired, "", now)
+		}
+	}
+}
+
+// place places a child order of a parameter, and rejects the parent order when the host rejects the child order.
+func (e *Engine) place(p *Order, index int, orderType types.OrderType, price float64, now time.Time) {
+	o, reason := e.host.NewChildOrder(p, index, orderType, price, now)
+	if o == nil {
+		e.Finish(p, types.OrderStateRejected, reason, now)
+		return
+	}
+	p.children = append(p.children, &child{
+		order:          o,
+		parameterIndex: index,
+	})
+	e.host.Event(p, types.EventTypeTrigger, index, o, "", now)
+	e.host.ExecuteChildOrder(o, now)
+}
+
+func (e *Engine) activate(p *Order, now time.Time) {
+	for _, index := range p.stages[p.stage] {
+		if p.State != types.OrderStateActive {
+			return
+		}
+		parameter := p.Parameters[index]
+		switch parameter.ConditionType {
+		case types.ConditionTypeLimit:
+			e.place(p, index, types.OrderTypeLimit, parameter.Price, now)
+		case types.ConditionTypeMarket:
+			e.place(p, index, types.OrderTypeMarket, 0, now)
+		default:
+			p.waiting[index] = e.host.LastTradedPrice(parameter.ProductCode)
+		}
+	}
+}
+
+// checkTriggers places child orders of STOP, STOP_LIMIT and TRAIL conditions triggered by the last traded price.
+func (e *Engine) checkTriggers(now time.Time) (bool) {
+	triggered := false
+	for _, p := range e.orders {
+		if p.State != types.OrderStateActive || len(p.waiting) == 0 {
+			continue
+		}
+		for _, index := range p.stages[p.stage] {
+			extreme, ok := p.waiting[index]
+			if !ok {
+				continue
+			}
+			parameter := p.Parameters[index]
+			ltp := e.host.LastTradedPrice(parameter.ProductCode)
+			if ltp == 0 {
+				continue
+			}
+			if extreme == 0 {
+				// activated before the first execution
+				extreme = ltp
+				p.waiting[index] = extreme
+			}
+			trigger := false
+			switch parameter.ConditionType {
+			case types.ConditionTypeStop, types.ConditionTypeStopLimit:
+				trigger = (parameter.Side == types.SideBuy && ltp >= parameter.TriggerPrice) ||
+				          (parameter.Side == types.SideSell && ltp <= parameter.TriggerPrice)
+			case types.ConditionTypeTrail:
+				if parameter.Side == types.SideBuy {
+					if ltp < extreme {
+						p.waiting[index] = ltp
+					}
+					trigger = ltp >= extreme + parameter.Offset
+				} else {
+					if ltp > extreme {
+						p.waiting[index] = ltp
+					}
+					trigger = ltp <= extreme - parameter.Offset
+				}
+			}
+			if !trigger {
+				continue
+			}
+			delete(p.waiting, index)
+			triggered = true
+			if parameter.ConditionType == types.ConditionTypeStopLimit {
+				e.place(p, index, types.OrderTypeLimit, parameter.Price, now)
+			} else {
+				e.place(p, index, types.OrderTypeMarket, 0, now)
+			}
+			if p.State != types.OrderStateActive {
+				break
+			}
+		}
+	}
+	return triggered
+}
+
+// Flush activates next stages of orders and checks triggers until nothing changes.
+func (e *Engine) Flush(now time.Time) {
+	for progressed := true; progressed; {
+		progressed = false
+		for len(e.pendingActivations) != 0 {
+			p := e.pendingActivations[0]
+			e.pendingActivations = e.pendingActivations[1:]
+			if p.State == types.OrderStateActive {
+				e.activate(p, now)
+			}
+			progressed = true
+		}
+		if e.checkTriggers(now) {
+			progressed = true
+		}
+	}
+}
+
+func NewEngine(host Host) (*Engine) {
+	return &Engine{
+		host:               host,
+		orders:             make([]*Order, 0),
+		pendingActivations: make([]*Order, 0),
+	}
+}
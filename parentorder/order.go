@@ -0,0 +1,157 @@
+package parentorder
+
+import (
+	"math"
+	"time"
+	"github.com/potix/gobitflyer/api/types"
+	"github.com/potix/gobitflyer/api/private"
+)
+
+const (
+	defaultMinuteToExpire int64 = 43200
+)
+
+// ChildOrder is a child order of a host, which the engine places for a parameter of a parent order.
+type ChildOrder interface {
+	State() (types.OrderState)
+	Response() (*private.GetChildOrdersOrder)
+}
+
+type child struct {
+	order          ChildOrder
+	parameterIndex int
+}
+
+// Order is a parent order. The fields are read by the host, and the state is changed only by Engine.
+type Order struct {
+	Id                      int64
+	ParentOrderId           string
+	ParentOrderAcceptanceId string
+	OrderMethod             types.OrderMethod
+	MinuteToExpire          int64
+	TimeInForce             types.TimeInForce
+	Parameters              []*private.SendParentOrderParameter
+	State                   types.OrderState
+	ParentOrderDate         time.Time
+	ExpireDate              time.Time
+	accepted                bool
+	stage                   int
+	stages                  [][]int
+	children                []*child
+	// parameter index -> extreme last traded price since activation (used by TRAIL)
+	waiting                 map[int]float64
+}
+
+// Accepted returns whether the order reached the exchange.
+func (p *Order) Accepted() (bool) {
+	return p.accepted
+}
+
+func (p *Order) ParentOrderType() (types.ParentOrderType) {
+	return types.NewParentOrderType(p.OrderMethod, p.Parameters[0].ConditionType)
+}
+
+func (p *Order) ProductCode() (types.ProductCode) {
+	return p.Parameters[0].ProductCode
+}
+
+// Response returns the order as an order of getparentorders, whose sizes are those of the first parameter.
+func (p *Order) Response() (*private.GetParentOrdersOrder) {
+	first := p.Parameters[0]
+	order := &private.GetParentOrdersOrder{
+		Id:                      p.Id,
+		ParentOrderId:           p.ParentOrderId,
+		ProductCode:             first.ProductCode,
+		Side:                    first.Side,
+		ParentOrderType:         p.ParentOrderType(),
+		Price:                   types.NewDecimalFromFloat(first.Price),
+		Size:                    types.NewDecimalFromFloat(first.Size),
+		ParentOrderState:        p.State,
+		ExpireDate:              types.NewTime(p.ExpireDate),
+		ParentOrderDate:         types.NewTime(p.ParentOrderDate),
+		ParentOrderAcceptanceId: p.ParentOrderAcceptanceId,
+		OutstandingSize:         types.NewDecimalFromFloat(first.Size),
+	}
+	for _, c := range p.children {
+		childOrder := c.order.Response()
+		order.TotalCommission = order.TotalCommission.Add(childOrder.TotalCommission)
+		if c.parameterIndex != 0 {
+			continue
+		}
+		order.AveragePrice = childOrder.AveragePrice
+		order.ExecutedSize = childOrder.ExecutedSize
+		order.CancelSize = childOrder.CancelSize
+		order.OutstandingSize = childOrder.OutstandingSize
+	}
+	return order
+}
+
+// Detail returns the order as a response of getparentorder.
+func (p *Order) Detail() (*private.GetParentOrderResponse) {
+	parameters := make([]*private.GetParentOrderParameter, 0, len(p.Parameters))
+	for _, parameter := range p.Parameters {
+		parameters = append(parameters, &private.GetParentOrderParameter{
+			ProductCode:   parameter.ProductCode,
+			ConditionType: parameter.ConditionType,
+			Side:          parameter.Side,
+			Price:         types.NewDecimalFromFloat(parameter.Price),
+			Size:          types.NewDecimalFromFloat(parameter.Size),
+			TriggerPrice:  types.NewDecimalFromFloat(parameter.TriggerPrice),
+			Offset:        types.NewDecimalFromFloat(parameter.Offset),
+		})
+	}
+	return &private.GetParentOrderResponse{
+		Id:             p.Id,
+		ParentOrderId:  p.ParentOrderId,
+		OrderMethod:    p.OrderMethod,
+		MinuteToExpire: p.MinuteToExpire,
+		TimeInForce:    p.TimeInForce,
+		Parameters:     parameters,
+	}
+}
+
+// Stages returns indexes of parameters which are placed together at each stage, or nil for an unknown order method.
+func Stages(orderMethod types.OrderMethod) ([][]int) {
+	switch orderMethod {
+	case types.OrderMethodNone, types.OrderMethodSimple:
+		return [][]int{ {0} }
+	case types.OrderMethodIFD:
+		return [][]int{ {0}, {1} }
+	case types.OrderMethodOCO:
+		return [][]int{ {0, 1} }
+	case types.OrderMethodIFDOCO:
+		return [][]int{ {0}, {1, 2} }
+	default:
+		return nil
+	}
+}
+
+// NewOrder creates an active order sent at now. It is run by Engine after Add and Accept.
+func NewOrder(id int64, parentOrderId string, parentOrderAcceptanceId string, orderMethod types.OrderMethod, minuteToExpire int64,
+	timeInForce types.TimeInForce, parameters []*private.SendParentOrderParameter, now time.Time) (*Order) {
+	if minuteToExpire <= 0 {
+		minuteToExpire = defaultMinuteToExpire
+	}
+	if timeInForce == types.TimeInForceNone {
+		timeInForce = types.TimeInForceGTC
+	}
+	return &Order{
+		Id:                      id,
+		ParentOrderId:           parentOrderId,
+		ParentOrderAcceptanceId: parentOrderAcceptanceId,
+		OrderMethod:             orderMethod,
+		MinuteToExpire:          minuteToExpire,
+		TimeInForce:             timeInForce,
+		Parameters:              parameters,
+		State:                   types.OrderStateActive,
+		ParentOrderDate:         now,
+		ExpireDate:              now.Add(time.Duration(minuteToExpire) * time.Minute),
+		stages:                  Stages(orderMethod),
+		children:                make([]*child, 0),
+		waiting:                 make(map[int]float64),
+	}
+}
+
+func round(v float64) (float64) {
+	return math.Round(v * 1e8) / 1e8
+}
@@ -87,16 +87,14 @@ func main() {
         log.Printf("Volume %v Volume By Product %v", getTickerResponse.Volume, getTickerResponse.VolumeByProduct)
 
 	// realtime api
-	wsClient1 := client.NewWSClient(0, 0, 3, 1, nil)
-        realApiClient1 := api.NewRealAPIClient(wsClient1)
-        err = realApiClient1.RealTickerStart("BTC_JPY", realtimeTickerCallback, nil)
+	wsClient := client.NewWSClient(0, 0, 3, 1, nil)
+        realApiClient := api.NewRealAPIClient(wsClient)
+        err = realApiClient.RealTickerStart("BTC_JPY", realtimeTickerCallback, nil)
         if err != nil {
                 log.Printf("error: %v", err)
 		os.Exit(1)
         }
-	wsClient2 := client.NewWSClient(0, 0, 3, 1, nil)
-        realApiClient2 := api.NewRealAPIClient(wsClient2)
-        err = realApiClient2.RealBoardStart("BTC_JPY", realtimeBoardCallback, nil, true)
+        err = realApiClient.RealBoardStart("BTC_JPY", realtimeBoardCallback, nil, true)
         if err != nil {
                 log.Printf("error: %v", err)
 		os.Exit(1)
@@ -104,15 +102,9 @@ func main() {
 
         time.Sleep(20 * time.Second)
 
-        err = realApiClient1.RealStop()
-        if err != nil {
-                log.Printf("error: %v", err)
-		os.Exit(1)
-        }
-        err = realApiClient2.RealStop()
+        err = realApiClient.RealStop()
         if err != nil {
                 log.Printf("error: %v", err)
 		os.Exit(1)
         }
 }
-